@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+// uiState mirrors the state returned by the deskpadd /api/ui endpoints.
+type uiState struct {
+	CurrentScreen struct {
+		Name string `json:"name"`
+	} `json:"currentScreen"`
+	Grid struct {
+		Rows    int `json:"rows"`
+		Columns int `json:"columns"`
+	} `json:"grid"`
+	// Keys contains the URL each key's image is served from.
+	Keys []*string `json:"keys"`
+}
+
+// remoteDeck forwards key presses to a deskpadd instance over the HTTP API.
+type remoteDeck struct {
+	addr      string
+	authToken string
+	client    *http.Client
+
+	// images holds the images referenced by the last state, keyed by URL. Image URLs are content addressed
+	// so a cached image never needs to be fetched again.
+	images map[string]image.Image
+}
+
+func (rd *remoteDeck) PressKey(ctx context.Context, keyID int, t deskpad.KeyPressType) error {
+	pressType := "short"
+	if t == deskpad.KeyPressLong {
+		pressType = "long"
+	}
+
+	body := strings.NewReader(fmt.Sprintf(`{"type":%q}`, pressType))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/ui/keys/%d/press", rd.addr, keyID), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+rd.authToken)
+
+	resp, err := rd.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("press failed with status %s", resp.Status)
+	}
+	return nil
+}
+
+// Subscribe streams the deck state from the event stream into the surface until the context is cancelled.
+func (rd *remoteDeck) Subscribe(ctx context.Context, surface deskpad.Surface) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rd.addr+"/api/ui/events", nil)
+	if err != nil {
+		return err
+	}
+	if rd.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+rd.authToken)
+	}
+
+	resp, err := rd.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream failed with status %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var state uiState
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &state); err != nil {
+			log.Printf("unable to decode ui state: %s\n", err.Error())
+			continue
+		}
+
+		if err := surface.Refresh(rd.stateToSnapshot(ctx, state)); err != nil {
+			log.Printf("unable to render ui state: %s\n", err.Error())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("event stream closed")
+}
+
+func (rd *remoteDeck) stateToSnapshot(ctx context.Context, state uiState) deskpad.Snapshot {
+	snapshot := deskpad.Snapshot{
+		ScreenName: state.CurrentScreen.Name,
+		Rows:       state.Grid.Rows,
+		Columns:    state.Grid.Columns,
+		Keys:       make([]image.Image, len(state.Keys)),
+	}
+
+	images := make(map[string]image.Image, len(state.Keys))
+	for i, key := range state.Keys {
+		if key == nil {
+			continue
+		}
+
+		img, ok := rd.images[*key]
+		if !ok {
+			var err error
+			img, err = rd.fetchImage(ctx, *key)
+			if err != nil {
+				log.Printf("unable to fetch key %d image: %s\n", i, err.Error())
+				continue
+			}
+		}
+		images[*key] = img
+		snapshot.Keys[i] = img
+	}
+	rd.images = images
+
+	return snapshot
+}
+
+func (rd *remoteDeck) fetchImage(ctx context.Context, path string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rd.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rd.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+rd.authToken)
+	}
+
+	resp, err := rd.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image fetch failed with status %s", resp.Status)
+	}
+	return png.Decode(resp.Body)
+}
+
+func parseTerminalMode(mode string) (deskpad.TerminalMode, error) {
+	switch mode {
+	case "auto":
+		return deskpad.DetectTerminalMode(), nil
+	case "blocks":
+		return deskpad.TerminalModeBlocks, nil
+	case "kitty":
+		return deskpad.TerminalModeKitty, nil
+	case "sixel":
+		return deskpad.TerminalModeSixel, nil
+	default:
+		return deskpad.TerminalModeBlocks, fmt.Errorf("unknown terminal mode %q", mode)
+	}
+}
+
+func main() {
+	addr := flag.String("addr", "http://127.0.0.1:1337", "address of the deskpadd HTTP API")
+	authToken := flag.String("token", os.Getenv("DESKPAD_AUTH_TOKEN"), "bearer token used to read the deck and press keys")
+	modeFlag := flag.String("mode", "auto", "terminal graphics mode: auto, blocks, kitty or sixel")
+	flag.Parse()
+
+	mode, err := parseTerminalMode(*modeFlag)
+	if err != nil {
+		log.Fatalf("invalid mode: %s\n", err.Error())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	rd := &remoteDeck{
+		addr:      strings.TrimSuffix(*addr, "/"),
+		authToken: *authToken,
+		client:    &http.Client{},
+	}
+	surface := deskpad.NewTerminalSurface(os.Stdout, os.Stdin, mode)
+
+	go func() {
+		for {
+			if err := rd.Subscribe(ctx, surface); err != nil && ctx.Err() == nil {
+				log.Printf("event stream error: %s; reconnecting\n", err.Error())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}()
+
+	surface.Run(ctx, rd)
+	surface.Clear()
+}
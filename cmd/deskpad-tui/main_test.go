@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStateToSnapshotFetchesImagesWithToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Key images need a read token when deskpadd has web.auth-reads set.
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		img.Set(0, 0, color.RGBA{R: 255, A: 255})
+		png.Encode(w, img)
+	}))
+	t.Cleanup(server.Close)
+
+	key := "/api/ui/keys/0123456789abcdef.png"
+	var state uiState
+	state.Keys = []*string{&key, nil}
+
+	rd := &remoteDeck{addr: server.URL, client: server.Client()}
+	if snapshot := rd.stateToSnapshot(context.Background(), state); snapshot.Keys[0] != nil {
+		t.Fatalf("key 0 fetched without a token")
+	}
+
+	rd.authToken = "secret"
+	snapshot := rd.stateToSnapshot(context.Background(), state)
+	if snapshot.Keys[0] == nil || snapshot.Keys[1] != nil {
+		t.Fatalf("keys = %v, want only key 0 fetched", snapshot.Keys)
+	}
+	if _, ok := rd.images[key]; !ok {
+		t.Fatalf("fetched image was not cached")
+	}
+}
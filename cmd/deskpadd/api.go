@@ -1,26 +1,33 @@
 package main
 
 import (
-	"bytes"
+	"crypto/subtle"
 	"embed"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/png"
 	"io/fs"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/metrics"
 	"github.com/rmrobinson/deskpad/ui"
 	"github.com/rmrobinson/deskpad/ui/controllers"
+	"github.com/rmrobinson/deskpad/ui/screens"
 )
 
-//go:embed web/index.html web/manifest.webmanifest web/service-worker.js web/icons/*.png
+var (
+	apiLogger   = logging.Logger("api")
+	auditLogger = logging.Logger("audit")
+)
+
+//go:embed web/index.html web/playlists.html web/manifest.webmanifest web/service-worker.js web/icons/*.png
 var webFiles embed.FS
 
 type MediaItem struct {
@@ -59,6 +66,12 @@ type StatusResponse struct {
 			Name string `json:"name"`
 		} `json:"currentScreen"`
 		StreamdeckID string `json:"streamDeckId"`
+		RenderCache  struct {
+			Entries   int    `json:"entries"`
+			Hits      uint64 `json:"hits"`
+			Misses    uint64 `json:"misses"`
+			Evictions uint64 `json:"evictions"`
+		} `json:"renderCache"`
 	} `json:"ui"`
 	// TODO: add playlists
 }
@@ -71,13 +84,28 @@ type UIStateResponse struct {
 		Rows    int `json:"rows"`
 		Columns int `json:"columns"`
 	} `json:"grid"`
+	// Keys contains the URL of each key's image, served by UIKeyImage; keys without an image are null.
 	Keys []*string `json:"keys"`
+	// Strip contains the touch strip segment for each dial; it is empty if there are no dials.
+	Strip []*string `json:"strip"`
 }
 
 type MediaPlayerController interface {
-	IsPlaying() bool
+	screens.MediaPlayerController
+
 	CurrentlyPlaying() *ui.MediaItem
 	ID() string
+
+	Seek(position time.Duration) error
+	SetVolume(volume int) error
+	Repeat(mode ui.RepeatMode) error
+	PlaybackState() ui.PlaybackState
+}
+
+// ScreenCatalog lists the screens which can be navigated to. It is satisfied by *screens.Home.
+type ScreenCatalog interface {
+	Screens() []deskpad.Screen
+	OnHome(s deskpad.Screen) bool
 }
 
 type API struct {
@@ -87,7 +115,20 @@ type API struct {
 
 	d         *deskpad.Deck
 	web       *deskpad.WebSurface
+	catalog   ScreenCatalog
+	wc        WeatherController
 	authToken string
+	tokens    *TokenFile
+	authReads bool
+	pairer    *Pairer
+	ca        *LocalCA
+	health    *Health
+	webhooks  *Webhooks
+
+	// drain is closed when the server shuts down, ending the event streams.
+	drain     chan struct{}
+	drainInit sync.Once
+	drainOnce sync.Once
 }
 
 func (a *API) Status(w http.ResponseWriter, r *http.Request) {
@@ -98,11 +139,21 @@ func (a *API) Status(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
+		if !a.authorizeRead(w, r) {
+			return
+		}
+
 		resp := &StatusResponse{}
 
 		resp.UI.CurrentScreen.Name = a.d.Screen().Name()
 		resp.UI.StreamdeckID = a.d.ID()
 
+		renderStats := a.d.RenderStats()
+		resp.UI.RenderCache.Entries = renderStats.Entries
+		resp.UI.RenderCache.Hits = renderStats.Hits
+		resp.UI.RenderCache.Misses = renderStats.Misses
+		resp.UI.RenderCache.Evictions = renderStats.Evictions
+
 		if a.mpc != nil {
 			isPlaying := a.mpc.IsPlaying()
 			if isPlaying {
@@ -117,36 +168,15 @@ func (a *API) Status(w http.ResponseWriter, r *http.Request) {
 			outputs := a.mpsc.GetAudioOutputs()
 
 			for _, output := range outputs {
-				resp.Audio.Outputs = append(resp.Audio.Outputs, AudioOutput{
-					ID:          output.ID,
-					Name:        output.Name,
-					Description: output.Description,
-					Muted:       output.Muted,
-					Active:      output.Active,
-				})
+				resp.Audio.Outputs = append(resp.Audio.Outputs, audioOutputFromUI(output))
 			}
 		}
 
 		if a.mpc != nil {
-			currentlyPlaying := a.mpc.CurrentlyPlaying()
-			if currentlyPlaying != nil {
-				resp.MediaPlayer.CurrentlyPlaying = &MediaItem{
-					ID:          currentlyPlaying.ID,
-					Title:       currentlyPlaying.Title,
-					Artists:     currentlyPlaying.Artists,
-					AlbumName:   currentlyPlaying.AlbumName,
-					AlbumArtURL: currentlyPlaying.AlburmArtURL,
-				}
-			}
+			resp.MediaPlayer.CurrentlyPlaying = mediaItemFromUI(a.mpc.CurrentlyPlaying())
 		}
 		if a.mplc != nil {
-			currentPlaylist := a.mplc.CurrentlyPlaylist()
-			if currentPlaylist != nil {
-				resp.MediaPlayer.CurrentPlaylist = &MediaPlaylist{
-					ID:   currentPlaylist.ID,
-					Name: currentPlaylist.Name,
-				}
-			}
+			resp.MediaPlayer.CurrentPlaylist = mediaPlaylistFromUI(a.mplc.CurrentlyPlaylist())
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -189,6 +219,16 @@ func (a *API) WebAsset(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/manifest+json")
 		serveEmbeddedFile(w, "web/manifest.webmanifest")
 
+	case r.URL.Path == "/playlists":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		serveEmbeddedFile(w, "web/playlists.html")
+
 	case r.URL.Path == "/service-worker.js":
 		if r.Method != http.MethodGet {
 			w.Header().Set("Allow", "GET")
@@ -228,6 +268,9 @@ func (a *API) UIState(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
 
 	writeJSON(w, snapshotToUIState(a.web.Snapshot()))
 }
@@ -242,6 +285,9 @@ func (a *API) UIEvents(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -254,9 +300,15 @@ func (a *API) UIEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
+	disableWriteTimeout(w)
+
 	events, cancel := a.web.Subscribe()
 	defer cancel()
 
+	subscribers := metrics.EventSubscribers.WithLabelValues("ui")
+	subscribers.Inc()
+	defer subscribers.Dec()
+
 	heartbeat := time.NewTicker(30 * time.Second)
 	defer heartbeat.Stop()
 
@@ -264,6 +316,8 @@ func (a *API) UIEvents(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-r.Context().Done():
 			return
+		case <-a.drained():
+			return
 		case <-heartbeat.C:
 			fmt.Fprint(w, ": heartbeat\n\n")
 			flusher.Flush()
@@ -274,7 +328,7 @@ func (a *API) UIEvents(w http.ResponseWriter, r *http.Request) {
 
 			data, err := json.Marshal(snapshotToUIState(snapshot))
 			if err != nil {
-				log.Printf("unable to marshal ui state event: %s\n", err.Error())
+				apiLogger.Error("unable to marshal ui state event", "err", err)
 				continue
 			}
 
@@ -284,6 +338,108 @@ func (a *API) UIEvents(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// UIKeys handles requests under /api/ui/keys/, which are either key images or key presses.
+func (a *API) UIKeys(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, ".png") {
+		a.UIKeyImage(w, r)
+		return
+	}
+
+	a.UIPressKey(w, r)
+}
+
+// UIKeyImage handles GET /api/ui/keys/{hash}.png. Images are addressed by their content hash so they
+// never change, allowing clients to cache them indefinitely. When reads require a token, browsers pass it in
+// the access_token query parameter as <img> can't set headers.
+func (a *API) UIKeyImage(w http.ResponseWriter, r *http.Request) {
+	hash, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/ui/keys/"), ".png")
+	if !strings.HasPrefix(r.URL.Path, "/api/ui/keys/") || !ok || hash == "" || strings.Contains(hash, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
+
+	data, ok := a.keyImage(hash)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Shared caches mustn't hand images which needed a token to other clients.
+	cacheControl := "public, max-age=31536000, immutable"
+	if a.authReads {
+		cacheControl = "private, max-age=31536000, immutable"
+	}
+	writePNG(w, r, hash, data, cacheControl)
+}
+
+// writePNG writes an encoded image using its content hash as the ETag, answering conditional requests
+// for an unchanged image without a body.
+func writePNG(w http.ResponseWriter, r *http.Request, hash string, data []byte, cacheControl string) {
+	etag := `"` + hash + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(data)
+}
+
+// keyImage returns the encoded image with the given hash. Images which have been evicted from the cache
+// are re-encoded if they are still visible.
+func (a *API) keyImage(hash string) ([]byte, bool) {
+	if data, ok := keyImageCache.Get(hash); ok {
+		return data, true
+	}
+
+	snapshot := a.web.Snapshot()
+	for _, img := range append(snapshot.Keys, snapshot.Strip...) {
+		if img == nil || deskpad.ImageHash(img) != hash {
+			continue
+		}
+
+		_, data, err := keyImageCache.Encode(img)
+		if err != nil {
+			apiLogger.Error("unable to encode key image", "hash", hash, "err", err)
+			return nil, false
+		}
+		return data, true
+	}
+
+	// Screen icons are listed by /api/screens without necessarily being on the deck.
+	if a.catalog != nil {
+		for _, screen := range a.catalog.Screens() {
+			icon := screen.Icon()
+			if icon == nil || deskpad.ImageHash(icon) != hash {
+				continue
+			}
+
+			_, data, err := keyImageCache.Encode(icon)
+			if err != nil {
+				apiLogger.Error("unable to encode screen icon", "screen", screen.Name(), "err", err)
+				return nil, false
+			}
+			return data, true
+		}
+	}
+
+	return nil, false
+}
+
 func (a *API) UIPressKey(w http.ResponseWriter, r *http.Request) {
 	if !strings.HasPrefix(r.URL.Path, "/api/ui/keys/") || !strings.HasSuffix(r.URL.Path, "/press") {
 		http.NotFound(w, r)
@@ -295,14 +451,7 @@ func (a *API) UIPressKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !a.authorized(r) {
-		if a.authToken == "" {
-			log.Printf("web writes disabled: web.auth-token is empty\n")
-			http.Error(w, "web writes disabled", http.StatusForbidden)
-			return
-		}
-
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	if !a.authorize(w, r, ScopeKeys) {
 		return
 	}
 
@@ -332,23 +481,186 @@ func (a *API) UIPressKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("web key press received: key=%d type=%s screen=%q\n", keyID, req.Type, a.d.Screen().Name())
+	screen := a.d.Screen().Name()
+	apiLogger.Debug("web key press received", "screen", screen, "key", keyID, "type", req.Type)
 	if err := a.d.PressKey(r.Context(), keyID, pressType); err != nil {
-		log.Printf("web key press failed: key=%d type=%s error=%s\n", keyID, req.Type, err.Error())
+		apiLogger.Error("web key press failed", "screen", screen, "key", keyID, "type", req.Type, "err", err)
 		http.Error(w, "press failed", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("web key press handled: key=%d type=%s\n", keyID, req.Type)
+	apiLogger.Info("web key press handled", "screen", screen, "key", keyID, "type", req.Type)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UIDial handles POST /api/ui/dials/{id}/{rotate,press,touch}. Rotations take the number of detents
+// turned as {"delta": n}; presses and touches optionally take {"type": "short"|"long"}.
+func (a *API) UIDial(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/ui/dials/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/api/ui/dials/") || len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	eventType, ok := deskpad.ParseDialEventType(parts[1])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !a.authorize(w, r, ScopeKeys) {
+		return
+	}
+
+	dialID, err := strconv.Atoi(parts[0])
+	if err != nil || dialID < 0 || dialID >= a.d.DialCount() {
+		http.Error(w, "invalid dial id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Delta int    `json:"delta"`
+		Type  string `json:"type"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	event := deskpad.DialEvent{Dial: dialID, Type: eventType}
+	switch {
+	case eventType == deskpad.DialRotate:
+		if req.Delta == 0 {
+			http.Error(w, "invalid delta", http.StatusBadRequest)
+			return
+		}
+		event.Delta = req.Delta
+	case req.Type == "" || req.Type == "short":
+		event.PressType = deskpad.KeyPressShort
+	case req.Type == "long":
+		event.PressType = deskpad.KeyPressLong
+	default:
+		http.Error(w, "invalid press type", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.d.DialChanged(r.Context(), event); err != nil {
+		apiLogger.Error("web dial event failed", "dial", dialID, "event", eventType, "err", err)
+		http.Error(w, "dial failed", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (a *API) authorized(r *http.Request) bool {
-	if a.authToken == "" {
+// authorize checks the request carries a token granting the scope, writing an error response if it doesn't.
+// Requests for anything beyond reading are written to the audit log.
+func (a *API) authorize(w http.ResponseWriter, r *http.Request, scope TokenScope) bool {
+	token, ok := a.authenticate(r)
+	if ok && token.Allows(scope) {
+		a.audit(token, scope, r.Method+" "+r.URL.Path, r.RemoteAddr, true)
+		return true
+	}
+
+	if !a.authConfigured() {
+		apiLogger.Warn("web auth disabled: no api tokens are configured")
+		http.Error(w, "web auth disabled", http.StatusForbidden)
+		return false
+	}
+	if ok {
+		a.audit(token, scope, r.Method+" "+r.URL.Path, r.RemoteAddr, false)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// authorizeRead checks the request may read state. Reads are open unless web.auth-reads is set.
+func (a *API) authorizeRead(w http.ResponseWriter, r *http.Request) bool {
+	if !a.authReads {
+		return true
+	}
+	return a.authorize(w, r, ScopeRead)
+}
+
+// authenticate returns the token supplied with the request. Reads may also pass the token in the access_token
+// query parameter, for browser APIs such as EventSource which can't set headers.
+func (a *API) authenticate(r *http.Request) (*APIToken, bool) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		secret = r.URL.Query().Get("access_token")
+	}
+	return a.lookupToken(secret)
+}
+
+// lookupToken returns the token matching the secret. The legacy web.auth-token is treated as an admin token.
+func (a *API) lookupToken(secret string) (*APIToken, bool) {
+	if secret == "" {
+		return nil, false
+	}
+
+	if a.authToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.authToken)) == 1 {
+		return &APIToken{Name: "default", Scopes: []TokenScope{ScopeAdmin}}, true
+	}
+	if a.tokens != nil {
+		return a.tokens.Lookup(secret)
+	}
+	return nil, false
+}
+
+// refreshToken returns the current copy of a token looked up earlier, or false if it has since been revoked.
+func (a *API) refreshToken(token *APIToken) (*APIToken, bool) {
+	// Only the legacy web.auth-token has no hash, and it can't change while running.
+	if token.Hash == "" {
+		return token, a.authToken != ""
+	}
+	if a.tokens == nil {
+		return nil, false
+	}
+
+	tokens, err := a.tokens.Tokens()
+	if err != nil {
+		return nil, false
+	}
+	for _, stored := range tokens {
+		if stored.Name == token.Name && stored.Hash == token.Hash {
+			return &stored, true
+		}
+	}
+	return nil, false
+}
+
+func (a *API) authConfigured() bool {
+	if a.authToken != "" {
+		return true
+	}
+	if a.tokens == nil {
 		return false
 	}
 
-	return r.Header.Get("Authorization") == "Bearer "+a.authToken
+	tokens, err := a.tokens.Tokens()
+	return err == nil && len(tokens) > 0
+}
+
+// audit logs the use of a token. Reads aren't logged as the UI polls them constantly.
+func (a *API) audit(token *APIToken, scope TokenScope, action string, remoteAddr string, allowed bool) {
+	if scope == ScopeRead {
+		return
+	}
+
+	result := "allowed"
+	if !allowed {
+		result = "denied"
+	}
+	auditLogger.Info("token used", "token", token.Name, "scope", scope, "action", action, "remote", remoteAddr, "result", result)
 }
 
 func snapshotToUIState(snapshot deskpad.Snapshot) UIStateResponse {
@@ -363,24 +675,42 @@ func snapshotToUIState(snapshot deskpad.Snapshot) UIStateResponse {
 			continue
 		}
 
-		dataURL, err := imageDataURL(key)
+		url, err := imageURL(key)
 		if err != nil {
-			log.Printf("unable to encode key %d image: %s\n", i, err.Error())
+			apiLogger.Error("unable to encode key image", "key", i, "err", err)
 			continue
 		}
-		resp.Keys[i] = &dataURL
+		resp.Keys[i] = &url
+	}
+
+	resp.Strip = make([]*string, len(snapshot.Strip))
+	for i, segment := range snapshot.Strip {
+		if segment == nil {
+			continue
+		}
+
+		url, err := imageURL(segment)
+		if err != nil {
+			apiLogger.Error("unable to encode strip segment image", "dial", i, "err", err)
+			continue
+		}
+		resp.Strip[i] = &url
 	}
 
 	return resp
 }
 
-func imageDataURL(img image.Image) (string, error) {
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, img); err != nil {
+// keyImageCache holds the PNG encoding of recently rendered keys, so each distinct icon is only encoded once.
+var keyImageCache = deskpad.NewEncodedImageCache(png.Encode, 1024)
+
+// imageURL encodes the image into the key image cache and returns the URL it is served from.
+func imageURL(img image.Image) (string, error) {
+	hash, _, err := keyImageCache.Encode(img)
+	if err != nil {
 		return "", err
 	}
 
-	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	return "/api/ui/keys/" + hash + ".png", nil
 }
 
 func writeJSON(w http.ResponseWriter, v any) {
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"image"
 	"image/color"
+	"image/png"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -43,6 +44,24 @@ func (p apiTestMediaPlayer) ID() string {
 	return p.id
 }
 
+func (p apiTestMediaPlayer) Play()                           {}
+func (p apiTestMediaPlayer) Pause()                          {}
+func (p apiTestMediaPlayer) Next()                           {}
+func (p apiTestMediaPlayer) Previous()                       {}
+func (p apiTestMediaPlayer) FastForward()                    {}
+func (p apiTestMediaPlayer) Rewind()                         {}
+func (p apiTestMediaPlayer) VolumeUp()                       {}
+func (p apiTestMediaPlayer) VolumeDown()                     {}
+func (p apiTestMediaPlayer) Mute()                           {}
+func (p apiTestMediaPlayer) Unmute()                         {}
+func (p apiTestMediaPlayer) Shuffle(bool)                    {}
+func (p apiTestMediaPlayer) IsShuffle() bool                 { return false }
+func (p apiTestMediaPlayer) IsMuted() bool                   { return false }
+func (p apiTestMediaPlayer) Seek(time.Duration) error        { return nil }
+func (p apiTestMediaPlayer) SetVolume(int) error             { return nil }
+func (p apiTestMediaPlayer) Repeat(ui.RepeatMode) error      { return nil }
+func (p apiTestMediaPlayer) PlaybackState() ui.PlaybackState { return ui.PlaybackState{} }
+
 func (s *apiTestScreen) Name() string {
 	return s.name
 }
@@ -91,14 +110,76 @@ func TestUIStateReturnsCurrentScreenGridAndKeys(t *testing.T) {
 	if len(resp.Keys) != deck.KeyCount() {
 		t.Fatalf("keys length = %d, want %d", len(resp.Keys), deck.KeyCount())
 	}
-	if resp.Keys[0] == nil || !strings.HasPrefix(*resp.Keys[0], "data:image/png;base64,") {
-		t.Fatalf("key 0 did not contain a png data url")
+	if resp.Keys[0] == nil || *resp.Keys[0] != "/api/ui/keys/"+deskpad.ImageHash(apiTestImage())+".png" {
+		t.Fatalf("key 0 did not reference the image by hash")
 	}
 	if resp.Keys[1] != nil {
 		t.Fatalf("key 1 = %v, want nil", *resp.Keys[1])
 	}
 }
 
+func TestUIKeyImageServesImagesByHash(t *testing.T) {
+	screen := &apiTestScreen{name: "home", showKeys: []image.Image{apiTestImage()}}
+	deck := deskpad.NewDeck(screen)
+	web := deskpad.NewWebSurface()
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+	api := &API{d: deck, web: web}
+
+	state := snapshotToUIState(web.Snapshot())
+	rec := httptest.NewRecorder()
+	api.UIKeys(rec, httptest.NewRequest(http.MethodGet, *state.Keys[0], nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("status = %d, content type %q; want a png", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("cache control = %q, want immutable", rec.Header().Get("Cache-Control"))
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil || deskpad.ImageHash(img) != deskpad.ImageHash(apiTestImage()) {
+		t.Fatalf("served image does not match key 0: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, *state.Keys[0], nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	api.UIKeys(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("conditional request status = %d with %d bytes, want 304", rec.Code, rec.Body.Len())
+	}
+
+	rec = httptest.NewRecorder()
+	api.UIKeys(rec, httptest.NewRequest(http.MethodGet, "/api/ui/keys/0123456789abcdef.png", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown image status = %d, want 404", rec.Code)
+	}
+}
+
+func TestUIKeyImageRequiresTokenWhenReadsAreAuthenticated(t *testing.T) {
+	screen := &apiTestScreen{name: "home", showKeys: []image.Image{apiTestImage()}}
+	deck := deskpad.NewDeck(screen)
+	web := deskpad.NewWebSurface()
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+	api := &API{d: deck, web: web, authToken: "secret", authReads: true}
+
+	path := *snapshotToUIState(web.Snapshot()).Keys[0]
+	rec := httptest.NewRecorder()
+	api.UIKeys(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous image status = %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	api.UIKeys(rec, httptest.NewRequest(http.MethodGet, path+"?access_token=secret", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("image with access_token status = %d, want 200", rec.Code)
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "private") {
+		t.Fatalf("cache control = %q, want private", cc)
+	}
+}
+
 func TestStatusReturnsMediaPlayerDetails(t *testing.T) {
 	screen := &apiTestScreen{name: "home"}
 	deck := deskpad.NewDeck(screen)
@@ -155,6 +236,27 @@ func TestStatusReturnsMediaPlayerDetails(t *testing.T) {
 	}
 }
 
+func TestStatusReturnsRenderCacheStats(t *testing.T) {
+	screen := &apiTestScreen{name: "home", showKeys: []image.Image{apiTestImage()}}
+	deck := deskpad.NewDeck(screen)
+	deck.RegisterSurface(deskpad.NewFileSurface(t.TempDir(), false))
+	deck.RefreshScreen()
+	deck.RefreshScreen()
+	api := &API{d: deck}
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	rec := httptest.NewRecorder()
+	api.Status(rec, req)
+
+	var resp StatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal status response: %s", err)
+	}
+	if cache := resp.UI.RenderCache; cache.Entries != 1 || cache.Misses != 1 || cache.Hits != 1 {
+		t.Fatalf("render cache = %+v, want one entry rendered once and reused once", cache)
+	}
+}
+
 func TestWebAssetServesPWAAssets(t *testing.T) {
 	api := &API{}
 
@@ -223,8 +325,8 @@ func TestUIEventsReceivesInitialSnapshotAndUpdate(t *testing.T) {
 	}
 
 	update := readSSEData(t, scanner)
-	if !strings.Contains(update, "data:image/png;base64,") {
-		t.Fatalf("update event = %q, want encoded image", update)
+	if !strings.Contains(update, "/api/ui/keys/"+deskpad.ImageHash(updated)+".png") {
+		t.Fatalf("update event = %q, want updated image url", update)
 	}
 }
 
@@ -290,6 +392,59 @@ func TestUIPressKeyAcceptsValidTokenAndPressesDeck(t *testing.T) {
 	}
 }
 
+type apiTestDialScreen struct {
+	apiTestScreen
+	event *deskpad.DialEvent
+}
+
+func (s *apiTestDialScreen) ShowStrip(dialCount int) []image.Image {
+	return []image.Image{apiTestImage()}
+}
+
+func (s *apiTestDialScreen) DialChanged(ctx context.Context, event deskpad.DialEvent) (deskpad.KeyPressAction, error) {
+	s.event = &event
+	return deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop}, nil
+}
+
+func TestUIDialRotatesVirtualDial(t *testing.T) {
+	screen := &apiTestDialScreen{apiTestScreen: apiTestScreen{name: "media"}}
+	deck := deskpad.NewDeck(screen)
+	web := deskpad.NewWebSurface()
+	web.SetVirtualDials(2)
+	deck.RegisterSurface(web)
+	api := &API{d: deck, web: web, authToken: "secret"}
+
+	state := snapshotToUIState(web.Snapshot())
+	if len(state.Strip) != 2 || state.Strip[0] == nil || state.Strip[1] != nil {
+		t.Fatalf("state strip = %v, want one populated segment of two", state.Strip)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ui/dials/1/rotate", bytes.NewBufferString(`{"delta":-3}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.UIDial(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204: %s", rec.Code, rec.Body.String())
+	}
+	if screen.event == nil || screen.event.Dial != 1 || screen.event.Type != deskpad.DialRotate || screen.event.Delta != -3 {
+		t.Fatalf("dial event = %+v, want rotation of dial 1 by -3", screen.event)
+	}
+
+	for path, want := range map[string]int{
+		"/api/ui/dials/2/rotate": http.StatusBadRequest,
+		"/api/ui/dials/0/spin":   http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"delta":1}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		api.UIDial(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
 func readSSEData(t *testing.T, scanner *bufio.Scanner) string {
 	t.Helper()
 
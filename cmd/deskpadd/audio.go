@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+// AudioOutputsResponse lists the available audio outputs.
+type AudioOutputsResponse struct {
+	Outputs []AudioOutput `json:"outputs"`
+}
+
+// AudioOutputs handles the audio output endpoints:
+//
+//	GET  /api/audio/outputs
+//	POST /api/audio/outputs/refresh
+//	POST /api/audio/outputs/{id}/select
+//	POST /api/audio/outputs/{id}/volume  {"volume": 0-100}
+//	POST /api/audio/outputs/{id}/mute    {"muted": true|false}
+//
+// The refresh and write endpoints respond with the updated list of outputs.
+func (a *API) AudioOutputs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/audio/outputs" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !a.authorizeRead(w, r) || !a.requireAudioOutputs(w) {
+			return
+		}
+
+		a.writeAudioOutputs(w)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/audio/outputs/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) > 2 || (len(parts) == 1 && parts[0] != "refresh") {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 2 && parts[1] != "select" && parts[1] != "volume" && parts[1] != "mute" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !a.authorize(w, r, ScopeMedia) || !a.requireAudioOutputs(w) {
+		return
+	}
+
+	if len(parts) == 1 {
+		if err := a.mpsc.RefreshAudioOutputs(r.Context()); err != nil {
+			http.Error(w, "unable to refresh audio outputs", http.StatusBadGateway)
+			return
+		}
+		a.d.RefreshScreen()
+		a.writeAudioOutputs(w)
+		return
+	}
+
+	id, err := url.PathUnescape(parts[0])
+	if err != nil || id == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req struct {
+		Volume *int  `json:"volume"`
+		Muted  *bool `json:"muted"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch parts[1] {
+	case "select":
+		if !a.hasAudioOutput(id) {
+			http.NotFound(w, r)
+			return
+		}
+		err = a.mpsc.SelectAudioOutput(r.Context(), id)
+	case "volume":
+		if req.Volume == nil || *req.Volume < 0 || *req.Volume > 100 {
+			http.Error(w, "invalid volume", http.StatusBadRequest)
+			return
+		}
+		err = a.mpsc.SetAudioOutputVolume(r.Context(), id, *req.Volume)
+	case "mute":
+		if req.Muted == nil {
+			http.Error(w, "invalid muted state", http.StatusBadRequest)
+			return
+		}
+		err = a.mpsc.SetAudioOutputMute(r.Context(), id, *req.Muted)
+	}
+
+	switch {
+	case errors.Is(err, controllers.ErrAudioOutputNotFound):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		apiLogger.Error("audio output update failed", "output", id, "action", parts[1], "err", err)
+		http.Error(w, "audio output update failed", http.StatusBadGateway)
+		return
+	}
+
+	if err := a.mpsc.RefreshAudioOutputs(r.Context()); err != nil {
+		apiLogger.Error("unable to refresh audio outputs", "err", err)
+	}
+	a.d.RefreshScreen()
+	a.writeAudioOutputs(w)
+}
+
+func (a *API) requireAudioOutputs(w http.ResponseWriter) bool {
+	if a.mpsc == nil {
+		http.Error(w, "audio outputs unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func (a *API) hasAudioOutput(id string) bool {
+	for _, output := range a.mpsc.GetAudioOutputs() {
+		if output.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *API) writeAudioOutputs(w http.ResponseWriter) {
+	resp := AudioOutputsResponse{Outputs: []AudioOutput{}}
+	for _, output := range a.mpsc.GetAudioOutputs() {
+		resp.Outputs = append(resp.Outputs, audioOutputFromUI(output))
+	}
+	writeJSON(w, resp)
+}
+
+func audioOutputFromUI(output ui.AudioOutput) AudioOutput {
+	return AudioOutput{
+		ID:          output.ID,
+		Name:        output.Name,
+		Description: output.Description,
+		Volume:      output.Volume,
+		Muted:       output.Muted,
+		Active:      output.Active,
+	}
+}
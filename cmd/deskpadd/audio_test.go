@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/sim"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+func newAudioTestAPI(t *testing.T) (*API, *sim.PulseAudio) {
+	t.Helper()
+
+	pulse := sim.NewPulseAudio()
+	pulse.AddSink("sim-headphones", "Simulated headphones")
+	mpsc := controllers.NewMediaPlayerSetting(nil, pulse)
+	if err := mpsc.RefreshAudioOutputs(context.Background()); err != nil {
+		t.Fatalf("RefreshAudioOutputs returned error: %s", err)
+	}
+
+	return &API{d: deskpad.NewDeck(&apiTestScreen{name: "home"}), mpsc: mpsc, authToken: "secret"}, pulse
+}
+
+func doAudioRequest(t *testing.T, api *API, method string, path string, body string) (int, AudioOutputsResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.AudioOutputs(rec, req)
+
+	var resp AudioOutputsResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal response: %s", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestAudioOutputsCanBeSelected(t *testing.T) {
+	api, pulse := newAudioTestAPI(t)
+
+	code, resp := doAudioRequest(t, api, http.MethodGet, "/api/audio/outputs", "")
+	if code != http.StatusOK || len(resp.Outputs) != 2 || !resp.Outputs[0].Active || resp.Outputs[0].Volume != 50 {
+		t.Fatalf("outputs = %d %+v, want two outputs with the first active at volume 50", code, resp.Outputs)
+	}
+
+	code, resp = doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/1/select", "")
+	if code != http.StatusOK || resp.Outputs[0].Active || !resp.Outputs[1].Active {
+		t.Fatalf("outputs after select = %d %+v, want the second output active", code, resp.Outputs)
+	}
+	if pulse.DefaultSink() != "sim-headphones" {
+		t.Fatalf("default sink = %q, want sim-headphones", pulse.DefaultSink())
+	}
+
+	if code, _ := doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/9/select", ""); code != http.StatusNotFound {
+		t.Fatalf("unknown output status = %d, want 404", code)
+	}
+}
+
+func TestAudioOutputVolumeAndMute(t *testing.T) {
+	api, _ := newAudioTestAPI(t)
+
+	code, resp := doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/1/volume", `{"volume":20}`)
+	if code != http.StatusOK || resp.Outputs[1].Volume != 20 || resp.Outputs[0].Volume != 50 {
+		t.Fatalf("outputs after volume change = %d %+v, want only the second output at 20", code, resp.Outputs)
+	}
+	if code, _ := doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/1/volume", `{"volume":120}`); code != http.StatusBadRequest {
+		t.Fatalf("invalid volume status = %d, want 400", code)
+	}
+
+	code, resp = doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/0/mute", `{"muted":true}`)
+	if code != http.StatusOK || !resp.Outputs[0].Muted {
+		t.Fatalf("outputs after mute = %d %+v, want the first output muted", code, resp.Outputs)
+	}
+
+	// Outputs other than the selected one are muted by their volume, which is restored on unmute.
+	code, resp = doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/1/mute", `{"muted":true}`)
+	if code != http.StatusOK || !resp.Outputs[1].Muted || resp.Outputs[1].Volume != 0 {
+		t.Fatalf("outputs after muting an unselected output = %d %+v, want the second output muted", code, resp.Outputs)
+	}
+	code, resp = doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/1/mute", `{"muted":false}`)
+	if code != http.StatusOK || resp.Outputs[1].Muted || resp.Outputs[1].Volume != 20 {
+		t.Fatalf("outputs after unmuting = %d %+v, want the second output back at 20", code, resp.Outputs)
+	}
+	if !resp.Outputs[0].Muted {
+		t.Fatalf("outputs = %+v, want the first output still muted", resp.Outputs)
+	}
+}
+
+func TestAudioOutputRefreshRequiresToken(t *testing.T) {
+	api, _ := newAudioTestAPI(t)
+
+	rec := httptest.NewRecorder()
+	api.AudioOutputs(rec, httptest.NewRequest(http.MethodPost, "/api/audio/outputs/refresh", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+
+	if code, resp := doAudioRequest(t, api, http.MethodPost, "/api/audio/outputs/refresh", ""); code != http.StatusOK || len(resp.Outputs) != 2 {
+		t.Fatalf("refresh = %d %+v, want two outputs", code, resp.Outputs)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// healthCacheTTL limits how often the subsystems are probed, as some checks call remote services.
+	healthCacheTTL = 10 * time.Second
+	// healthCheckTimeout is how long a single subsystem check may take before it is reported as failing.
+	healthCheckTimeout = 2 * time.Second
+)
+
+// Subsystem states reported by the health endpoints.
+const (
+	HealthOK       = "ok"
+	HealthError    = "error"
+	HealthDisabled = "disabled"
+)
+
+// HealthCheck returns nil if the subsystem is working, or the reason it isn't.
+type HealthCheck func(ctx context.Context) error
+
+type healthSubsystem struct {
+	name     string
+	required bool
+	check    HealthCheck
+}
+
+// Health tracks the state of the subsystems deskpadd depends on.
+type Health struct {
+	lock       sync.Mutex
+	subsystems []healthSubsystem
+	report     map[string]SubsystemHealth
+	checked    time.Time
+}
+
+// NewHealth creates an empty set of health checks.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// Register adds a subsystem check. If the check is nil the subsystem is reported as disabled. Failing required
+// subsystems mark deskpadd as not ready; the others are only reported.
+func (h *Health) Register(name string, required bool, check HealthCheck) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.subsystems = append(h.subsystems, healthSubsystem{name: name, required: required, check: check})
+	h.report = nil
+}
+
+// Check returns the state of each subsystem, probing them if the last results are stale.
+func (h *Health) Check(ctx context.Context) map[string]SubsystemHealth {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.report != nil && time.Since(h.checked) < healthCacheTTL {
+		return h.report
+	}
+
+	report := make(map[string]SubsystemHealth, len(h.subsystems))
+	results := make([]SubsystemHealth, len(h.subsystems))
+	var wg sync.WaitGroup
+	for i, subsystem := range h.subsystems {
+		if subsystem.check == nil {
+			results[i] = SubsystemHealth{Status: HealthDisabled, Required: subsystem.required}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, subsystem)
+		}()
+	}
+	wg.Wait()
+
+	for i, subsystem := range h.subsystems {
+		report[subsystem.name] = results[i]
+	}
+	h.report = report
+	h.checked = time.Now()
+	return report
+}
+
+// runHealthCheck runs the check with a timeout. Checks which don't respect the context are abandoned when the
+// timeout passes.
+func runHealthCheck(ctx context.Context, subsystem healthSubsystem) SubsystemHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- subsystem.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = errors.New("health check timed out")
+	}
+
+	if err != nil {
+		return SubsystemHealth{Status: HealthError, Required: subsystem.required, Error: err.Error()}
+	}
+	return SubsystemHealth{Status: HealthOK, Required: subsystem.required}
+}
+
+// statusError records the outcome of the last operation on a subsystem which can't be probed directly.
+type statusError struct {
+	lock sync.Mutex
+	err  error
+}
+
+// Set records the outcome of the latest operation.
+func (s *statusError) Set(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err
+}
+
+// Check returns the outcome of the latest operation; it satisfies HealthCheck.
+func (s *statusError) Check(context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+// SubsystemHealth is the state of a single subsystem.
+type SubsystemHealth struct {
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HealthResponse is returned by the health endpoints. Status is "ok", "degraded" if an optional subsystem is
+// failing, or "unavailable" if a required subsystem is failing or the server is shutting down.
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Subsystems map[string]SubsystemHealth `json:"subsystems"`
+}
+
+// Healthz handles GET /healthz, the liveness check. It succeeds until the server starts shutting down,
+// reporting the subsystem states for information.
+func (a *API) Healthz(w http.ResponseWriter, r *http.Request) {
+	a.writeHealth(w, r, "/healthz", false)
+}
+
+// Readyz handles GET /readyz, the readiness check. It fails while a required subsystem is failing or the server
+// is shutting down.
+func (a *API) Readyz(w http.ResponseWriter, r *http.Request) {
+	a.writeHealth(w, r, "/readyz", true)
+}
+
+func (a *API) writeHealth(w http.ResponseWriter, r *http.Request, path string, readiness bool) {
+	if r.URL.Path != path {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := HealthResponse{Status: HealthOK, Subsystems: map[string]SubsystemHealth{}}
+	if a.health != nil {
+		resp.Subsystems = a.health.Check(r.Context())
+	}
+
+	for _, subsystem := range resp.Subsystems {
+		if subsystem.Status != HealthError {
+			continue
+		}
+		if subsystem.Required {
+			resp.Status = "unavailable"
+			break
+		}
+		resp.Status = "degraded"
+	}
+	if a.isDraining() {
+		resp.Status = "unavailable"
+	}
+
+	code := http.StatusOK
+	if a.isDraining() || (readiness && resp.Status == "unavailable") {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Drain ends the long-lived event streams so the server can shut down. It is safe to call more than once.
+func (a *API) Drain() {
+	drained := a.drained()
+	a.drainOnce.Do(func() {
+		close(drained)
+	})
+}
+
+// drained returns a channel which is closed once the server starts shutting down.
+func (a *API) drained() chan struct{} {
+	a.drainInit.Do(func() {
+		a.drain = make(chan struct{})
+	})
+	return a.drain
+}
+
+func (a *API) isDraining() bool {
+	select {
+	case <-a.drained():
+		return true
+	default:
+		return false
+	}
+}
+
+// disableWriteTimeout lifts the server's write timeout for a streaming response. It is a no-op for writers which
+// don't support deadlines, such as the recorders used in testing.
+func disableWriteTimeout(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+func doHealthRequest(t *testing.T, handler func(http.ResponseWriter, *http.Request), path string) (int, HealthResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal %s response: %s", path, err)
+	}
+	return rec.Code, resp
+}
+
+func TestHealthReportsSubsystems(t *testing.T) {
+	var spotifyErr error
+	health := NewHealth()
+	health.Register("deck", false, func(context.Context) error { return errors.New("no stream deck attached") })
+	health.Register("mpris", true, nil)
+	health.Register("spotify", true, func(context.Context) error { return spotifyErr })
+	api := &API{health: health}
+
+	code, resp := doHealthRequest(t, api.Readyz, "/readyz")
+	if code != http.StatusOK || resp.Status != "degraded" {
+		t.Fatalf("readyz = %d %q, want 200 degraded", code, resp.Status)
+	}
+	if resp.Subsystems["deck"].Status != HealthError || resp.Subsystems["deck"].Error != "no stream deck attached" {
+		t.Fatalf("deck = %+v, want the deck error", resp.Subsystems["deck"])
+	}
+	if resp.Subsystems["mpris"].Status != HealthDisabled || resp.Subsystems["spotify"].Status != HealthOK {
+		t.Fatalf("subsystems = %+v, want mpris disabled and spotify ok", resp.Subsystems)
+	}
+
+	// Results are cached, so the failure only shows up once they are stale.
+	spotifyErr = errors.New("token expired")
+	if _, resp := doHealthRequest(t, api.Readyz, "/readyz"); resp.Subsystems["spotify"].Status != HealthOK {
+		t.Fatalf("spotify = %+v, want the cached result", resp.Subsystems["spotify"])
+	}
+	health.checked = time.Now().Add(-healthCacheTTL)
+
+	code, resp = doHealthRequest(t, api.Readyz, "/readyz")
+	if code != http.StatusServiceUnavailable || resp.Status != "unavailable" {
+		t.Fatalf("readyz = %d %q, want 503 unavailable", code, resp.Status)
+	}
+	if code, _ := doHealthRequest(t, api.Healthz, "/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz = %d, want 200 while running", code)
+	}
+
+	api.Drain()
+	api.Drain()
+	if code, _ := doHealthRequest(t, api.Healthz, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("healthz = %d, want 503 while shutting down", code)
+	}
+}
+
+func TestHealthCheckTimesOut(t *testing.T) {
+	health := NewHealth()
+	block := make(chan struct{})
+	defer close(block)
+	health.Register("weather", false, func(context.Context) error {
+		<-block
+		return nil
+	})
+
+	report := health.Check(context.Background())
+	if report["weather"].Status != HealthError {
+		t.Fatalf("weather = %+v, want a timeout error", report["weather"])
+	}
+}
+
+func TestShutdownDrainsEventStreams(t *testing.T) {
+	deck := deskpad.NewDeck(&apiTestScreen{name: "home"})
+	web := deskpad.NewWebSurface()
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+	api := &API{d: deck, web: web}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ui/events", api.UIEvents)
+	server := httptest.NewUnstartedServer(mux)
+	server.Config = newHTTPServer("", mux)
+	server.Config.RegisterOnShutdown(api.Drain)
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/ui/events")
+	if err != nil {
+		t.Fatalf("get events: %s", err)
+	}
+	defer resp.Body.Close()
+	readSSEData(t, bufio.NewScanner(resp.Body))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error: %s", err)
+	}
+}
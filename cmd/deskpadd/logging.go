@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/spf13/viper"
+)
+
+var logger = logging.Logger("deskpadd")
+
+// loggingConfig reads the logging section of the config file:
+//
+//	logging:
+//	  format: json
+//	  level: info
+//	  components:
+//	    api: debug
+func loggingConfig(v *viper.Viper) (logging.Config, error) {
+	cfg := logging.Config{
+		Format:     v.GetString("logging.format"),
+		Components: map[string]slog.Level{},
+	}
+
+	if name := v.GetString("logging.level"); len(name) > 0 {
+		level, err := logging.ParseLevel(name)
+		if err != nil {
+			return logging.Config{}, fmt.Errorf("invalid logging.level: %w", err)
+		}
+		cfg.Level = level
+	}
+
+	for component, name := range v.GetStringMapString("logging.components") {
+		level, err := logging.ParseLevel(name)
+		if err != nil {
+			return logging.Config{}, fmt.Errorf("invalid level for component %s: %w", component, err)
+		}
+		cfg.Components[component] = level
+	}
+	return cfg, nil
+}
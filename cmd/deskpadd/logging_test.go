@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoggingConfigReadsComponentLevels(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(`
+logging:
+  format: json
+  level: warn
+  components:
+    api: debug
+`)); err != nil {
+		t.Fatalf("ReadConfig returned error: %s", err)
+	}
+
+	cfg, err := loggingConfig(v)
+	if err != nil {
+		t.Fatalf("loggingConfig returned error: %s", err)
+	}
+	if cfg.Format != "json" || cfg.Level != slog.LevelWarn || cfg.Components["api"] != slog.LevelDebug {
+		t.Fatalf("config = %+v, want json at warn with api at debug", cfg)
+	}
+
+	v.Set("logging.components", map[string]any{"deck": "chatty"})
+	if _, err := loggingConfig(v); err == nil {
+		t.Fatalf("loggingConfig accepted an invalid component level")
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,6 +17,7 @@ import (
 	"github.com/lawl/pulseaudio"
 	"github.com/muka/go-bluetooth/bluez/profile/adapter"
 	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/logging"
 	"github.com/rmrobinson/deskpad/ui"
 	"github.com/rmrobinson/deskpad/ui/controllers"
 	"github.com/rmrobinson/deskpad/ui/screens"
@@ -37,10 +40,10 @@ func configureSpotifyClient(ctx context.Context, tokenFilePath string) *spotify.
 		if err == nil {
 			token = &t
 		} else {
-			log.Printf("failed to deserialize token: %s\n", err.Error())
+			logger.Warn("failed to deserialize spotify token", "err", err)
 		}
 
-		log.Printf("*** using cached token from %s for spotify client\n", tokenFilePath)
+		logger.Info("using cached spotify token", "path", tokenFilePath)
 	}
 
 	if token == nil {
@@ -48,30 +51,30 @@ func configureSpotifyClient(ctx context.Context, tokenFilePath string) *spotify.
 		token = sth.Token(ctx)
 
 		if token == nil {
-			log.Fatalf("unable to get spotify token: %s\n", err.Error())
+			logging.Fatal(logger, "unable to get spotify token", "err", err)
 		}
 
 		tokenStr, err := json.Marshal(token)
 		if err != nil {
-			log.Fatalf("unable to marshal token to string: %s\n", err.Error())
+			logging.Fatal(logger, "unable to marshal spotify token", "err", err)
 		}
 
 		err = os.WriteFile(tokenFilePath, tokenStr, 0600)
 		if err != nil {
-			log.Fatalf("unable to save creds to json file: %s\n", err.Error())
+			logging.Fatal(logger, "unable to save spotify token", "path", tokenFilePath, "err", err)
 		}
 
-		log.Printf("*** saved spotify token to %s; using new token for spotify client\n", tokenFilePath)
+		logger.Info("saved new spotify token", "path", tokenFilePath)
 	}
 
 	spc := spotifyClientFromToken(token)
 
 	user, err := spc.CurrentUser(ctx)
 	if err != nil {
-		log.Fatalf("unable to get spotify current user: %s\n", err.Error())
+		logging.Fatal(logger, "unable to get spotify current user", "err", err)
 	}
 
-	log.Printf("spotify user %s\n", user.ID)
+	logger.Info("spotify user", "user", user.ID)
 	return spc
 }
 
@@ -81,37 +84,40 @@ func main() {
 	viper.AddConfigPath("$HOME/.deskpad")
 	viper.AddConfigPath(".")
 	viper.SetDefault("web.addr", ":1337")
+	viper.SetDefault("web.tls.dir", "tls")
 
 	err := viper.ReadInConfig()
 	if err != nil {
-		log.Fatalf("unable to load config file: %s\n", err.Error())
+		logging.Fatal(logger, "unable to load config file", "err", err)
 	}
 
-	var sd *sdeck.Client
-	if viper.GetBool("use-streamdeck") {
-		// Detect and initialize the Stream Deck
-		// No point in continuing if we can't find the right hardware to use.
-		sd, err = sdeck.New(sdeck.StreamDeckOriginalV2)
-		if err != nil {
-			log.Fatalf("unable to initialize stream deck: %s\n", err.Error())
-		}
-		defer sd.Close()
+	logConfig, err := loggingConfig(viper.GetViper())
+	if err != nil {
+		logging.Fatal(logger, "unable to configure logging", "err", err)
+	}
+	if err := logging.Configure(logConfig); err != nil {
+		logging.Fatal(logger, "unable to configure logging", "err", err)
+	}
+	// Libraries which use the log package are logged as deskpadd.
+	slog.SetDefault(logger)
 
-		serial, err := sd.Serial()
-		if err != nil {
-			log.Fatalf("unable to get serial number: %s\n", err.Error())
+	// API tokens are stored hashed in the token file, and managed with 'deskpadd token'.
+	var tokenFile *TokenFile
+	if path := viper.GetString("web.tokens-path"); len(path) > 0 {
+		tokenFile = NewTokenFile(path)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if tokenFile == nil {
+			logging.Fatal(logger, "web.tokens-path must be configured to manage tokens")
 		}
-		log.Printf("*** Using stream deck '%s'\n", serial)
+		os.Exit(runTokenCommand(tokenFile, os.Args[2:], os.Stdout, os.Stderr))
+	}
 
-		err = sd.ClearAllKeys()
-		if err != nil {
-			log.Fatalf("error resetting deck - consider unplugging & replugging the stream deck. Details: %s\n", err.Error())
-		}
-	} else {
-		log.Printf("*** Stream Deck disabled\n")
+	if !viper.GetBool("use-streamdeck") {
+		logger.Info("stream deck disabled")
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	// Setup Spotify. This is used as the playlist provider; and if not using the Linux MPRIS interface
@@ -120,7 +126,7 @@ func main() {
 
 	var mprisConn *dbus.Conn
 	var mprisInstanceName string
-	var pulseAudioClient *pulseaudio.Client
+	var pulseAudioClient controllers.PulseAudioClient
 	// Setup MPRIS & PulseAudio, if configured
 	if viper.GetBool("use-mpris") {
 		conn, err := dbus.SessionBus()
@@ -134,16 +140,16 @@ func main() {
 			panic(err)
 		}
 		if len(names) == 0 {
-			log.Printf("*** No MPRIS media player found; falling back to Spotify playback control\n")
+			logger.Info("no mpris media player found; falling back to spotify playback control")
 			conn.Close()
 			mprisConn = nil
 		} else {
 			mprisInstanceName = names[0]
-			log.Printf("*** MPRIS media player at '%s'\n", mprisInstanceName)
+			logger.Info("using mpris media player", "player", mprisInstanceName)
 
 			paClient, err := pulseaudio.NewClient()
 			if err != nil {
-				log.Printf("error connecting to pulseaudio; falling back to Spotify playback control: %s\n", err.Error())
+				logger.Warn("error connecting to pulseaudio; falling back to spotify playback control", "err", err)
 				conn.Close()
 				mprisConn = nil
 			} else {
@@ -153,7 +159,7 @@ func main() {
 			}
 		}
 	} else {
-		log.Printf("*** MPRIS disabled\n")
+		logger.Info("mpris disabled")
 	}
 
 	// Setup weather, if configured
@@ -166,12 +172,41 @@ func main() {
 		)
 		go wc.Run(ctx)
 	} else {
-		log.Printf("no weather address provided, will not check for weather updates")
+		logger.Info("no weather address provided, will not check for weather updates")
+	}
+
+	// Setup Home Assistant, if configured
+	var hac *controllers.HomeAssistant
+	if haURL := viper.GetString("home-assistant.url"); len(haURL) > 0 {
+		var entities []controllers.HomeAssistantEntityConfig
+		if err := viper.UnmarshalKey("home-assistant.entities", &entities); err != nil {
+			logger.Error("unable to retrieve home assistant entities", "err", err)
+		}
+		hac = controllers.NewHomeAssistant(haURL, viper.GetString("home-assistant.token"), entities)
+		go hac.Run(ctx)
+	} else {
+		logger.Info("no home assistant url provided, will not control home assistant entities")
+	}
+
+	// Setup the Hue bridge, if configured
+	var huec *controllers.Hue
+	if hueAddr := viper.GetString("hue.addr"); len(hueAddr) > 0 {
+		huec = controllers.NewHue(hueAddr, viper.GetString("hue.username"), viper.GetString("hue.username-path"))
+		if huec.Paired() {
+			if err := huec.RefreshLights(ctx); err != nil {
+				logger.Error("unable to refresh hue lights", "err", err)
+			}
+		} else {
+			logger.Info("not paired with the hue bridge, press the link button then the pair key on the hue screen")
+		}
+	} else {
+		logger.Info("no hue bridge address provided, will not control hue lights")
 	}
 
 	// Setup Timebox, if configured
 	tbAddr := viper.GetString("timebox.addr")
 	var tbc *timebox.Conn
+	var tbStatus statusError
 	if len(tbAddr) > 0 {
 		viper.SetDefault("timebox.color.red", 0)
 		viper.SetDefault("timebox.color.green", 255)
@@ -180,20 +215,20 @@ func main() {
 
 		btAddr, err := tbbt.NewAddress(tbAddr)
 		if err != nil {
-			log.Fatalf("invalid bluetooth address (%s): %s\n", tbAddr, err.Error())
+			logging.Fatal(logger, "invalid timebox bluetooth address", "addr", tbAddr, "err", err)
 		}
 
 		btChann := viper.GetInt("timebox.channel")
 		btConn := &tbbt.Connection{}
 		err = btConn.Connect(btAddr, uint8(btChann))
 		if err != nil {
-			log.Fatalf("unable to connect to bluetooth device: %s\n", err.Error())
+			logging.Fatal(logger, "unable to connect to timebox", "addr", tbAddr, "err", err)
 		}
 		defer btConn.Close()
 
 		tbConn := timebox.NewConn(btConn)
 		if err := tbConn.Initialize(); err != nil {
-			log.Fatalf("unable to establish connection with timebox: %s\n", err.Error())
+			logging.Fatal(logger, "unable to establish connection with timebox", "addr", tbAddr, "err", err)
 		}
 
 		tbConn.SetColor(&timebox.Colour{
@@ -237,8 +272,8 @@ func main() {
 						conds = timebox.WeatherSun
 					}
 
-					log.Printf("weather shows feels-like %0.2f C (actual %0.2f C) with condition %s\n", r.FeelsLikeC, r.TempC, r.Condition)
-					tbConn.SetTemperatureAndWeather(int(r.FeelsLikeC), timebox.Celsius, conds)
+					logger.Debug("sending weather to timebox", "feelsLikeC", r.FeelsLikeC, "tempC", r.TempC, "condition", r.Condition)
+					tbStatus.Set(tbConn.SetTemperatureAndWeather(int(r.FeelsLikeC), timebox.Celsius, conds))
 				}
 
 				pushWeather()
@@ -263,14 +298,26 @@ func main() {
 	if len(btAdapterID) > 0 {
 		btAdapter, err = adapter.NewAdapter1FromAdapterID(btAdapterID)
 		if err != nil {
-			log.Fatalf("unable to get bt adapter from ID %s: %s\n", btAdapterID, err.Error())
+			logging.Fatal(logger, "unable to get bluetooth adapter", "adapter", btAdapterID, "err", err)
 		}
 	}
 
-	// Retrieve any static media playlists
+	// Retrieve any static media playlists. Once playlists have been edited through the API they are
+	// read from the playlist file instead of the config.
 	var playlists []ui.MediaPlaylist
 	if err := viper.UnmarshalKey("media-playlists", &playlists); err != nil {
-		log.Printf("unable to retrieve playlists: %s\n", err.Error())
+		logger.Error("unable to retrieve playlists", "err", err)
+	}
+
+	var playlistFile *controllers.PlaylistFile
+	if path := viper.GetString("media-playlists-path"); len(path) > 0 {
+		playlistFile = controllers.NewPlaylistFile(path)
+		storedPlaylists, err := playlistFile.Load()
+		if err == nil {
+			playlists = storedPlaylists
+		} else if !errors.Is(err, os.ErrNotExist) {
+			logger.Error("unable to load playlists", "path", path, "err", err)
+		}
 	}
 
 	// Set up the UI
@@ -303,6 +350,9 @@ func main() {
 	mps.SetSettingsScreen(mpss)
 
 	mplc := controllers.NewMediaPlaylist(spotifyClient, playlistPlaybackController, playlists)
+	if playlistFile != nil {
+		mplc.SetPlaylistStore(playlistFile)
+	}
 	mplc.RefreshPlaylists(ctx)
 
 	mpls := screens.NewMediaPlaylist(hs, mplc)
@@ -315,9 +365,9 @@ func main() {
 			time.Sleep(time.Hour)
 
 			if err := mplc.RefreshPlaylists(context.Background()); err != nil {
-				log.Printf("unable to refresh spotify playlist: %s\n", err.Error())
+				logger.Error("unable to refresh spotify playlists", "err", err)
 			} else {
-				log.Printf("playlists refreshed\n")
+				logger.Info("playlists refreshed")
 			}
 		}
 	}()
@@ -331,53 +381,333 @@ func main() {
 		_ = screens.NewWeather(hs, wc)
 	}
 
+	var has *screens.HomeAssistant
+	if hac != nil {
+		has = screens.NewHomeAssistant(hs, hac)
+	}
+
+	if huec != nil {
+		_ = screens.NewHue(hs, huec)
+	}
+
 	bs := controllers.NewBluetoothSetting(btAdapter, btAdapterID)
 	bs.RefreshDevices(ctx)
 	_ = screens.NewBluetoothSetting(hs, bs)
 
 	d := deskpad.NewDeck(hs)
-	var streamDeckSurface *deskpad.StreamDeckSurface
-	if sd != nil {
-		streamDeckSurface = deskpad.NewStreamDeckSurface(sd)
-		d.RegisterSurface(streamDeckSurface)
+
+	// Devices are paired by entering a code shown on the deck; the issued tokens are kept in the token file.
+	var pairer *Pairer
+	if tokenFile != nil {
+		pairer = NewPairer(d, tokenFile)
+		pairer.SetScreen(screens.NewPairing(hs, pairer))
 	}
 
 	webSurface := deskpad.NewWebSurface()
+	webSurface.SetVirtualDials(viper.GetInt("web.virtual-dials"))
 	d.RegisterSurface(webSurface)
+
+	// Render to PNG files on disk, if configured. Useful for debugging layouts without a deck attached.
+	if fileSurfaceDir := viper.GetString("file-surface.dir"); len(fileSurfaceDir) > 0 {
+		logger.Info("rendering deck to files", "dir", fileSurfaceDir)
+		d.RegisterSurface(deskpad.NewFileSurface(fileSurfaceDir, viper.GetBool("file-surface.history")))
+	}
 	d.RefreshScreen()
 
-	if streamDeckSurface != nil {
-		go streamDeckSurface.Run(ctx, d)
+	// Redraw the Home Assistant screens as the entities change state.
+	if has != nil {
+		go func() {
+			changes, cancel := hac.Subscribe()
+			defer cancel()
+
+			for range changes {
+				if s := d.Screen(); s == deskpad.Screen(has) || s == deskpad.Screen(has.LightScreen()) {
+					d.RefreshScreen()
+				}
+			}
+		}()
+	}
+
+	// Record the session, if configured, so it can be replayed against the simulator later.
+	if recordPath := viper.GetString("record.path"); len(recordPath) > 0 {
+		recordFile, err := os.OpenFile(recordPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logging.Fatal(logger, "unable to open recording file", "path", recordPath, "err", err)
+		}
+		defer recordFile.Close()
+
+		logger.Info("recording deck session", "path", recordPath)
+		d.SetRecorder(deskpad.NewRecorder(recordFile))
+	}
+
+	// Attach the Stream Deck whenever one is plugged in; deskpadd keeps running without one.
+	// Decks are driven through hidraw where it is available, which lets key images be encoded once and cached and
+	// supports the dials and touch strip of the Stream Deck +; elsewhere the Stream Deck library is used for the
+	// Original V2.
+	var manager *deskpad.StreamDeckManager
+	if viper.GetBool("use-streamdeck") {
+		manager = deskpad.NewStreamDeckManager(d, func() (deskpad.StreamDeckDevice, error) {
+			if sd, err := deskpad.OpenHIDStreamDeck(); !errors.Is(err, deskpad.ErrHIDUnavailable) {
+				return sd, err
+			}
+
+			sd, err := sdeck.New(sdeck.StreamDeckOriginalV2)
+			if err != nil {
+				return nil, err
+			}
+			return sd, nil
+		})
+		go manager.Run(ctx)
+	}
+
+	// Render to the controlling terminal, if configured. Exiting the terminal surface stops deskpadd.
+	if viper.GetBool("use-terminal") {
+		terminalSurface := deskpad.NewTerminalSurface(os.Stdout, os.Stdin, deskpad.DetectTerminalMode())
+		d.RegisterSurface(terminalSurface)
+		go func() {
+			terminalSurface.Run(ctx, d)
+			stop()
+		}()
+	}
+
+	// Serve over HTTPS if a certificate is configured, or with a certificate from a local CA if requested.
+	var tlsConfig *tls.Config
+	var localCA *LocalCA
+	if certPath, keyPath := viper.GetString("web.tls.cert"), viper.GetString("web.tls.key"); len(certPath) > 0 || len(keyPath) > 0 {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			logging.Fatal(logger, "unable to load tls certificate", "err", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	} else if viper.GetBool("web.tls.self-signed") {
+		localCA = NewLocalCA(viper.GetString("web.tls.dir"))
+		cert, err := localCA.Certificate(certificateHosts(viper.GetStringSlice("web.tls.hosts")))
+		if err != nil {
+			logging.Fatal(logger, "unable to create self-signed certificate", "err", err)
+		}
+		logger.Info("using self-signed certificate; install the ca certificate or /ca.pem on clients to trust it", "ca", localCA.CACertPath())
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	// Report the state of each subsystem through /healthz and /readyz.
+	health := NewHealth()
+	var deckCheck HealthCheck
+	if manager != nil {
+		deckCheck = func(context.Context) error {
+			if !manager.Attached() {
+				return errors.New("no stream deck attached")
+			}
+			return nil
+		}
+	}
+	health.Register("deck", false, deckCheck)
+	var mprisCheck, pulseAudioCheck HealthCheck
+	if mprisConn != nil && pulseAudioClient != nil {
+		mprisCheck = func(context.Context) error {
+			return mprisConn.Object(mprisInstanceName, "/org/mpris/MediaPlayer2").Call("org.freedesktop.DBus.Peer.Ping", 0).Err
+		}
+		pulseAudioCheck = func(context.Context) error {
+			_, err := pulseAudioClient.ServerInfo()
+			return err
+		}
+	}
+	health.Register("mpris", true, mprisCheck)
+	health.Register("pulseaudio", true, pulseAudioCheck)
+	health.Register("spotify", true, func(ctx context.Context) error {
+		_, err := spotifyClient.CurrentUser(ctx)
+		return err
+	})
+	var weatherCheck HealthCheck
+	if wc != nil {
+		weatherCheck = func(context.Context) error {
+			return wc.StreamStatus()
+		}
+	}
+	health.Register("weather", false, weatherCheck)
+	var homeAssistantCheck HealthCheck
+	if hac != nil {
+		homeAssistantCheck = func(context.Context) error {
+			return hac.StreamStatus()
+		}
+	}
+	health.Register("homeassistant", false, homeAssistantCheck)
+	var timeboxCheck HealthCheck
+	if tbc != nil {
+		timeboxCheck = tbStatus.Check
+	}
+	health.Register("timebox", false, timeboxCheck)
+
+	// Send events to webhooks, if configured
+	var webhooks *Webhooks
+	var webhookConfigs []WebhookConfig
+	if err := viper.UnmarshalKey("webhooks.endpoints", &webhookConfigs); err != nil {
+		logger.Error("unable to retrieve webhooks", "err", err)
+	}
+	if len(webhookConfigs) > 0 {
+		var thresholds []WeatherThreshold
+		if err := viper.UnmarshalKey("webhooks.weather-thresholds", &thresholds); err != nil {
+			logger.Error("unable to retrieve weather thresholds", "err", err)
+		}
+
+		webhooks = NewWebhooks(webhookConfigs, thresholds)
+		webhooks.web = webSurface
+		webhooks.mpc = apiMPC
+		webhooks.mplc = mplc
+		webhooks.bt = bs
+		if wc != nil {
+			webhooks.wc = wc
+		}
+		d.AddKeyPressListener(webhooks.KeyPressed)
+		go webhooks.Run(ctx)
+	} else {
+		logger.Info("no webhooks configured, will not send events")
 	}
 
 	// Set up the API
+	api := &API{
+		mpc:       apiMPC,
+		mplc:      mplc,
+		mpsc:      mpsc,
+		d:         d,
+		web:       webSurface,
+		catalog:   hs,
+		authToken: viper.GetString("web.auth-token"),
+		tokens:    tokenFile,
+		authReads: viper.GetBool("web.auth-reads"),
+		pairer:    pairer,
+		ca:        localCA,
+		health:    health,
+		webhooks:  webhooks,
+	}
+	if wc != nil {
+		api.wc = wc
+	}
+
+	// Publish the deck state over MQTT, if configured
+	if broker := viper.GetString("mqtt.broker"); len(broker) > 0 {
+		viper.SetDefault("mqtt.client-id", "deskpadd")
+		viper.SetDefault("mqtt.topic-prefix", "deskpad")
+		viper.SetDefault("mqtt.discovery-prefix", "homeassistant")
+		viper.SetDefault("mqtt.node-id", "deskpad")
+
+		bridge := &MQTTBridge{
+			cfg: MQTTConfig{
+				Broker:          broker,
+				ClientID:        viper.GetString("mqtt.client-id"),
+				Username:        viper.GetString("mqtt.username"),
+				Password:        viper.GetString("mqtt.password"),
+				TopicPrefix:     viper.GetString("mqtt.topic-prefix"),
+				DiscoveryPrefix: viper.GetString("mqtt.discovery-prefix"),
+				NodeID:          viper.GetString("mqtt.node-id"),
+			},
+			d:       d,
+			web:     webSurface,
+			catalog: hs,
+			mpc:     apiMPC,
+			bt:      bs,
+		}
+		if wc != nil {
+			bridge.wc = wc
+		}
+
+		go func() {
+			if err := bridge.Run(ctx); err != nil {
+				logger.Error("mqtt bridge stopped", "broker", broker, "err", err)
+			}
+		}()
+	} else {
+		logger.Info("no mqtt broker provided, will not publish state over mqtt")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", api.Index)
+	mux.HandleFunc("/manifest.webmanifest", api.WebAsset)
+	mux.HandleFunc("/service-worker.js", api.WebAsset)
+	mux.HandleFunc("/icons/", api.WebAsset)
+	mux.HandleFunc("/playlists", api.WebAsset)
+	mux.HandleFunc("/ca.pem", api.CACert)
+	mux.HandleFunc("/healthz", api.Healthz)
+	mux.HandleFunc("/readyz", api.Readyz)
+	mux.HandleFunc("/metrics", api.Metrics)
+	mux.HandleFunc("/status", api.Status)
+	mux.HandleFunc("/api/ui/state", api.UIState)
+	mux.HandleFunc("/api/ui/events", api.UIEvents)
+	mux.HandleFunc("/api/ui/keys/", api.UIKeys)
+	mux.HandleFunc("/api/ui/dials/", api.UIDial)
+	mux.HandleFunc("/api/ws", api.UIWebSocket)
+	mux.HandleFunc("/api/media", api.Media)
+	mux.HandleFunc("/api/media/", api.Media)
+	mux.HandleFunc("/api/playlists", api.Playlists)
+	mux.HandleFunc("/api/playlists/", api.Playlists)
+	mux.HandleFunc("/api/audio/outputs", api.AudioOutputs)
+	mux.HandleFunc("/api/audio/outputs/", api.AudioOutputs)
+	mux.HandleFunc("/api/pairing", api.Pairing)
+	mux.HandleFunc("/api/pairing/complete", api.Pairing)
+	mux.HandleFunc("/api/devices", api.Devices)
+	mux.HandleFunc("/api/devices/", api.Devices)
+	mux.HandleFunc("/api/screens", api.Screens)
+	mux.HandleFunc("/api/screens/", api.Screens)
+	mux.HandleFunc("/api/weather", api.Weather)
+	mux.HandleFunc("/api/weather/events", api.WeatherEvents)
+	mux.HandleFunc("/api/webhooks/deliveries", api.WebhookDeliveries)
+
+	server := newHTTPServer(viper.GetString("web.addr"), mux)
+	server.TLSConfig = tlsConfig
+	// Shutdown doesn't wait for the event streams, so end them when it starts.
+	server.RegisterOnShutdown(api.Drain)
+	servers := []*http.Server{server}
+
 	go func() {
-		api := &API{
-			mpc:       apiMPC,
-			mplc:      mplc,
-			mpsc:      mpsc,
-			d:         d,
-			web:       webSurface,
-			authToken: viper.GetString("web.auth-token"),
-		}
-
-		mux := http.NewServeMux()
-		mux.HandleFunc("/", api.Index)
-		mux.HandleFunc("/manifest.webmanifest", api.WebAsset)
-		mux.HandleFunc("/service-worker.js", api.WebAsset)
-		mux.HandleFunc("/icons/", api.WebAsset)
-		mux.HandleFunc("/status", api.Status)
-		mux.HandleFunc("/api/ui/state", api.UIState)
-		mux.HandleFunc("/api/ui/events", api.UIEvents)
-		mux.HandleFunc("/api/ui/keys/", api.UIPressKey)
-
-		addr := viper.GetString("web.addr")
-		log.Printf("starting http api on %s\n", addr)
-		if err := http.ListenAndServe(addr, mux); err != nil && err != http.ErrServerClosed {
-			log.Printf("http api stopped: %s\n", err.Error())
+		var err error
+		if tlsConfig == nil {
+			logger.Info("starting http api", "addr", server.Addr)
+			err = server.ListenAndServe()
+		} else {
+			logger.Info("starting https api", "addr", server.Addr)
+			err = server.ListenAndServeTLS("", "")
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("http api stopped", "err", err)
 		}
 	}()
 
+	// Send plain HTTP clients to the HTTPS listener, if configured.
+	if redirectAddr := viper.GetString("web.redirect-addr"); tlsConfig != nil && len(redirectAddr) > 0 {
+		redirect := newHTTPServer(redirectAddr, httpsRedirect(server.Addr))
+		servers = append(servers, redirect)
+		go func() {
+			logger.Info("redirecting http to https", "addr", redirectAddr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("http redirect stopped", "err", err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("unable to shut down http server", "addr", srv.Addr, "err", err)
+		}
+	}
 	d.Clear()
 }
+
+// shutdownTimeout is how long in-flight requests are given to complete when deskpadd stops.
+const shutdownTimeout = 10 * time.Second
+
+// newHTTPServer creates a server with timeouts, so slow or stalled clients can't hold connections open
+// indefinitely. The event streams lift the write timeout themselves.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
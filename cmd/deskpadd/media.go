@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+// MediaStateResponse is the full state of the media player returned by GET /api/media.
+type MediaStateResponse struct {
+	ID               string         `json:"id"`
+	Playing          bool           `json:"playing"`
+	Shuffle          bool           `json:"shuffle"`
+	Muted            bool           `json:"muted"`
+	Repeat           ui.RepeatMode  `json:"repeat"`
+	Volume           int            `json:"volume"`
+	PositionMs       int64          `json:"positionMs"`
+	DurationMs       int64          `json:"durationMs"`
+	CurrentlyPlaying *MediaItem     `json:"currentlyPlaying"`
+	CurrentPlaylist  *MediaPlaylist `json:"currentPlaylist"`
+}
+
+// MediaCommandRequest holds the arguments of the media commands which take one.
+type MediaCommandRequest struct {
+	PositionMs *int64        `json:"positionMs"`
+	Volume     *int          `json:"volume"`
+	Muted      *bool         `json:"muted"`
+	Shuffle    *bool         `json:"shuffle"`
+	Mode       ui.RepeatMode `json:"mode"`
+}
+
+var errNoMediaPlayer = errors.New("no media player configured")
+
+// runMediaCommand applies the named playback command to the media player.
+// The shuffle command uses value to enable or disable shuffling.
+func runMediaCommand(mpc MediaPlayerController, command string, value bool) error {
+	if mpc == nil {
+		return errNoMediaPlayer
+	}
+
+	switch command {
+	case "play":
+		mpc.Play()
+	case "pause":
+		mpc.Pause()
+	case "playPause":
+		if mpc.IsPlaying() {
+			mpc.Pause()
+		} else {
+			mpc.Play()
+		}
+	case "next":
+		mpc.Next()
+	case "previous":
+		mpc.Previous()
+	case "fastForward":
+		mpc.FastForward()
+	case "rewind":
+		mpc.Rewind()
+	case "volumeUp":
+		mpc.VolumeUp()
+	case "volumeDown":
+		mpc.VolumeDown()
+	case "mute":
+		mpc.Mute()
+	case "unmute":
+		mpc.Unmute()
+	case "shuffle":
+		mpc.Shuffle(value)
+	default:
+		return fmt.Errorf("unknown media command %q", command)
+	}
+
+	return nil
+}
+
+// Media handles GET /api/media, returning the player state, and POST /api/media/{command} for the
+// play, pause, next, previous, seek, volume, mute, shuffle and repeat commands.
+func (a *API) Media(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/media" {
+		a.mediaState(w, r)
+		return
+	}
+
+	command, ok := strings.CutPrefix(r.URL.Path, "/api/media/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch command {
+	case "play", "pause", "next", "previous", "seek", "volume", "mute", "shuffle", "repeat":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !a.authorize(w, r, ScopeMedia) {
+		return
+	}
+	if a.mpc == nil {
+		http.Error(w, errNoMediaPlayer.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var req MediaCommandRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var err error
+	switch command {
+	case "seek":
+		if req.PositionMs == nil || *req.PositionMs < 0 {
+			http.Error(w, "invalid position", http.StatusBadRequest)
+			return
+		}
+		err = a.mpc.Seek(time.Duration(*req.PositionMs) * time.Millisecond)
+	case "volume":
+		if req.Volume == nil || *req.Volume < 0 || *req.Volume > 100 {
+			http.Error(w, "invalid volume", http.StatusBadRequest)
+			return
+		}
+		err = a.mpc.SetVolume(*req.Volume)
+	case "mute":
+		if req.Muted == nil {
+			http.Error(w, "invalid muted state", http.StatusBadRequest)
+			return
+		}
+		if *req.Muted {
+			err = runMediaCommand(a.mpc, "mute", false)
+		} else {
+			err = runMediaCommand(a.mpc, "unmute", false)
+		}
+	case "shuffle":
+		if req.Shuffle == nil {
+			http.Error(w, "invalid shuffle state", http.StatusBadRequest)
+			return
+		}
+		err = runMediaCommand(a.mpc, "shuffle", *req.Shuffle)
+	case "repeat":
+		switch req.Mode {
+		case ui.RepeatOff, ui.RepeatTrack, ui.RepeatPlaylist:
+		default:
+			http.Error(w, "invalid repeat mode", http.StatusBadRequest)
+			return
+		}
+		err = a.mpc.Repeat(req.Mode)
+	default:
+		err = runMediaCommand(a.mpc, command, false)
+	}
+	if err != nil {
+		apiLogger.Error("media command failed", "command", command, "err", err)
+		http.Error(w, "media command failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Keys such as play/pause reflect the player state.
+	a.d.RefreshScreen()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) mediaState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
+	if a.mpc == nil {
+		http.Error(w, errNoMediaPlayer.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	playback := a.mpc.PlaybackState()
+	resp := MediaStateResponse{
+		ID:               a.mpc.ID(),
+		Playing:          a.mpc.IsPlaying(),
+		Shuffle:          a.mpc.IsShuffle(),
+		Muted:            a.mpc.IsMuted(),
+		Repeat:           playback.Repeat,
+		Volume:           playback.Volume,
+		PositionMs:       playback.Position.Milliseconds(),
+		DurationMs:       playback.Duration.Milliseconds(),
+		CurrentlyPlaying: mediaItemFromUI(a.mpc.CurrentlyPlaying()),
+	}
+	if a.mplc != nil {
+		resp.CurrentPlaylist = mediaPlaylistFromUI(a.mplc.CurrentlyPlaylist())
+	}
+
+	writeJSON(w, resp)
+}
+
+func mediaItemFromUI(item *ui.MediaItem) *MediaItem {
+	if item == nil {
+		return nil
+	}
+
+	return &MediaItem{
+		ID:          item.ID,
+		Title:       item.Title,
+		Artists:     item.Artists,
+		AlbumName:   item.AlbumName,
+		AlbumArtURL: item.AlburmArtURL,
+	}
+}
+
+func mediaPlaylistFromUI(playlist *ui.MediaPlaylist) *MediaPlaylist {
+	if playlist == nil {
+		return nil
+	}
+
+	return &MediaPlaylist{
+		ID:   playlist.ID,
+		Name: playlist.Name,
+	}
+}
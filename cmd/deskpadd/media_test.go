@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+type mediaTestPlayer struct {
+	apiTestMediaPlayer
+	calls []string
+}
+
+func (p *mediaTestPlayer) record(format string, args ...any) {
+	p.calls = append(p.calls, fmt.Sprintf(format, args...))
+}
+
+func (p *mediaTestPlayer) Play()                { p.record("play") }
+func (p *mediaTestPlayer) Next()                { p.record("next") }
+func (p *mediaTestPlayer) Unmute()              { p.record("unmute") }
+func (p *mediaTestPlayer) Shuffle(shuffle bool) { p.record("shuffle %t", shuffle) }
+func (p *mediaTestPlayer) IsShuffle() bool      { return true }
+func (p *mediaTestPlayer) CurrentlyPlaying() *ui.MediaItem {
+	return &ui.MediaItem{ID: "track-1", Title: "Song", Artists: []string{"Artist"}}
+}
+
+func (p *mediaTestPlayer) Seek(position time.Duration) error {
+	p.record("seek %s", position)
+	return nil
+}
+
+func (p *mediaTestPlayer) SetVolume(volume int) error {
+	p.record("volume %d", volume)
+	return nil
+}
+
+func (p *mediaTestPlayer) Repeat(mode ui.RepeatMode) error {
+	p.record("repeat %s", mode)
+	return nil
+}
+
+func (p *mediaTestPlayer) PlaybackState() ui.PlaybackState {
+	return ui.PlaybackState{Position: 30 * time.Second, Duration: 3 * time.Minute, Volume: 40, Repeat: ui.RepeatTrack}
+}
+
+func TestMediaReturnsPlayerState(t *testing.T) {
+	api := &API{d: deskpad.NewDeck(&apiTestScreen{name: "home"}), mpc: &mediaTestPlayer{}}
+
+	rec := httptest.NewRecorder()
+	api.Media(rec, httptest.NewRequest(http.MethodGet, "/api/media", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var resp MediaStateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %s", err)
+	}
+	if !resp.Shuffle || resp.Repeat != ui.RepeatTrack || resp.Volume != 40 || resp.PositionMs != 30000 || resp.DurationMs != 180000 {
+		t.Fatalf("state = %+v, want shuffled track repeat at 30s of 3m with volume 40", resp)
+	}
+	if resp.CurrentlyPlaying == nil || resp.CurrentlyPlaying.Title != "Song" {
+		t.Fatalf("currently playing = %+v, want Song", resp.CurrentlyPlaying)
+	}
+}
+
+func TestMediaCommandsControlPlayer(t *testing.T) {
+	player := &mediaTestPlayer{}
+	api := &API{d: deskpad.NewDeck(&apiTestScreen{name: "home"}), mpc: player, authToken: "secret"}
+
+	tests := []struct {
+		command string
+		body    string
+		code    int
+		call    string
+	}{
+		{command: "play", code: http.StatusNoContent, call: "play"},
+		{command: "next", code: http.StatusNoContent, call: "next"},
+		{command: "seek", body: `{"positionMs":90000}`, code: http.StatusNoContent, call: "seek 1m30s"},
+		{command: "seek", body: `{}`, code: http.StatusBadRequest},
+		{command: "volume", body: `{"volume":25}`, code: http.StatusNoContent, call: "volume 25"},
+		{command: "volume", body: `{"volume":101}`, code: http.StatusBadRequest},
+		{command: "mute", body: `{"muted":false}`, code: http.StatusNoContent, call: "unmute"},
+		{command: "shuffle", body: `{"shuffle":true}`, code: http.StatusNoContent, call: "shuffle true"},
+		{command: "repeat", body: `{"mode":"playlist"}`, code: http.StatusNoContent, call: "repeat playlist"},
+		{command: "repeat", body: `{"mode":"forever"}`, code: http.StatusBadRequest},
+		{command: "eject", code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		player.calls = nil
+		req := httptest.NewRequest(http.MethodPost, "/api/media/"+tt.command, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		api.Media(rec, req)
+
+		if rec.Code != tt.code {
+			t.Fatalf("%s %s status = %d, want %d", tt.command, tt.body, rec.Code, tt.code)
+		}
+		if tt.call == "" && len(player.calls) != 0 {
+			t.Fatalf("%s %s called player: %v", tt.command, tt.body, player.calls)
+		}
+		if tt.call != "" && (len(player.calls) != 1 || player.calls[0] != tt.call) {
+			t.Fatalf("%s %s calls = %v, want %q", tt.command, tt.body, player.calls, tt.call)
+		}
+	}
+}
+
+func TestMediaCommandsRequireToken(t *testing.T) {
+	player := &mediaTestPlayer{}
+	api := &API{d: deskpad.NewDeck(&apiTestScreen{name: "home"}), mpc: player, authToken: "secret"}
+
+	rec := httptest.NewRecorder()
+	api.Media(rec, httptest.NewRequest(http.MethodPost, "/api/media/play", nil))
+	if rec.Code != http.StatusUnauthorized || len(player.calls) != 0 {
+		t.Fatalf("status = %d with calls %v, want 401 and no calls", rec.Code, player.calls)
+	}
+}
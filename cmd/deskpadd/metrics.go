@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/rmrobinson/deskpad/metrics"
+)
+
+var metricsHandler = metrics.Handler()
+
+// Metrics handles GET /metrics, serving the Prometheus metrics. Scrapers need a token when reads require one.
+func (a *API) Metrics(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
+
+	metricsHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+func TestMetricsExportsKeyPresses(t *testing.T) {
+	screen := &apiTestScreen{
+		name:   "metrics-home",
+		action: deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop},
+	}
+	deck := deskpad.NewDeck(screen)
+	if err := deck.PressKey(context.Background(), 3, deskpad.KeyPressLong); err != nil {
+		t.Fatalf("PressKey returned error: %s", err)
+	}
+	api := &API{d: deck}
+
+	rec := httptest.NewRecorder()
+	api.Metrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`deskpad_key_presses_total{key="3",screen="metrics-home",type="long"} 1`,
+		`deskpad_key_press_duration_seconds_count{screen="metrics-home"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q", want)
+		}
+	}
+}
+
+func TestMetricsRequireTokenWhenReadsAreAuthenticated(t *testing.T) {
+	api := &API{authToken: "secret", authReads: true}
+
+	rec := httptest.NewRecorder()
+	api.Metrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	api.Metrics(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status with token = %d, want 200", rec.Code)
+	}
+}
+
+func TestSpotifyClientCountsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"status":503,"message":"unavailable"}}`, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	counter := &spotifyErrorCounter{next: http.DefaultTransport}
+	client := &http.Client{Transport: counter}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("get returned error: %s", err)
+	}
+	resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	(&API{}).Metrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `deskpad_spotify_api_errors_total{code="503"}`) {
+		t.Fatalf("metrics missing spotify error count:\n%s", rec.Body.String())
+	}
+}
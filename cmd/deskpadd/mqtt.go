@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+var mqttLogger = logging.Logger("mqtt")
+
+const (
+	// mqttPollInterval is how often state without change notifications, such as now playing, is checked.
+	mqttPollInterval = 5 * time.Second
+	mqttTimeout      = 10 * time.Second
+)
+
+// mqttMediaButtons are the media commands offered as Home Assistant buttons, with their names.
+var mqttMediaButtons = []struct {
+	command string
+	name    string
+}{
+	{"playPause", "Play/pause"},
+	{"next", "Next track"},
+	{"previous", "Previous track"},
+	{"volumeUp", "Volume up"},
+	{"volumeDown", "Volume down"},
+}
+
+// BluetoothController lists the connected Bluetooth devices. It is satisfied by *controllers.BluetoothSetting.
+type BluetoothController interface {
+	ConnectedDevices() ([]controllers.BluetoothDevice, error)
+}
+
+// MQTTConfig describes the broker to connect to and the topics to use.
+type MQTTConfig struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TopicPrefix is prepended to every state and command topic.
+	TopicPrefix string
+	// DiscoveryPrefix is where Home Assistant discovery configs are published; discovery is disabled if empty.
+	DiscoveryPrefix string
+	// NodeID identifies this deck in Home Assistant.
+	NodeID string
+}
+
+// MQTTBridge publishes the deck state to retained MQTT topics and handles commands sent to it:
+//
+//	{prefix}/status              online or offline
+//	{prefix}/screen              the current screen
+//	{prefix}/screen/set          shows the named screen
+//	{prefix}/key/press           presses a key: "3" or {"key": 3, "type": "long"}
+//	{prefix}/media/state         playing, paused or idle
+//	{prefix}/media/now_playing   the current track, or {} if nothing is playing
+//	{prefix}/media/set           runs a media command, such as next or playPause
+//	{prefix}/weather             the latest weather reading
+//	{prefix}/bluetooth           the connected Bluetooth devices
+type MQTTBridge struct {
+	cfg MQTTConfig
+
+	d       *deskpad.Deck
+	web     *deskpad.WebSurface
+	catalog ScreenCatalog
+	mpc     MediaPlayerController
+	wc      WeatherController
+	bt      BluetoothController
+
+	client mqtt.Client
+	ctx    context.Context
+
+	lock sync.Mutex
+	// published holds the last payload sent to each state topic so unchanged state isn't republished.
+	published map[string]string
+}
+
+// Run connects to the broker and publishes state until the context is cancelled. The client reconnects by
+// itself if the broker goes away.
+func (b *MQTTBridge) Run(ctx context.Context) error {
+	b.ctx = ctx
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.cfg.Broker).
+		SetClientID(b.cfg.ClientID).
+		SetUsername(b.cfg.Username).
+		SetPassword(b.cfg.Password).
+		SetWill(b.topic("status"), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			mqttLogger.Warn("connection to broker lost", "broker", b.cfg.Broker, "err", err)
+		})
+	b.client = mqtt.NewClient(opts)
+
+	if token := b.client.Connect(); token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+		return fmt.Errorf("unable to connect to %s: %w", b.cfg.Broker, token.Error())
+	}
+
+	var events <-chan deskpad.Snapshot
+	if b.web != nil {
+		var cancel func()
+		events, cancel = b.web.Subscribe()
+		defer cancel()
+	}
+
+	var readings <-chan *weatherv1.WeatherReading
+	if b.wc != nil {
+		r, cancel := b.wc.Subscribe()
+		defer cancel()
+		readings = r
+	}
+
+	poll := time.NewTicker(mqttPollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.publish(b.topic("status"), "offline")
+			b.client.Disconnect(250)
+			return nil
+		case snapshot, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			b.publish(b.topic("screen"), snapshot.ScreenName)
+		case reading, ok := <-readings:
+			if !ok {
+				readings = nil
+				continue
+			}
+			b.publishJSON(b.topic("weather"), weatherResponse(reading))
+		case <-poll.C:
+			b.publishMedia()
+			b.publishBluetooth()
+		}
+	}
+}
+
+// onConnect runs on every connection, including reconnections, so the subscriptions and retained state are
+// restored if the broker lost them.
+func (b *MQTTBridge) onConnect(client mqtt.Client) {
+	mqttLogger.Info("connected to broker", "broker", b.cfg.Broker)
+
+	b.lock.Lock()
+	b.published = map[string]string{}
+	b.lock.Unlock()
+
+	b.publish(b.topic("status"), "online")
+	b.publishDiscovery()
+
+	for topic, handler := range map[string]mqtt.MessageHandler{
+		b.topic("screen/set"): b.handleScreen,
+		b.topic("key/press"):  b.handleKeyPress,
+		b.topic("media/set"):  b.handleMedia,
+	} {
+		if token := client.Subscribe(topic, 1, handler); token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			mqttLogger.Error("unable to subscribe", "topic", topic, "err", token.Error())
+		}
+	}
+
+	b.publishState()
+}
+
+func (b *MQTTBridge) publishState() {
+	if b.d != nil && b.d.Screen() != nil {
+		b.publish(b.topic("screen"), b.d.Screen().Name())
+	}
+	if b.wc != nil {
+		if reading := b.wc.LatestReading(); reading != nil {
+			b.publishJSON(b.topic("weather"), weatherResponse(reading))
+		}
+	}
+	b.publishMedia()
+	b.publishBluetooth()
+}
+
+func (b *MQTTBridge) publishMedia() {
+	if b.mpc == nil {
+		return
+	}
+
+	state := "paused"
+	item := mediaItemFromUI(b.mpc.CurrentlyPlaying())
+	if b.mpc.IsPlaying() {
+		state = "playing"
+	} else if item == nil {
+		state = "idle"
+	}
+	b.publish(b.topic("media/state"), state)
+
+	if item == nil {
+		b.publish(b.topic("media/now_playing"), "{}")
+		return
+	}
+	b.publishJSON(b.topic("media/now_playing"), item)
+}
+
+// BluetoothState lists the connected Bluetooth devices by name.
+type BluetoothState struct {
+	Connected []string `json:"connected"`
+}
+
+func (b *MQTTBridge) publishBluetooth() {
+	if b.bt == nil {
+		return
+	}
+
+	devices, err := b.bt.ConnectedDevices()
+	if err != nil {
+		mqttLogger.Warn("unable to get connected bluetooth devices", "err", err)
+		return
+	}
+
+	state := BluetoothState{Connected: []string{}}
+	for _, device := range devices {
+		state.Connected = append(state.Connected, device.Name)
+	}
+	b.publishJSON(b.topic("bluetooth"), state)
+}
+
+func (b *MQTTBridge) publishJSON(topic string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		mqttLogger.Error("unable to marshal state", "topic", topic, "err", err)
+		return
+	}
+	b.publish(topic, string(data))
+}
+
+// publish sends a retained message, skipping it if the topic already holds the payload. Nothing is sent while
+// disconnected; the full state is republished on reconnection.
+func (b *MQTTBridge) publish(topic string, payload string) {
+	if !b.client.IsConnectionOpen() {
+		return
+	}
+
+	b.lock.Lock()
+	if last, ok := b.published[topic]; ok && last == payload {
+		b.lock.Unlock()
+		return
+	}
+	if b.published == nil {
+		b.published = map[string]string{}
+	}
+	b.published[topic] = payload
+	b.lock.Unlock()
+
+	token := b.client.Publish(topic, 1, true, payload)
+	if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+		mqttLogger.Error("unable to publish", "topic", topic, "err", token.Error())
+
+		// Try again next time the state is published.
+		b.lock.Lock()
+		delete(b.published, topic)
+		b.lock.Unlock()
+	}
+}
+
+func (b *MQTTBridge) topic(name string) string {
+	return b.cfg.TopicPrefix + "/" + name
+}
+
+func (b *MQTTBridge) handleScreen(_ mqtt.Client, msg mqtt.Message) {
+	name := strings.TrimSpace(string(msg.Payload()))
+
+	var screen deskpad.Screen
+	if b.catalog != nil {
+		for _, s := range b.catalog.Screens() {
+			if s.Name() == name {
+				screen = s
+				break
+			}
+		}
+	}
+	if screen == nil {
+		mqttLogger.Warn("unknown screen requested", "screen", name)
+		return
+	}
+
+	mqttLogger.Info("showing screen", "screen", name)
+	b.d.ChangeScreen(b.ctx, screen)
+}
+
+// MQTTKeyPress is the payload of a key press command. A bare key number is also accepted for a short press.
+type MQTTKeyPress struct {
+	Key  int    `json:"key"`
+	Type string `json:"type"`
+}
+
+func (b *MQTTBridge) handleKeyPress(_ mqtt.Client, msg mqtt.Message) {
+	req, err := parseMQTTKeyPress(msg.Payload())
+	if err != nil {
+		mqttLogger.Warn("invalid key press", "payload", string(msg.Payload()), "err", err)
+		return
+	}
+
+	pressType := deskpad.KeyPressShort
+	if req.Type == "long" {
+		pressType = deskpad.KeyPressLong
+	}
+
+	screen := b.d.Screen().Name()
+	if err := b.d.PressKey(b.ctx, req.Key, pressType); err != nil {
+		mqttLogger.Error("key press failed", "screen", screen, "key", req.Key, "err", err)
+		return
+	}
+	mqttLogger.Info("key press handled", "screen", screen, "key", req.Key, "type", req.Type)
+}
+
+func parseMQTTKeyPress(payload []byte) (MQTTKeyPress, error) {
+	req := MQTTKeyPress{Type: "short"}
+
+	text := strings.TrimSpace(string(payload))
+	if key, err := strconv.Atoi(text); err == nil {
+		req.Key = key
+		return req, nil
+	}
+	if err := json.Unmarshal([]byte(text), &req); err != nil {
+		return MQTTKeyPress{}, err
+	}
+	if req.Type != "short" && req.Type != "long" {
+		return MQTTKeyPress{}, errors.New("invalid press type")
+	}
+	return req, nil
+}
+
+func (b *MQTTBridge) handleMedia(_ mqtt.Client, msg mqtt.Message) {
+	command := strings.TrimSpace(string(msg.Payload()))
+	if err := runMediaCommand(b.mpc, command, false); err != nil {
+		mqttLogger.Error("media command failed", "command", command, "err", err)
+		return
+	}
+
+	b.publishMedia()
+	if b.d != nil {
+		b.d.RefreshScreen()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+// mqttDiscoveryDevice groups the discovered entities under a single device in Home Assistant.
+type mqttDiscoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+}
+
+// mqttDiscoveryConfig is a Home Assistant MQTT discovery payload. Only the fields used by deskpad are included.
+type mqttDiscoveryConfig struct {
+	Name                string              `json:"name"`
+	UniqueID            string              `json:"unique_id"`
+	AvailabilityTopic   string              `json:"availability_topic"`
+	Device              mqttDiscoveryDevice `json:"device"`
+	Icon                string              `json:"icon,omitempty"`
+	StateTopic          string              `json:"state_topic,omitempty"`
+	ValueTemplate       string              `json:"value_template,omitempty"`
+	JSONAttributesTopic string              `json:"json_attributes_topic,omitempty"`
+	CommandTopic        string              `json:"command_topic,omitempty"`
+	PayloadPress        string              `json:"payload_press,omitempty"`
+	Options             []string            `json:"options,omitempty"`
+	DeviceClass         string              `json:"device_class,omitempty"`
+	StateClass          string              `json:"state_class,omitempty"`
+	UnitOfMeasurement   string              `json:"unit_of_measurement,omitempty"`
+}
+
+// publishDiscovery announces the deck's entities to Home Assistant. Entities are only announced for the
+// subsystems which are configured.
+func (b *MQTTBridge) publishDiscovery() {
+	if len(b.cfg.DiscoveryPrefix) < 1 {
+		return
+	}
+
+	if b.catalog != nil {
+		var options []string
+		for _, screen := range b.catalog.Screens() {
+			options = append(options, screen.Name())
+		}
+		b.publishDiscoveryConfig("select", "screen", mqttDiscoveryConfig{
+			Name:         "Screen",
+			Icon:         "mdi:monitor-dashboard",
+			StateTopic:   b.topic("screen"),
+			CommandTopic: b.topic("screen/set"),
+			Options:      options,
+		})
+	}
+
+	if b.mpc != nil {
+		b.publishDiscoveryConfig("sensor", "media_state", mqttDiscoveryConfig{
+			Name:       "Media state",
+			Icon:       "mdi:play-pause",
+			StateTopic: b.topic("media/state"),
+		})
+		b.publishDiscoveryConfig("sensor", "now_playing", mqttDiscoveryConfig{
+			Name:                "Now playing",
+			Icon:                "mdi:music",
+			StateTopic:          b.topic("media/now_playing"),
+			ValueTemplate:       "{{ value_json.title | default('') }}",
+			JSONAttributesTopic: b.topic("media/now_playing"),
+		})
+		for _, button := range mqttMediaButtons {
+			b.publishDiscoveryConfig("button", button.command, mqttDiscoveryConfig{
+				Name:         button.name,
+				CommandTopic: b.topic("media/set"),
+				PayloadPress: button.command,
+			})
+		}
+	}
+
+	if b.wc != nil {
+		b.publishDiscoveryConfig("sensor", "temperature", mqttDiscoveryConfig{
+			Name:                "Temperature",
+			StateTopic:          b.topic("weather"),
+			ValueTemplate:       "{{ value_json.tempC }}",
+			JSONAttributesTopic: b.topic("weather"),
+			DeviceClass:         "temperature",
+			StateClass:          "measurement",
+			UnitOfMeasurement:   "°C",
+		})
+	}
+
+	if b.bt != nil {
+		b.publishDiscoveryConfig("sensor", "bluetooth", mqttDiscoveryConfig{
+			Name:                "Bluetooth connections",
+			Icon:                "mdi:bluetooth-connect",
+			StateTopic:          b.topic("bluetooth"),
+			ValueTemplate:       "{{ value_json.connected | count }}",
+			JSONAttributesTopic: b.topic("bluetooth"),
+		})
+	}
+}
+
+// publishDiscoveryConfig publishes the config of a single entity to {discovery prefix}/{component}/{node}/{object}/config.
+func (b *MQTTBridge) publishDiscoveryConfig(component string, object string, cfg mqttDiscoveryConfig) {
+	cfg.UniqueID = b.cfg.NodeID + "_" + object
+	cfg.AvailabilityTopic = b.topic("status")
+	cfg.Device = mqttDiscoveryDevice{
+		Identifiers:  []string{b.cfg.NodeID},
+		Name:         "deskpad",
+		Manufacturer: "deskpad",
+		Model:        "deskpadd",
+	}
+
+	b.publishJSON(b.cfg.DiscoveryPrefix+"/"+component+"/"+b.cfg.NodeID+"/"+object+"/config", cfg)
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+// mqttTestBroker is a minimal MQTT 3.1.1 broker for testing. It supports retained messages, wildcard
+// subscriptions and wills; everything is delivered to subscribers at QoS 0.
+type mqttTestBroker struct {
+	listener net.Listener
+
+	lock     sync.Mutex
+	retained map[string][]byte
+	clients  map[*mqttTestClient]struct{}
+}
+
+type mqttTestClient struct {
+	conn net.Conn
+
+	writeLock  sync.Mutex
+	filters    []string
+	willTopic  string
+	will       []byte
+	willRetain bool
+}
+
+func newMQTTTestBroker(t *testing.T) *mqttTestBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+
+	b := &mqttTestBroker{
+		listener: listener,
+		retained: map[string][]byte{},
+		clients:  map[*mqttTestClient]struct{}{},
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *mqttTestBroker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *mqttTestBroker) serve(conn net.Conn) {
+	c := &mqttTestClient{conn: conn}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	clean := false
+	defer func() {
+		b.lock.Lock()
+		delete(b.clients, c)
+		b.lock.Unlock()
+
+		if !clean && len(c.willTopic) > 0 {
+			b.publish(c.willTopic, c.will, c.willRetain)
+		}
+	}()
+
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			c.parseConnect(body)
+			b.lock.Lock()
+			b.clients[c] = struct{}{}
+			b.lock.Unlock()
+			c.write(0x20, []byte{0, 0})
+		case 3: // PUBLISH
+			qos := (header >> 1) & 3
+			topic, rest := readMQTTString(body)
+			if qos > 0 {
+				c.write(0x40, rest[:2])
+				rest = rest[2:]
+			}
+			b.publish(topic, rest, header&1 == 1)
+		case 8: // SUBSCRIBE
+			id, rest := body[:2], body[2:]
+			ack := append([]byte{}, id...)
+			var filters []string
+			for len(rest) > 0 {
+				var filter string
+				filter, rest = readMQTTString(rest)
+				rest = rest[1:]
+				filters = append(filters, filter)
+				ack = append(ack, 0)
+			}
+
+			b.lock.Lock()
+			c.filters = append(c.filters, filters...)
+			var retained []mqttTestMessage
+			for topic, payload := range b.retained {
+				for _, filter := range filters {
+					if mqttTopicMatches(filter, topic) {
+						retained = append(retained, mqttTestMessage{topic, payload})
+						break
+					}
+				}
+			}
+			b.lock.Unlock()
+
+			c.write(0x90, ack)
+			for _, msg := range retained {
+				c.writePublish(msg.topic, msg.payload, true)
+			}
+		case 10: // UNSUBSCRIBE
+			c.write(0xb0, body[:2])
+		case 12: // PINGREQ
+			c.write(0xd0, nil)
+		case 14: // DISCONNECT
+			clean = true
+			return
+		}
+	}
+}
+
+type mqttTestMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (b *mqttTestBroker) publish(topic string, payload []byte, retain bool) {
+	b.lock.Lock()
+	if retain {
+		if len(payload) == 0 {
+			delete(b.retained, topic)
+		} else {
+			b.retained[topic] = payload
+		}
+	}
+	var targets []*mqttTestClient
+	for c := range b.clients {
+		for _, filter := range c.filters {
+			if mqttTopicMatches(filter, topic) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.lock.Unlock()
+
+	for _, c := range targets {
+		c.writePublish(topic, payload, false)
+	}
+}
+
+func (c *mqttTestClient) parseConnect(body []byte) {
+	_, rest := readMQTTString(body)
+	flags := rest[1]
+	rest = rest[4:]
+	_, rest = readMQTTString(rest)
+	if flags&0x04 != 0 {
+		c.willTopic, rest = readMQTTString(rest)
+		var will string
+		will, _ = readMQTTString(rest)
+		c.will = []byte(will)
+		c.willRetain = flags&0x20 != 0
+	}
+}
+
+func (c *mqttTestClient) writePublish(topic string, payload []byte, retain bool) {
+	header := byte(0x30)
+	if retain {
+		header |= 1
+	}
+	body := appendMQTTString(nil, topic)
+	c.write(header, append(body, payload...))
+}
+
+func (c *mqttTestClient) write(header byte, body []byte) {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.conn.Write(append(packet, body...))
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func readMQTTString(b []byte) (string, []byte) {
+	length := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+length]), b[2+length:]
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func mqttTopicMatches(filter string, topic string) bool {
+	filterParts := strings.Split(filter, "/")
+	topicParts := strings.Split(topic, "/")
+	for i, part := range filterParts {
+		if part == "#" {
+			return true
+		}
+		if i >= len(topicParts) || (part != "+" && part != topicParts[i]) {
+			return false
+		}
+	}
+	return len(filterParts) == len(topicParts)
+}
+
+// mqttTestWatcher subscribes to every topic on the broker and records the latest payload of each.
+type mqttTestWatcher struct {
+	client mqtt.Client
+
+	lock     sync.Mutex
+	messages map[string]string
+}
+
+func watchMQTT(t *testing.T, broker *mqttTestBroker) *mqttTestWatcher {
+	t.Helper()
+
+	w := &mqttTestWatcher{messages: map[string]string{}}
+	w.client = mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker.URL()).SetClientID("watcher"))
+	if token := w.client.Connect(); !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		t.Fatalf("connect watcher: %v", token.Error())
+	}
+	t.Cleanup(func() { w.client.Disconnect(0) })
+
+	token := w.client.Subscribe("#", 0, func(_ mqtt.Client, msg mqtt.Message) {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		w.messages[msg.Topic()] = string(msg.Payload())
+	})
+	if !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe watcher: %v", token.Error())
+	}
+	return w
+}
+
+// waitFor waits until the topic holds a payload accepted by match, returning it.
+func (w *mqttTestWatcher) waitFor(t *testing.T, topic string, match func(payload string) bool) string {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w.lock.Lock()
+		payload, ok := w.messages[topic]
+		w.lock.Unlock()
+		if ok && match(payload) {
+			return payload
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %q, timed out waiting for the expected payload", topic, payload)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (w *mqttTestWatcher) waitForPayload(t *testing.T, topic string, want string) {
+	t.Helper()
+	w.waitFor(t, topic, func(payload string) bool { return payload == want })
+}
+
+func (w *mqttTestWatcher) send(t *testing.T, topic string, payload string) {
+	t.Helper()
+	if token := w.client.Publish(topic, 1, false, payload); !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		t.Fatalf("publish %s: %v", topic, token.Error())
+	}
+}
+
+// mqttTestBluetooth is a Bluetooth controller whose connected devices can change between calls.
+type mqttTestBluetooth struct {
+	lock      sync.Mutex
+	connected []controllers.BluetoothDevice
+	err       error
+}
+
+func (b *mqttTestBluetooth) ConnectedDevices() ([]controllers.BluetoothDevice, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.connected, b.err
+}
+
+func (b *mqttTestBluetooth) set(err error, connected ...controllers.BluetoothDevice) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.connected = connected
+	b.err = err
+}
+
+type mqttTestKeyPress struct {
+	key       int
+	pressType deskpad.KeyPressType
+}
+
+type mqttTestScreen struct {
+	apiTestScreen
+	presses chan mqttTestKeyPress
+}
+
+func (s *mqttTestScreen) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
+	s.presses <- mqttTestKeyPress{id, t}
+	return deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop}, nil
+}
+
+type mqttTestMediaPlayer struct {
+	apiTestMediaPlayer
+	commands chan string
+}
+
+func (p *mqttTestMediaPlayer) Next() {
+	p.commands <- "next"
+}
+
+func startMQTTBridge(t *testing.T, bridge *MQTTBridge) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- bridge.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run returned error: %s", err)
+		}
+	})
+}
+
+func TestMQTTBridgePublishesStateAndDiscovery(t *testing.T) {
+	broker := newMQTTTestBroker(t)
+	watcher := watchMQTT(t, broker)
+
+	home := &apiTestScreen{name: "home"}
+	settings := &apiTestScreen{name: "media player setting"}
+	web := deskpad.NewWebSurface()
+	deck := deskpad.NewDeck(home)
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+
+	wc := &weatherTestController{}
+	wc.publish(&weatherv1.WeatherReading{TempC: 21.5, Condition: weatherv1.WeatherCondition_WEATHER_CONDITION_SUNNY})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bridge := &MQTTBridge{
+		cfg: MQTTConfig{
+			Broker:          broker.URL(),
+			ClientID:        "deskpadd",
+			TopicPrefix:     "deskpad",
+			DiscoveryPrefix: "homeassistant",
+			NodeID:          "desk",
+		},
+		d:       deck,
+		web:     web,
+		catalog: wsTestCatalog{home, settings},
+		mpc:     apiTestMediaPlayer{playing: true, item: &ui.MediaItem{ID: "track-1", Title: "Song"}},
+		wc:      wc,
+		bt:      &mqttTestBluetooth{},
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- bridge.Run(ctx)
+	}()
+
+	watcher.waitForPayload(t, "deskpad/status", "online")
+	watcher.waitForPayload(t, "deskpad/screen", "home")
+	watcher.waitForPayload(t, "deskpad/media/state", "playing")
+	watcher.waitForPayload(t, "deskpad/bluetooth", `{"connected":[]}`)
+
+	var item MediaItem
+	json.Unmarshal([]byte(watcher.waitFor(t, "deskpad/media/now_playing", func(string) bool { return true })), &item)
+	if item.Title != "Song" {
+		t.Fatalf("now playing = %+v, want Song", item)
+	}
+
+	wc.publish(&weatherv1.WeatherReading{TempC: 18, Condition: weatherv1.WeatherCondition_WEATHER_CONDITION_RAIN})
+	watcher.waitFor(t, "deskpad/weather", func(payload string) bool {
+		var reading WeatherResponse
+		return json.Unmarshal([]byte(payload), &reading) == nil && reading.TempC == 18 && reading.Condition == "rain"
+	})
+
+	var screenConfig mqttDiscoveryConfig
+	json.Unmarshal([]byte(watcher.waitFor(t, "homeassistant/select/desk/screen/config", func(string) bool { return true })), &screenConfig)
+	if screenConfig.CommandTopic != "deskpad/screen/set" || len(screenConfig.Options) != 2 || screenConfig.Options[1] != "media player setting" {
+		t.Fatalf("screen config = %+v, want both screens settable", screenConfig)
+	}
+	if screenConfig.AvailabilityTopic != "deskpad/status" || screenConfig.UniqueID != "desk_screen" || screenConfig.Device.Identifiers[0] != "desk" {
+		t.Fatalf("screen config = %+v, want the deck availability and device", screenConfig)
+	}
+
+	var buttonConfig mqttDiscoveryConfig
+	json.Unmarshal([]byte(watcher.waitFor(t, "homeassistant/button/desk/next/config", func(string) bool { return true })), &buttonConfig)
+	if buttonConfig.CommandTopic != "deskpad/media/set" || buttonConfig.PayloadPress != "next" {
+		t.Fatalf("next button config = %+v, want it to send next", buttonConfig)
+	}
+	watcher.waitFor(t, "homeassistant/sensor/desk/temperature/config", func(string) bool { return true })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	watcher.waitForPayload(t, "deskpad/status", "offline")
+}
+
+func TestMQTTBridgePublishesBluetoothChanges(t *testing.T) {
+	broker := newMQTTTestBroker(t)
+	watcher := watchMQTT(t, broker)
+
+	bt := &mqttTestBluetooth{}
+	bridge := &MQTTBridge{cfg: MQTTConfig{TopicPrefix: "deskpad"}, bt: bt, client: watcher.client}
+
+	bridge.publishBluetooth()
+	watcher.waitForPayload(t, "deskpad/bluetooth", `{"connected":[]}`)
+
+	headphones := controllers.BluetoothDevice{Address: "AA:BB:CC:DD:EE:FF", Name: "Headphones"}
+	bt.set(nil, headphones)
+	bridge.publishBluetooth()
+	watcher.waitForPayload(t, "deskpad/bluetooth", `{"connected":["Headphones"]}`)
+
+	// A failed lookup keeps the last published state rather than reporting everything as disconnected.
+	bt.set(errors.New("adapter unavailable"))
+	bridge.publishBluetooth()
+	if got := bridge.published["deskpad/bluetooth"]; got != `{"connected":["Headphones"]}` {
+		t.Fatalf("published = %q after a failed lookup, want the headphones still connected", got)
+	}
+
+	bt.set(nil)
+	bridge.publishBluetooth()
+	watcher.waitForPayload(t, "deskpad/bluetooth", `{"connected":[]}`)
+}
+
+func TestMQTTBridgeHandlesCommands(t *testing.T) {
+	broker := newMQTTTestBroker(t)
+	watcher := watchMQTT(t, broker)
+
+	home := &mqttTestScreen{apiTestScreen: apiTestScreen{name: "home"}, presses: make(chan mqttTestKeyPress, 1)}
+	settings := &apiTestScreen{name: "media player setting"}
+	web := deskpad.NewWebSurface()
+	deck := deskpad.NewDeck(home)
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+	mpc := &mqttTestMediaPlayer{commands: make(chan string, 1)}
+
+	startMQTTBridge(t, &MQTTBridge{
+		cfg:     MQTTConfig{Broker: broker.URL(), ClientID: "deskpadd", TopicPrefix: "deskpad"},
+		d:       deck,
+		web:     web,
+		catalog: wsTestCatalog{home, settings},
+		mpc:     mpc,
+	})
+	watcher.waitForPayload(t, "deskpad/screen", "home")
+
+	watcher.send(t, "deskpad/key/press", "4")
+	watcher.send(t, "deskpad/key/press", `{"key": 2, "type": "long"}`)
+	for _, want := range []mqttTestKeyPress{{4, deskpad.KeyPressShort}, {2, deskpad.KeyPressLong}} {
+		select {
+		case press := <-home.presses:
+			if press != want {
+				t.Fatalf("key press = %+v, want %+v", press, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for key press %+v", want)
+		}
+	}
+
+	watcher.send(t, "deskpad/media/set", "next")
+	select {
+	case command := <-mpc.commands:
+		if command != "next" {
+			t.Fatalf("media command = %q, want next", command)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the media command")
+	}
+
+	watcher.send(t, "deskpad/screen/set", "unknown")
+	watcher.send(t, "deskpad/screen/set", "media player setting")
+	watcher.waitForPayload(t, "deskpad/screen", "media player setting")
+	if deck.Screen() != settings {
+		t.Fatalf("screen = %s, want media player setting", deck.Screen().Name())
+	}
+
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	for topic := range watcher.messages {
+		if strings.HasPrefix(topic, "homeassistant/") {
+			t.Fatalf("discovery published to %s while disabled", topic)
+		}
+	}
+}
+
+func TestParseMQTTKeyPress(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    MQTTKeyPress
+		wantErr bool
+	}{
+		{payload: " 3\n", want: MQTTKeyPress{Key: 3, Type: "short"}},
+		{payload: `{"key": 5}`, want: MQTTKeyPress{Key: 5, Type: "short"}},
+		{payload: `{"key": 5, "type": "long"}`, want: MQTTKeyPress{Key: 5, Type: "long"}},
+		{payload: `{"key": 5, "type": "double"}`, wantErr: true},
+		{payload: "press", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseMQTTKeyPress([]byte(test.payload))
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseMQTTKeyPress(%q) = %+v, %v; want %+v, error %t", test.payload, got, err, test.want, test.wantErr)
+		}
+	}
+}
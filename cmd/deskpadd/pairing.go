@@ -0,0 +1,505 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/logging"
+)
+
+var pairingLogger = logging.Logger("pairing")
+
+const (
+	pairingCodeDigits  = 6
+	pairingCodeTTL     = 2 * time.Minute
+	maxPairingAttempts = 5
+	maxDeviceNameLen   = 64
+
+	// pairingLockout is how long pairing can't be started after a code is cancelled for too many invalid guesses.
+	// It doubles with each cancellation until a device pairs, up to maxPairingLockout.
+	pairingLockout    = 5 * time.Minute
+	maxPairingLockout = 2 * time.Hour
+	// pairingGuessWindow is how long invalid guesses count against the client which made them. A client which
+	// makes maxPairingAttempts invalid guesses can't guess again until the window ends, whichever code is shown.
+	pairingGuessWindow = 30 * time.Minute
+)
+
+// pairedDeviceScopes are granted to devices paired through the web UI.
+var pairedDeviceScopes = []TokenScope{ScopeKeys, ScopeMedia}
+
+var (
+	// ErrPairingUnavailable is returned when there is nowhere to store paired device tokens.
+	ErrPairingUnavailable = errors.New("pairing unavailable")
+	// ErrNoPairing is returned when completing pairing while no pairing code is being shown.
+	ErrNoPairing = errors.New("no pairing in progress")
+	// ErrPairingCodeInvalid is returned when the supplied code doesn't match the code shown on the deck.
+	ErrPairingCodeInvalid = errors.New("invalid pairing code")
+	// ErrPairingLocked is matched by a PairingLockedError.
+	ErrPairingLocked = errors.New("too many invalid pairing codes")
+)
+
+// PairingLockedError is returned while pairing is locked out after too many invalid codes.
+type PairingLockedError struct {
+	Until time.Time
+}
+
+func (e *PairingLockedError) Error() string {
+	return fmt.Sprintf("%s; try again after %s", ErrPairingLocked, e.Until.Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrPairingLocked.
+func (e *PairingLockedError) Is(target error) bool {
+	return target == ErrPairingLocked
+}
+
+// pairingGuesses counts the invalid codes a client has sent since the start of its window.
+type pairingGuesses struct {
+	count int
+	since time.Time
+}
+
+// Pairer issues device tokens to clients which enter the code shown on the deck.
+type Pairer struct {
+	d      *deskpad.Deck
+	tokens *TokenFile
+	screen deskpad.Screen
+
+	lock     sync.Mutex
+	code     string
+	expires  time.Time
+	attempts int
+	previous deskpad.Screen
+	timer    *time.Timer
+
+	// lockedUntil is when pairing can next be started after a code was cancelled; lockouts counts the
+	// cancellations since a device last paired.
+	lockedUntil time.Time
+	lockouts    int
+	// guesses holds the invalid codes sent by each client host.
+	guesses map[string]*pairingGuesses
+}
+
+// NewPairer creates a pairer which stores the issued tokens in the token file.
+func NewPairer(d *deskpad.Deck, tokens *TokenFile) *Pairer {
+	return &Pairer{
+		d:      d,
+		tokens: tokens,
+	}
+}
+
+// SetScreen configures the screen which displays the pairing code.
+func (p *Pairer) SetScreen(screen deskpad.Screen) {
+	p.screen = screen
+}
+
+// Start shows a pairing code on the deck, returning when it expires. If a code is already being shown it is
+// kept, so a second client can't replace the code the user is reading. A PairingLockedError is returned while
+// pairing is locked out after a code was cancelled.
+func (p *Pairer) Start(ctx context.Context) (time.Time, error) {
+	if p.tokens == nil || p.screen == nil {
+		return time.Time{}, ErrPairingUnavailable
+	}
+
+	p.lock.Lock()
+	if time.Now().Before(p.lockedUntil) {
+		until := p.lockedUntil
+		p.lock.Unlock()
+		return time.Time{}, &PairingLockedError{Until: until}
+	}
+	if p.code != "" {
+		expires := p.expires
+		p.lock.Unlock()
+		return expires, nil
+	}
+
+	code, err := newPairingCode()
+	if err != nil {
+		p.lock.Unlock()
+		return time.Time{}, err
+	}
+
+	p.code = code
+	p.expires = time.Now().Add(pairingCodeTTL)
+	p.attempts = 0
+	p.previous = p.d.Screen()
+	if p.previous == p.screen {
+		p.previous = nil
+	}
+	p.timer = time.AfterFunc(pairingCodeTTL, p.expire)
+	expires := p.expires
+	p.lock.Unlock()
+
+	p.d.ChangeScreen(ctx, p.screen)
+	return expires, nil
+}
+
+// Complete checks the code sent from the remote address and issues a token for the named device. The pairing
+// code is discarded once it has been used, or after too many wrong guesses, which also locks out pairing for a
+// while. A client which sends too many wrong codes gets a PairingLockedError until its guesses expire.
+func (p *Pairer) Complete(ctx context.Context, remoteAddr string, code string, name string) (string, error) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	now := time.Now()
+
+	p.lock.Lock()
+	guesses := p.guesses[host]
+	if guesses != nil && now.Sub(guesses.since) >= pairingGuessWindow {
+		guesses = nil
+	}
+	if guesses != nil && guesses.count >= maxPairingAttempts {
+		p.lock.Unlock()
+		return "", &PairingLockedError{Until: guesses.since.Add(pairingGuessWindow)}
+	}
+	if p.code == "" {
+		p.lock.Unlock()
+		return "", ErrNoPairing
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(p.code)) != 1 {
+		if guesses == nil {
+			guesses = &pairingGuesses{since: now}
+		}
+		guesses.count++
+		p.recordGuessesLocked(host, guesses, now)
+
+		p.attempts++
+		if p.attempts < maxPairingAttempts {
+			p.lock.Unlock()
+			return "", ErrPairingCodeInvalid
+		}
+
+		p.lockouts++
+		lockout := pairingLockout
+		for i := 1; i < p.lockouts && lockout < maxPairingLockout; i++ {
+			lockout *= 2
+		}
+		p.lockedUntil = now.Add(min(lockout, maxPairingLockout))
+
+		pairingLogger.Warn("pairing cancelled after too many invalid codes", "attempts", p.attempts, "lockedUntil", p.lockedUntil)
+		previous := p.endLocked()
+		p.lock.Unlock()
+		p.restoreScreen(ctx, previous)
+		return "", ErrPairingCodeInvalid
+	}
+
+	// Check the name is free before using up the code, so the user can pick another name.
+	if p.deviceExists(name) {
+		p.lock.Unlock()
+		return "", ErrTokenExists
+	}
+
+	previous := p.endLocked()
+	p.lockouts = 0
+	p.lock.Unlock()
+	p.restoreScreen(ctx, previous)
+
+	secret, err := p.tokens.MintPaired(name, pairedDeviceScopes)
+	if err != nil {
+		return "", err
+	}
+
+	pairingLogger.Info("paired device", "device", name)
+	return secret, nil
+}
+
+// PairingCode returns the code being shown, or an empty string if pairing isn't in progress.
+func (p *Pairer) PairingCode() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.code
+}
+
+// CancelPairing discards the code being shown. The pairing screen navigates away itself.
+func (p *Pairer) CancelPairing() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.endLocked()
+}
+
+// recordGuessesLocked stores the guesses made by the host, dropping the expired guesses of other hosts.
+func (p *Pairer) recordGuessesLocked(host string, guesses *pairingGuesses, now time.Time) {
+	if p.guesses == nil {
+		p.guesses = map[string]*pairingGuesses{}
+	}
+	for h, g := range p.guesses {
+		if now.Sub(g.since) >= pairingGuessWindow {
+			delete(p.guesses, h)
+		}
+	}
+	p.guesses[host] = guesses
+}
+
+func (p *Pairer) deviceExists(name string) bool {
+	tokens, err := p.tokens.Tokens()
+	if err != nil {
+		return false
+	}
+	for _, token := range tokens {
+		if token.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pairer) expire() {
+	p.lock.Lock()
+	if p.code == "" || time.Now().Before(p.expires) {
+		p.lock.Unlock()
+		return
+	}
+	previous := p.endLocked()
+	p.lock.Unlock()
+
+	p.restoreScreen(context.Background(), previous)
+}
+
+func (p *Pairer) endLocked() deskpad.Screen {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+
+	previous := p.previous
+	p.code = ""
+	p.attempts = 0
+	p.previous = nil
+	return previous
+}
+
+// restoreScreen returns the deck to the screen shown before pairing, unless the user has since navigated away.
+func (p *Pairer) restoreScreen(ctx context.Context, previous deskpad.Screen) {
+	if previous == nil || p.d.Screen() != p.screen {
+		return
+	}
+	p.d.ChangeScreen(ctx, previous)
+}
+
+func newPairingCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < pairingCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", pairingCodeDigits, n), nil
+}
+
+// PairingStartResponse is returned when pairing starts.
+type PairingStartResponse struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PairingCompleteRequest contains the code shown on the deck and a name for the device being paired.
+type PairingCompleteRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// PairingCompleteResponse contains the token issued to a paired device. It can't be retrieved again.
+type PairingCompleteResponse struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// PairedDevice describes a device paired through the web UI.
+type PairedDevice struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// PairedDevicesResponse lists the paired devices.
+type PairedDevicesResponse struct {
+	Devices []PairedDevice `json:"devices"`
+}
+
+// Pairing handles the pairing endpoints:
+//
+//	POST /api/pairing            shows a pairing code on the deck
+//	POST /api/pairing/complete   {"code": "123456", "name": "phone"}
+//
+// Neither requires a token; the code can only be read by someone standing at the deck.
+func (a *API) Pairing(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/pairing" && r.URL.Path != "/api/pairing/complete" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.pairer == nil {
+		http.Error(w, "pairing unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path == "/api/pairing" {
+		expires, err := a.pairer.Start(r.Context())
+		if lockedErr := (*PairingLockedError)(nil); errors.As(err, &lockedErr) {
+			writePairingLocked(w, lockedErr)
+			return
+		} else if errors.Is(err, ErrPairingUnavailable) {
+			http.Error(w, "pairing unavailable", http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			pairingLogger.Error("unable to start pairing", "err", err)
+			http.Error(w, "unable to start pairing", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(PairingStartResponse{ExpiresAt: expires})
+		return
+	}
+
+	var req PairingCompleteRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxDeviceNameLen {
+		http.Error(w, "invalid device name", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := a.pairer.Complete(r.Context(), r.RemoteAddr, strings.TrimSpace(req.Code), req.Name)
+	lockedErr := (*PairingLockedError)(nil)
+	switch {
+	case errors.As(err, &lockedErr):
+		pairingLogger.Warn("pairing code rejected from locked out client", "remote", r.RemoteAddr)
+		writePairingLocked(w, lockedErr)
+		return
+	case errors.Is(err, ErrNoPairing):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrPairingCodeInvalid):
+		pairingLogger.Warn("invalid pairing code", "remote", r.RemoteAddr)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrTokenExists):
+		http.Error(w, "a device with that name already exists", http.StatusConflict)
+		return
+	case err != nil:
+		pairingLogger.Error("unable to pair device", "device", req.Name, "err", err)
+		http.Error(w, "unable to pair device", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PairingCompleteResponse{Name: req.Name, Token: secret})
+}
+
+// writePairingLocked tells the client when it can try pairing again.
+func writePairingLocked(w http.ResponseWriter, err *PairingLockedError) {
+	retryAfter := int(math.Ceil(time.Until(err.Until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+}
+
+// Devices handles the paired device endpoints:
+//
+//	GET    /api/devices
+//	DELETE /api/devices/{name}
+func (a *API) Devices(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/devices" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !a.authorize(w, r, ScopeAdmin) {
+			return
+		}
+
+		resp := PairedDevicesResponse{Devices: []PairedDevice{}}
+		if a.tokens != nil {
+			tokens, err := a.tokens.Tokens()
+			if err != nil {
+				pairingLogger.Error("unable to load tokens", "err", err)
+				http.Error(w, "unable to load devices", http.StatusInternalServerError)
+				return
+			}
+			for _, token := range tokens {
+				if token.Paired {
+					resp.Devices = append(resp.Devices, PairedDevice{Name: token.Name, Created: token.Created})
+				}
+			}
+		}
+		writeJSON(w, resp)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/devices/")
+	if !ok || strings.Contains(rest, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	name, err := url.PathUnescape(rest)
+	if err != nil || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorize(w, r, ScopeAdmin) {
+		return
+	}
+
+	if a.tokens == nil || !a.isPairedDevice(name) {
+		http.NotFound(w, r)
+		return
+	}
+	if err := a.tokens.Revoke(name); errors.Is(err, ErrTokenNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		pairingLogger.Error("unable to revoke device", "device", name, "err", err)
+		http.Error(w, "unable to revoke device", http.StatusInternalServerError)
+		return
+	}
+
+	pairingLogger.Info("revoked paired device", "device", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isPairedDevice checks the named token was issued through pairing, so tokens minted from the command line
+// can't be revoked here.
+func (a *API) isPairedDevice(name string) bool {
+	tokens, err := a.tokens.Tokens()
+	if err != nil {
+		return false
+	}
+	for _, token := range tokens {
+		if token.Name == name {
+			return token.Paired
+		}
+	}
+	return false
+}
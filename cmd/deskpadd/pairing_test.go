@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+func newPairingTestAPI(t *testing.T) (*API, *Pairer, deskpad.Screen) {
+	t.Helper()
+
+	home := &apiTestScreen{name: "home"}
+	tf := NewTokenFile(filepath.Join(t.TempDir(), "tokens.json"))
+	if _, err := tf.Mint("owner", []TokenScope{ScopeAdmin}); err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+
+	d := deskpad.NewDeck(home)
+	pairer := NewPairer(d, tf)
+	pairer.SetScreen(&apiTestScreen{name: "pairing"})
+	return &API{d: d, tokens: tf, pairer: pairer, authToken: "secret"}, pairer, home
+}
+
+func doPairingRequest(api *API, handler func(http.ResponseWriter, *http.Request), method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestPairingIssuesDeviceToken(t *testing.T) {
+	api, pairer, home := newPairingTestAPI(t)
+
+	if rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing/complete", `{"code":"000000","name":"phone"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("complete without pairing status = %d, want 409", rec.Code)
+	}
+
+	if rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("start status = %d, want 202", rec.Code)
+	}
+	if api.d.Screen().Name() != "pairing" {
+		t.Fatalf("screen = %q, want pairing", api.d.Screen().Name())
+	}
+	code := pairer.PairingCode()
+	if len(code) != pairingCodeDigits {
+		t.Fatalf("code = %q, want %d digits", code, pairingCodeDigits)
+	}
+
+	// A second start keeps the code already on the deck.
+	doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", "")
+	if pairer.PairingCode() != code {
+		t.Fatalf("restarting pairing replaced the code")
+	}
+
+	if rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing/complete", `{"code":"`+code+`","name":"owner"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate name status = %d, want 409", rec.Code)
+	}
+
+	rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing/complete", `{"code":"`+code+`","name":"phone"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("complete status = %d, want 201", rec.Code)
+	}
+	var resp PairingCompleteResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %s", err)
+	}
+	if api.d.Screen() != home {
+		t.Fatalf("screen after pairing = %q, want home", api.d.Screen().Name())
+	}
+
+	token, ok := api.tokens.Lookup(resp.Token)
+	if !ok || token.Name != "phone" || !token.Paired || !token.Allows(ScopeKeys) || token.Allows(ScopeAdmin) {
+		t.Fatalf("device token = %+v %t, want a paired phone token without admin", token, ok)
+	}
+
+	rec = doPairingRequest(api, api.Devices, http.MethodGet, "/api/devices", "")
+	var devices PairedDevicesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &devices); err != nil || len(devices.Devices) != 1 || devices.Devices[0].Name != "phone" {
+		t.Fatalf("devices = %s (%v), want only the phone", rec.Body.String(), err)
+	}
+
+	if rec := doPairingRequest(api, api.Devices, http.MethodDelete, "/api/devices/owner", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("revoking a cli token status = %d, want 404", rec.Code)
+	}
+	if rec := doPairingRequest(api, api.Devices, http.MethodDelete, "/api/devices/phone", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke status = %d, want 204", rec.Code)
+	}
+	if _, ok := api.tokens.Lookup(resp.Token); ok {
+		t.Fatalf("revoked device token is still valid")
+	}
+}
+
+func TestPairingEndsAfterTooManyInvalidCodes(t *testing.T) {
+	api, pairer, home := newPairingTestAPI(t)
+
+	doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", "")
+	code := pairer.PairingCode()
+	wrong := "1" + code[1:]
+	if wrong == code {
+		wrong = "2" + code[1:]
+	}
+
+	for i := 0; i < maxPairingAttempts; i++ {
+		if rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing/complete", `{"code":"`+wrong+`","name":"phone"}`); rec.Code != http.StatusForbidden {
+			t.Fatalf("attempt %d status = %d, want 403", i, rec.Code)
+		}
+	}
+
+	if pairer.PairingCode() != "" || api.d.Screen() != home {
+		t.Fatalf("pairing still active after %d invalid codes", maxPairingAttempts)
+	}
+	if rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing/complete", `{"code":"`+code+`","name":"phone"}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("complete after cancellation status = %d, want 429", rec.Code)
+	}
+}
+
+func TestPairingLocksOutRepeatedInvalidCodes(t *testing.T) {
+	api, pairer, home := newPairingTestAPI(t)
+
+	guess := func(remoteAddr string, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/pairing/complete", strings.NewReader(`{"code":"`+code+`","name":"phone"}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		api.Pairing(rec, req)
+		return rec
+	}
+	wrongCode := func() string {
+		code := pairer.PairingCode()
+		if code[0] == '1' {
+			return "2" + code[1:]
+		}
+		return "1" + code[1:]
+	}
+
+	doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", "")
+	wrong := wrongCode()
+	for i := 0; i < maxPairingAttempts; i++ {
+		guess("192.0.2.10:4000", wrong)
+	}
+
+	// Starting again straight away is refused, without showing the pairing screen.
+	rec := doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("start after cancellation status = %d retry-after %q, want 429 with retry-after", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if pairer.PairingCode() != "" || api.d.Screen() != home {
+		t.Fatalf("pairing started while locked out")
+	}
+	firstLockout := time.Until(pairer.lockedUntil)
+
+	// Once the lockout ends, the client which made the guesses stays locked out, even with a new code, from a
+	// different port, and with the right code.
+	pairer.lockedUntil = time.Time{}
+	doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", "")
+	if rec := guess("192.0.2.10:4001", pairer.PairingCode()); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("guess from locked out client status = %d, want 429", rec.Code)
+	}
+
+	// Other clients can keep guessing, but cancelling the code again locks pairing out for longer.
+	wrong = wrongCode()
+	for i := 0; i < maxPairingAttempts; i++ {
+		if rec := guess("192.0.2.11:4000", wrong); rec.Code != http.StatusForbidden {
+			t.Fatalf("attempt %d status = %d, want 403", i, rec.Code)
+		}
+	}
+	if secondLockout := time.Until(pairer.lockedUntil); secondLockout <= firstLockout {
+		t.Fatalf("second lockout = %s, want longer than %s", secondLockout, firstLockout)
+	}
+
+	pairer.lockedUntil = time.Time{}
+	doPairingRequest(api, api.Pairing, http.MethodPost, "/api/pairing", "")
+	if rec := guess("192.0.2.12:4000", pairer.PairingCode()); rec.Code != http.StatusCreated {
+		t.Fatalf("pairing from another client status = %d, want 201", rec.Code)
+	}
+	if pairer.lockouts != 0 {
+		t.Fatalf("lockouts = %d after pairing, want 0", pairer.lockouts)
+	}
+}
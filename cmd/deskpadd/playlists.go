@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+const (
+	defaultPlaylistPageSize = 50
+	maxPlaylistPageSize     = 200
+)
+
+// PlaylistResponse describes a single playlist. IconURL is null for playlists without an icon.
+type PlaylistResponse struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	IconURL *string `json:"iconUrl"`
+	// Static is true for the configured playlists, which can be edited.
+	Static bool `json:"static"`
+}
+
+// PlaylistsResponse is a page of playlists returned by GET /api/playlists.
+type PlaylistsResponse struct {
+	Playlists []PlaylistResponse `json:"playlists"`
+	Offset    int                `json:"offset"`
+	Limit     int                `json:"limit"`
+	Total     int                `json:"total"`
+}
+
+// PlaylistRequest creates or renames a static playlist.
+type PlaylistRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Playlists handles the playlist collection:
+//
+//	GET  /api/playlists?offset=0&limit=50[&source=static]
+//	POST /api/playlists               {"id": "...", "name": "..."}
+//
+// and individual playlists:
+//
+//	PUT    /api/playlists/{id}        {"name": "..."}
+//	DELETE /api/playlists/{id}
+//	GET    /api/playlists/{id}/icon
+//	POST   /api/playlists/{id}/play
+//	POST   /api/playlists/{id}/move   {"position": n}
+//
+// Only static playlists can be modified.
+func (a *API) Playlists(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/playlists" {
+		a.playlistCollection(w, r)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/playlists/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := url.PathUnescape(parts[0])
+	if err != nil || id == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		a.playlist(w, r, id)
+	case "icon":
+		a.playlistIcon(w, r, id)
+	case "play":
+		a.playPlaylist(w, r, id)
+	case "move":
+		a.movePlaylist(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *API) playlistCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !a.authorizeRead(w, r) {
+			return
+		}
+		if a.mplc == nil {
+			writeJSON(w, PlaylistsResponse{Playlists: []PlaylistResponse{}})
+			return
+		}
+
+		offset, limit, ok := parsePage(r.URL.Query())
+		if !ok {
+			http.Error(w, "invalid paging parameters", http.StatusBadRequest)
+			return
+		}
+
+		var playlists []ui.MediaPlaylist
+		switch r.URL.Query().Get("source") {
+		case "":
+			playlists = a.mplc.GetPlaylists(a.mplc.PlaylistCount(), 0)
+		case "static":
+			playlists = a.mplc.StaticPlaylists()
+		default:
+			http.Error(w, "invalid source", http.StatusBadRequest)
+			return
+		}
+
+		resp := PlaylistsResponse{Playlists: []PlaylistResponse{}, Offset: offset, Limit: limit, Total: len(playlists)}
+		// Clamp the offset first so adding the limit to a huge offset can't overflow.
+		start := min(offset, len(playlists))
+		for _, playlist := range playlists[start:min(start+limit, len(playlists))] {
+			resp.Playlists = append(resp.Playlists, a.playlistResponse(playlist))
+		}
+		writeJSON(w, resp)
+
+	case http.MethodPost:
+		if !a.authorize(w, r, ScopeAdmin) || !a.requirePlaylists(w) {
+			return
+		}
+
+		var req PlaylistRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.ID, req.Name = strings.TrimSpace(req.ID), strings.TrimSpace(req.Name)
+		if req.ID == "" || req.Name == "" {
+			http.Error(w, "id and name are required", http.StatusBadRequest)
+			return
+		}
+
+		playlist := ui.MediaPlaylist{ID: req.ID, Name: req.Name}
+		if err := a.mplc.AddStaticPlaylist(playlist); err != nil {
+			writePlaylistError(w, "add", req.ID, err)
+			return
+		}
+
+		w.Header().Set("Location", "/api/playlists/"+url.PathEscape(req.ID))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a.playlistResponse(playlist))
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) playlist(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		if !a.authorizeRead(w, r) || !a.requirePlaylists(w) {
+			return
+		}
+
+		playlist, ok := a.mplc.Playlist(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, a.playlistResponse(*playlist))
+
+	case http.MethodPut:
+		if !a.authorize(w, r, ScopeAdmin) || !a.requirePlaylists(w) {
+			return
+		}
+
+		var req PlaylistRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Name = strings.TrimSpace(req.Name); req.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := a.mplc.RenameStaticPlaylist(id, req.Name); err != nil {
+			writePlaylistError(w, "rename", id, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if !a.authorize(w, r, ScopeAdmin) || !a.requirePlaylists(w) {
+			return
+		}
+
+		if err := a.mplc.RemoveStaticPlaylist(id); err != nil {
+			writePlaylistError(w, "remove", id, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) playlistIcon(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeRead(w, r) || !a.requirePlaylists(w) {
+		return
+	}
+
+	playlist, ok := a.mplc.Playlist(id)
+	if !ok || playlist.Icon == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hash, data, err := keyImageCache.Encode(playlist.Icon)
+	if err != nil {
+		apiLogger.Error("unable to encode playlist icon", "playlist", id, "err", err)
+		http.Error(w, "unable to encode icon", http.StatusInternalServerError)
+		return
+	}
+
+	// Playlist icons can change when the playlists are refreshed, so clients must revalidate them.
+	writePNG(w, r, hash, data, "no-cache")
+}
+
+func (a *API) playPlaylist(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorize(w, r, ScopeMedia) || !a.requirePlaylists(w) {
+		return
+	}
+
+	if _, ok := a.mplc.Playlist(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	a.mplc.StartPlaylist(r.Context(), id)
+	a.d.RefreshScreen()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *API) movePlaylist(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorize(w, r, ScopeAdmin) || !a.requirePlaylists(w) {
+		return
+	}
+
+	var req struct {
+		Position *int `json:"position"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil || req.Position == nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.mplc.MoveStaticPlaylist(id, *req.Position); err != nil {
+		writePlaylistError(w, "move", id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) requirePlaylists(w http.ResponseWriter) bool {
+	if a.mplc == nil {
+		http.Error(w, "playlists unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func (a *API) playlistResponse(playlist ui.MediaPlaylist) PlaylistResponse {
+	resp := PlaylistResponse{
+		ID:     playlist.ID,
+		Name:   playlist.Name,
+		Static: a.mplc.IsStaticPlaylist(playlist.ID),
+	}
+	if playlist.Icon != nil {
+		iconURL := "/api/playlists/" + url.PathEscape(playlist.ID) + "/icon"
+		resp.IconURL = &iconURL
+	}
+	return resp
+}
+
+func writePlaylistError(w http.ResponseWriter, op string, id string, err error) {
+	switch {
+	case errors.Is(err, controllers.ErrPlaylistNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, controllers.ErrPlaylistExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		apiLogger.Error("unable to save playlist", "op", op, "playlist", id, "err", err)
+		http.Error(w, "unable to save playlists", http.StatusInternalServerError)
+	}
+}
+
+// parsePage reads the offset and limit query parameters, applying the default page size.
+func parsePage(query url.Values) (int, int, bool) {
+	offset, limit := 0, defaultPlaylistPageSize
+
+	if v := query.Get("offset"); v != "" {
+		var err error
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, false
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxPlaylistPageSize {
+			return 0, 0, false
+		}
+	}
+
+	return offset, limit, true
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+type playlistTestPlayback struct {
+	lock sync.Mutex
+	uris []string
+}
+
+func (p *playlistTestPlayback) PlayURI(ctx context.Context, uri string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.uris = append(p.uris, uri)
+	return nil
+}
+
+func (p *playlistTestPlayback) played() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]string(nil), p.uris...)
+}
+
+func newPlaylistTestAPI(t *testing.T, playlists []ui.MediaPlaylist) (*API, *controllers.PlaylistFile, *playlistTestPlayback) {
+	t.Helper()
+
+	playback := &playlistTestPlayback{}
+	store := controllers.NewPlaylistFile(filepath.Join(t.TempDir(), "playlists.json"))
+	mplc := controllers.NewMediaPlaylist(nil, playback, playlists)
+	mplc.SetPlaylistStore(store)
+
+	api := &API{d: deskpad.NewDeck(&apiTestScreen{name: "home"}), mplc: mplc, authToken: "secret"}
+	return api, store, playback
+}
+
+func doPlaylistRequest(api *API, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.Playlists(rec, req)
+	return rec
+}
+
+func TestPlaylistsArePaged(t *testing.T) {
+	var playlists []ui.MediaPlaylist
+	for i := 0; i < 5; i++ {
+		playlists = append(playlists, ui.MediaPlaylist{ID: fmt.Sprintf("spotify:playlist:%d", i), Name: fmt.Sprintf("Playlist %d", i)})
+	}
+	playlists[3].Icon = image.NewRGBA(image.Rect(0, 0, 4, 4))
+	api, _, _ := newPlaylistTestAPI(t, playlists)
+
+	rec := doPlaylistRequest(api, http.MethodGet, "/api/playlists?offset=2&limit=2", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var resp PlaylistsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %s", err)
+	}
+	if resp.Total != 5 || len(resp.Playlists) != 2 || resp.Playlists[0].ID != "spotify:playlist:2" {
+		t.Fatalf("page = %+v, want playlists 2 and 3 of 5", resp)
+	}
+	if resp.Playlists[0].IconURL != nil || resp.Playlists[1].IconURL == nil || !resp.Playlists[1].Static {
+		t.Fatalf("playlists = %+v, want an icon url for playlist 3 only", resp.Playlists)
+	}
+
+	icon := doPlaylistRequest(api, http.MethodGet, *resp.Playlists[1].IconURL, "")
+	if icon.Code != http.StatusOK || icon.Header().Get("Content-Type") != "image/png" || icon.Header().Get("ETag") == "" {
+		t.Fatalf("icon status = %d, headers %v; want a png with an etag", icon.Code, icon.Header())
+	}
+
+	if rec := doPlaylistRequest(api, http.MethodGet, "/api/playlists?limit=0", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid limit status = %d, want 400", rec.Code)
+	}
+
+	rec = doPlaylistRequest(api, http.MethodGet, "/api/playlists?offset=9223372036854775800&limit=50", "")
+	resp = PlaylistsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("huge offset status = %d (%v), want 200", rec.Code, err)
+	}
+	if resp.Total != 5 || len(resp.Playlists) != 0 {
+		t.Fatalf("page = %+v, want no playlists past the end", resp)
+	}
+}
+
+func TestPlaylistsCanBeEditedAndPlayed(t *testing.T) {
+	api, store, playback := newPlaylistTestAPI(t, []ui.MediaPlaylist{{ID: "spotify:playlist:a", Name: "A"}})
+
+	if rec := doPlaylistRequest(api, http.MethodPost, "/api/playlists", `{"id":"spotify:playlist:b","name":"B"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201", rec.Code)
+	}
+	if rec := doPlaylistRequest(api, http.MethodPost, "/api/playlists", `{"id":"spotify:playlist:b","name":"B"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate create status = %d, want 409", rec.Code)
+	}
+	if rec := doPlaylistRequest(api, http.MethodPost, "/api/playlists/spotify:playlist:b/move", `{"position":0}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("move status = %d, want 204", rec.Code)
+	}
+	if rec := doPlaylistRequest(api, http.MethodPut, "/api/playlists/spotify:playlist:a", `{"name":"Renamed"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("rename status = %d, want 204", rec.Code)
+	}
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if len(stored) != 2 || stored[0].ID != "spotify:playlist:b" || stored[1].Name != "Renamed" {
+		t.Fatalf("stored playlists = %+v, want b then renamed a", stored)
+	}
+
+	if rec := doPlaylistRequest(api, http.MethodPost, "/api/playlists/spotify:playlist:a/play", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("play status = %d, want 202", rec.Code)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(playback.played()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if played := playback.played(); len(played) != 1 || played[0] != "spotify:playlist:a" {
+		t.Fatalf("played = %v, want playlist a", played)
+	}
+
+	if rec := doPlaylistRequest(api, http.MethodDelete, "/api/playlists/spotify:playlist:a", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want 204", rec.Code)
+	}
+	if rec := doPlaylistRequest(api, http.MethodDelete, "/api/playlists/spotify:playlist:a", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("second delete status = %d, want 404", rec.Code)
+	}
+	if rec := doPlaylistRequest(api, http.MethodPost, "/api/playlists/spotify:playlist:a/play", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("play removed playlist status = %d, want 404", rec.Code)
+	}
+}
+
+func TestPlaylistWritesRequireToken(t *testing.T) {
+	api, store, _ := newPlaylistTestAPI(t, nil)
+
+	rec := httptest.NewRecorder()
+	api.Playlists(rec, httptest.NewRequest(http.MethodPost, "/api/playlists", strings.NewReader(`{"id":"x","name":"X"}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+	if _, err := store.Load(); err == nil {
+		t.Fatalf("playlists were saved without a token")
+	}
+}
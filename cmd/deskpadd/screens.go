@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+// ScreenResponse describes a single screen which can be navigated to. IconURL is null for screens without an icon.
+type ScreenResponse struct {
+	Name    string  `json:"name"`
+	IconURL *string `json:"iconUrl"`
+	// OnHome is set for screens reachable directly from a key on the home screen.
+	OnHome bool `json:"onHome"`
+	Active bool `json:"active"`
+}
+
+// ScreensResponse lists the navigable screens along with the path taken to reach the active screen.
+type ScreensResponse struct {
+	Current string           `json:"current"`
+	Path    []string         `json:"path"`
+	Screens []ScreenResponse `json:"screens"`
+}
+
+// Screens handles the screen navigation endpoints:
+//
+//	GET  /api/screens
+//	POST /api/screens/{name}/show
+func (a *API) Screens(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/screens" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !a.authorizeRead(w, r) {
+			return
+		}
+
+		a.writeScreens(w)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/screens/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 || parts[1] != "show" {
+		http.NotFound(w, r)
+		return
+	}
+	name, err := url.PathUnescape(parts[0])
+	if err != nil || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !a.authorize(w, r, ScopeKeys) {
+		return
+	}
+
+	screen := a.screenByName(name)
+	if screen == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	a.d.ChangeScreen(r.Context(), screen)
+	a.writeScreens(w)
+}
+
+func (a *API) screenByName(name string) deskpad.Screen {
+	if a.catalog == nil {
+		return nil
+	}
+
+	for _, screen := range a.catalog.Screens() {
+		if screen.Name() == name {
+			return screen
+		}
+	}
+	return nil
+}
+
+func (a *API) writeScreens(w http.ResponseWriter) {
+	current := a.d.Screen()
+	resp := ScreensResponse{
+		Current: current.Name(),
+		Path:    a.d.Path(),
+		Screens: []ScreenResponse{},
+	}
+
+	if a.catalog != nil {
+		for _, screen := range a.catalog.Screens() {
+			info := ScreenResponse{
+				Name:   screen.Name(),
+				OnHome: a.catalog.OnHome(screen),
+				Active: screen.Name() == current.Name(),
+			}
+			if icon := screen.Icon(); icon != nil {
+				src, err := imageURL(icon)
+				if err != nil {
+					apiLogger.Error("unable to encode screen icon", "screen", screen.Name(), "err", err)
+				} else {
+					info.IconURL = &src
+				}
+			}
+			resp.Screens = append(resp.Screens, info)
+		}
+	}
+
+	writeJSON(w, resp)
+}
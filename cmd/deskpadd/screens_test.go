@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+func doScreensRequest(t *testing.T, api *API, method string, path string) (int, ScreensResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.Screens(rec, req)
+
+	var resp ScreensResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal response: %s", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestScreensListsCatalog(t *testing.T) {
+	home := &apiTestScreen{name: "home"}
+	settings := &apiTestScreen{name: "media player setting"}
+	web := deskpad.NewWebSurface()
+	deck := deskpad.NewDeck(home)
+	deck.RegisterSurface(web)
+	api := &API{d: deck, web: web, catalog: wsTestCatalog{home, settings}, authToken: "secret"}
+
+	code, resp := doScreensRequest(t, api, http.MethodGet, "/api/screens")
+	if code != http.StatusOK || resp.Current != "home" || len(resp.Screens) != 2 {
+		t.Fatalf("screens = %d %+v, want two screens with home active", code, resp)
+	}
+	if !resp.Screens[0].OnHome || !resp.Screens[0].Active || resp.Screens[1].OnHome || resp.Screens[1].Active {
+		t.Fatalf("screens = %+v, want only home on home and active", resp.Screens)
+	}
+	if resp.Screens[1].IconURL == nil {
+		t.Fatalf("screen %+v has no icon url", resp.Screens[1])
+	}
+
+	rec := httptest.NewRecorder()
+	api.UIKeys(rec, httptest.NewRequest(http.MethodGet, *resp.Screens[1].IconURL, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("icon status = %d, content type %q; want a png", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestScreensShowChangesScreen(t *testing.T) {
+	home := &apiTestScreen{name: "home"}
+	settings := &apiTestScreen{name: "media player setting"}
+	deck := deskpad.NewDeck(home)
+	api := &API{d: deck, catalog: wsTestCatalog{home, settings}, authToken: "secret"}
+
+	code, resp := doScreensRequest(t, api, http.MethodPost, "/api/screens/media%20player%20setting/show")
+	if code != http.StatusOK || resp.Current != "media player setting" {
+		t.Fatalf("show = %d %+v, want the settings screen active", code, resp)
+	}
+	if !reflect.DeepEqual(resp.Path, []string{"home", "media player setting"}) {
+		t.Fatalf("path = %v, want home then settings", resp.Path)
+	}
+	if deck.Screen() != settings {
+		t.Fatalf("deck screen = %q, want media player setting", deck.Screen().Name())
+	}
+
+	if code, _ := doScreensRequest(t, api, http.MethodPost, "/api/screens/missing/show"); code != http.StatusNotFound {
+		t.Fatalf("unknown screen status = %d, want 404", code)
+	}
+	if code, _ := doScreensRequest(t, api, http.MethodGet, "/api/screens/home/show"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET show status = %d, want 405", code)
+	}
+
+	rec := httptest.NewRecorder()
+	api.Screens(rec, httptest.NewRequest(http.MethodPost, "/api/screens/home/show", nil))
+	if rec.Code != http.StatusUnauthorized || deck.Screen() != settings {
+		t.Fatalf("unauthorized show status = %d, screen %q; want 401 and no change", rec.Code, deck.Screen().Name())
+	}
+}
@@ -3,15 +3,19 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/metrics"
 	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
 	"golang.org/x/oauth2"
 )
 
+var spotifyAuthLogger = logging.Logger("spotify")
+
 const completeLoginPath = "/completeLogin"
 
 type spotifyAuthHandler struct {
@@ -25,9 +29,27 @@ type spotifyAuthHandler struct {
 
 func spotifyClientFromToken(token *oauth2.Token) *spotify.Client {
 	httpClient := spotifyauth.New().Client(context.Background(), token)
+	httpClient.Transport = &spotifyErrorCounter{next: httpClient.Transport}
 	return spotify.New(httpClient)
 }
 
+// spotifyErrorCounter counts the Spotify API requests which fail, so every controller using the client is covered.
+type spotifyErrorCounter struct {
+	next http.RoundTripper
+}
+
+func (c *spotifyErrorCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		metrics.SpotifyAPIErrors.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		metrics.SpotifyAPIErrors.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, nil
+}
+
 func newSpotifyAuthHander(port int) *spotifyAuthHandler {
 	redirectURI := fmt.Sprintf("http://127.0.0.1:%d%s", port, completeLoginPath)
 
@@ -52,20 +74,20 @@ func (h *spotifyAuthHandler) Token(ctx context.Context) *oauth2.Token {
 
 	http.HandleFunc(completeLoginPath, func(w http.ResponseWriter, r *http.Request) {
 		if st := r.FormValue("state"); st != h.authState {
-			log.Printf("mismatched state value: got %s, expected %s\n", st, h.authState)
+			spotifyAuthLogger.Warn("mismatched oauth state value", "got", st, "expected", h.authState)
 			http.NotFound(w, r)
 			return
 		}
 
 		tok, err := h.auth.Token(r.Context(), h.authState, r)
 		if err != nil {
-			log.Printf("unable to get spotify token: %s\n", err.Error())
+			spotifyAuthLogger.Error("unable to get spotify token", "err", err)
 			http.Error(w, "Couldn't get token", http.StatusForbidden)
 			return
 		}
 
 		h.token = tok
-		log.Print("received spotify token\n")
+		spotifyAuthLogger.Info("received spotify token")
 
 		w.Header().Set("Content-Type", "text/html")
 		fmt.Fprintf(w, "Login Successful")
@@ -80,7 +102,7 @@ func (h *spotifyAuthHandler) Token(ctx context.Context) *oauth2.Token {
 	go func() {
 		err := srv.ListenAndServe()
 		if err != nil && err != http.ErrServerClosed {
-			log.Fatalf("unable to start spotify listener: %s\n", err.Error())
+			logging.Fatal(spotifyAuthLogger, "unable to start spotify listener", "err", err)
 		}
 	}()
 
@@ -88,11 +110,11 @@ func (h *spotifyAuthHandler) Token(ctx context.Context) *oauth2.Token {
 	fmt.Printf("Please go to %s to log in\n", url)
 
 	<-cancelSrv
-	log.Printf("shutting down http listener and continuing\n")
+	spotifyAuthLogger.Info("shutting down spotify login listener")
 	srv.Shutdown(ctx)
 
 	if h.token == nil {
-		log.Fatal("oauth token wasn't populated; can't start\n")
+		logging.Fatal(spotifyAuthLogger, "oauth token wasn't populated; can't start")
 	}
 
 	return h.token
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rmrobinson/deskpad/logging"
+)
+
+var tlsLogger = logging.Logger("tls")
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+	// Certificates are replaced this long before they expire.
+	certRenewBefore = 30 * 24 * time.Hour
+)
+
+// LocalCA issues the self-signed certificates used when no certificate is configured. The CA and the server
+// certificate are kept in a directory so clients only need to trust the CA once.
+type LocalCA struct {
+	dir string
+}
+
+// NewLocalCA creates a local CA which keeps its keys and certificates in the supplied directory.
+func NewLocalCA(dir string) *LocalCA {
+	return &LocalCA{
+		dir: dir,
+	}
+}
+
+// CACertPath returns the path of the PEM encoded CA certificate, which clients should install to trust deskpadd.
+func (ca *LocalCA) CACertPath() string {
+	return filepath.Join(ca.dir, "ca.pem")
+}
+
+// Certificate returns a server certificate valid for the supplied hosts, creating the CA and certificate if
+// they don't exist yet. The certificate is reissued when it is close to expiring or doesn't cover the hosts.
+func (ca *LocalCA) Certificate(hosts []string) (tls.Certificate, error) {
+	if err := os.MkdirAll(ca.dir, 0o700); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	caCert, caKey, err := ca.loadOrCreateCA()
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load local ca: %w", err)
+	}
+
+	certPath := filepath.Join(ca.dir, "cert.pem")
+	keyPath := filepath.Join(ca.dir, "key.pem")
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil && certificateCurrent(cert.Leaf, caCert, hosts) {
+		return cert, nil
+	}
+
+	tlsLogger.Info("issuing self-signed certificate", "hosts", hosts)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template, err := certificateTemplate("deskpad", certValidity)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0o644); err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0o600); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+func (ca *LocalCA) loadOrCreateCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	keyPath := filepath.Join(ca.dir, "ca-key.pem")
+
+	pair, err := tls.LoadX509KeyPair(ca.CACertPath(), keyPath)
+	if err == nil && time.Now().Add(certRenewBefore).Before(pair.Leaf.NotAfter) {
+		if key, ok := pair.PrivateKey.(*ecdsa.PrivateKey); ok {
+			return pair.Leaf, key, nil
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	tlsLogger.Info("creating local certificate authority", "dir", ca.dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template, err := certificateTemplate("deskpad local CA", caValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.MaxPathLenZero = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0o600); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(ca.CACertPath(), "CERTIFICATE", der, 0o644); err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+// certificateCurrent checks the certificate was issued by the CA, isn't close to expiring and covers every host.
+func certificateCurrent(cert *x509.Certificate, caCert *x509.Certificate, hosts []string) bool {
+	if cert == nil || cert.CheckSignatureFrom(caCert) != nil || time.Now().Add(certRenewBefore).After(cert.NotAfter) {
+		return false
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if !slices.ContainsFunc(cert.IPAddresses, ip.Equal) {
+				return false
+			}
+		} else if !slices.Contains(cert.DNSNames, host) {
+			return false
+		}
+	}
+	return true
+}
+
+func certificateTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"deskpad"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+func writePEM(path string, blockType string, der []byte, perm os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+// certificateHosts returns the names the self-signed certificate should cover: the configured hosts along with
+// this machine's hostname and loopback addresses.
+func certificateHosts(extra []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname, hostname+".local")
+	}
+
+	for _, host := range extra {
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// httpsRedirect redirects every request to the same path on the HTTPS listener at httpsAddr.
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// CACert handles GET /ca.pem, serving the local CA certificate so devices can be set up to trust deskpadd.
+func (a *API) CACert(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ca.pem" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.ca == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := os.ReadFile(a.ca.CACertPath())
+	if err != nil {
+		tlsLogger.Error("unable to read ca certificate", "err", err)
+		http.Error(w, "unable to read ca certificate", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="deskpad-ca.pem"`)
+	w.Write(data)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestLocalCAIssuesAndReusesCertificates(t *testing.T) {
+	ca := NewLocalCA(t.TempDir())
+
+	cert, err := ca.Certificate([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Certificate returned error: %s", err)
+	}
+
+	caPEM, err := os.ReadFile(ca.CACertPath())
+	if err != nil {
+		t.Fatalf("unable to read ca certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots}); err != nil {
+		t.Fatalf("certificate doesn't verify against the local ca: %s", err)
+	}
+
+	again, err := ca.Certificate([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("second Certificate returned error: %s", err)
+	}
+	if again.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) != 0 {
+		t.Fatalf("certificate was reissued although it covers the hosts")
+	}
+
+	// Adding a host reissues the certificate from the same CA.
+	reissued, err := ca.Certificate([]string{"localhost", "127.0.0.1", "deskpad.lan"})
+	if err != nil {
+		t.Fatalf("third Certificate returned error: %s", err)
+	}
+	if _, err := reissued.Leaf.Verify(x509.VerifyOptions{DNSName: "deskpad.lan", Roots: roots}); err != nil {
+		t.Fatalf("reissued certificate doesn't verify against the original ca: %s", err)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+		want string
+	}{
+		{addr: ":1337", host: "deskpad.lan", want: "https://deskpad.lan:1337/status?x=1"},
+		{addr: ":1337", host: "deskpad.lan:8080", want: "https://deskpad.lan:1337/status?x=1"},
+		{addr: ":443", host: "deskpad.lan:8080", want: "https://deskpad.lan/status?x=1"},
+		{addr: ":443", host: "[::1]:8080", want: "https://[::1]/status?x=1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/status?x=1", nil)
+		req.Host = test.host
+		rec := httptest.NewRecorder()
+		httpsRedirect(test.addr).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != test.want {
+			t.Fatalf("redirect from %s to %s = %d %q, want %q", test.host, test.addr, rec.Code, rec.Header().Get("Location"), test.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const tokenUsage = `usage:
+  deskpadd token mint [-scopes read,keys,media,admin] NAME
+  deskpadd token revoke NAME
+  deskpadd token list
+`
+
+// runTokenCommand mints, revokes and lists the API tokens stored in the token file, returning the exit code.
+func runTokenCommand(tf *TokenFile, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, tokenUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "mint":
+		fs := flag.NewFlagSet("token mint", flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		scopeList := fs.String("scopes", string(ScopeRead), "comma separated scopes granted to the token")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if fs.NArg() != 1 {
+			fmt.Fprint(stderr, tokenUsage)
+			return 2
+		}
+
+		var scopes []TokenScope
+		for _, name := range strings.Split(*scopeList, ",") {
+			scope, ok := ParseTokenScope(strings.TrimSpace(name))
+			if !ok {
+				fmt.Fprintf(stderr, "unknown scope %q\n", name)
+				return 2
+			}
+			scopes = append(scopes, scope)
+		}
+
+		secret, err := tf.Mint(fs.Arg(0), scopes)
+		if err != nil {
+			fmt.Fprintf(stderr, "unable to mint token %s: %s\n", fs.Arg(0), err.Error())
+			return 1
+		}
+
+		// The secret is only shown once; only its hash is stored.
+		fmt.Fprintln(stdout, secret)
+		return 0
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprint(stderr, tokenUsage)
+			return 2
+		}
+
+		if err := tf.Revoke(args[1]); errors.Is(err, ErrTokenNotFound) {
+			fmt.Fprintf(stderr, "no token named %s\n", args[1])
+			return 1
+		} else if err != nil {
+			fmt.Fprintf(stderr, "unable to revoke token %s: %s\n", args[1], err.Error())
+			return 1
+		}
+		return 0
+
+	case "list":
+		tokens, err := tf.Tokens()
+		if err != nil {
+			fmt.Fprintf(stderr, "unable to list tokens: %s\n", err.Error())
+			return 1
+		}
+
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSCOPES\tPAIRED\tCREATED")
+		for _, token := range tokens {
+			scopes := make([]string, 0, len(token.Scopes))
+			for _, scope := range token.Scopes {
+				scopes = append(scopes, string(scope))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", token.Name, strings.Join(scopes, ","), token.Paired, token.Created.Format(time.RFC3339))
+		}
+		tw.Flush()
+		return 0
+	}
+
+	fmt.Fprint(stderr, tokenUsage)
+	return 2
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TokenScope limits what an API token may do.
+type TokenScope string
+
+const (
+	// ScopeRead allows reading the deck, media and settings state. Every token has this scope.
+	ScopeRead TokenScope = "read"
+	// ScopeKeys allows pressing keys, turning dials and changing screens.
+	ScopeKeys TokenScope = "keys"
+	// ScopeMedia allows controlling playback, playing playlists and changing audio outputs.
+	ScopeMedia TokenScope = "media"
+	// ScopeAdmin allows everything, including editing the playlists.
+	ScopeAdmin TokenScope = "admin"
+)
+
+var (
+	// ErrTokenExists is returned when minting a token with a name which is already in use.
+	ErrTokenExists = errors.New("token already exists")
+	// ErrTokenNotFound is returned when revoking a token which doesn't exist.
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// ParseTokenScope returns the scope with the supplied name.
+func ParseTokenScope(name string) (TokenScope, bool) {
+	switch scope := TokenScope(name); scope {
+	case ScopeRead, ScopeKeys, ScopeMedia, ScopeAdmin:
+		return scope, true
+	}
+	return "", false
+}
+
+// APIToken is a named API token. Only a hash of the token is kept.
+type APIToken struct {
+	Name    string       `json:"name"`
+	Hash    string       `json:"hash"`
+	Scopes  []TokenScope `json:"scopes"`
+	Created time.Time    `json:"created"`
+	// Paired is set for tokens issued to devices through the pairing flow.
+	Paired bool `json:"paired,omitempty"`
+}
+
+// Allows returns whether the token grants the scope.
+func (t *APIToken) Allows(scope TokenScope) bool {
+	if scope == ScopeRead {
+		return true
+	}
+
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// hashToken returns the hash stored for a token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// TokenFile stores the API tokens as JSON in a file on disk. The file is reloaded when it changes so tokens
+// minted or revoked from the command line take effect without a restart.
+type TokenFile struct {
+	path string
+
+	lock    sync.Mutex
+	tokens  []APIToken
+	modTime time.Time
+	size    int64
+}
+
+// NewTokenFile creates a token store backed by the file at the supplied path.
+func NewTokenFile(path string) *TokenFile {
+	return &TokenFile{
+		path: path,
+	}
+}
+
+// Tokens returns the stored tokens. A missing file has no tokens.
+func (f *TokenFile) Tokens() ([]APIToken, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return append([]APIToken(nil), f.tokens...), nil
+}
+
+// Lookup returns the stored token matching the supplied secret.
+func (f *TokenFile) Lookup(secret string) (*APIToken, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.reloadLocked(); err != nil {
+		return nil, false
+	}
+
+	hash := []byte(hashToken(secret))
+	for i := range f.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(f.tokens[i].Hash)) == 1 {
+			token := f.tokens[i]
+			return &token, true
+		}
+	}
+	return nil, false
+}
+
+// Mint creates a token with the supplied name and scopes, returning the secret. The secret is not stored and
+// can't be retrieved again.
+func (f *TokenFile) Mint(name string, scopes []TokenScope) (string, error) {
+	return f.mint(APIToken{Name: name, Scopes: scopes})
+}
+
+// MintPaired creates a token for a device paired through the web UI, returning the secret.
+func (f *TokenFile) MintPaired(name string, scopes []TokenScope) (string, error) {
+	return f.mint(APIToken{Name: name, Scopes: scopes, Paired: true})
+}
+
+func (f *TokenFile) mint(token APIToken) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.reloadLocked(); err != nil {
+		return "", err
+	}
+	for _, existing := range f.tokens {
+		if existing.Name == token.Name {
+			return "", ErrTokenExists
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+
+	token.Hash = hashToken(secret)
+	token.Created = time.Now().UTC().Truncate(time.Second)
+
+	tokens := append(append([]APIToken(nil), f.tokens...), token)
+	if err := f.saveLocked(tokens); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Revoke removes the token with the supplied name.
+func (f *TokenFile) Revoke(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.reloadLocked(); err != nil {
+		return err
+	}
+
+	tokens := make([]APIToken, 0, len(f.tokens))
+	for _, token := range f.tokens {
+		if token.Name != name {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == len(f.tokens) {
+		return ErrTokenNotFound
+	}
+
+	return f.saveLocked(tokens)
+}
+
+func (f *TokenFile) reloadLocked() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.tokens = nil
+		f.modTime = time.Time{}
+		f.size = 0
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if f.tokens != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	var tokens []APIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", f.path, err)
+	}
+
+	f.tokens = append([]APIToken{}, tokens...)
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return nil
+}
+
+// saveLocked replaces the stored tokens. The file is replaced atomically so a failed write doesn't lose the
+// previous tokens.
+func (f *TokenFile) saveLocked(tokens []APIToken) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+
+	// Force a reload, as the modification time may not have changed on coarse grained filesystems.
+	f.tokens = nil
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+func TestTokenFileMintLookupAndRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tf := NewTokenFile(path)
+
+	secret, err := tf.Mint("kitchen", []TokenScope{ScopeMedia})
+	if err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+	if _, err := tf.Mint("kitchen", nil); !errors.Is(err, ErrTokenExists) {
+		t.Fatalf("duplicate Mint error = %v, want ErrTokenExists", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read token file: %s", err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Fatalf("token file contains the secret")
+	}
+
+	// A separate instance stands in for the running daemon picking up changes made from the command line.
+	daemon := NewTokenFile(path)
+	token, ok := daemon.Lookup(secret)
+	if !ok || token.Name != "kitchen" || !token.Allows(ScopeMedia) || !token.Allows(ScopeRead) || token.Allows(ScopeKeys) {
+		t.Fatalf("Lookup = %+v %t, want the kitchen token with media and read scopes", token, ok)
+	}
+	if _, ok := daemon.Lookup("wrong"); ok {
+		t.Fatalf("Lookup accepted the wrong secret")
+	}
+
+	if err := tf.Revoke("kitchen"); err != nil {
+		t.Fatalf("Revoke returned error: %s", err)
+	}
+	if _, ok := daemon.Lookup(secret); ok {
+		t.Fatalf("Lookup accepted a revoked token")
+	}
+	if err := tf.Revoke("kitchen"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("second Revoke error = %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestTokenScopesLimitEndpoints(t *testing.T) {
+	tf := NewTokenFile(filepath.Join(t.TempDir(), "tokens.json"))
+	keys, err := tf.Mint("keys", []TokenScope{ScopeKeys})
+	if err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+	admin, err := tf.Mint("admin", []TokenScope{ScopeAdmin})
+	if err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+
+	screen := &apiTestScreen{name: "home", action: deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop}}
+	api := &API{d: deskpad.NewDeck(screen), mpc: apiTestMediaPlayer{}, tokens: tf}
+
+	press := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/ui/keys/0/press", strings.NewReader(`{"type":"short"}`))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		api.UIPressKey(rec, req)
+		return rec.Code
+	}
+	play := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/media/play", nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		api.Media(rec, req)
+		return rec.Code
+	}
+
+	if code := press(keys); code != http.StatusNoContent {
+		t.Fatalf("keys token press status = %d, want 204", code)
+	}
+	if code := play(keys); code != http.StatusForbidden {
+		t.Fatalf("keys token media status = %d, want 403", code)
+	}
+	if code := play(admin); code != http.StatusNoContent {
+		t.Fatalf("admin token media status = %d, want 204", code)
+	}
+	if code := press("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown token press status = %d, want 401", code)
+	}
+}
+
+func TestAuthReadsRequiresToken(t *testing.T) {
+	tf := NewTokenFile(filepath.Join(t.TempDir(), "tokens.json"))
+	secret, err := tf.Mint("viewer", []TokenScope{ScopeRead})
+	if err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+
+	web := deskpad.NewWebSurface()
+	deck := deskpad.NewDeck(&apiTestScreen{name: "home"})
+	deck.RegisterSurface(web)
+	api := &API{d: deck, web: web, tokens: tf, authReads: true}
+
+	rec := httptest.NewRecorder()
+	api.UIState(rec, httptest.NewRequest(http.MethodGet, "/api/ui/state", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous read status = %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	api.UIState(rec, httptest.NewRequest(http.MethodGet, "/api/ui/state?access_token="+secret, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("read with access_token status = %d, want 200", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ui/keys/0/press?access_token="+secret, strings.NewReader(`{"type":"short"}`))
+	rec = httptest.NewRecorder()
+	api.UIPressKey(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("write with access_token status = %d, want 401", rec.Code)
+	}
+
+	api.authReads = false
+	rec = httptest.NewRecorder()
+	api.UIState(rec, httptest.NewRequest(http.MethodGet, "/api/ui/state", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("anonymous read with auth-reads disabled status = %d, want 200", rec.Code)
+	}
+}
+
+func TestTokenCommand(t *testing.T) {
+	tf := NewTokenFile(filepath.Join(t.TempDir(), "tokens.json"))
+
+	var stdout, stderr bytes.Buffer
+	if code := runTokenCommand(tf, []string{"mint", "-scopes", "keys,media", "remote"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("mint exit code = %d, stderr %q", code, stderr.String())
+	}
+	token, ok := tf.Lookup(strings.TrimSpace(stdout.String()))
+	if !ok || !token.Allows(ScopeKeys) || !token.Allows(ScopeMedia) || token.Allows(ScopeAdmin) {
+		t.Fatalf("minted token = %+v %t, want keys and media scopes", token, ok)
+	}
+
+	if code := runTokenCommand(tf, []string{"mint", "-scopes", "owner", "other"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("unknown scope exit code = %d, want 2", code)
+	}
+
+	stdout.Reset()
+	if code := runTokenCommand(tf, []string{"list"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "remote") {
+		t.Fatalf("list = %d %q, want the remote token", code, stdout.String())
+	}
+
+	if code := runTokenCommand(tf, []string{"revoke", "remote"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("revoke exit code = %d, stderr %q", code, stderr.String())
+	}
+	if code := runTokenCommand(tf, []string{"revoke", "remote"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("second revoke exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rmrobinson/deskpad/metrics"
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+// WeatherController provides the readings from the weather service. It is satisfied by *controllers.Weather.
+type WeatherController interface {
+	LatestReading() *weatherv1.WeatherReading
+	Subscribe() (<-chan *weatherv1.WeatherReading, func())
+}
+
+// WeatherResponse is a single weather reading. Temperatures are in degrees Celsius.
+type WeatherResponse struct {
+	Condition         string  `json:"condition"`
+	TempC             float64 `json:"tempC"`
+	FeelsLikeC        float64 `json:"feelsLikeC"`
+	DewPointC         float64 `json:"dewPointC"`
+	HumidityPct       float64 `json:"humidityPct"`
+	PressureHpa       float64 `json:"pressureHpa"`
+	WindSpeedMs       float64 `json:"windSpeedMs"`
+	WindDirDeg        float64 `json:"windDirDeg"`
+	WindGustMs        float64 `json:"windGustMs"`
+	RainMmHr          float64 `json:"rainMmHr"`
+	RainDailyMm       float64 `json:"rainDailyMm"`
+	UVIndex           float64 `json:"uvIndex"`
+	CloudCoverPct     float64 `json:"cloudCoverPct"`
+	IndoorTempC       float64 `json:"indoorTempC"`
+	IndoorHumidityPct float64 `json:"indoorHumidityPct"`
+}
+
+var weatherConditionNames = map[weatherv1.WeatherCondition]string{
+	weatherv1.WeatherCondition_WEATHER_CONDITION_SUNNY:         "sunny",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_MOSTLY_SUNNY:  "mostlySunny",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_PARTLY_CLOUDY: "partlyCloudy",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_MOSTLY_CLOUDY: "mostlyCloudy",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_OVERCAST:      "overcast",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_LIGHT_RAIN:    "lightRain",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_RAIN:          "rain",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_HEAVY_RAIN:    "heavyRain",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_FREEZING_RAIN: "freezingRain",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_SNOW:          "snow",
+	weatherv1.WeatherCondition_WEATHER_CONDITION_NIGHT:         "night",
+}
+
+// Weather handles GET /api/weather, returning the latest reading.
+func (a *API) Weather(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/weather" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
+	if a.wc == nil {
+		http.Error(w, "weather not configured", http.StatusNotFound)
+		return
+	}
+
+	reading := a.wc.LatestReading()
+	if reading == nil {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "no weather reading yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, weatherResponse(reading))
+}
+
+// WeatherEvents handles GET /api/weather/events, streaming the latest reading and each new reading as
+// server-sent events.
+func (a *API) WeatherEvents(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/weather/events" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
+	if a.wc == nil {
+		http.Error(w, "weather not configured", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	disableWriteTimeout(w)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	readings, cancel := a.wc.Subscribe()
+	defer cancel()
+
+	subscribers := metrics.EventSubscribers.WithLabelValues("weather")
+	subscribers.Inc()
+	defer subscribers.Dec()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.drained():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case reading, ok := <-readings:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(weatherResponse(reading))
+			if err != nil {
+				apiLogger.Error("unable to marshal weather event", "err", err)
+				continue
+			}
+
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+func weatherResponse(reading *weatherv1.WeatherReading) WeatherResponse {
+	condition, ok := weatherConditionNames[reading.Condition]
+	if !ok {
+		condition = "unknown"
+	}
+
+	return WeatherResponse{
+		Condition:         condition,
+		TempC:             reading.TempC,
+		FeelsLikeC:        reading.FeelsLikeC,
+		DewPointC:         reading.DewPointC,
+		HumidityPct:       reading.HumidityPct,
+		PressureHpa:       reading.PressureHpa,
+		WindSpeedMs:       reading.WindSpeedMs,
+		WindDirDeg:        reading.WindDirDeg,
+		WindGustMs:        reading.WindGustMs,
+		RainMmHr:          reading.RainMmHr,
+		RainDailyMm:       reading.RainDailyMm,
+		UVIndex:           reading.UvIndex,
+		CloudCoverPct:     reading.CloudCoverPct,
+		IndoorTempC:       reading.TempInC,
+		IndoorHumidityPct: reading.HumidityInPct,
+	}
+}
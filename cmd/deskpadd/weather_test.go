@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+type weatherTestController struct {
+	lock        sync.Mutex
+	reading     *weatherv1.WeatherReading
+	subscribers []chan *weatherv1.WeatherReading
+}
+
+func (c *weatherTestController) LatestReading() *weatherv1.WeatherReading {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.reading
+}
+
+func (c *weatherTestController) Subscribe() (<-chan *weatherv1.WeatherReading, func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan *weatherv1.WeatherReading, 4)
+	if c.reading != nil {
+		ch <- c.reading
+	}
+	c.subscribers = append(c.subscribers, ch)
+	return ch, func() {}
+}
+
+func (c *weatherTestController) publish(reading *weatherv1.WeatherReading) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.reading = reading
+	for _, ch := range c.subscribers {
+		ch <- reading
+	}
+}
+
+func TestWeatherReturnsLatestReading(t *testing.T) {
+	wc := &weatherTestController{}
+	api := &API{wc: wc}
+
+	rec := httptest.NewRecorder()
+	api.Weather(rec, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status before first reading = %d, want 503", rec.Code)
+	}
+
+	wc.publish(&weatherv1.WeatherReading{TempC: 21.5, FeelsLikeC: 23, HumidityPct: 40, Condition: weatherv1.WeatherCondition_WEATHER_CONDITION_PARTLY_CLOUDY})
+	rec = httptest.NewRecorder()
+	api.Weather(rec, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var resp WeatherResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %s", err)
+	}
+	if resp.TempC != 21.5 || resp.FeelsLikeC != 23 || resp.HumidityPct != 40 || resp.Condition != "partlyCloudy" {
+		t.Fatalf("reading = %+v, want the published reading", resp)
+	}
+}
+
+func TestWeatherWithoutControllerIsNotFound(t *testing.T) {
+	api := &API{}
+
+	rec := httptest.NewRecorder()
+	api.Weather(rec, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestWeatherEventsStreamsReadings(t *testing.T) {
+	wc := &weatherTestController{}
+	wc.publish(&weatherv1.WeatherReading{TempC: 10})
+	api := &API{wc: wc}
+
+	server := httptest.NewServer(http.HandlerFunc(api.WeatherEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/weather/events")
+	if err != nil {
+		t.Fatalf("get events: %s", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var reading WeatherResponse
+	if err := json.Unmarshal([]byte(readSSEData(t, scanner)), &reading); err != nil || reading.TempC != 10 {
+		t.Fatalf("initial event = %+v (%v), want the latest reading", reading, err)
+	}
+
+	wc.publish(&weatherv1.WeatherReading{TempC: 11, Condition: weatherv1.WeatherCondition_WEATHER_CONDITION_SNOW})
+	if err := json.Unmarshal([]byte(readSSEData(t, scanner)), &reading); err != nil || reading.TempC != 11 || reading.Condition != "snow" {
+		t.Fatalf("update event = %+v (%v), want the new reading", reading, err)
+	}
+}
@@ -27,7 +27,7 @@ self.addEventListener("fetch", (event) => {
     return;
   }
 
-  if (url.pathname === "/" || url.pathname === "/service-worker.js" || url.pathname === "/manifest.webmanifest") {
+  if (url.pathname === "/" || url.pathname === "/playlists" || url.pathname === "/service-worker.js" || url.pathname === "/manifest.webmanifest") {
     event.respondWith(fetch(event.request).catch(() => caches.match(event.request)));
     return;
   }
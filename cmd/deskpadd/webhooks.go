@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/metrics"
+	"github.com/rmrobinson/deskpad/ui"
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+var webhookLogger = logging.Logger("webhooks")
+
+const (
+	// webhookPollInterval is how often state without change notifications, such as now playing, is checked.
+	webhookPollInterval   = 5 * time.Second
+	webhookTimeout        = 10 * time.Second
+	webhookMaxAttempts    = 5
+	webhookInitialBackoff = time.Second
+	webhookMaxBackoff     = time.Minute
+	// webhookQueueSize is the number of events each webhook can have waiting before new events are dropped.
+	webhookQueueSize = 64
+	// webhookLogSize is the number of deliveries kept in the delivery log.
+	webhookLogSize = 100
+
+	// WebhookSignatureHeader holds the hex HMAC-SHA256 of the request body, keyed with the webhook secret and
+	// prefixed with "sha256=".
+	WebhookSignatureHeader = "X-Deskpad-Signature"
+	WebhookEventHeader     = "X-Deskpad-Event"
+	WebhookDeliveryHeader  = "X-Deskpad-Delivery"
+)
+
+// The events which can be sent to a webhook.
+const (
+	WebhookScreenChanged         = "screen.changed"
+	WebhookKeyPressed            = "key.pressed"
+	WebhookTrackChanged          = "media.track_changed"
+	WebhookPlaylistStarted       = "media.playlist_started"
+	WebhookBluetoothConnected    = "bluetooth.connected"
+	WebhookBluetoothDisconnected = "bluetooth.disconnected"
+	WebhookWeatherThreshold      = "weather.threshold_crossed"
+)
+
+// The states of a webhook delivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+var errWebhookQueueFull = errors.New("webhook queue full")
+
+// PlaylistController reports the playlist which was last started. It is satisfied by *controllers.MediaPlaylist.
+type PlaylistController interface {
+	CurrentlyPlaylist() *ui.MediaPlaylist
+}
+
+// WebhookConfig describes an endpoint which is sent events.
+type WebhookConfig struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	// Secret signs each request if set; see WebhookSignatureHeader.
+	Secret string `mapstructure:"secret"`
+	// Events limits the webhook to the listed event types; every event is sent if it is empty.
+	Events []string `mapstructure:"events"`
+}
+
+// WeatherThreshold sends an event when a weather reading crosses Value, in either direction. Field is the name
+// of a field in WeatherResponse, such as tempC.
+type WeatherThreshold struct {
+	Name  string  `mapstructure:"name"`
+	Field string  `mapstructure:"field"`
+	Value float64 `mapstructure:"value"`
+}
+
+// WebhookEvent is the body POSTed to a webhook.
+type WebhookEvent struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// WebhookScreenData is the data of a screen.changed event.
+type WebhookScreenData struct {
+	Screen   string `json:"screen"`
+	Previous string `json:"previous"`
+}
+
+// WebhookKeyData is the data of a key.pressed event.
+type WebhookKeyData struct {
+	Screen string `json:"screen"`
+	Key    int    `json:"key"`
+	Type   string `json:"type"`
+}
+
+// WebhookBluetoothData is the data of the bluetooth.connected and bluetooth.disconnected events.
+type WebhookBluetoothData struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+}
+
+// WebhookWeatherData is the data of a weather.threshold_crossed event. Direction is above or below.
+type WebhookWeatherData struct {
+	Threshold string          `json:"threshold"`
+	Field     string          `json:"field"`
+	Limit     float64         `json:"limit"`
+	Value     float64         `json:"value"`
+	Direction string          `json:"direction"`
+	Reading   WeatherResponse `json:"reading"`
+}
+
+// WebhookDelivery records the progress of sending an event to a webhook.
+type WebhookDelivery struct {
+	EventID    string    `json:"eventId"`
+	Event      string    `json:"event"`
+	Webhook    string    `json:"webhook"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+type webhook struct {
+	cfg   WebhookConfig
+	queue chan webhookJob
+}
+
+type webhookJob struct {
+	delivery *WebhookDelivery
+	body     []byte
+}
+
+// Webhooks POSTs deck events to the configured webhooks. Each webhook has its own queue, so a slow or failing
+// endpoint doesn't hold up the others, and failed deliveries are retried with exponential backoff.
+type Webhooks struct {
+	hooks      []*webhook
+	thresholds []WeatherThreshold
+
+	web  *deskpad.WebSurface
+	mpc  MediaPlayerController
+	mplc PlaylistController
+	wc   WeatherController
+	bt   BluetoothController
+
+	client  *http.Client
+	backoff time.Duration
+
+	lock sync.Mutex
+	// deliveries is the delivery log, oldest first.
+	deliveries []*WebhookDelivery
+}
+
+// NewWebhooks creates a dispatcher for the supplied webhooks. Thresholds on unknown weather fields are ignored.
+func NewWebhooks(configs []WebhookConfig, thresholds []WeatherThreshold) *Webhooks {
+	wh := &Webhooks{
+		client:  &http.Client{Timeout: webhookTimeout},
+		backoff: webhookInitialBackoff,
+	}
+
+	for _, cfg := range configs {
+		if len(cfg.Name) < 1 {
+			cfg.Name = cfg.URL
+		}
+		wh.hooks = append(wh.hooks, &webhook{
+			cfg:   cfg,
+			queue: make(chan webhookJob, webhookQueueSize),
+		})
+	}
+
+	for _, threshold := range thresholds {
+		if _, ok := weatherField(WeatherResponse{}, threshold.Field); !ok {
+			webhookLogger.Error("ignoring weather threshold on unknown field", "threshold", threshold.Name, "field", threshold.Field)
+			continue
+		}
+		wh.thresholds = append(wh.thresholds, threshold)
+	}
+
+	return wh
+}
+
+// Run delivers queued events and watches for state changes until the context is cancelled.
+func (wh *Webhooks) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, hook := range wh.hooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wh.deliver(ctx, hook)
+		}()
+	}
+	defer wg.Wait()
+
+	var snapshots <-chan deskpad.Snapshot
+	if wh.web != nil {
+		var cancel func()
+		snapshots, cancel = wh.web.Subscribe()
+		defer cancel()
+	}
+
+	var readings <-chan *weatherv1.WeatherReading
+	if wh.wc != nil && len(wh.thresholds) > 0 {
+		var cancel func()
+		readings, cancel = wh.wc.Subscribe()
+		defer cancel()
+	}
+
+	poll := time.NewTicker(webhookPollInterval)
+	defer poll.Stop()
+
+	// The first value seen of each piece of state is the baseline for detecting changes, not a change itself.
+	var screen string
+	above := map[string]bool{}
+	polled := wh.pollState(webhookPollState{})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snapshot, ok := <-snapshots:
+			if !ok {
+				snapshots = nil
+				continue
+			}
+			if len(screen) > 0 && snapshot.ScreenName != screen {
+				wh.Emit(WebhookScreenChanged, WebhookScreenData{Screen: snapshot.ScreenName, Previous: screen})
+			}
+			screen = snapshot.ScreenName
+		case reading, ok := <-readings:
+			if !ok {
+				readings = nil
+				continue
+			}
+			wh.checkThresholds(weatherResponse(reading), above)
+		case <-poll.C:
+			polled = wh.pollState(polled)
+		}
+	}
+}
+
+// KeyPressed sends a key.pressed event. It is a deskpad.KeyPressListener.
+func (wh *Webhooks) KeyPressed(screenName string, keyID int, t deskpad.KeyPressType) {
+	pressType := "short"
+	if t == deskpad.KeyPressLong {
+		pressType = "long"
+	}
+	wh.Emit(WebhookKeyPressed, WebhookKeyData{Screen: screenName, Key: keyID, Type: pressType})
+}
+
+// Emit queues the event for each webhook which wants it. It doesn't block; if a webhook has fallen too far
+// behind, the event is recorded as failed for that webhook.
+func (wh *Webhooks) Emit(eventType string, data any) {
+	event := WebhookEvent{
+		ID:   newWebhookEventID(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		webhookLogger.Error("unable to marshal event", "event", eventType, "err", err)
+		return
+	}
+
+	for _, hook := range wh.hooks {
+		if len(hook.cfg.Events) > 0 && !slices.Contains(hook.cfg.Events, eventType) {
+			continue
+		}
+
+		delivery := wh.logDelivery(hook.cfg.Name, event)
+		select {
+		case hook.queue <- webhookJob{delivery: delivery, body: body}:
+		default:
+			webhookLogger.Warn("webhook queue full, dropping event", "webhook", hook.cfg.Name, "event", eventType)
+			wh.updateDelivery(delivery, WebhookDeliveryFailed, 0, 0, errWebhookQueueFull)
+		}
+	}
+}
+
+// Deliveries returns the delivery log, newest first, optionally limited to the named webhook.
+func (wh *Webhooks) Deliveries(webhookName string) []WebhookDelivery {
+	wh.lock.Lock()
+	defer wh.lock.Unlock()
+
+	deliveries := []WebhookDelivery{}
+	for i := len(wh.deliveries) - 1; i >= 0; i-- {
+		if len(webhookName) > 0 && wh.deliveries[i].Webhook != webhookName {
+			continue
+		}
+		deliveries = append(deliveries, *wh.deliveries[i])
+	}
+	return deliveries
+}
+
+func (wh *Webhooks) deliver(ctx context.Context, hook *webhook) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-hook.queue:
+			wh.send(ctx, hook, job)
+		}
+	}
+}
+
+// send POSTs the job, retrying with exponential backoff on network errors, rate limiting and server errors.
+func (wh *Webhooks) send(ctx context.Context, hook *webhook, job webhookJob) {
+	backoff := wh.backoff
+	for attempt := 1; ; attempt++ {
+		code, err := wh.post(ctx, hook, job)
+		if err == nil {
+			wh.updateDelivery(job.delivery, WebhookDeliveryDelivered, attempt, code, nil)
+			metrics.WebhookDeliveries.WithLabelValues(hook.cfg.Name, WebhookDeliveryDelivered).Inc()
+			return
+		}
+
+		retry := code == 0 || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+		if !retry || attempt >= webhookMaxAttempts || ctx.Err() != nil {
+			webhookLogger.Error("webhook delivery failed", "webhook", hook.cfg.Name, "event", job.delivery.Event, "attempts", attempt, "err", err)
+			wh.updateDelivery(job.delivery, WebhookDeliveryFailed, attempt, code, err)
+			metrics.WebhookDeliveries.WithLabelValues(hook.cfg.Name, WebhookDeliveryFailed).Inc()
+			return
+		}
+
+		webhookLogger.Warn("webhook delivery failed; retrying", "webhook", hook.cfg.Name, "event", job.delivery.Event, "attempt", attempt, "backoff", backoff, "err", err)
+		wh.updateDelivery(job.delivery, WebhookDeliveryPending, attempt, code, err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+}
+
+// post makes a single delivery attempt, returning the response status code, or 0 if there was no response.
+func (wh *Webhooks) post(ctx context.Context, hook *webhook, job webhookJob) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.cfg.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "deskpadd")
+	req.Header.Set(WebhookEventHeader, job.delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, job.delivery.EventID)
+	if len(hook.cfg.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, signWebhookBody(hook.cfg.Secret, job.body))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (wh *Webhooks) logDelivery(webhookName string, event WebhookEvent) *WebhookDelivery {
+	delivery := &WebhookDelivery{
+		EventID: event.ID,
+		Event:   event.Type,
+		Webhook: webhookName,
+		Status:  WebhookDeliveryPending,
+		Created: event.Time,
+		Updated: event.Time,
+	}
+
+	wh.lock.Lock()
+	defer wh.lock.Unlock()
+
+	wh.deliveries = append(wh.deliveries, delivery)
+	if len(wh.deliveries) > webhookLogSize {
+		wh.deliveries = slices.Delete(wh.deliveries, 0, len(wh.deliveries)-webhookLogSize)
+	}
+	return delivery
+}
+
+func (wh *Webhooks) updateDelivery(delivery *WebhookDelivery, status string, attempts int, code int, err error) {
+	wh.lock.Lock()
+	defer wh.lock.Unlock()
+
+	delivery.Status = status
+	delivery.Attempts = attempts
+	delivery.StatusCode = code
+	delivery.Updated = time.Now().UTC()
+	delivery.Error = ""
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+}
+
+// webhookPollState is the state which doesn't notify of changes, as seen by the previous poll.
+type webhookPollState struct {
+	// polled is set once the media state has been read, making it the baseline for later polls.
+	polled   bool
+	track    *MediaItem
+	playlist *MediaPlaylist
+	// bluetooth is nil until the connected devices have been read successfully.
+	bluetooth map[string]string
+}
+
+// pollState checks the state which doesn't notify of changes, sending events for anything that changed since
+// the previous poll. The first value read of each piece of state is the baseline rather than a change.
+func (wh *Webhooks) pollState(prev webhookPollState) webhookPollState {
+	curr := webhookPollState{polled: true, bluetooth: prev.bluetooth}
+
+	if wh.mpc != nil {
+		curr.track = mediaItemFromUI(wh.mpc.CurrentlyPlaying())
+		if prev.polled && curr.track != nil && (prev.track == nil || prev.track.ID != curr.track.ID) {
+			wh.Emit(WebhookTrackChanged, curr.track)
+		}
+	}
+
+	if wh.mplc != nil {
+		curr.playlist = mediaPlaylistFromUI(wh.mplc.CurrentlyPlaylist())
+		if prev.polled && curr.playlist != nil && (prev.playlist == nil || prev.playlist.ID != curr.playlist.ID) {
+			wh.Emit(WebhookPlaylistStarted, curr.playlist)
+		}
+	}
+
+	if wh.bt == nil {
+		return curr
+	}
+
+	devices, err := wh.bt.ConnectedDevices()
+	if err != nil {
+		// Keep the previous devices so a failed lookup isn't reported as everything disconnecting.
+		webhookLogger.Warn("unable to get connected bluetooth devices", "err", err)
+		return curr
+	}
+
+	curr.bluetooth = map[string]string{}
+	for _, device := range devices {
+		curr.bluetooth[device.Address] = device.Name
+	}
+	if prev.bluetooth != nil {
+		connected, disconnected := bluetoothChanges(prev.bluetooth, curr.bluetooth)
+		for _, device := range connected {
+			wh.Emit(WebhookBluetoothConnected, device)
+		}
+		for _, device := range disconnected {
+			wh.Emit(WebhookBluetoothDisconnected, device)
+		}
+	}
+
+	return curr
+}
+
+// checkThresholds sends an event for each threshold the reading has crossed. above holds which side of each
+// threshold the previous reading was on; the first reading only sets it.
+func (wh *Webhooks) checkThresholds(reading WeatherResponse, above map[string]bool) {
+	for _, threshold := range wh.thresholds {
+		value, _ := weatherField(reading, threshold.Field)
+		isAbove := value >= threshold.Value
+
+		wasAbove, seen := above[threshold.Name]
+		above[threshold.Name] = isAbove
+		if !seen || wasAbove == isAbove {
+			continue
+		}
+
+		direction := "below"
+		if isAbove {
+			direction = "above"
+		}
+		wh.Emit(WebhookWeatherThreshold, WebhookWeatherData{
+			Threshold: threshold.Name,
+			Field:     threshold.Field,
+			Limit:     threshold.Value,
+			Value:     value,
+			Direction: direction,
+			Reading:   reading,
+		})
+	}
+}
+
+// bluetoothChanges compares two sets of connected devices, keyed by address, sorted by address.
+func bluetoothChanges(prev map[string]string, curr map[string]string) ([]WebhookBluetoothData, []WebhookBluetoothData) {
+	var connected, disconnected []WebhookBluetoothData
+	for address, name := range curr {
+		if _, ok := prev[address]; !ok {
+			connected = append(connected, WebhookBluetoothData{Address: address, Name: name})
+		}
+	}
+	for address, name := range prev {
+		if _, ok := curr[address]; !ok {
+			disconnected = append(disconnected, WebhookBluetoothData{Address: address, Name: name})
+		}
+	}
+
+	byAddress := func(a, b WebhookBluetoothData) int {
+		return strings.Compare(a.Address, b.Address)
+	}
+	slices.SortFunc(connected, byAddress)
+	slices.SortFunc(disconnected, byAddress)
+	return connected, disconnected
+}
+
+// weatherField returns the named field of the reading, using its JSON name.
+func weatherField(reading WeatherResponse, field string) (float64, bool) {
+	data, err := json.Marshal(reading)
+	if err != nil {
+		return 0, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return 0, false
+	}
+
+	value, ok := fields[field].(float64)
+	return value, ok
+}
+
+// signWebhookBody returns the value of the WebhookSignatureHeader for the body.
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookEventID() string {
+	raw := make([]byte, 12)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+// WebhookDeliveriesResponse lists the recent webhook deliveries, newest first.
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookDeliveries handles GET /api/webhooks/deliveries, returning the delivery log. It can be limited to a
+// single webhook with ?webhook={name}.
+func (a *API) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/webhooks/deliveries" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.authorize(w, r, ScopeAdmin) {
+		return
+	}
+	if a.webhooks == nil {
+		http.Error(w, "webhooks not configured", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, WebhookDeliveriesResponse{Deliveries: a.webhooks.Deliveries(r.URL.Query().Get("webhook"))})
+}
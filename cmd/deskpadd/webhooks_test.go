@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+type webhookTestRequest struct {
+	header http.Header
+	body   []byte
+	event  WebhookEvent
+}
+
+// newWebhookTestServer returns a server which records each request and replies with the next status code,
+// or 200 once they run out.
+func newWebhookTestServer(t *testing.T, codes ...int) (*httptest.Server, chan webhookTestRequest) {
+	t.Helper()
+
+	requests := make(chan webhookTestRequest, 16)
+	codeCh := make(chan int, len(codes))
+	for _, code := range codes {
+		codeCh <- code
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := webhookTestRequest{header: r.Header, body: body}
+		json.Unmarshal(body, &req.event)
+		requests <- req
+
+		select {
+		case code := <-codeCh:
+			w.WriteHeader(code)
+		default:
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func startWebhooks(t *testing.T, wh *Webhooks) {
+	t.Helper()
+
+	wh.backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		wh.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitForWebhook(t *testing.T, requests chan webhookTestRequest) webhookTestRequest {
+	t.Helper()
+
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for webhook request")
+		return webhookTestRequest{}
+	}
+}
+
+func waitForDelivery(t *testing.T, wh *Webhooks, status string) WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries := wh.Deliveries(""); len(deliveries) > 0 && deliveries[0].Status == status {
+			return deliveries[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s delivery; log = %+v", status, wh.Deliveries(""))
+	return WebhookDelivery{}
+}
+
+func TestWebhooksSignAndRetryDeliveries(t *testing.T) {
+	server, requests := newWebhookTestServer(t, http.StatusServiceUnavailable)
+
+	wh := NewWebhooks([]WebhookConfig{{Name: "automations", URL: server.URL, Secret: "shh"}}, nil)
+	startWebhooks(t, wh)
+	wh.Emit(WebhookScreenChanged, WebhookScreenData{Screen: "weather", Previous: "home"})
+
+	first := waitForWebhook(t, requests)
+	second := waitForWebhook(t, requests)
+	if first.event.ID != second.event.ID || second.header.Get(WebhookDeliveryHeader) != second.event.ID {
+		t.Fatalf("retry sent event %s with delivery %s, want the same event %s", second.event.ID, second.header.Get(WebhookDeliveryHeader), first.event.ID)
+	}
+	if got, want := second.header.Get(WebhookSignatureHeader), signWebhookBody("shh", second.body); got != want {
+		t.Fatalf("signature = %q, want %q", got, want)
+	}
+	if got := second.header.Get(WebhookEventHeader); got != WebhookScreenChanged {
+		t.Fatalf("event header = %q, want %q", got, WebhookScreenChanged)
+	}
+
+	data, _ := json.Marshal(second.event.Data)
+	if string(data) != `{"previous":"home","screen":"weather"}` {
+		t.Fatalf("event data = %s", data)
+	}
+
+	delivery := waitForDelivery(t, wh, WebhookDeliveryDelivered)
+	if delivery.Attempts != 2 || delivery.StatusCode != http.StatusOK || delivery.Webhook != "automations" || delivery.Error != "" {
+		t.Fatalf("delivery = %+v, want delivered on the second attempt", delivery)
+	}
+}
+
+func TestWebhooksFilterEventsAndStopOnClientErrors(t *testing.T) {
+	server, requests := newWebhookTestServer(t, http.StatusBadRequest)
+
+	wh := NewWebhooks([]WebhookConfig{{URL: server.URL, Events: []string{WebhookKeyPressed}}}, nil)
+	startWebhooks(t, wh)
+	wh.Emit(WebhookScreenChanged, WebhookScreenData{Screen: "weather"})
+	wh.KeyPressed("home", 3, deskpad.KeyPressLong)
+
+	req := waitForWebhook(t, requests)
+	if req.event.Type != WebhookKeyPressed || req.header.Get(WebhookSignatureHeader) != "" {
+		t.Fatalf("request = %+v, want an unsigned key press", req.event)
+	}
+
+	delivery := waitForDelivery(t, wh, WebhookDeliveryFailed)
+	if delivery.Attempts != 1 || delivery.StatusCode != http.StatusBadRequest || delivery.Webhook != server.URL {
+		t.Fatalf("delivery = %+v, want a single failed attempt", delivery)
+	}
+	if deliveries := wh.Deliveries(""); len(deliveries) != 1 {
+		t.Fatalf("deliveries = %+v, want only the key press", deliveries)
+	}
+	select {
+	case req := <-requests:
+		t.Fatalf("unexpected request %+v", req.event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhooksSendStateChanges(t *testing.T) {
+	server, requests := newWebhookTestServer(t)
+
+	home := &apiTestScreen{name: "home"}
+	weather := &apiTestScreen{name: "weather", action: deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop}}
+	web := deskpad.NewWebSurface()
+	deck := deskpad.NewDeck(home)
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+
+	wc := &weatherTestController{}
+	wc.publish(&weatherv1.WeatherReading{TempC: 2})
+
+	wh := NewWebhooks([]WebhookConfig{{Name: "all", URL: server.URL}}, []WeatherThreshold{
+		{Name: "freezing", Field: "tempC", Value: 0},
+		{Name: "bogus", Field: "notAField", Value: 1},
+	})
+	wh.web = web
+	wh.wc = wc
+	deck.AddKeyPressListener(wh.KeyPressed)
+	startWebhooks(t, wh)
+
+	// Let the webhooks see the initial screen and reading before they change.
+	time.Sleep(50 * time.Millisecond)
+	deck.ChangeScreen(context.Background(), weather)
+	if req := waitForWebhook(t, requests); req.event.Type != WebhookScreenChanged {
+		t.Fatalf("event = %s, want %s", req.event.Type, WebhookScreenChanged)
+	}
+
+	deck.PressKey(context.Background(), 2, deskpad.KeyPressShort)
+	if req := waitForWebhook(t, requests); req.event.Type != WebhookKeyPressed {
+		t.Fatalf("event = %s, want %s", req.event.Type, WebhookKeyPressed)
+	}
+
+	wc.publish(&weatherv1.WeatherReading{TempC: 1})
+	wc.publish(&weatherv1.WeatherReading{TempC: -1.5})
+	req := waitForWebhook(t, requests)
+	data, _ := json.Marshal(req.event.Data)
+	var weatherData WebhookWeatherData
+	json.Unmarshal(data, &weatherData)
+	if req.event.Type != WebhookWeatherThreshold || weatherData.Threshold != "freezing" || weatherData.Direction != "below" || weatherData.Value != -1.5 {
+		t.Fatalf("event = %s %s, want freezing crossed below at -1.5", req.event.Type, data)
+	}
+}
+
+type webhookTestPlaylists struct {
+	playlist *ui.MediaPlaylist
+}
+
+func (p webhookTestPlaylists) CurrentlyPlaylist() *ui.MediaPlaylist {
+	return p.playlist
+}
+
+func TestWebhooksPollMediaChanges(t *testing.T) {
+	wh := NewWebhooks([]WebhookConfig{{Name: "all", URL: "http://127.0.0.1:0"}}, nil)
+	wh.mpc = apiTestMediaPlayer{item: &ui.MediaItem{ID: "track-1"}}
+	wh.mplc = webhookTestPlaylists{}
+
+	polled := wh.pollState(webhookPollState{})
+	if deliveries := wh.Deliveries(""); len(deliveries) != 0 {
+		t.Fatalf("deliveries = %+v, want none for the initial state", deliveries)
+	}
+
+	wh.mpc = apiTestMediaPlayer{item: &ui.MediaItem{ID: "track-2"}}
+	wh.mplc = webhookTestPlaylists{playlist: &ui.MediaPlaylist{ID: "playlist-1"}}
+	polled = wh.pollState(polled)
+	wh.pollState(polled)
+
+	var events []string
+	for _, delivery := range wh.Deliveries("all") {
+		events = append(events, delivery.Event)
+	}
+	if want := []string{WebhookPlaylistStarted, WebhookTrackChanged}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+}
+
+func TestWebhooksPollBluetoothChanges(t *testing.T) {
+	bt := &mqttTestBluetooth{}
+	wh := NewWebhooks([]WebhookConfig{{Name: "all", URL: "http://127.0.0.1:0"}}, nil)
+	wh.bt = bt
+
+	headphones := controllers.BluetoothDevice{Address: "AA:BB:CC:DD:EE:FF", Name: "Headphones"}
+	polled := wh.pollState(webhookPollState{})
+	bt.set(nil, headphones)
+	polled = wh.pollState(polled)
+	bt.set(errors.New("adapter unavailable"))
+	polled = wh.pollState(polled)
+	bt.set(nil)
+	wh.pollState(polled)
+
+	var events []string
+	for _, delivery := range wh.Deliveries("all") {
+		events = append(events, delivery.Event)
+	}
+	if want := []string{WebhookBluetoothDisconnected, WebhookBluetoothConnected}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+}
+
+func TestWebhooksPollMediaChangesWithoutBluetooth(t *testing.T) {
+	bt := &mqttTestBluetooth{}
+	bt.set(errors.New("no bluez"))
+	wh := NewWebhooks([]WebhookConfig{{Name: "all", URL: "http://127.0.0.1:0"}}, nil)
+	wh.mpc = apiTestMediaPlayer{item: &ui.MediaItem{ID: "track-1"}}
+	wh.bt = bt
+
+	polled := wh.pollState(webhookPollState{})
+	wh.mpc = apiTestMediaPlayer{item: &ui.MediaItem{ID: "track-2"}}
+	polled = wh.pollState(polled)
+
+	// Bluetooth becoming available sets its baseline rather than reporting the devices as newly connected.
+	bt.set(nil, controllers.BluetoothDevice{Address: "AA:BB:CC:DD:EE:FF", Name: "Headphones"})
+	wh.pollState(polled)
+
+	var events []string
+	for _, delivery := range wh.Deliveries("all") {
+		events = append(events, delivery.Event)
+	}
+	if want := []string{WebhookTrackChanged}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+}
+
+func TestBluetoothChanges(t *testing.T) {
+	connected, disconnected := bluetoothChanges(
+		map[string]string{"AA": "Headphones", "BB": "Speaker"},
+		map[string]string{"BB": "Speaker", "DD": "Keyboard", "CC": "Mouse"},
+	)
+
+	if want := []WebhookBluetoothData{{"CC", "Mouse"}, {"DD", "Keyboard"}}; !reflect.DeepEqual(connected, want) {
+		t.Fatalf("connected = %+v, want %+v", connected, want)
+	}
+	if want := []WebhookBluetoothData{{"AA", "Headphones"}}; !reflect.DeepEqual(disconnected, want) {
+		t.Fatalf("disconnected = %+v, want %+v", disconnected, want)
+	}
+}
+
+func TestWebhookDeliveriesEndpoint(t *testing.T) {
+	api := &API{authToken: "secret"}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/webhooks/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	api.WebhookDeliveries(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status without webhooks = %d, want 404", rec.Code)
+	}
+
+	api.webhooks = NewWebhooks([]WebhookConfig{{Name: "a", URL: "http://127.0.0.1:0"}, {Name: "b", URL: "http://127.0.0.1:0"}}, nil)
+	api.webhooks.Emit(WebhookKeyPressed, WebhookKeyData{Screen: "home"})
+
+	rec = httptest.NewRecorder()
+	api.WebhookDeliveries(rec, httptest.NewRequest(http.MethodGet, "/api/webhooks/deliveries", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status without token = %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/webhooks/deliveries?webhook=b", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	api.WebhookDeliveries(rec, req)
+
+	var resp WebhookDeliveriesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	if len(resp.Deliveries) != 1 || resp.Deliveries[0].Webhook != "b" || resp.Deliveries[0].Status != WebhookDeliveryPending {
+		t.Fatalf("deliveries = %+v, want a pending delivery to b", resp.Deliveries)
+	}
+}
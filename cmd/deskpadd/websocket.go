@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+// WSRequest is a command sent by a WebSocket client. Every request is answered with an ack or error message
+// carrying the same ID.
+type WSRequest struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+
+	// Token authorizes the connection for control requests, for "auth" requests.
+	Token string `json:"token,omitempty"`
+	// Key is the key being pressed or released, for "keyDown" and "keyUp" requests.
+	Key int `json:"key"`
+	// Dial, Action, Delta and Press describe "dial" requests; Action is one of rotate, press or touch.
+	Dial   int    `json:"dial"`
+	Action string `json:"action,omitempty"`
+	Delta  int    `json:"delta,omitempty"`
+	Press  string `json:"press,omitempty"`
+	// Screen is the name of the screen to show, for "navigate" requests.
+	Screen string `json:"screen,omitempty"`
+	// Command and Value describe "media" requests.
+	Command string `json:"command,omitempty"`
+	Value   bool   `json:"value,omitempty"`
+}
+
+// WSMessage is sent by the server to WebSocket clients. A full state is sent when the client connects or the
+// deck layout changes; afterwards only deltas containing the changed keys are sent.
+type WSMessage struct {
+	Type  string           `json:"type"`
+	ID    string           `json:"id,omitempty"`
+	Error string           `json:"error,omitempty"`
+	State *UIStateResponse `json:"state,omitempty"`
+	Delta *UIStateDelta    `json:"delta,omitempty"`
+}
+
+// UIStateDelta contains the keys and touch strip segments which changed since the previous message.
+// Removed images are sent as null.
+type UIStateDelta struct {
+	Screen string          `json:"screen"`
+	Keys   map[int]*string `json:"keys,omitempty"`
+	Strip  map[int]*string `json:"strip,omitempty"`
+}
+
+// wsSession holds the state of a single WebSocket connection.
+type wsSession struct {
+	api  *API
+	conn *websocket.Conn
+
+	writeLock sync.Mutex
+
+	lock       sync.Mutex
+	remoteAddr string
+	token      *APIToken
+	keysDown   map[int]time.Time
+
+	// The hashes of the images last sent to the client.
+	screen      string
+	rows        int
+	columns     int
+	keyHashes   []string
+	stripHashes []string
+}
+
+// UIWebSocket handles GET /api/ws, streaming state to the client and accepting control requests.
+// Connections are authorized by a bearer token in the handshake or by sending an "auth" request. When reads
+// require a token it must be supplied in the handshake, using the access_token query parameter from browsers.
+func (a *API) UIWebSocket(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/ws" {
+		http.NotFound(w, r)
+		return
+	}
+	if !a.authorizeRead(w, r) {
+		return
+	}
+
+	token, _ := a.authenticate(r)
+	server := websocket.Server{
+		Handshake: checkWebSocketOrigin,
+		Handler: func(conn *websocket.Conn) {
+			// The hijacked connection keeps the server's timeouts, which would close an idle session.
+			conn.SetDeadline(time.Time{})
+
+			session := &wsSession{
+				api:        a,
+				conn:       conn,
+				remoteAddr: r.RemoteAddr,
+				token:      token,
+				keysDown:   make(map[int]time.Time),
+			}
+			session.run(r.Context())
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkWebSocketOrigin rejects browser connections from other sites. Clients which don't send an origin,
+// such as command line tools, are allowed.
+func checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Host != r.Host {
+		return fmt.Errorf("origin %s not allowed", origin)
+	}
+
+	config.Origin = u
+	return nil
+}
+
+func (s *wsSession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, unsubscribe := s.api.web.Subscribe()
+	defer unsubscribe()
+
+	go func() {
+		defer cancel()
+
+		for {
+			var req WSRequest
+			if err := websocket.JSON.Receive(s.conn, &req); err != nil {
+				return
+			}
+
+			if err := s.handle(ctx, req); err != nil {
+				s.send(WSMessage{Type: "error", ID: req.ID, Error: err.Error()})
+				continue
+			}
+			s.send(WSMessage{Type: "ack", ID: req.ID})
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.api.drained():
+			return
+		case snapshot, ok := <-events:
+			if !ok {
+				return
+			}
+
+			msg, changed := s.stateMessage(snapshot)
+			if !changed {
+				continue
+			}
+			if err := s.send(msg); err != nil {
+				apiLogger.Error("unable to send state to websocket client", "err", err)
+				return
+			}
+		}
+	}
+}
+
+func (s *wsSession) send(msg WSMessage) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	return websocket.JSON.Send(s.conn, msg)
+}
+
+// stateMessage returns the message which brings the client up to date with the snapshot, and false if
+// nothing has changed since the last message.
+func (s *wsSession) stateMessage(snapshot deskpad.Snapshot) (WSMessage, bool) {
+	keyHashes := imageHashes(snapshot.Keys)
+	stripHashes := imageHashes(snapshot.Strip)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	full := s.keyHashes == nil || len(keyHashes) != len(s.keyHashes) || len(stripHashes) != len(s.stripHashes) ||
+		snapshot.Rows != s.rows || snapshot.Columns != s.columns
+
+	delta := &UIStateDelta{Screen: snapshot.ScreenName}
+	if !full {
+		delta.Keys = changedImages(snapshot.Keys, s.keyHashes, keyHashes)
+		delta.Strip = changedImages(snapshot.Strip, s.stripHashes, stripHashes)
+	}
+	changed := full || snapshot.ScreenName != s.screen || len(delta.Keys) > 0 || len(delta.Strip) > 0
+
+	s.screen = snapshot.ScreenName
+	s.rows, s.columns = snapshot.Rows, snapshot.Columns
+	s.keyHashes, s.stripHashes = keyHashes, stripHashes
+
+	if full {
+		state := snapshotToUIState(snapshot)
+		return WSMessage{Type: "state", State: &state}, true
+	}
+	return WSMessage{Type: "delta", Delta: delta}, changed
+}
+
+func imageHashes(imgs []image.Image) []string {
+	hashes := make([]string, len(imgs))
+	for i, img := range imgs {
+		hashes[i] = deskpad.ImageHash(img)
+	}
+	return hashes
+}
+
+func changedImages(imgs []image.Image, previous, current []string) map[int]*string {
+	changed := map[int]*string{}
+	for i := range current {
+		if previous[i] == current[i] {
+			continue
+		}
+
+		changed[i] = nil
+		if imgs[i] == nil {
+			continue
+		}
+
+		src, err := imageURL(imgs[i])
+		if err != nil {
+			apiLogger.Error("unable to encode image", "index", i, "err", err)
+			continue
+		}
+		changed[i] = &src
+	}
+	return changed
+}
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("forbidden")
+)
+
+// wsRequestScopes are the token scopes required by each request type.
+var wsRequestScopes = map[string]TokenScope{
+	"keyDown":  ScopeKeys,
+	"keyUp":    ScopeKeys,
+	"dial":     ScopeKeys,
+	"navigate": ScopeKeys,
+	"media":    ScopeMedia,
+}
+
+func (s *wsSession) handle(ctx context.Context, req WSRequest) error {
+	if req.Type == "auth" {
+		token, ok := s.api.lookupToken(req.Token)
+		if !ok {
+			return errUnauthorized
+		}
+
+		s.lock.Lock()
+		s.token = token
+		s.lock.Unlock()
+		return nil
+	}
+
+	s.lock.Lock()
+	token := s.token
+	s.lock.Unlock()
+	if token == nil {
+		return errUnauthorized
+	}
+
+	// The token may have been revoked or changed since the session authorized with it.
+	token, ok := s.api.refreshToken(token)
+	s.lock.Lock()
+	s.token = token
+	s.lock.Unlock()
+	if !ok {
+		return errUnauthorized
+	}
+
+	if scope, ok := wsRequestScopes[req.Type]; ok {
+		allowed := token.Allows(scope)
+		s.api.audit(token, scope, "ws "+req.Type, s.remoteAddr, allowed)
+		if !allowed {
+			return errForbidden
+		}
+	}
+
+	d := s.api.d
+	switch req.Type {
+	case "keyDown":
+		if req.Key < 0 || req.Key >= d.KeyCount() {
+			return fmt.Errorf("invalid key id %d", req.Key)
+		}
+
+		s.lock.Lock()
+		s.keysDown[req.Key] = time.Now()
+		s.lock.Unlock()
+		return nil
+
+	case "keyUp":
+		s.lock.Lock()
+		down, ok := s.keysDown[req.Key]
+		delete(s.keysDown, req.Key)
+		s.lock.Unlock()
+
+		// A key released without being pressed is treated as a short press.
+		pressType := deskpad.KeyPressShort
+		if ok {
+			pressType = deskpad.KeyPressTypeForDuration(time.Since(down))
+		}
+		return d.PressKey(ctx, req.Key, pressType)
+
+	case "dial":
+		eventType, ok := deskpad.ParseDialEventType(req.Action)
+		if !ok {
+			return fmt.Errorf("invalid dial action %q", req.Action)
+		}
+
+		event := deskpad.DialEvent{Dial: req.Dial, Type: eventType, Delta: req.Delta}
+		if req.Press == "long" {
+			event.PressType = deskpad.KeyPressLong
+		}
+		return d.DialChanged(ctx, event)
+
+	case "navigate":
+		if s.api.catalog == nil {
+			return errors.New("navigation unavailable")
+		}
+
+		screen := s.api.screenByName(req.Screen)
+		if screen == nil {
+			return fmt.Errorf("unknown screen %q", req.Screen)
+		}
+		d.ChangeScreen(ctx, screen)
+		return nil
+
+	case "media":
+		if err := runMediaCommand(s.api.mpc, req.Command, req.Value); err != nil {
+			return err
+		}
+
+		// Keys such as play/pause reflect the player state.
+		d.RefreshScreen()
+		return nil
+	}
+
+	return fmt.Errorf("unknown request type %q", req.Type)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+type wsTestCatalog []deskpad.Screen
+
+func (c wsTestCatalog) Screens() []deskpad.Screen {
+	return c
+}
+
+func (c wsTestCatalog) OnHome(s deskpad.Screen) bool {
+	return len(c) > 0 && c[0] == s
+}
+
+type wsTestMediaPlayer struct {
+	apiTestMediaPlayer
+	commands []string
+}
+
+func (p *wsTestMediaPlayer) Next() {
+	p.commands = append(p.commands, "next")
+}
+
+func dialTestWebSocket(t *testing.T, api *API, header http.Header) *websocket.Conn {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(api.UIWebSocket))
+	t.Cleanup(server.Close)
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", server.URL)
+	if err != nil {
+		t.Fatalf("websocket config: %s", err)
+	}
+	config.Header = header
+
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("dial websocket: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receiveWSMessage reads messages until one matches, failing the test if none arrives in time.
+func receiveWSMessage(t *testing.T, conn *websocket.Conn, match func(WSMessage) bool) WSMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg WSMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			t.Fatalf("did not receive expected websocket message: %s", err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+func isReply(id string) func(WSMessage) bool {
+	return func(msg WSMessage) bool {
+		return msg.ID == id
+	}
+}
+
+func TestUIWebSocketStreamsStateAndAcceptsCommands(t *testing.T) {
+	other := &apiTestScreen{name: "other", showKeys: []image.Image{apiTestImage()}}
+	home := &apiTestScreen{
+		name:     "home",
+		showKeys: []image.Image{apiTestImage(), nil, nil},
+		action:   deskpad.KeyPressAction{Action: deskpad.KeyPressActionUpdateIcon, NewIcon: apiTestImageColor(200)},
+	}
+	deck := deskpad.NewDeck(home)
+	web := deskpad.NewWebSurface()
+	deck.RegisterSurface(web)
+	deck.RefreshScreen()
+
+	player := &wsTestMediaPlayer{}
+	api := &API{d: deck, web: web, mpc: player, catalog: wsTestCatalog{home, other}, authToken: "secret"}
+	conn := dialTestWebSocket(t, api, nil)
+
+	state := receiveWSMessage(t, conn, func(msg WSMessage) bool { return msg.Type == "state" })
+	if state.State.CurrentScreen.Name != "home" || len(state.State.Keys) != 15 || state.State.Keys[0] == nil {
+		t.Fatalf("initial state = %+v, want home with key 0", state.State)
+	}
+
+	websocket.JSON.Send(conn, WSRequest{ID: "1", Type: "keyUp", Key: 2})
+	if reply := receiveWSMessage(t, conn, isReply("1")); reply.Type != "error" || reply.Error != "unauthorized" {
+		t.Fatalf("unauthorized key press reply = %+v, want unauthorized error", reply)
+	}
+	websocket.JSON.Send(conn, WSRequest{ID: "2", Type: "auth", Token: "wrong"})
+	if reply := receiveWSMessage(t, conn, isReply("2")); reply.Type != "error" {
+		t.Fatalf("wrong token reply = %+v, want error", reply)
+	}
+	websocket.JSON.Send(conn, WSRequest{ID: "3", Type: "auth", Token: "secret"})
+	if reply := receiveWSMessage(t, conn, isReply("3")); reply.Type != "ack" {
+		t.Fatalf("auth reply = %+v, want ack", reply)
+	}
+
+	websocket.JSON.Send(conn, WSRequest{ID: "4", Type: "keyDown", Key: 2})
+	websocket.JSON.Send(conn, WSRequest{ID: "5", Type: "keyUp", Key: 2})
+	delta := receiveWSMessage(t, conn, func(msg WSMessage) bool { return msg.Type == "delta" })
+	if len(delta.Delta.Keys) != 1 || delta.Delta.Keys[2] == nil {
+		t.Fatalf("delta after key press = %+v, want only key 2", delta.Delta)
+	}
+	if home.pressedKey != 2 || home.pressedType != deskpad.KeyPressShort {
+		t.Fatalf("pressed key %d type %d, want short press of key 2", home.pressedKey, home.pressedType)
+	}
+
+	websocket.JSON.Send(conn, WSRequest{ID: "6", Type: "navigate", Screen: "other"})
+	delta = receiveWSMessage(t, conn, func(msg WSMessage) bool { return msg.Type == "delta" })
+	if delta.Delta.Screen != "other" || len(delta.Delta.Keys) != 1 || delta.Delta.Keys[2] != nil {
+		t.Fatalf("delta after navigation = %+v, want other screen with key 2 cleared", delta.Delta)
+	}
+
+	websocket.JSON.Send(conn, WSRequest{ID: "7", Type: "media", Command: "next"})
+	if reply := receiveWSMessage(t, conn, isReply("7")); reply.Type != "ack" {
+		t.Fatalf("media reply = %+v, want ack", reply)
+	}
+	if len(player.commands) != 1 || player.commands[0] != "next" {
+		t.Fatalf("player commands = %v, want next", player.commands)
+	}
+
+	websocket.JSON.Send(conn, WSRequest{ID: "8", Type: "navigate", Screen: "missing"})
+	if reply := receiveWSMessage(t, conn, isReply("8")); reply.Type != "error" {
+		t.Fatalf("unknown screen reply = %+v, want error", reply)
+	}
+}
+
+func TestUIWebSocketAcceptsBearerTokenAndRejectsOtherOrigins(t *testing.T) {
+	screen := &apiTestScreen{name: "home", action: deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop}}
+	deck := deskpad.NewDeck(screen)
+	web := deskpad.NewWebSurface()
+	deck.RegisterSurface(web)
+	api := &API{d: deck, web: web, authToken: "secret"}
+
+	conn := dialTestWebSocket(t, api, http.Header{"Authorization": {"Bearer secret"}})
+	websocket.JSON.Send(conn, WSRequest{ID: "1", Type: "keyUp", Key: 4})
+	if reply := receiveWSMessage(t, conn, isReply("1")); reply.Type != "ack" {
+		t.Fatalf("key press reply = %+v, want ack", reply)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(api.UIWebSocket))
+	defer server.Close()
+	if _, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", "", "https://evil.example"); err == nil {
+		t.Fatalf("connection from another origin was accepted")
+	}
+}
+
+func apiTestImageColor(r uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: r, A: 255})
+	return img
+}
+
+func TestUIWebSocketRejectsRevokedToken(t *testing.T) {
+	home := &apiTestScreen{name: "home", action: deskpad.KeyPressAction{Action: deskpad.KeyPressActionNoop}}
+	deck := deskpad.NewDeck(home)
+	web := deskpad.NewWebSurface()
+	deck.RegisterSurface(web)
+
+	tf := NewTokenFile(filepath.Join(t.TempDir(), "tokens.json"))
+	secret, err := tf.Mint("phone", []TokenScope{ScopeKeys})
+	if err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+
+	api := &API{d: deck, web: web, tokens: tf}
+	conn := dialTestWebSocket(t, api, http.Header{"Authorization": {"Bearer " + secret}})
+
+	websocket.JSON.Send(conn, WSRequest{ID: "1", Type: "keyUp", Key: 2})
+	if reply := receiveWSMessage(t, conn, isReply("1")); reply.Type != "ack" {
+		t.Fatalf("key press reply = %+v, want ack", reply)
+	}
+
+	if err := tf.Revoke("phone"); err != nil {
+		t.Fatalf("Revoke returned error: %s", err)
+	}
+	home.pressedKey = -1
+
+	websocket.JSON.Send(conn, WSRequest{ID: "2", Type: "keyUp", Key: 3})
+	if reply := receiveWSMessage(t, conn, isReply("2")); reply.Type != "error" || reply.Error != "unauthorized" {
+		t.Fatalf("key press reply after revocation = %+v, want unauthorized error", reply)
+	}
+	if home.pressedKey != -1 {
+		t.Fatalf("key %d pressed with a revoked token", home.pressedKey)
+	}
+
+	// A new token with the same name doesn't revive the session.
+	if _, err := tf.Mint("phone", []TokenScope{ScopeKeys}); err != nil {
+		t.Fatalf("Mint returned error: %s", err)
+	}
+	websocket.JSON.Send(conn, WSRequest{ID: "3", Type: "keyUp", Key: 3})
+	if reply := receiveWSMessage(t, conn, isReply("3")); reply.Type != "error" {
+		t.Fatalf("key press reply after reissue = %+v, want error", reply)
+	}
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"fmt"
 	"image"
-	"log"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/metrics"
 )
 
+var deckLogger = logging.Logger("deck")
+
 // KeyPressType indicates if there was a short or a long keypress
 type KeyPressType int
 
@@ -47,11 +52,17 @@ type KeyPressAction struct {
 // Deck coordinates a screen across all registered control surfaces.
 type Deck struct {
 	screen   Screen
+	path     []string
 	surfaces []Surface
 	keys     []image.Image
+	strip    []image.Image
 	rows     int
 	columns  int
 
+	recorder          *Recorder
+	renderer          *Renderer
+	keyPressListeners []KeyPressListener
+
 	lock      sync.RWMutex
 	pressLock sync.Mutex
 }
@@ -60,11 +71,18 @@ type Deck struct {
 func NewDeck(screen Screen) *Deck {
 	rows, columns := deckGeometry(defaultKeyCount)
 
+	var path []string
+	if screen != nil {
+		path = []string{screen.Name()}
+	}
+
 	return &Deck{
-		screen:  screen,
-		keys:    make([]image.Image, defaultKeyCount),
-		rows:    rows,
-		columns: columns,
+		screen:   screen,
+		path:     path,
+		keys:     make([]image.Image, defaultKeyCount),
+		rows:     rows,
+		columns:  columns,
+		renderer: NewRenderer(0),
 	}
 }
 
@@ -76,6 +94,10 @@ func (d *Deck) RegisterSurface(s Surface) {
 
 	d.lock.Lock()
 	resized := d.configureGeometryLocked(s.KeyCount())
+	if ds, ok := s.(DialSurface); ok && ds.DialCount() > len(d.strip) {
+		d.strip = make([]image.Image, ds.DialCount())
+		resized = true
+	}
 	d.surfaces = append(d.surfaces, s)
 	snapshot := d.snapshotLocked()
 	screen := d.screen
@@ -86,11 +108,48 @@ func (d *Deck) RegisterSurface(s Surface) {
 		return
 	}
 
-	if err := s.Refresh(snapshot); err != nil {
-		log.Printf("error refreshing surface %s: %s\n", s.ID(), err.Error())
+	if err := d.refreshSurface(s, snapshot); err != nil {
+		deckLogger.Error("unable to refresh surface", "surface", s.ID(), "err", err)
 	}
 }
 
+// UnregisterSurface removes a control surface, such as one which has been disconnected, so it no longer receives renders.
+func (d *Deck) UnregisterSurface(s Surface) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for idx, surface := range d.surfaces {
+		if surface == s {
+			d.surfaces = append(d.surfaces[:idx], d.surfaces[idx+1:]...)
+			return
+		}
+	}
+}
+
+// SetRecorder starts recording key presses, screen changes and rendered state to the recorder.
+// The current state is recorded immediately. Passing nil stops recording.
+func (d *Deck) SetRecorder(r *Recorder) {
+	d.lock.Lock()
+	d.recorder = r
+	snapshot := d.snapshotLocked()
+	d.lock.Unlock()
+
+	r.recordScreenChange(snapshot.ScreenName)
+	r.recordRender(snapshot)
+}
+
+// KeyPressListener is called with the screen, key and press type of each key press.
+type KeyPressListener func(screenName string, keyID int, t KeyPressType)
+
+// AddKeyPressListener registers a listener which is called for every key press before the screen handles it.
+// Listeners are called from the goroutine handling the press, so they should return quickly.
+func (d *Deck) AddKeyPressListener(l KeyPressListener) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.keyPressListeners = append(d.keyPressListeners, l)
+}
+
 // ChangeScreen allows for the currently displayed screen to be updated to the specified screen.
 func (d *Deck) ChangeScreen(ctx context.Context, s Screen) {
 	d.renderScreen(s)
@@ -113,6 +172,15 @@ func (d *Deck) Screen() Screen {
 	return d.screen
 }
 
+// Path returns the names of the screens navigated through to reach the active screen, starting with the first
+// screen shown. Returning to a screen already on the path drops everything after it.
+func (d *Deck) Path() []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return append([]string(nil), d.path...)
+}
+
 // ID returns the ID of the first registered control surface.
 func (d *Deck) ID() string {
 	d.lock.RLock()
@@ -133,6 +201,19 @@ func (d *Deck) KeyCount() int {
 	return len(d.keys)
 }
 
+// DialCount returns the number of dials on the registered control surfaces.
+func (d *Deck) DialCount() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return len(d.strip)
+}
+
+// RenderStats returns statistics about the cache of key images rendered for surfaces.
+func (d *Deck) RenderStats() RenderStats {
+	return d.renderer.Stats()
+}
+
 // Snapshot returns the current rendered control-surface state.
 func (d *Deck) Snapshot() Snapshot {
 	d.lock.RLock()
@@ -149,7 +230,7 @@ func (d *Deck) Clear() {
 
 	for _, surface := range surfaces {
 		if err := surface.Clear(); err != nil {
-			log.Printf("error clearing surface %s: %s\n", surface.ID(), err.Error())
+			deckLogger.Error("unable to clear surface", "surface", surface.ID(), "err", err)
 		}
 	}
 }
@@ -169,24 +250,36 @@ func (d *Deck) PressKey(ctx context.Context, keyID int, t KeyPressType) error {
 	}
 	screen := d.screen
 	screenName := screen.Name()
+	recorder := d.recorder
+	listeners := d.keyPressListeners
 	d.lock.RUnlock()
 
+	start := time.Now()
+	defer func() {
+		metrics.KeyPressDuration.WithLabelValues(screenName).Observe(time.Since(start).Seconds())
+	}()
+	metrics.KeyPresses.WithLabelValues(screenName, strconv.Itoa(keyID), keyPressTypeName(t)).Inc()
+	recorder.recordKeyPress(screenName, keyID, t)
+	for _, l := range listeners {
+		l(screenName, keyID, t)
+	}
+
 	action, err := screen.KeyPressed(keyCtx, keyID, t)
 	if err != nil {
-		log.Printf("screen %s got error handling key press for key %d: %s\n", screenName, keyID, err.Error())
+		deckLogger.Error("screen failed to handle key press", "screen", screenName, "key", keyID, "err", err)
 		return err
 	}
 
 	switch action.Action {
 	case KeyPressActionChangeScreen:
 		if action.NewScreen == nil {
-			log.Fatal("deck asked to update screen but provided null screen")
+			logging.Fatal(deckLogger, "deck asked to update screen but provided null screen", "screen", screenName, "key", keyID)
 			return nil
 		}
 		d.renderScreen(action.NewScreen)
 	case KeyPressActionUpdateIcon:
 		if action.NewIcon == nil {
-			log.Fatal("deck asked to update icon but provided null icon")
+			logging.Fatal(deckLogger, "deck asked to update icon but provided null icon", "screen", screenName, "key", keyID)
 			return nil
 		}
 
@@ -198,8 +291,10 @@ func (d *Deck) PressKey(ctx context.Context, keyID int, t KeyPressType) error {
 		d.keys[keyID] = action.NewIcon
 		snapshot := d.snapshotLocked()
 		surfaces := d.surfacesLocked()
+		recorder := d.recorder
 		d.lock.Unlock()
 
+		recorder.recordRender(snapshot)
 		d.updateKey(surfaces, snapshot, keyID)
 	case KeyPressActionRefreshScreen:
 		d.renderScreen(screen)
@@ -210,6 +305,76 @@ func (d *Deck) PressKey(ctx context.Context, keyID int, t KeyPressType) error {
 	return nil
 }
 
+// DialChanged handles a dial being rotated, pressed or its touch strip segment touched on any control surface.
+// Screens which don't implement DialScreen ignore dial events.
+func (d *Deck) DialChanged(ctx context.Context, event DialEvent) error {
+	dialCtx, dialCtxCancel := context.WithTimeout(ctx, keyHandlingTimeoutDuration)
+	defer dialCtxCancel()
+
+	d.pressLock.Lock()
+	defer d.pressLock.Unlock()
+
+	d.lock.RLock()
+	if event.Dial < 0 || event.Dial >= len(d.strip) {
+		d.lock.RUnlock()
+		return fmt.Errorf("invalid dial id %d", event.Dial)
+	}
+	screen := d.screen
+	recorder := d.recorder
+	d.lock.RUnlock()
+
+	event.Delta = min(max(event.Delta, -MaxDialDelta), MaxDialDelta)
+
+	recorder.recordDial(screen.Name(), event)
+
+	dialScreen, ok := screen.(DialScreen)
+	if !ok {
+		return nil
+	}
+
+	action, err := dialScreen.DialChanged(dialCtx, event)
+	if err != nil {
+		deckLogger.Error("screen failed to handle dial event", "screen", screen.Name(), "dial", event.Dial, "event", event.Type, "err", err)
+		return err
+	}
+
+	switch action.Action {
+	case KeyPressActionChangeScreen:
+		if action.NewScreen == nil {
+			return fmt.Errorf("screen %s asked to change to a null screen", screen.Name())
+		}
+		d.renderScreen(action.NewScreen)
+	case KeyPressActionUpdateIcon:
+		if action.NewIcon == nil {
+			return fmt.Errorf("screen %s asked to update dial %d to a null icon", screen.Name(), event.Dial)
+		}
+
+		d.lock.Lock()
+		d.strip[event.Dial] = action.NewIcon
+		snapshot := d.snapshotLocked()
+		surfaces := d.surfacesLocked()
+		d.lock.Unlock()
+
+		recorder.recordRender(snapshot)
+		d.refreshSurfaces(surfaces, snapshot)
+	case KeyPressActionRefreshScreen:
+		d.renderScreen(screen)
+	case KeyPressActionNoop:
+		// Nothing to do!
+	}
+
+	return nil
+}
+
+// KeyPressTypeForDuration returns the type of key press for a key which was held down for the specified duration.
+func KeyPressTypeForDuration(held time.Duration) KeyPressType {
+	if held > longKeypressDuration {
+		return KeyPressLong
+	}
+
+	return KeyPressShort
+}
+
 func (d *Deck) renderScreen(screen Screen) {
 	if screen == nil {
 		return
@@ -217,38 +382,85 @@ func (d *Deck) renderScreen(screen Screen) {
 
 	d.lock.RLock()
 	keyCount := len(d.keys)
+	dialCount := len(d.strip)
 	d.lock.RUnlock()
 
 	renderedKeys := make([]image.Image, keyCount)
 	keys := screen.Show()
 	copy(renderedKeys, keys)
 
+	renderedStrip := make([]image.Image, dialCount)
+	if dialScreen, ok := screen.(DialScreen); ok && dialCount > 0 {
+		copy(renderedStrip, dialScreen.ShowStrip(dialCount))
+	}
+
 	d.lock.Lock()
+	changed := d.screen == nil || d.screen.Name() != screen.Name()
+	if changed {
+		d.path = appendScreenPath(d.path, screen.Name())
+	}
 	d.screen = screen
 	d.keys = renderedKeys
+	d.strip = renderedStrip
 	snapshot := d.snapshotLocked()
 	surfaces := d.surfacesLocked()
+	recorder := d.recorder
 	d.lock.Unlock()
 
+	if changed {
+		recorder.recordScreenChange(snapshot.ScreenName)
+	}
+	recorder.recordRender(snapshot)
+
 	d.refreshSurfaces(surfaces, snapshot)
 }
 
+func appendScreenPath(path []string, name string) []string {
+	for i, visited := range path {
+		if visited == name {
+			return path[:i+1]
+		}
+	}
+	return append(path, name)
+}
+
 func (d *Deck) refreshSurfaces(surfaces []Surface, snapshot Snapshot) {
 	for _, surface := range surfaces {
-		if err := surface.Refresh(snapshot); err != nil {
-			log.Printf("error refreshing surface %s for screen %s: %s\n", surface.ID(), snapshot.ScreenName, err.Error())
+		if err := d.refreshSurface(surface, snapshot); err != nil {
+			deckLogger.Error("unable to refresh surface", "surface", surface.ID(), "screen", snapshot.ScreenName, "err", err)
 		}
 	}
 }
 
+// refreshSurface renders the snapshot for the surface and draws it, recording how long it took.
+func (d *Deck) refreshSurface(surface Surface, snapshot Snapshot) error {
+	duration := metrics.SurfaceRefreshDuration.WithLabelValues(surface.ID())
+	start := time.Now()
+	defer func() {
+		duration.Observe(time.Since(start).Seconds())
+	}()
+
+	return surface.Refresh(d.renderFor(surface, snapshot))
+}
+
 func (d *Deck) updateKey(surfaces []Surface, snapshot Snapshot, keyID int) {
 	for _, surface := range surfaces {
-		if err := surface.UpdateKey(snapshot, keyID); err != nil {
-			log.Printf("deck got error setting image for key %d on surface %s: %s\n", keyID, surface.ID(), err.Error())
+		if err := surface.UpdateKey(d.renderFor(surface, snapshot), keyID); err != nil {
+			deckLogger.Error("unable to update key image", "surface", surface.ID(), "key", keyID, "err", err)
 		}
 	}
 }
 
+// renderFor renders the snapshot to the native resolution of the surface, if it has one.
+func (d *Deck) renderFor(surface Surface, snapshot Snapshot) Snapshot {
+	rs, ok := surface.(RenderingSurface)
+	if !ok {
+		return snapshot
+	}
+
+	return d.renderer.RenderSnapshot(snapshot, rs.RenderSpec())
+}
+
 func (d *Deck) surfacesLocked() []Surface {
 	return append([]Surface(nil), d.surfaces...)
 }
@@ -257,11 +469,18 @@ func (d *Deck) snapshotLocked() Snapshot {
 	keys := make([]image.Image, len(d.keys))
 	copy(keys, d.keys)
 
+	var strip []image.Image
+	if len(d.strip) > 0 {
+		strip = make([]image.Image, len(d.strip))
+		copy(strip, d.strip)
+	}
+
 	return Snapshot{
 		ScreenName: d.screen.Name(),
 		Rows:       d.rows,
 		Columns:    d.columns,
 		Keys:       keys,
+		Strip:      strip,
 	}
 }
 
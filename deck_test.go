@@ -2,8 +2,10 @@ package deskpad
 
 import (
 	"context"
+	"fmt"
 	"image"
 	"image/color"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -140,6 +142,28 @@ func TestPressKeyChangeScreenRefreshesAllSurfaces(t *testing.T) {
 	}
 }
 
+func TestChangeScreenTracksNavigationPath(t *testing.T) {
+	home := &fakeScreen{name: "home"}
+	deck := NewDeck(home)
+
+	deck.ChangeScreen(context.Background(), &fakeScreen{name: "media"})
+	deck.ChangeScreen(context.Background(), &fakeScreen{name: "settings"})
+	deck.RefreshScreen()
+	if path := deck.Path(); !reflect.DeepEqual(path, []string{"home", "media", "settings"}) {
+		t.Fatalf("path = %v, want home, media, settings", path)
+	}
+
+	deck.ChangeScreen(context.Background(), &fakeScreen{name: "media"})
+	if path := deck.Path(); !reflect.DeepEqual(path, []string{"home", "media"}) {
+		t.Fatalf("path after going back = %v, want home, media", path)
+	}
+
+	deck.ChangeScreen(context.Background(), home)
+	if path := deck.Path(); !reflect.DeepEqual(path, []string{"home"}) {
+		t.Fatalf("path after returning home = %v, want home", path)
+	}
+}
+
 func TestPressKeyRefreshActionCallsShowAndRedrawsAllKeys(t *testing.T) {
 	icon := testImage(color.RGBA{R: 100, A: 255})
 	screen := &fakeScreen{
@@ -166,6 +190,28 @@ func TestPressKeyRefreshActionCallsShowAndRedrawsAllKeys(t *testing.T) {
 	}
 }
 
+func TestPressKeyNotifiesListeners(t *testing.T) {
+	screen := &fakeScreen{
+		name:   "home",
+		action: KeyPressAction{Action: KeyPressActionNoop},
+	}
+	deck := NewDeck(screen)
+
+	var presses []string
+	deck.AddKeyPressListener(func(screenName string, keyID int, t KeyPressType) {
+		presses = append(presses, fmt.Sprintf("%s %d %s", screenName, keyID, keyPressTypeName(t)))
+	})
+
+	deck.PressKey(context.Background(), 3, KeyPressLong)
+	if err := deck.PressKey(context.Background(), 99, KeyPressShort); err == nil {
+		t.Fatalf("PressKey with invalid key returned no error")
+	}
+
+	if want := []string{"home 3 long"}; !reflect.DeepEqual(presses, want) {
+		t.Fatalf("presses = %q, want %q", presses, want)
+	}
+}
+
 func TestDeckGeometryUsesStreamDeckKeyCount(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -239,3 +285,99 @@ func testImage(c color.Color) image.Image {
 	img.Set(0, 0, c)
 	return img
 }
+
+type fakeDialScreen struct {
+	fakeScreen
+	strip  []image.Image
+	events []DialEvent
+	action KeyPressAction
+}
+
+func (s *fakeDialScreen) ShowStrip(dialCount int) []image.Image {
+	return s.strip
+}
+
+func (s *fakeDialScreen) DialChanged(ctx context.Context, event DialEvent) (KeyPressAction, error) {
+	s.events = append(s.events, event)
+	return s.action, nil
+}
+
+type fakeDialSurface struct {
+	fakeSurface
+	dials     int
+	lastStrip []image.Image
+}
+
+func (s *fakeDialSurface) DialCount() int {
+	return s.dials
+}
+
+func (s *fakeDialSurface) Refresh(snapshot Snapshot) error {
+	s.lastStrip = append([]image.Image(nil), snapshot.Strip...)
+	return s.fakeSurface.Refresh(snapshot)
+}
+
+func TestDeckDialChangedUpdatesStripSegment(t *testing.T) {
+	volume := testImage(color.RGBA{R: 255, A: 255})
+	muted := testImage(color.RGBA{B: 255, A: 255})
+	screen := &fakeDialScreen{
+		fakeScreen: fakeScreen{name: "media"},
+		strip:      []image.Image{volume},
+		action:     KeyPressAction{Action: KeyPressActionUpdateIcon, NewIcon: muted},
+	}
+	deck := NewDeck(screen)
+	surface := &fakeDialSurface{fakeSurface: fakeSurface{id: "plus"}, dials: 4}
+	deck.RegisterSurface(surface)
+
+	if deck.DialCount() != 4 {
+		t.Fatalf("dial count = %d, want 4", deck.DialCount())
+	}
+	if len(surface.lastStrip) != 4 || surface.lastStrip[0] != volume {
+		t.Fatalf("registered surface strip = %v, want volume segment first", surface.lastStrip)
+	}
+
+	event := DialEvent{Dial: 0, Type: DialPress}
+	if err := deck.DialChanged(context.Background(), event); err != nil {
+		t.Fatalf("DialChanged returned error: %s", err)
+	}
+	if len(screen.events) != 1 || screen.events[0] != event {
+		t.Fatalf("screen received events %v, want %v", screen.events, event)
+	}
+	if surface.lastStrip[0] != muted || deck.Snapshot().Strip[0] != muted {
+		t.Fatalf("strip segment was not updated")
+	}
+
+	if err := deck.DialChanged(context.Background(), DialEvent{Dial: 4, Type: DialRotate, Delta: 1}); err == nil {
+		t.Fatalf("DialChanged accepted an invalid dial")
+	}
+}
+
+func TestDeckDialChangedClampsDelta(t *testing.T) {
+	screen := &fakeDialScreen{
+		fakeScreen: fakeScreen{name: "media"},
+		action:     KeyPressAction{Action: KeyPressActionNoop},
+	}
+	deck := NewDeck(screen)
+	deck.RegisterSurface(&fakeDialSurface{fakeSurface: fakeSurface{id: "plus"}, dials: 1})
+
+	for _, delta := range []int{1 << 30, -1 << 30} {
+		if err := deck.DialChanged(context.Background(), DialEvent{Dial: 0, Type: DialRotate, Delta: delta}); err != nil {
+			t.Fatalf("DialChanged returned error: %s", err)
+		}
+	}
+	if len(screen.events) != 2 || screen.events[0].Delta != MaxDialDelta || screen.events[1].Delta != -MaxDialDelta {
+		t.Fatalf("screen received events %v, want deltas clamped to ±%d", screen.events, MaxDialDelta)
+	}
+}
+
+func TestDeckDialChangedIgnoredByScreensWithoutDials(t *testing.T) {
+	deck := NewDeck(&fakeScreen{name: "home"})
+	deck.RegisterSurface(&fakeDialSurface{fakeSurface: fakeSurface{id: "plus"}, dials: 2})
+
+	if err := deck.DialChanged(context.Background(), DialEvent{Dial: 1, Type: DialRotate, Delta: -2}); err != nil {
+		t.Fatalf("DialChanged returned error: %s", err)
+	}
+	if strip := deck.Snapshot().Strip; len(strip) != 2 || strip[0] != nil || strip[1] != nil {
+		t.Fatalf("strip = %v, want two empty segments", strip)
+	}
+}
@@ -0,0 +1,66 @@
+package deskpad
+
+import (
+	"context"
+	"image"
+)
+
+// DialEventType indicates how a dial, or the touch strip segment above it, was used.
+type DialEventType int
+
+const (
+	DialRotate DialEventType = iota
+	DialPress
+	DialTouch
+)
+
+// MaxDialDelta bounds the detents handled from a single rotate event, so a bogus delta from a client can't make a
+// screen step through thousands of volume or seek changes.
+const MaxDialDelta = 32
+
+// DialEvent describes input from a rotary encoder such as those on the Stream Deck+.
+type DialEvent struct {
+	Dial int
+	Type DialEventType
+	// Delta is the number of detents turned for rotate events; positive values are clockwise.
+	Delta int
+	// PressType indicates if a press or touch was short or long.
+	PressType KeyPressType
+}
+
+// DialScreen is implemented by screens which make use of dials and the touch strip, on surfaces which have them.
+// The strip is split into one segment per dial; ShowStrip returns the image for each segment.
+// Returning KeyPressActionUpdateIcon from DialChanged replaces the strip segment of the dial.
+type DialScreen interface {
+	Screen
+	ShowStrip(dialCount int) []image.Image
+	DialChanged(ctx context.Context, event DialEvent) (KeyPressAction, error)
+}
+
+// DialSurface is implemented by surfaces which have dials and a touch strip.
+type DialSurface interface {
+	Surface
+	DialCount() int
+}
+
+func (t DialEventType) String() string {
+	switch t {
+	case DialRotate:
+		return "rotate"
+	case DialPress:
+		return "press"
+	case DialTouch:
+		return "touch"
+	}
+	return "unknown"
+}
+
+// ParseDialEventType converts the name of a dial event type, as returned by String, back to the type.
+func ParseDialEventType(name string) (DialEventType, bool) {
+	for _, t := range []DialEventType{DialRotate, DialPress, DialTouch} {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return DialRotate, false
+}
@@ -0,0 +1,84 @@
+package deskpad
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"sync"
+)
+
+const defaultEncodedImageCacheSize = 256
+
+// EncodedImageCache holds encoded copies of key images, keyed by their content hash, so icons which
+// are shown repeatedly are only encoded once. The oldest entries are evicted once the cache is full.
+type EncodedImageCache struct {
+	encode     func(io.Writer, image.Image) error
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[string][]byte
+	order   []string
+}
+
+// NewEncodedImageCache creates a cache which encodes images with the supplied encoder, such as png.Encode.
+// If maxEntries is not positive a default size is used.
+func NewEncodedImageCache(encode func(io.Writer, image.Image) error, maxEntries int) *EncodedImageCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultEncodedImageCacheSize
+	}
+
+	return &EncodedImageCache{
+		encode:     encode,
+		maxEntries: maxEntries,
+		entries:    make(map[string][]byte),
+	}
+}
+
+// Encode returns the content hash and encoded form of the image, encoding it only if it is not already cached.
+func (c *EncodedImageCache) Encode(img image.Image) (string, []byte, error) {
+	hash := ImageHash(img)
+
+	c.lock.Lock()
+	data, ok := c.entries[hash]
+	c.lock.Unlock()
+	if ok {
+		return hash, data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := c.encode(&buf, img); err != nil {
+		return "", nil, err
+	}
+	data = buf.Bytes()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[hash]; !ok {
+		if len(c.order) >= c.maxEntries {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.entries[hash] = data
+		c.order = append(c.order, hash)
+	}
+
+	return hash, data, nil
+}
+
+// Len returns the number of cached images.
+func (c *EncodedImageCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.entries)
+}
+
+// Get returns the encoded image with the given content hash, if it is cached.
+func (c *EncodedImageCache) Get(hash string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	data, ok := c.entries[hash]
+	return data, ok
+}
@@ -0,0 +1,49 @@
+package deskpad
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestEncodedImageCacheEncodesOnce(t *testing.T) {
+	encodes := 0
+	cache := NewEncodedImageCache(func(w io.Writer, img image.Image) error {
+		encodes++
+		return png.Encode(w, img)
+	}, 2)
+
+	red := testImage(color.RGBA{R: 255, A: 255})
+	hash, data, err := cache.Encode(red)
+	if err != nil {
+		t.Fatalf("Encode returned error: %s", err)
+	}
+	if hash != ImageHash(red) || len(data) == 0 {
+		t.Fatalf("Encode returned hash %q and %d bytes", hash, len(data))
+	}
+	if cached, ok := cache.Get(hash); !ok || len(cached) != len(data) {
+		t.Fatalf("Get(%q) = %d bytes, %t; want the encoded image", hash, len(cached), ok)
+	}
+
+	if _, _, err := cache.Encode(testImage(color.RGBA{R: 255, A: 255})); err != nil {
+		t.Fatalf("Encode returned error: %s", err)
+	}
+	if encodes != 1 {
+		t.Fatalf("identical icon encoded %d times, want 1", encodes)
+	}
+
+	cache.Encode(testImage(color.RGBA{G: 255, A: 255}))
+	cache.Encode(testImage(color.RGBA{B: 255, A: 255}))
+	if cache.Len() != 2 {
+		t.Fatalf("cache holds %d images, want 2", cache.Len())
+	}
+	if _, ok := cache.Get(hash); ok {
+		t.Fatalf("evicted icon is still cached")
+	}
+	cache.Encode(red)
+	if encodes != 4 {
+		t.Fatalf("evicted icon was not re-encoded: %d encodes", encodes)
+	}
+}
@@ -0,0 +1,33 @@
+package deskpad
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"image"
+	"image/draw"
+)
+
+// ImageHash returns a hash of the pixel content of the image, allowing rendered keys to be compared
+// without keeping the images themselves. An empty string is returned for a nil image.
+func ImageHash(img image.Image) string {
+	if img == nil {
+		return ""
+	}
+
+	bounds := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+	}
+
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, [2]int32{int32(bounds.Dx()), int32(bounds.Dy())})
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		offset := rgba.PixOffset(bounds.Min.X, y)
+		h.Write(rgba.Pix[offset : offset+bounds.Dx()*4])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
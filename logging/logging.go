@@ -0,0 +1,157 @@
+// Package logging provides the structured loggers used across deskpad. Each component gets its own logger, and
+// the output format and level of each component can be changed at runtime with Configure.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Output formats supported by Configure.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config controls how log records are written.
+type Config struct {
+	// Format is either FormatText or FormatJSON; it defaults to text.
+	Format string
+	// Level is the minimum level logged by components without their own level.
+	Level slog.Level
+	// Components overrides the level of individual components, keyed by component name.
+	Components map[string]slog.Level
+	// Output is where records are written; it defaults to stderr.
+	Output io.Writer
+}
+
+type state struct {
+	handler    slog.Handler
+	level      slog.Level
+	components map[string]slog.Level
+}
+
+func (s *state) levelFor(component string) slog.Level {
+	if level, ok := s.components[component]; ok {
+		return level
+	}
+	return s.level
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	Configure(Config{})
+}
+
+// Configure replaces the logging configuration. Loggers which have already been created pick up the change.
+func Configure(cfg Config) error {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	// Filtering happens per component, so the underlying handler accepts everything.
+	opts := &slog.HandlerOptions{Level: slog.Level(-1 << 10)}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(out, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	current.Store(&state{
+		handler:    handler,
+		level:      cfg.Level,
+		components: cfg.Components,
+	})
+	return nil
+}
+
+// ParseLevel parses a level name such as "debug" or "warn".
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, err
+	}
+	return level, nil
+}
+
+// Logger returns a logger for the named component. Records carry the component as an attribute and are filtered
+// by the component's level.
+func Logger(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component})
+}
+
+// Fatal logs the message at error level and exits.
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// componentHandler looks up the configured handler when a record is logged, so loggers held in package variables
+// follow later calls to Configure.
+type componentHandler struct {
+	component string
+	// ops are the WithAttrs and WithGroup calls made on this handler, replayed onto the configured handler.
+	ops []func(slog.Handler) slog.Handler
+
+	lock    sync.Mutex
+	base    *state
+	derived slog.Handler
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().levelFor(h.component)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler {
+		return next.WithAttrs(attrs)
+	})
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler {
+		return next.WithGroup(name)
+	})
+}
+
+func (h *componentHandler) with(op func(slog.Handler) slog.Handler) *componentHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &componentHandler{component: h.component, ops: append(ops, op)}
+}
+
+// handler returns the configured handler with the component and any attributes applied, rebuilding it if the
+// configuration has changed.
+func (h *componentHandler) handler() slog.Handler {
+	base := current.Load()
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.base != base {
+		handler := base.handler.WithAttrs([]slog.Attr{slog.String("component", h.component)})
+		for _, op := range h.ops {
+			handler = op(handler)
+		}
+		h.base = base
+		h.derived = handler
+	}
+	return h.derived
+}
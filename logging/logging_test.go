@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggerFollowsComponentLevels(t *testing.T) {
+	defer Configure(Config{})
+
+	// Loggers are usually created before the configuration is loaded.
+	deck := Logger("deck").With("surface", "web")
+	api := Logger("api")
+
+	var out bytes.Buffer
+	if err := Configure(Config{
+		Format:     FormatJSON,
+		Level:      slog.LevelWarn,
+		Components: map[string]slog.Level{"deck": slog.LevelDebug},
+		Output:     &out,
+	}); err != nil {
+		t.Fatalf("Configure returned error: %s", err)
+	}
+
+	deck.Debug("key pressed", "key", 3)
+	api.Info("request handled")
+	api.Warn("token rejected")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d records, want 2:\n%s", len(lines), out.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("unmarshal record: %s", err)
+	}
+	if record["component"] != "deck" || record["surface"] != "web" || record["key"] != float64(3) || record["level"] != "DEBUG" {
+		t.Fatalf("record = %v, want the deck debug record with its attributes", record)
+	}
+	if !strings.Contains(lines[1], `"msg":"token rejected"`) {
+		t.Fatalf("record = %s, want the api warning", lines[1])
+	}
+}
+
+func TestConfigureRejectsUnknownFormat(t *testing.T) {
+	if err := Configure(Config{Format: "xml"}); err == nil {
+		t.Fatalf("Configure accepted an unknown format")
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Fatalf("ParseLevel accepted an unknown level")
+	}
+}
@@ -0,0 +1,93 @@
+// Package metrics contains the Prometheus metrics exported by deskpad.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every deskpad metric along with the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// KeyPresses counts key presses by screen, key and press type.
+	KeyPresses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "deskpad",
+		Name:      "key_presses_total",
+		Help:      "Key presses handled by the deck, by screen, key and press type.",
+	}, []string{"screen", "key", "type"})
+
+	// KeyPressDuration measures how long screens take to handle a key press.
+	KeyPressDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "deskpad",
+		Name:      "key_press_duration_seconds",
+		Help:      "Time taken to handle a key press, including any redraw it causes.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2},
+	}, []string{"screen"})
+
+	// SurfaceRefreshDuration measures how long it takes to redraw a control surface.
+	SurfaceRefreshDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "deskpad",
+		Name:      "surface_refresh_duration_seconds",
+		Help:      "Time taken to render and send a full screen to a control surface.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5},
+	}, []string{"surface"})
+
+	// EventSubscribers tracks the clients connected to each server-sent event stream.
+	EventSubscribers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "deskpad",
+		Name:      "event_subscribers",
+		Help:      "Clients subscribed to a server-sent event stream.",
+	}, []string{"stream"})
+
+	// WeatherReconnects counts attempts to reopen the weather stream after it fails.
+	WeatherReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "deskpad",
+		Name:      "weather_stream_reconnects_total",
+		Help:      "Attempts to reconnect to the weather service after the stream ended.",
+	})
+
+	// SpotifyAPIErrors counts failed Spotify API requests by status code, or "error" if no response was received.
+	SpotifyAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "deskpad",
+		Name:      "spotify_api_errors_total",
+		Help:      "Spotify API requests which failed, by response status code.",
+	}, []string{"code"})
+
+	// PlaylistRefreshDuration measures how long it takes to reload the playlists and their artwork.
+	PlaylistRefreshDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "deskpad",
+		Name:      "playlist_refresh_duration_seconds",
+		Help:      "Time taken to refresh the playlists from Spotify.",
+		Buckets:   []float64{.25, .5, 1, 2.5, 5, 10, 30, 60},
+	})
+	// WebhookDeliveries counts completed webhook deliveries by webhook and result, either delivered or failed.
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "deskpad",
+		Name:      "webhook_deliveries_total",
+		Help:      "Events sent to webhooks, by webhook and whether they were delivered after any retries.",
+	}, []string{"webhook", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		KeyPresses,
+		KeyPressDuration,
+		SurfaceRefreshDuration,
+		EventSubscribers,
+		WeatherReconnects,
+		SpotifyAPIErrors,
+		PlaylistRefreshDuration,
+		WebhookDeliveries,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
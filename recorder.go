@@ -0,0 +1,267 @@
+package deskpad
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordedEventType indicates what happened in a recorded deck session.
+type RecordedEventType string
+
+const (
+	RecordedKeyPress     RecordedEventType = "keyPress"
+	RecordedScreenChange RecordedEventType = "screenChange"
+	RecordedRender       RecordedEventType = "render"
+	RecordedDial         RecordedEventType = "dial"
+)
+
+// RecordedEvent is a single entry in a recorded deck session. Rendered keys are stored as image hashes.
+type RecordedEvent struct {
+	Time      time.Time         `json:"time"`
+	Type      RecordedEventType `json:"type"`
+	Screen    string            `json:"screen"`
+	KeyID     int               `json:"keyId"`
+	PressType string            `json:"pressType,omitempty"`
+	Keys      []string          `json:"keys,omitempty"`
+	Strip     []string          `json:"strip,omitempty"`
+
+	Dial       int    `json:"dial,omitempty"`
+	DialAction string `json:"dialAction,omitempty"`
+	Delta      int    `json:"delta,omitempty"`
+}
+
+// Recorder writes a timestamped log of key presses, screen changes and rendered state as JSON lines.
+type Recorder struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+	err  error
+}
+
+// NewRecorder creates a recorder which writes the session log to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error encountered writing the log, if any.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) record(event RecordedEvent) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	event.Time = time.Now()
+	r.err = r.enc.Encode(event)
+}
+
+func (r *Recorder) recordKeyPress(screenName string, keyID int, t KeyPressType) {
+	r.record(RecordedEvent{Type: RecordedKeyPress, Screen: screenName, KeyID: keyID, PressType: keyPressTypeName(t)})
+}
+
+func (r *Recorder) recordDial(screenName string, event DialEvent) {
+	r.record(RecordedEvent{
+		Type:       RecordedDial,
+		Screen:     screenName,
+		Dial:       event.Dial,
+		DialAction: event.Type.String(),
+		Delta:      event.Delta,
+		PressType:  keyPressTypeName(event.PressType),
+	})
+}
+
+func (r *Recorder) recordScreenChange(screenName string) {
+	r.record(RecordedEvent{Type: RecordedScreenChange, Screen: screenName})
+}
+
+func (r *Recorder) recordRender(snapshot Snapshot) {
+	if r == nil {
+		return
+	}
+
+	r.record(RecordedEvent{Type: RecordedRender, Screen: snapshot.ScreenName, Keys: imageHashes(snapshot.Keys), Strip: imageHashes(snapshot.Strip)})
+}
+
+func imageHashes(imgs []image.Image) []string {
+	if len(imgs) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, len(imgs))
+	for i, img := range imgs {
+		hashes[i] = ImageHash(img)
+	}
+	return hashes
+}
+
+func keyPressTypeName(t KeyPressType) string {
+	if t == KeyPressLong {
+		return "long"
+	}
+	return "short"
+}
+
+// Divergence describes a difference between the recorded and replayed state of the deck.
+type Divergence struct {
+	// Step is the number of key presses replayed before the divergence was detected; 0 is the initial state.
+	Step  int
+	Press *RecordedEvent
+
+	ExpectedScreen string
+	ActualScreen   string
+	// Keys lists the IDs of keys whose rendered image differs.
+	Keys []int
+	// Strip lists the IDs of dials whose touch strip segment differs.
+	Strip []int
+}
+
+func (dv Divergence) String() string {
+	var sb strings.Builder
+	switch {
+	case dv.Press == nil:
+		sb.WriteString("initial state")
+	case dv.Press.Type == RecordedDial:
+		fmt.Fprintf(&sb, "step %d (%s of dial %d on %s)", dv.Step, dv.Press.DialAction, dv.Press.Dial, dv.Press.Screen)
+	default:
+		fmt.Fprintf(&sb, "step %d (%s press of key %d on %s)", dv.Step, dv.Press.PressType, dv.Press.KeyID, dv.Press.Screen)
+	}
+	if dv.ExpectedScreen != dv.ActualScreen {
+		fmt.Fprintf(&sb, ": screen %q, want %q", dv.ActualScreen, dv.ExpectedScreen)
+	}
+	if len(dv.Keys) > 0 {
+		fmt.Fprintf(&sb, ": keys %v differ", dv.Keys)
+	}
+	if len(dv.Strip) > 0 {
+		fmt.Fprintf(&sb, ": strip segments %v differ", dv.Strip)
+	}
+	return sb.String()
+}
+
+// Replay drives the deck with the key presses and dial events from a recorded session, comparing the rendered state after
+// each press with what was recorded. The deck should be configured with the same screens and key count as
+// the recorded deck, and be showing the screen the recording started on.
+func Replay(ctx context.Context, r io.Reader, d *Deck) ([]Divergence, error) {
+	var divergences []Divergence
+
+	// The expected state is the last render recorded before the next key press.
+	var expected *RecordedEvent
+	var press *RecordedEvent
+	step := 0
+
+	compare := func() {
+		if expected == nil {
+			return
+		}
+
+		actual := d.Snapshot()
+		dv := Divergence{
+			Step:           step,
+			Press:          press,
+			ExpectedScreen: expected.Screen,
+			ActualScreen:   actual.ScreenName,
+		}
+
+		dv.Keys = diffHashes(expected.Keys, imageHashes(actual.Keys))
+		dv.Strip = diffHashes(expected.Strip, imageHashes(actual.Strip))
+
+		if dv.ExpectedScreen != dv.ActualScreen || len(dv.Keys) > 0 || len(dv.Strip) > 0 {
+			divergences = append(divergences, dv)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var event RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return divergences, fmt.Errorf("invalid recorded event: %w", err)
+		}
+
+		switch event.Type {
+		case RecordedRender:
+			expected = &event
+		case RecordedKeyPress:
+			compare()
+			if err := ctx.Err(); err != nil {
+				return divergences, err
+			}
+
+			t := KeyPressShort
+			if event.PressType == "long" {
+				t = KeyPressLong
+			}
+
+			step++
+			press = &event
+			if err := d.PressKey(ctx, event.KeyID, t); err != nil {
+				return divergences, fmt.Errorf("replaying step %d: %w", step, err)
+			}
+		case RecordedDial:
+			compare()
+			if err := ctx.Err(); err != nil {
+				return divergences, err
+			}
+
+			dialType, ok := ParseDialEventType(event.DialAction)
+			if !ok {
+				return divergences, fmt.Errorf("invalid recorded dial action %q", event.DialAction)
+			}
+			dialEvent := DialEvent{Dial: event.Dial, Type: dialType, Delta: event.Delta}
+			if event.PressType == "long" {
+				dialEvent.PressType = KeyPressLong
+			}
+
+			step++
+			press = &event
+			if err := d.DialChanged(ctx, dialEvent); err != nil {
+				return divergences, fmt.Errorf("replaying step %d: %w", step, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return divergences, err
+	}
+
+	compare()
+	return divergences, nil
+}
+
+// diffHashes returns the indexes at which the hashes differ.
+func diffHashes(want, got []string) []int {
+	var diff []int
+	for idx := 0; idx < max(len(want), len(got)); idx++ {
+		var w, g string
+		if idx < len(want) {
+			w = want[idx]
+		}
+		if idx < len(got) {
+			g = got[idx]
+		}
+		if w != g {
+			diff = append(diff, idx)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,109 @@
+package deskpad
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func newRecordingTestDeck(otherIcon image.Image) *Deck {
+	other := &fakeScreen{name: "other", showKeys: []image.Image{otherIcon}}
+	home := &fakeScreen{name: "home", showKeys: []image.Image{testImage(color.RGBA{R: 255, A: 255})}}
+	home.action = KeyPressAction{Action: KeyPressActionChangeScreen, NewScreen: other}
+	other.action = KeyPressAction{Action: KeyPressActionChangeScreen, NewScreen: home}
+
+	deck := NewDeck(home)
+	deck.RefreshScreen()
+	return deck
+}
+
+func TestRecorderLogsPressesScreenChangesAndRenders(t *testing.T) {
+	var log bytes.Buffer
+	deck := newRecordingTestDeck(testImage(color.RGBA{G: 255, A: 255}))
+	deck.SetRecorder(NewRecorder(&log))
+
+	if err := deck.PressKey(context.Background(), 0, KeyPressLong); err != nil {
+		t.Fatalf("PressKey returned error: %s", err)
+	}
+
+	var events []RecordedEvent
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var event RecordedEvent
+		if err := dec.Decode(&event); err != nil {
+			t.Fatalf("decode event: %s", err)
+		}
+		events = append(events, event)
+	}
+
+	wantTypes := []RecordedEventType{RecordedScreenChange, RecordedRender, RecordedKeyPress, RecordedScreenChange, RecordedRender}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("recorded %d events, want %d: %+v", len(events), len(wantTypes), events)
+	}
+	for i, want := range wantTypes {
+		if events[i].Type != want {
+			t.Fatalf("event %d type = %s, want %s", i, events[i].Type, want)
+		}
+		if events[i].Time.IsZero() {
+			t.Fatalf("event %d has no timestamp", i)
+		}
+	}
+	if events[2].Screen != "home" || events[2].PressType != "long" {
+		t.Fatalf("key press event = %+v, want long press on home", events[2])
+	}
+	if events[3].Screen != "other" || events[4].Keys[0] != ImageHash(testImage(color.RGBA{G: 255, A: 255})) {
+		t.Fatalf("render after press = %+v, want other screen with green key", events[4])
+	}
+}
+
+func TestReplayReportsDivergences(t *testing.T) {
+	var log bytes.Buffer
+	deck := newRecordingTestDeck(testImage(color.RGBA{G: 255, A: 255}))
+	deck.SetRecorder(NewRecorder(&log))
+	for i := 0; i < 3; i++ {
+		if err := deck.PressKey(context.Background(), 0, KeyPressShort); err != nil {
+			t.Fatalf("PressKey returned error: %s", err)
+		}
+	}
+	recording := log.Bytes()
+
+	divergences, err := Replay(context.Background(), bytes.NewReader(recording), newRecordingTestDeck(testImage(color.RGBA{G: 255, A: 255})))
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if len(divergences) != 0 {
+		t.Fatalf("Replay of identical deck diverged: %v", divergences)
+	}
+
+	divergences, err = Replay(context.Background(), bytes.NewReader(recording), newRecordingTestDeck(testImage(color.RGBA{B: 255, A: 255})))
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if len(divergences) != 2 {
+		t.Fatalf("Replay reported %d divergences, want 2: %v", len(divergences), divergences)
+	}
+	if divergences[0].Step != 1 || divergences[1].Step != 3 {
+		t.Fatalf("divergences at steps %d and %d, want 1 and 3", divergences[0].Step, divergences[1].Step)
+	}
+	if len(divergences[0].Keys) != 1 || divergences[0].Keys[0] != 0 {
+		t.Fatalf("divergent keys = %v, want [0]", divergences[0].Keys)
+	}
+}
+
+func TestImageHashComparesPixels(t *testing.T) {
+	red := testImage(color.RGBA{R: 255, A: 255})
+	paletted := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.RGBA{R: 255, A: 255}})
+
+	if ImageHash(red) != ImageHash(paletted) {
+		t.Fatalf("identical pixels hashed differently")
+	}
+	if ImageHash(red) == ImageHash(testImage(color.RGBA{G: 255, A: 255})) {
+		t.Fatalf("different pixels hashed identically")
+	}
+	if ImageHash(nil) != "" {
+		t.Fatalf("nil image hash = %q, want empty", ImageHash(nil))
+	}
+}
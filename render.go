@@ -0,0 +1,190 @@
+package deskpad
+
+import (
+	"container/list"
+	"image"
+	"image/draw"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const defaultRenderCacheSize = 512
+
+// RenderSpec describes the native resolution of the keys, and touch strip segments, of a surface.
+// A zero width or height leaves those images untouched.
+type RenderSpec struct {
+	KeyWidth  int
+	KeyHeight int
+	// Rotation is the clockwise rotation, in degrees, applied to key images; one of 0, 90, 180 or 270.
+	Rotation int
+
+	StripWidth  int
+	StripHeight int
+}
+
+// RenderingSurface is implemented by surfaces with a fixed native key resolution. The deck renders key
+// images to the spec of the surface before they are passed to Refresh or UpdateKey, so each surface doesn't
+// need to scale images itself.
+type RenderingSurface interface {
+	Surface
+	RenderSpec() RenderSpec
+}
+
+// RenderStats contains statistics about the render cache.
+type RenderStats struct {
+	Entries   int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+type renderKey struct {
+	img      image.Image
+	width    int
+	height   int
+	rotation int
+}
+
+type renderEntry struct {
+	key      renderKey
+	rendered image.Image
+}
+
+// Renderer scales, crops and rotates images to a target size. Results are cached by image identity and
+// target size, so images must not be modified after they have been rendered.
+type Renderer struct {
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[renderKey]*list.Element
+	lru     *list.List
+	stats   RenderStats
+}
+
+// NewRenderer creates a renderer which caches up to maxEntries rendered images.
+// If maxEntries is not positive a default size is used.
+func NewRenderer(maxEntries int) *Renderer {
+	if maxEntries <= 0 {
+		maxEntries = defaultRenderCacheSize
+	}
+
+	return &Renderer{
+		maxEntries: maxEntries,
+		entries:    make(map[renderKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Render returns the image scaled to cover the target size, cropped to it around the centre, then rotated
+// clockwise by the specified number of degrees. Images which already match are returned as-is.
+func (r *Renderer) Render(img image.Image, width, height, rotation int) image.Image {
+	if img == nil || width <= 0 || height <= 0 {
+		return img
+	}
+	// Rotations are normalized to a quarter turn in the range [0, 360).
+	rotation = (rotation%360 + 360) % 360 / 90 * 90
+	if rotation == 0 && img.Bounds().Dx() == width && img.Bounds().Dy() == height {
+		return img
+	}
+
+	key := renderKey{img: img, width: width, height: height, rotation: rotation}
+
+	r.lock.Lock()
+	if elem, ok := r.entries[key]; ok {
+		r.lru.MoveToFront(elem)
+		r.stats.Hits++
+		r.lock.Unlock()
+		return elem.Value.(*renderEntry).rendered
+	}
+	r.stats.Misses++
+	r.lock.Unlock()
+
+	rendered := renderImage(img, width, height, rotation)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.entries[key]; !ok {
+		r.entries[key] = r.lru.PushFront(&renderEntry{key: key, rendered: rendered})
+		for r.lru.Len() > r.maxEntries {
+			oldest := r.lru.Back()
+			r.lru.Remove(oldest)
+			delete(r.entries, oldest.Value.(*renderEntry).key)
+			r.stats.Evictions++
+		}
+	}
+
+	return rendered
+}
+
+// Stats returns the current cache statistics.
+func (r *Renderer) Stats() RenderStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stats := r.stats
+	stats.Entries = r.lru.Len()
+	return stats
+}
+
+// RenderSnapshot renders the keys and strip of the snapshot to the spec.
+func (r *Renderer) RenderSnapshot(snapshot Snapshot, spec RenderSpec) Snapshot {
+	rendered := cloneSnapshot(snapshot)
+	for i, key := range rendered.Keys {
+		rendered.Keys[i] = r.Render(key, spec.KeyWidth, spec.KeyHeight, spec.Rotation)
+	}
+	for i, segment := range rendered.Strip {
+		rendered.Strip[i] = r.Render(segment, spec.StripWidth, spec.StripHeight, 0)
+	}
+	return rendered
+}
+
+func renderImage(img image.Image, width, height, rotation int) image.Image {
+	// Dimensions before rotation.
+	scaledWidth, scaledHeight := width, height
+	if rotation == 90 || rotation == 270 {
+		scaledWidth, scaledHeight = height, width
+	}
+
+	// Crop the source to the aspect ratio of the target, keeping the centre.
+	src := img.Bounds()
+	crop := src
+	if src.Dx()*scaledHeight > src.Dy()*scaledWidth {
+		cropWidth := src.Dy() * scaledWidth / scaledHeight
+		crop.Min.X += (src.Dx() - cropWidth) / 2
+		crop.Max.X = crop.Min.X + cropWidth
+	} else if src.Dx()*scaledHeight < src.Dy()*scaledWidth {
+		cropHeight := src.Dx() * scaledHeight / scaledWidth
+		crop.Min.Y += (src.Dy() - cropHeight) / 2
+		crop.Max.Y = crop.Min.Y + cropHeight
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	if crop.Dx() == scaledWidth && crop.Dy() == scaledHeight {
+		draw.Draw(scaled, scaled.Bounds(), img, crop.Min, draw.Src)
+	} else {
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, crop, draw.Src, nil)
+	}
+
+	if rotation == 0 {
+		return scaled
+	}
+
+	rotated := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < scaledHeight; y++ {
+		for x := 0; x < scaledWidth; x++ {
+			var dx, dy int
+			switch rotation {
+			case 90:
+				dx, dy = scaledHeight-1-y, x
+			case 180:
+				dx, dy = scaledWidth-1-x, scaledHeight-1-y
+			case 270:
+				dx, dy = y, scaledWidth-1-x
+			}
+			rotated.SetRGBA(dx, dy, scaled.RGBAAt(x, y))
+		}
+	}
+	return rotated
+}
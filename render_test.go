@@ -0,0 +1,93 @@
+package deskpad
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRendererCropsScalesAndRotates(t *testing.T) {
+	// A wide image with a red left half and a blue right half.
+	wide := image.NewRGBA(image.Rect(0, 0, 40, 10))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 10; y++ {
+			if x < 20 {
+				wide.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				wide.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	renderer := NewRenderer(0)
+	square := renderer.Render(wide, 20, 20, 0)
+	if square.Bounds().Dx() != 20 || square.Bounds().Dy() != 20 {
+		t.Fatalf("rendered size = %v, want 20x20", square.Bounds())
+	}
+	// The centre of the image is kept, so the edges are the inner parts of each half.
+	if r, _, b, _ := square.At(1, 10).RGBA(); r != 0xffff || b != 0 {
+		t.Fatalf("left edge is not red")
+	}
+	if r, _, b, _ := square.At(18, 10).RGBA(); r != 0 || b != 0xffff {
+		t.Fatalf("right edge is not blue")
+	}
+
+	rotated := renderer.Render(wide, 10, 40, 90)
+	if rotated.Bounds().Dx() != 10 || rotated.Bounds().Dy() != 40 {
+		t.Fatalf("rotated size = %v, want 10x40", rotated.Bounds())
+	}
+	if r, _, _, _ := rotated.At(5, 1).RGBA(); r != 0xffff {
+		t.Fatalf("top of image rotated clockwise is not red")
+	}
+
+	exact := testImage(color.White)
+	if renderer.Render(exact, 1, 1, 360) != exact {
+		t.Fatalf("image already at the target size was copied")
+	}
+}
+
+func TestRendererCachesByIdentityAndSize(t *testing.T) {
+	renderer := NewRenderer(2)
+	red := testImage(color.RGBA{R: 255, A: 255})
+
+	first := renderer.Render(red, 8, 8, 0)
+	if renderer.Render(red, 8, 8, 0) != first {
+		t.Fatalf("cached render was not reused")
+	}
+	renderer.Render(red, 16, 16, 0)
+	renderer.Render(testImage(color.RGBA{G: 255, A: 255}), 8, 8, 0)
+
+	stats := renderer.Stats()
+	if stats.Entries != 2 || stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 {
+		t.Fatalf("stats = %+v, want 2 entries, 1 hit, 3 misses and 1 eviction", stats)
+	}
+}
+
+type fakeRenderingSurface struct {
+	fakeSurface
+	spec RenderSpec
+}
+
+func (s *fakeRenderingSurface) RenderSpec() RenderSpec {
+	return s.spec
+}
+
+func TestDeckRendersKeysForSurfaceSpec(t *testing.T) {
+	icon := testImage(color.RGBA{R: 255, A: 255})
+	deck := NewDeck(&fakeScreen{name: "home", showKeys: []image.Image{icon}})
+	native := &fakeRenderingSurface{fakeSurface: fakeSurface{id: "native"}, spec: RenderSpec{KeyWidth: 96, KeyHeight: 96}}
+	mirror := &fakeSurface{id: "mirror"}
+	deck.RegisterSurface(native)
+	deck.RegisterSurface(mirror)
+	deck.RefreshScreen()
+
+	if got := native.lastRefreshed[0]; got == nil || got.Bounds().Dx() != 96 {
+		t.Fatalf("native surface received %v, want a 96x96 key", got)
+	}
+	if mirror.lastRefreshed[0] != icon {
+		t.Fatalf("surface without a spec did not receive the original key")
+	}
+	if deck.Snapshot().Keys[0] != icon {
+		t.Fatalf("deck snapshot does not hold the original key")
+	}
+}
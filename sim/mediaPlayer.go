@@ -0,0 +1,189 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+const mediaPlayerSeekStepMs = 10000
+
+// MediaPlayer is a fake MPRIS media player. It satisfies the media player and playlist playback
+// controllers used by the screens, and records every command it receives.
+type MediaPlayer struct {
+	lock sync.Mutex
+
+	name       string
+	playing    bool
+	shuffle    bool
+	muted      bool
+	volume     int
+	positionMs int64
+	uri        string
+	track      int
+
+	commands []string
+}
+
+// NewMediaPlayer creates a stopped fake media player with the supplied MPRIS instance name.
+func NewMediaPlayer(name string) *MediaPlayer {
+	return &MediaPlayer{
+		name:   name,
+		volume: 50,
+	}
+}
+
+// Commands returns every command received by the player, in order.
+func (mp *MediaPlayer) Commands() []string {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return append([]string(nil), mp.commands...)
+}
+
+// PlayingURI returns the URI of the currently loaded media.
+func (mp *MediaPlayer) PlayingURI() string {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return mp.uri
+}
+
+// Volume returns the current volume, as a percentage.
+func (mp *MediaPlayer) Volume() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return mp.volume
+}
+
+// Position returns the current playback position, in milliseconds.
+func (mp *MediaPlayer) Position() int64 {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return mp.positionMs
+}
+
+func (mp *MediaPlayer) command(name string, apply func()) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	mp.commands = append(mp.commands, name)
+	apply()
+}
+
+func (mp *MediaPlayer) ID() string {
+	return mp.name
+}
+
+func (mp *MediaPlayer) Play() {
+	mp.command("Play", func() { mp.playing = true })
+}
+
+func (mp *MediaPlayer) Pause() {
+	mp.command("Pause", func() { mp.playing = false })
+}
+
+func (mp *MediaPlayer) Next() {
+	mp.command("Next", func() {
+		mp.track++
+		mp.positionMs = 0
+	})
+}
+
+func (mp *MediaPlayer) Previous() {
+	mp.command("Previous", func() {
+		if mp.track > 1 {
+			mp.track--
+		}
+		mp.positionMs = 0
+	})
+}
+
+func (mp *MediaPlayer) FastForward() {
+	mp.command("FastForward", func() { mp.positionMs += mediaPlayerSeekStepMs })
+}
+
+func (mp *MediaPlayer) Rewind() {
+	mp.command("Rewind", func() {
+		mp.positionMs -= mediaPlayerSeekStepMs
+		if mp.positionMs < 0 {
+			mp.positionMs = 0
+		}
+	})
+}
+
+func (mp *MediaPlayer) VolumeUp() {
+	mp.command("VolumeUp", func() { mp.volume = min(mp.volume+5, 100) })
+}
+
+func (mp *MediaPlayer) VolumeDown() {
+	mp.command("VolumeDown", func() { mp.volume = max(mp.volume-5, 0) })
+}
+
+func (mp *MediaPlayer) Mute() {
+	mp.command("Mute", func() { mp.muted = true })
+}
+
+func (mp *MediaPlayer) Unmute() {
+	mp.command("Unmute", func() { mp.muted = false })
+}
+
+func (mp *MediaPlayer) Shuffle(shuffle bool) {
+	mp.command(fmt.Sprintf("Shuffle %t", shuffle), func() { mp.shuffle = shuffle })
+}
+
+func (mp *MediaPlayer) IsPlaying() bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return mp.playing
+}
+
+func (mp *MediaPlayer) IsShuffle() bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return mp.shuffle
+}
+
+func (mp *MediaPlayer) IsMuted() bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	return mp.muted
+}
+
+// PlayURI loads and starts playing the supplied URI, as done by the MPRIS OpenUri call.
+func (mp *MediaPlayer) PlayURI(ctx context.Context, uri string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mp.command("OpenUri "+uri, func() {
+		mp.uri = uri
+		mp.track = 1
+		mp.positionMs = 0
+		mp.playing = true
+	})
+	return nil
+}
+
+func (mp *MediaPlayer) CurrentlyPlaying() *ui.MediaItem {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	if !mp.playing || mp.uri == "" {
+		return nil
+	}
+
+	return &ui.MediaItem{
+		ID:        fmt.Sprintf("%s:%d", mp.uri, mp.track),
+		Title:     fmt.Sprintf("Track %d", mp.track),
+		Artists:   []string{"Simulated artist"},
+		AlbumName: mp.uri,
+	}
+}
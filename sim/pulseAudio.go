@@ -0,0 +1,148 @@
+package sim
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/lawl/pulseaudio"
+)
+
+// PulseAudio is a fake PulseAudio server connection, satisfying controllers.PulseAudioClient.
+type PulseAudio struct {
+	lock        sync.Mutex
+	volumes     map[string]float32
+	muted       bool
+	sinks       []pulseaudio.Sink
+	defaultSink string
+}
+
+// NewPulseAudio creates a fake PulseAudio connection with a single active sink.
+func NewPulseAudio() *PulseAudio {
+	pa := &PulseAudio{volumes: map[string]float32{}}
+	pa.AddSink("sim-speakers", "Simulated speakers")
+	pa.defaultSink = "sim-speakers"
+	return pa
+}
+
+// AddSink adds an audio output with the supplied name and description.
+func (pa *PulseAudio) AddSink(name, description string) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	pa.sinks = append(pa.sinks, pulseaudio.Sink{
+		Index:       uint32(len(pa.sinks)),
+		Name:        name,
+		Description: description,
+		SinkState:   2,
+	})
+	pa.volumes[name] = 0.5
+}
+
+// DefaultSink returns the name of the sink audio is currently sent to.
+func (pa *PulseAudio) DefaultSink() string {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	return pa.defaultSink
+}
+
+func (pa *PulseAudio) Volume() (float32, error) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	return pa.volumes[pa.defaultSink], nil
+}
+
+func (pa *PulseAudio) SetVolume(volume float32) error {
+	if volume < 0 {
+		return errors.New("volume must not be negative")
+	}
+
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	pa.volumes[pa.defaultSink] = volume
+	return nil
+}
+
+// SetSinkVolume sets the volume of a single sink. Both sink names and indexes are accepted.
+func (pa *PulseAudio) SetSinkVolume(sinkName string, volume float32) error {
+	if volume < 0 {
+		return errors.New("volume must not be negative")
+	}
+
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	sink, ok := pa.findSinkLocked(sinkName)
+	if !ok {
+		return errors.New("no such entity")
+	}
+
+	pa.volumes[sink.Name] = volume
+	return nil
+}
+
+func (pa *PulseAudio) ServerInfo() (*pulseaudio.Server, error) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	return &pulseaudio.Server{DefaultSink: pa.defaultSink}, nil
+}
+
+func (pa *PulseAudio) Mute() (bool, error) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	return pa.muted, nil
+}
+
+func (pa *PulseAudio) SetMute(muted bool) error {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	pa.muted = muted
+	return nil
+}
+
+func (pa *PulseAudio) Sinks() ([]pulseaudio.Sink, error) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	sinks := make([]pulseaudio.Sink, len(pa.sinks))
+	for idx, sink := range pa.sinks {
+		// Only the default sink can be muted.
+		sink.Cvolume = append(sink.Cvolume[:0:0], uint32(pa.volumes[sink.Name]*0xffff))
+		if sink.Name == pa.defaultSink {
+			sink.Muted = pa.muted
+			sink.SinkState = 0
+		}
+		sinks[idx] = sink
+	}
+	return sinks, nil
+}
+
+// SetDefaultSink selects the output sink. Both sink names and indexes are accepted.
+func (pa *PulseAudio) SetDefaultSink(sinkName string) error {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+
+	sink, ok := pa.findSinkLocked(sinkName)
+	if !ok {
+		return errors.New("no such entity")
+	}
+
+	pa.defaultSink = sink.Name
+	return nil
+}
+
+func (pa *PulseAudio) findSinkLocked(sinkName string) (pulseaudio.Sink, bool) {
+	for _, sink := range pa.sinks {
+		if sink.Name == sinkName || strconv.Itoa(int(sink.Index)) == sinkName {
+			return sink, true
+		}
+	}
+
+	return pulseaudio.Sink{}, false
+}
@@ -0,0 +1,292 @@
+package sim_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/sim"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+	"github.com/rmrobinson/deskpad/ui/screens"
+)
+
+var (
+	_ controllers.PulseAudioClient           = (*sim.PulseAudio)(nil)
+	_ controllers.PlaylistPlaybackController = (*sim.MediaPlayer)(nil)
+	_ screens.MediaPlayerController          = (*sim.MediaPlayer)(nil)
+	_ screens.HomeController                 = (*sim.Timebox)(nil)
+	_ screens.ScoreboardController           = (*sim.Timebox)(nil)
+	_ deskpad.Surface                        = (*sim.Surface)(nil)
+	_ screens.MediaPlayerSettingController   = (*controllers.MediaPlayerSetting)(nil)
+	_ screens.MediaPlaylistController        = (*controllers.MediaPlaylist)(nil)
+	_ controllers.PlaylistPlaybackController = (*controllers.SpotifyMediaPlayer)(nil)
+	_ screens.MediaPlayerController          = (*controllers.SpotifyMediaPlayer)(nil)
+)
+
+type harness struct {
+	deck    *deskpad.Deck
+	surface *sim.Surface
+
+	timebox *sim.Timebox
+	player  *sim.MediaPlayer
+	pulse   *sim.PulseAudio
+	spotify *sim.Spotify
+
+	home        *screens.Home
+	mediaPlayer *screens.MediaPlayer
+	playlists   *screens.MediaPlaylist
+	settings    *screens.MediaPlayerSetting
+	scoreboard  *screens.Scoreboard
+
+	playlistController *controllers.MediaPlaylist
+}
+
+// newHarness wires up the screens the same way deskpadd does, using simulated dependencies.
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	h := &harness{
+		surface: sim.NewSurface(15),
+		timebox: sim.NewTimebox(),
+		player:  sim.NewMediaPlayer("org.mpris.MediaPlayer2.sim"),
+		pulse:   sim.NewPulseAudio(),
+		spotify: sim.NewSpotify(),
+	}
+	t.Cleanup(h.spotify.Close)
+
+	h.spotify.AddPlaylist("spotify:playlist:focus", "Focus", nil)
+	h.spotify.AddPlaylist("spotify:playlist:art", "With art", solidImage(color.RGBA{B: 255, A: 255}))
+	h.pulse.AddSink("sim-headphones", "Simulated headphones")
+
+	h.home = screens.NewHome(h.timebox)
+	h.mediaPlayer = screens.NewMediaPlayer(h.home, h.player)
+
+	mpsc := controllers.NewMediaPlayerSetting(h.spotify.Client(), h.pulse)
+	if err := mpsc.RefreshAudioOutputs(context.Background()); err != nil {
+		t.Fatalf("RefreshAudioOutputs returned error: %s", err)
+	}
+	h.settings = screens.NewMediaPlayerSetting(h.home, mpsc)
+	h.settings.SetPlayerScreen(h.mediaPlayer)
+	h.mediaPlayer.SetSettingsScreen(h.settings)
+
+	h.playlistController = controllers.NewMediaPlaylist(h.spotify.Client(), h.player, nil)
+	if err := h.playlistController.RefreshPlaylists(context.Background()); err != nil {
+		t.Fatalf("RefreshPlaylists returned error: %s", err)
+	}
+	h.playlists = screens.NewMediaPlaylist(h.home, h.playlistController)
+	h.playlists.SetPlayerScreen(h.mediaPlayer)
+	h.mediaPlayer.SetPlaylistScreen(h.playlists)
+
+	h.scoreboard = screens.NewScoreboard(h.home, h.timebox)
+
+	h.deck = deskpad.NewDeck(h.home)
+	h.deck.RegisterSurface(h.surface)
+	h.deck.RefreshScreen()
+
+	return h
+}
+
+func (h *harness) pressIcon(t *testing.T, icon image.Image) {
+	t.Helper()
+
+	keyID, ok := h.surface.KeyWithImage(icon)
+	if !ok {
+		t.Fatalf("icon not displayed on screen %q", h.surface.Snapshot().ScreenName)
+	}
+	h.press(t, keyID)
+}
+
+func (h *harness) press(t *testing.T, keyID int) {
+	t.Helper()
+
+	if err := h.surface.Press(context.Background(), h.deck, keyID); err != nil {
+		t.Fatalf("press key %d returned error: %s", keyID, err)
+	}
+}
+
+func (h *harness) expectScreen(t *testing.T, name string) {
+	t.Helper()
+
+	if got := h.surface.Snapshot().ScreenName; got != name {
+		t.Fatalf("surface shows screen %q, want %q", got, name)
+	}
+}
+
+func TestNavigateHomeToPlaylistAndPlay(t *testing.T) {
+	h := newHarness(t)
+	h.expectScreen(t, "home")
+
+	h.pressIcon(t, h.mediaPlayer.Icon())
+	h.expectScreen(t, "media player")
+
+	h.pressIcon(t, h.playlists.Icon())
+	h.expectScreen(t, "media playlist")
+
+	snapshot := h.surface.Snapshot()
+	if snapshot.Keys[0] == nil || snapshot.Keys[1] == nil {
+		t.Fatalf("playlist keys were not rendered")
+	}
+	if snapshot.Keys[1].Bounds().Dx() != 72 {
+		t.Fatalf("playlist art width = %d, want 72", snapshot.Keys[1].Bounds().Dx())
+	}
+
+	h.press(t, 0)
+	waitFor(t, func() bool { return h.player.PlayingURI() == "spotify:playlist:focus" })
+
+	if current := h.playlistController.CurrentlyPlaylist(); current == nil || current.Name != "Focus" {
+		t.Fatalf("current playlist = %v, want Focus", current)
+	}
+
+	h.pressIcon(t, h.mediaPlayer.Icon())
+	h.expectScreen(t, "media player")
+
+	// The play/pause key should now offer to pause; pressing it pauses and swaps the icon in place.
+	calls := len(h.surface.Calls())
+	h.press(t, 1)
+	if h.player.IsPlaying() {
+		t.Fatalf("player still playing after pressing play/pause")
+	}
+	newCalls := h.surface.Calls()[calls:]
+	if len(newCalls) != 1 || newCalls[0].Type != sim.CallUpdateKey || newCalls[0].KeyID != 1 {
+		t.Fatalf("surface calls after play/pause = %+v, want a single key update", newCalls)
+	}
+}
+
+func TestSelectAudioOutputFromSettings(t *testing.T) {
+	h := newHarness(t)
+
+	h.pressIcon(t, h.mediaPlayer.Icon())
+	h.pressIcon(t, h.settings.Icon())
+	h.expectScreen(t, "media player setting")
+
+	h.press(t, 1)
+	if got := h.pulse.DefaultSink(); got != "sim-headphones" {
+		t.Fatalf("default sink = %q, want sim-headphones", got)
+	}
+}
+
+func TestScoreboardLongPressResetsScore(t *testing.T) {
+	h := newHarness(t)
+	ctx := context.Background()
+
+	h.pressIcon(t, h.scoreboard.Icon())
+	h.expectScreen(t, "scoreboard")
+
+	// Red plus, then a short hold of the red icon which should not reset.
+	h.press(t, 1)
+	h.press(t, 1)
+	if err := h.surface.Hold(ctx, h.deck, 6, 400*time.Millisecond); err != nil {
+		t.Fatalf("Hold returned error: %s", err)
+	}
+	if red, _ := h.timebox.Score(); red != 2 {
+		t.Fatalf("red score = %d, want 2", red)
+	}
+
+	if err := h.surface.KeyDown(6); err != nil {
+		t.Fatalf("KeyDown returned error: %s", err)
+	}
+	h.surface.Advance(time.Second)
+	if err := h.surface.KeyUp(ctx, h.deck, 6); err != nil {
+		t.Fatalf("KeyUp returned error: %s", err)
+	}
+	if red, _ := h.timebox.Score(); red != 0 {
+		t.Fatalf("red score = %d, want 0 after long press", red)
+	}
+}
+
+func TestSpotifyMediaPlayerControlsFakeSpotify(t *testing.T) {
+	spotify := sim.NewSpotify()
+	defer spotify.Close()
+	spotify.AddPlaylist("spotify:playlist:focus", "Focus", nil)
+
+	ctx := context.Background()
+	mp := controllers.NewSpotifyMediaPlayer(ctx, spotify.Client())
+
+	if err := mp.PlayURI(ctx, "spotify:playlist:focus"); err != nil {
+		t.Fatalf("PlayURI returned error: %s", err)
+	}
+	mp.Next()
+	mp.FastForward()
+	mp.VolumeUp()
+	mp.Shuffle(true)
+
+	state := spotify.State()
+	if !state.Playing {
+		t.Fatalf("spotify not playing")
+	}
+	if state.Item == nil || state.Item.Name != "Track 2" {
+		t.Fatalf("item = %+v, want Track 2", state.Item)
+	}
+	if state.Progress != 10000 {
+		t.Fatalf("progress = %d, want 10000", state.Progress)
+	}
+	if state.Device.Volume != 55 {
+		t.Fatalf("volume = %d, want 55", state.Device.Volume)
+	}
+	if !state.ShuffleState {
+		t.Fatalf("shuffle not enabled")
+	}
+
+	item := mp.CurrentlyPlaying()
+	if item == nil || item.Title != "Track 2" || item.AlbumName != "Focus" {
+		t.Fatalf("currently playing = %+v, want Track 2 from Focus", item)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func solidImage(c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 300; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestReplayRecordedSession(t *testing.T) {
+	var recording bytes.Buffer
+	h := newHarness(t)
+	h.deck.SetRecorder(deskpad.NewRecorder(&recording))
+
+	h.pressIcon(t, h.mediaPlayer.Icon())
+	h.press(t, 1)
+	h.pressIcon(t, h.settings.Icon())
+	h.expectScreen(t, "media player setting")
+
+	replayed := newHarness(t)
+	divergences, err := deskpad.Replay(context.Background(), bytes.NewReader(recording.Bytes()), replayed.deck)
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if len(divergences) != 0 {
+		t.Fatalf("Replay diverged: %v", divergences)
+	}
+	replayed.expectScreen(t, "media player setting")
+
+	// A player which is already playing renders a pause key where the recording had a play key.
+	diverged := newHarness(t)
+	diverged.player.Play()
+	divergences, err = deskpad.Replay(context.Background(), bytes.NewReader(recording.Bytes()), diverged.deck)
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if len(divergences) == 0 || divergences[0].Step != 1 || divergences[0].ActualScreen != "media player" {
+		t.Fatalf("divergences = %v, want the media player screen to diverge after the first press", divergences)
+	}
+}
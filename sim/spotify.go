@@ -0,0 +1,293 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const spotifyTrackDurationMs = 180000
+
+// SpotifyPlaylist is a playlist served by the simulated Spotify API.
+type SpotifyPlaylist struct {
+	URI  string
+	Name string
+	Icon image.Image
+}
+
+// Spotify is a fake Spotify Web API server. It implements the player and playlist endpoints used by deskpad,
+// tracking playback state in memory so the real Spotify backed controllers can be exercised.
+type Spotify struct {
+	server *httptest.Server
+
+	lock      sync.Mutex
+	playlists []SpotifyPlaylist
+	devices   []spotify.PlayerDevice
+	state     spotify.PlayerState
+	requests  []string
+}
+
+// NewSpotify starts a fake Spotify API server. Close should be called once it is no longer needed.
+func NewSpotify() *Spotify {
+	s := &Spotify{
+		devices: []spotify.PlayerDevice{
+			{ID: "sim-device", Name: "Simulated speaker", Type: "Speaker", Active: true, Volume: 50},
+		},
+	}
+	s.state.Device = s.devices[0]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me", s.handleUser)
+	mux.HandleFunc("/v1/me/playlists", s.handlePlaylists)
+	mux.HandleFunc("/v1/me/player", s.handlePlayer)
+	mux.HandleFunc("/v1/me/player/", s.handlePlayerCommand)
+	mux.HandleFunc("/images/", s.handleImage)
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Close shuts down the fake server.
+func (s *Spotify) Close() {
+	s.server.Close()
+}
+
+// Client returns a Spotify client which talks to the fake server.
+func (s *Spotify) Client() *spotify.Client {
+	return spotify.New(s.server.Client(), spotify.WithBaseURL(s.server.URL+"/v1/"))
+}
+
+// AddPlaylist adds a playlist to the current user's playlists. The icon is optional.
+func (s *Spotify) AddPlaylist(uri, name string, icon image.Image) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.playlists = append(s.playlists, SpotifyPlaylist{URI: uri, Name: name, Icon: icon})
+}
+
+// AddDevice adds a playback device which the user can transfer playback to.
+func (s *Spotify) AddDevice(id, name, deviceType string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.devices = append(s.devices, spotify.PlayerDevice{ID: spotify.ID(id), Name: name, Type: deviceType, Volume: 50})
+}
+
+// State returns the current simulated playback state.
+func (s *Spotify) State() spotify.PlayerState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.state
+}
+
+// Requests returns the method and path of every request received, in order.
+func (s *Spotify) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Spotify) recordRequest(r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+}
+
+func (s *Spotify) handleUser(w http.ResponseWriter, r *http.Request) {
+	s.recordRequest(r)
+	writeSpotifyJSON(w, spotify.PrivateUser{User: spotify.User{ID: "sim", DisplayName: "Simulated user"}})
+}
+
+func (s *Spotify) handlePlaylists(w http.ResponseWriter, r *http.Request) {
+	s.recordRequest(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var page spotify.SimplePlaylistPage
+	page.Total = spotify.Numeric(len(s.playlists))
+	for idx, playlist := range s.playlists {
+		p := spotify.SimplePlaylist{
+			ID:   spotify.ID(strconv.Itoa(idx)),
+			Name: playlist.Name,
+			URI:  spotify.URI(playlist.URI),
+		}
+		if playlist.Icon != nil {
+			p.Images = []spotify.Image{{URL: fmt.Sprintf("%s/images/%d.png", s.server.URL, idx)}}
+		}
+		page.Playlists = append(page.Playlists, p)
+	}
+
+	writeSpotifyJSON(w, page)
+}
+
+func (s *Spotify) handleImage(w http.ResponseWriter, r *http.Request) {
+	s.recordRequest(r)
+
+	idx, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/images/"), ".png"))
+
+	s.lock.Lock()
+	var icon image.Image
+	if err == nil && idx >= 0 && idx < len(s.playlists) {
+		icon = s.playlists[idx].Icon
+	}
+	s.lock.Unlock()
+
+	if icon == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, icon)
+}
+
+func (s *Spotify) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	s.recordRequest(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeSpotifyJSON(w, s.State())
+
+	case http.MethodPut:
+		var req struct {
+			DeviceIDs []spotify.ID `json:"device_ids"`
+			Play      bool         `json:"play"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.DeviceIDs) != 1 {
+			http.Error(w, "invalid transfer request", http.StatusBadRequest)
+			return
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		found := false
+		for idx := range s.devices {
+			s.devices[idx].Active = s.devices[idx].ID == req.DeviceIDs[0]
+			if s.devices[idx].Active {
+				s.state.Device = s.devices[idx]
+				found = true
+			}
+		}
+		if !found {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		}
+		if req.Play {
+			s.state.Playing = true
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Spotify) handlePlayerCommand(w http.ResponseWriter, r *http.Request) {
+	s.recordRequest(r)
+
+	command := strings.TrimPrefix(r.URL.Path, "/v1/me/player/")
+	if command == "devices" {
+		s.lock.Lock()
+		devices := append([]spotify.PlayerDevice(nil), s.devices...)
+		s.lock.Unlock()
+
+		writeSpotifyJSON(w, struct {
+			Devices []spotify.PlayerDevice `json:"devices"`
+		}{Devices: devices})
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	query := r.URL.Query()
+	switch command {
+	case "play":
+		var opts spotify.PlayOptions
+		if r.ContentLength != 0 {
+			json.NewDecoder(r.Body).Decode(&opts)
+		}
+		if opts.PlaybackContext != nil {
+			s.state.PlaybackContext = spotify.PlaybackContext{URI: *opts.PlaybackContext, Type: "playlist"}
+			s.state.Progress = 0
+			s.state.Item = s.trackLocked(1)
+		}
+		s.state.Playing = true
+	case "pause":
+		s.state.Playing = false
+	case "next", "previous":
+		if s.state.Item == nil {
+			break
+		}
+
+		trackNumber := int(s.state.Item.TrackNumber)
+		if command == "next" {
+			trackNumber++
+		} else if trackNumber > 1 {
+			trackNumber--
+		}
+		s.state.Item = s.trackLocked(trackNumber)
+		s.state.Progress = 0
+	case "seek":
+		position, err := strconv.Atoi(query.Get("position_ms"))
+		if err != nil || position < 0 {
+			http.Error(w, "invalid position", http.StatusBadRequest)
+			return
+		}
+		s.state.Progress = spotify.Numeric(position)
+	case "volume":
+		volume, err := strconv.Atoi(query.Get("volume_percent"))
+		if err != nil || volume < 0 || volume > 100 {
+			http.Error(w, "invalid volume", http.StatusBadRequest)
+			return
+		}
+		s.state.Device.Volume = spotify.Numeric(volume)
+	case "shuffle":
+		s.state.ShuffleState = query.Get("state") == "true"
+	case "repeat":
+		s.state.RepeatState = query.Get("state")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// trackLocked generates a track for the current playback context.
+func (s *Spotify) trackLocked(trackNumber int) *spotify.FullTrack {
+	contextURI := string(s.state.PlaybackContext.URI)
+	name := contextURI
+	for _, playlist := range s.playlists {
+		if playlist.URI == contextURI {
+			name = playlist.Name
+		}
+	}
+
+	track := &spotify.FullTrack{}
+	track.ID = spotify.ID(fmt.Sprintf("%s:%d", contextURI, trackNumber))
+	track.Name = fmt.Sprintf("Track %d", trackNumber)
+	track.TrackNumber = spotify.Numeric(trackNumber)
+	track.Duration = spotifyTrackDurationMs
+	track.Artists = []spotify.SimpleArtist{{Name: "Simulated artist"}}
+	track.Album = spotify.SimpleAlbum{Name: name}
+	return track
+}
+
+func writeSpotifyJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,182 @@
+// Package sim contains an in-memory control surface and fake implementations of the external systems
+// deskpad talks to, allowing complete deck scenarios to be exercised without any hardware.
+package sim
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+// CallType indicates which Surface function was called by the deck.
+type CallType int
+
+const (
+	CallRefresh CallType = iota
+	CallUpdateKey
+	CallClear
+)
+
+// Call records a single call made by the deck to the surface.
+type Call struct {
+	Type     CallType
+	Time     time.Time
+	Snapshot deskpad.Snapshot
+	KeyID    int
+}
+
+// Surface is an in-memory control surface which records every call made to it, and can inject key
+// presses into a deck using a simulated clock to control how long keys are held.
+type Surface struct {
+	id       string
+	keyCount int
+
+	lock     sync.Mutex
+	now      time.Time
+	calls    []Call
+	snapshot deskpad.Snapshot
+	keysDown map[int]time.Time
+}
+
+// NewSurface creates a simulated surface with the specified number of keys.
+func NewSurface(keyCount int) *Surface {
+	return &Surface{
+		id:       "sim",
+		keyCount: keyCount,
+		now:      time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		keysDown: map[int]time.Time{},
+	}
+}
+
+func (s *Surface) ID() string {
+	return s.id
+}
+
+func (s *Surface) KeyCount() int {
+	return s.keyCount
+}
+
+func (s *Surface) Refresh(snapshot deskpad.Snapshot) error {
+	s.record(Call{Type: CallRefresh, Snapshot: snapshot})
+	return nil
+}
+
+func (s *Surface) UpdateKey(snapshot deskpad.Snapshot, keyID int) error {
+	s.record(Call{Type: CallUpdateKey, Snapshot: snapshot, KeyID: keyID})
+	return nil
+}
+
+func (s *Surface) Clear() error {
+	s.lock.Lock()
+	snapshot := s.snapshot
+	s.lock.Unlock()
+
+	snapshot.Keys = make([]image.Image, len(snapshot.Keys))
+	s.record(Call{Type: CallClear, Snapshot: snapshot})
+	return nil
+}
+
+func (s *Surface) record(call Call) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	call.Time = s.now
+	call.Snapshot.Keys = append([]image.Image(nil), call.Snapshot.Keys...)
+	s.calls = append(s.calls, call)
+	s.snapshot = call.Snapshot
+}
+
+// Calls returns every call made to the surface, in order.
+func (s *Surface) Calls() []Call {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// Snapshot returns the state currently displayed on the surface.
+func (s *Surface) Snapshot() deskpad.Snapshot {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snapshot := s.snapshot
+	snapshot.Keys = append([]image.Image(nil), snapshot.Keys...)
+	return snapshot
+}
+
+// KeyWithImage returns the ID of the first key currently displaying the supplied image.
+func (s *Surface) KeyWithImage(img image.Image) (int, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for keyID, keyImg := range s.snapshot.Keys {
+		if keyImg != nil && keyImg == img {
+			return keyID, true
+		}
+	}
+
+	return 0, false
+}
+
+// Now returns the current simulated time.
+func (s *Surface) Now() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.now
+}
+
+// Advance moves the simulated clock forward.
+func (s *Surface) Advance(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.now = s.now.Add(d)
+}
+
+// KeyDown marks the specified key as held down at the current simulated time.
+func (s *Surface) KeyDown(keyID int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.keysDown[keyID]; ok {
+		return fmt.Errorf("key %d is already down", keyID)
+	}
+
+	s.keysDown[keyID] = s.now
+	return nil
+}
+
+// KeyUp releases the specified key, sending a short or long press to the deck depending on how
+// much simulated time has passed since the key went down.
+func (s *Surface) KeyUp(ctx context.Context, kp deskpad.KeyPresser, keyID int) error {
+	s.lock.Lock()
+	downAt, ok := s.keysDown[keyID]
+	delete(s.keysDown, keyID)
+	now := s.now
+	s.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("key %d is not down", keyID)
+	}
+
+	return kp.PressKey(ctx, keyID, deskpad.KeyPressTypeForDuration(now.Sub(downAt)))
+}
+
+// Press pushes and immediately releases the specified key.
+func (s *Surface) Press(ctx context.Context, kp deskpad.KeyPresser, keyID int) error {
+	return s.Hold(ctx, kp, keyID, 0)
+}
+
+// Hold pushes the specified key, advances the simulated clock by the duration, and releases it.
+func (s *Surface) Hold(ctx context.Context, kp deskpad.KeyPresser, keyID int, d time.Duration) error {
+	if err := s.KeyDown(keyID); err != nil {
+		return err
+	}
+	s.Advance(d)
+	return s.KeyUp(ctx, kp, keyID)
+}
@@ -0,0 +1,111 @@
+package sim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+// Timebox is a fake Timebox display. It satisfies the home and scoreboard controllers used by the
+// screens, and records everything which would have been shown on the display.
+type Timebox struct {
+	lock sync.Mutex
+
+	currDisplay controllers.HomeDisplay
+	redScore    int
+	blueScore   int
+
+	displayed []string
+}
+
+// NewTimebox creates a fake Timebox showing the clock.
+func NewTimebox() *Timebox {
+	return &Timebox{currDisplay: controllers.HomeDisplayClock}
+}
+
+// Displayed returns everything shown on the display, in order.
+func (tb *Timebox) Displayed() []string {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	return append([]string(nil), tb.displayed...)
+}
+
+func (tb *Timebox) display(s string) {
+	tb.displayed = append(tb.displayed, s)
+}
+
+func (tb *Timebox) DisplayClock() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.currDisplay = controllers.HomeDisplayClock
+	tb.display("clock")
+}
+
+func (tb *Timebox) DisplayTemperature() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.currDisplay = controllers.HomeDisplayTemperature
+	tb.display("temperature")
+}
+
+func (tb *Timebox) CurrentDisplay() controllers.HomeDisplay {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	return tb.currDisplay
+}
+
+// Score returns the current red and blue scores.
+func (tb *Timebox) Score() (int, int) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	return tb.redScore, tb.blueScore
+}
+
+func (tb *Timebox) Display() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.displayScoreLocked()
+}
+
+func (tb *Timebox) displayScoreLocked() {
+	tb.display(fmt.Sprintf("scoreboard %d-%d", tb.redScore, tb.blueScore))
+}
+
+func (tb *Timebox) updateScore(update func()) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	update()
+	tb.displayScoreLocked()
+}
+
+func (tb *Timebox) IncrementRedScore() {
+	tb.updateScore(func() { tb.redScore++ })
+}
+
+func (tb *Timebox) DecrementRedScore() {
+	tb.updateScore(func() { tb.redScore = max(tb.redScore-1, 0) })
+}
+
+func (tb *Timebox) ResetRedScore() {
+	tb.updateScore(func() { tb.redScore = 0 })
+}
+
+func (tb *Timebox) IncrementBlueScore() {
+	tb.updateScore(func() { tb.blueScore++ })
+}
+
+func (tb *Timebox) DecrementBlueScore() {
+	tb.updateScore(func() { tb.blueScore = max(tb.blueScore-1, 0) })
+}
+
+func (tb *Timebox) ResetBlueScore() {
+	tb.updateScore(func() { tb.blueScore = 0 })
+}
@@ -0,0 +1,473 @@
+package deskpad
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdeck "github.com/Luzifer/streamdeck"
+)
+
+const (
+	elgatoVendorID = 0x0fd9
+
+	hidrawSysfsRoot = "/sys/class/hidraw"
+	hidrawDevRoot   = "/dev"
+
+	// Images are sent in fixed size output reports, each starting with a header describing the page it holds.
+	streamDeckImageReportLength      = 1024
+	streamDeckKeyImageHeaderLength   = 8
+	streamDeckStripImageHeaderLength = 16
+	streamDeckInputReportLength      = 512
+	streamDeckFeatureReportLength    = 32
+
+	streamDeckInputReportID     = 0x01
+	streamDeckImageReportID     = 0x02
+	streamDeckKeyImageCommand   = 0x07
+	streamDeckStripImageCommand = 0x0c
+	streamDeckSerialReportID    = 0x06
+
+	// Input reports carry the state of every key, a touch on the strip, or the state of the dials.
+	streamDeckInputKeys  = 0x00
+	streamDeckInputTouch = 0x02
+	streamDeckInputDials = 0x03
+
+	streamDeckTouchShort = 0x01
+	streamDeckTouchLong  = 0x02
+	streamDeckDialsPress = 0x00
+	streamDeckDialsTurn  = 0x01
+
+	streamDeckJPEGQuality = 95
+)
+
+var (
+	// ErrNoStreamDeck is returned by OpenHIDStreamDeck when no supported Stream Deck is attached.
+	ErrNoStreamDeck = errors.New("no supported stream deck attached")
+	// ErrHIDUnavailable is returned by OpenHIDStreamDeck when hidraw devices can't be used on this system.
+	ErrHIDUnavailable = errors.New("hidraw devices are not available")
+)
+
+// streamDeckModel describes the layout of a Stream Deck model supported by HIDStreamDeck.
+type streamDeckModel struct {
+	name      string
+	productID uint16
+	keys      int
+	keySize   int
+	// rotation is applied to key images, as some models have their panel mounted upside down.
+	rotation int
+
+	// dials is the number of rotary encoders, each with a segment of the touch strip above it.
+	dials       int
+	stripWidth  int
+	stripHeight int
+}
+
+var streamDeckModels = []streamDeckModel{
+	{name: "Stream Deck Original V2", productID: 0x006d, keys: 15, keySize: 72, rotation: 180},
+	{name: "Stream Deck MK.2", productID: 0x0080, keys: 15, keySize: 72, rotation: 180},
+	{name: "Stream Deck +", productID: 0x0084, keys: 8, keySize: 120, dials: 4, stripWidth: 800, stripHeight: 100},
+}
+
+// stripSegmentWidth returns the width of the touch strip above each dial.
+func (m streamDeckModel) stripSegmentWidth() int {
+	if m.dials == 0 {
+		return 0
+	}
+	return m.stripWidth / m.dials
+}
+
+// hidDevice is an open HID device. Reads return input reports and writes send output reports; both, and the
+// feature reports, start with the report ID.
+type hidDevice interface {
+	io.ReadWriteCloser
+	GetFeatureReport(report []byte) error
+}
+
+// HIDStreamDeck drives a Stream Deck directly through its hidraw device. Unlike the Stream Deck library it accepts
+// key images already encoded in the device format, so the surface can encode each distinct image once.
+type HIDStreamDeck struct {
+	dev   hidDevice
+	model streamDeckModel
+
+	writeLock  sync.Mutex
+	blankKey   []byte
+	blankStrip []byte
+
+	subscribe  sync.Once
+	events     chan sdeck.Event
+	dialEvents chan DialEvent
+	closeOnce  sync.Once
+	closed     chan struct{}
+}
+
+// OpenHIDStreamDeck opens the first supported Stream Deck attached to the system. It is a StreamDeckOpener.
+func OpenHIDStreamDeck() (StreamDeckDevice, error) {
+	path, model, err := findHIDStreamDeck(hidrawSysfsRoot, hidrawDevRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	dev, err := openHIDDevice(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	streamDeckLogger.Info("opened stream deck", "model", model.name, "path", path)
+	return newHIDStreamDeck(dev, model)
+}
+
+func newHIDStreamDeck(dev hidDevice, model streamDeckModel) (*HIDStreamDeck, error) {
+	var blankKey bytes.Buffer
+	if err := EncodeStreamDeckKeyImage(&blankKey, image.NewRGBA(image.Rect(0, 0, model.keySize, model.keySize))); err != nil {
+		return nil, err
+	}
+
+	var blankStrip bytes.Buffer
+	if model.dials > 0 {
+		if err := EncodeStreamDeckKeyImage(&blankStrip, image.NewRGBA(image.Rect(0, 0, model.stripSegmentWidth(), model.stripHeight))); err != nil {
+			return nil, err
+		}
+	}
+
+	return &HIDStreamDeck{
+		dev:        dev,
+		model:      model,
+		blankKey:   blankKey.Bytes(),
+		blankStrip: blankStrip.Bytes(),
+		events:     make(chan sdeck.Event, 16),
+		dialEvents: make(chan DialEvent, 16),
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// findHIDStreamDeck returns the device node and model of the first supported Stream Deck listed under sysfsRoot.
+func findHIDStreamDeck(sysfsRoot string, devRoot string) (string, streamDeckModel, error) {
+	entries, err := os.ReadDir(sysfsRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", streamDeckModel{}, ErrHIDUnavailable
+	} else if err != nil {
+		return "", streamDeckModel{}, err
+	}
+
+	for _, entry := range entries {
+		uevent, err := os.ReadFile(filepath.Join(sysfsRoot, entry.Name(), "device", "uevent"))
+		if err != nil {
+			continue
+		}
+
+		vendorID, productID, ok := parseHIDUevent(uevent)
+		if !ok || vendorID != elgatoVendorID {
+			continue
+		}
+		for _, model := range streamDeckModels {
+			if model.productID == productID {
+				return filepath.Join(devRoot, entry.Name()), model, nil
+			}
+		}
+	}
+
+	return "", streamDeckModel{}, ErrNoStreamDeck
+}
+
+// parseHIDUevent reads the vendor and product IDs from the HID_ID line of a HID device uevent file,
+// which has the form HID_ID=<bus>:<vendor>:<product> in hex.
+func parseHIDUevent(uevent []byte) (uint16, uint16, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(uevent))
+	for scanner.Scan() {
+		id, ok := strings.CutPrefix(scanner.Text(), "HID_ID=")
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(id, ":")
+		if len(parts) != 3 {
+			return 0, 0, false
+		}
+		vendorID, err := strconv.ParseUint(parts[1], 16, 32)
+		if err != nil || vendorID > 0xffff {
+			return 0, 0, false
+		}
+		productID, err := strconv.ParseUint(parts[2], 16, 32)
+		if err != nil || productID > 0xffff {
+			return 0, 0, false
+		}
+		return uint16(vendorID), uint16(productID), true
+	}
+	return 0, 0, false
+}
+
+// Serial returns the serial number of the device. It is read from the device on each call, so it also reports
+// if the device is still attached.
+func (sd *HIDStreamDeck) Serial() (string, error) {
+	report := make([]byte, streamDeckFeatureReportLength)
+	report[0] = streamDeckSerialReportID
+	if err := sd.dev.GetFeatureReport(report); err != nil {
+		return "", err
+	}
+
+	serial, _, _ := bytes.Cut(report[2:], []byte{0})
+	return strings.TrimSpace(string(serial)), nil
+}
+
+func (sd *HIDStreamDeck) NumKeys() int {
+	return sd.model.keys
+}
+
+// RenderSpec returns the native key resolution and orientation of the model, and the size of the touch strip
+// segment above each dial.
+func (sd *HIDStreamDeck) RenderSpec() RenderSpec {
+	return RenderSpec{
+		KeyWidth:    sd.model.keySize,
+		KeyHeight:   sd.model.keySize,
+		Rotation:    sd.model.rotation,
+		StripWidth:  sd.model.stripSegmentWidth(),
+		StripHeight: sd.model.stripHeight,
+	}
+}
+
+// DialCount returns the number of dials on the model, which is 0 for models without them.
+func (sd *HIDStreamDeck) DialCount() int {
+	return sd.model.dials
+}
+
+func (sd *HIDStreamDeck) ClearAllKeys() error {
+	for keyID := 0; keyID < sd.model.keys; keyID++ {
+		if err := sd.ClearKey(keyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sd *HIDStreamDeck) ClearKey(keyID int) error {
+	return sd.WriteKeyImage(keyID, sd.blankKey)
+}
+
+// FillImage scales the image to the key, encodes it and sends it to the device.
+func (sd *HIDStreamDeck) FillImage(keyID int, img image.Image) error {
+	var buf bytes.Buffer
+	if err := EncodeStreamDeckKeyImage(&buf, renderImage(img, sd.model.keySize, sd.model.keySize, sd.model.rotation)); err != nil {
+		return err
+	}
+	return sd.WriteKeyImage(keyID, buf.Bytes())
+}
+
+// WriteKeyImage sends a key image already encoded by EncodeStreamDeckKeyImage, at the size and orientation of
+// RenderSpec, to the device.
+func (sd *HIDStreamDeck) WriteKeyImage(keyID int, data []byte) error {
+	if keyID < 0 || keyID >= sd.model.keys {
+		return fmt.Errorf("invalid key id %d", keyID)
+	}
+
+	return sd.writeImage(data, streamDeckKeyImageHeaderLength, func(header []byte, last bool, length int, page int) {
+		header[0] = streamDeckImageReportID
+		header[1] = streamDeckKeyImageCommand
+		header[2] = byte(keyID)
+		if last {
+			header[3] = 1
+		}
+		header[4], header[5] = byte(length), byte(length>>8)
+		header[6], header[7] = byte(page), byte(page>>8)
+	})
+}
+
+// WriteStripImage sends the touch strip segment above the dial, already encoded by EncodeStreamDeckKeyImage at
+// the strip size of RenderSpec, to the device.
+func (sd *HIDStreamDeck) WriteStripImage(dial int, data []byte) error {
+	if dial < 0 || dial >= sd.model.dials {
+		return fmt.Errorf("invalid dial id %d", dial)
+	}
+
+	x, width, height := dial*sd.model.stripSegmentWidth(), sd.model.stripSegmentWidth(), sd.model.stripHeight
+	return sd.writeImage(data, streamDeckStripImageHeaderLength, func(header []byte, last bool, length int, page int) {
+		header[0] = streamDeckImageReportID
+		header[1] = streamDeckStripImageCommand
+		header[2], header[3] = byte(x), byte(x>>8)
+		header[4], header[5] = 0, 0
+		header[6], header[7] = byte(width), byte(width>>8)
+		header[8], header[9] = byte(height), byte(height>>8)
+		if last {
+			header[10] = 1
+		}
+		header[11], header[12] = byte(page), byte(page>>8)
+		header[13], header[14] = byte(length), byte(length>>8)
+	})
+}
+
+// ClearStripImage blanks the touch strip segment above the dial.
+func (sd *HIDStreamDeck) ClearStripImage(dial int) error {
+	return sd.WriteStripImage(dial, sd.blankStrip)
+}
+
+// writeImage splits the image into pages sent in separate output reports, each with a header filled by fillHeader.
+func (sd *HIDStreamDeck) writeImage(data []byte, headerLength int, fillHeader func(header []byte, last bool, length int, page int)) error {
+	sd.writeLock.Lock()
+	defer sd.writeLock.Unlock()
+
+	report := make([]byte, streamDeckImageReportLength)
+	payloadLength := streamDeckImageReportLength - headerLength
+	for page := 0; page == 0 || page*payloadLength < len(data); page++ {
+		chunk := data[page*payloadLength:]
+		if len(chunk) > payloadLength {
+			chunk = chunk[:payloadLength]
+		}
+
+		clear(report)
+		fillHeader(report[:headerLength], (page+1)*payloadLength >= len(data), len(chunk), page)
+		copy(report[headerLength:], chunk)
+		if _, err := sd.dev.Write(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the key events read from the device. The channel is closed once the device can't be read,
+// such as after it is unplugged or closed.
+func (sd *HIDStreamDeck) Subscribe() <-chan sdeck.Event {
+	sd.subscribe.Do(func() {
+		go sd.readInput()
+	})
+	return sd.events
+}
+
+// SubscribeDials returns the dial and touch strip events read from the device. Like Subscribe, the channel is
+// closed once the device can't be read; models without dials never send an event.
+func (sd *HIDStreamDeck) SubscribeDials() <-chan DialEvent {
+	sd.subscribe.Do(func() {
+		go sd.readInput()
+	})
+	return sd.dialEvents
+}
+
+func (sd *HIDStreamDeck) Close() error {
+	var err error
+	sd.closeOnce.Do(func() {
+		close(sd.closed)
+		err = sd.dev.Close()
+	})
+	return err
+}
+
+func (sd *HIDStreamDeck) readInput() {
+	defer close(sd.events)
+	defer close(sd.dialEvents)
+
+	pressed := make([]bool, sd.model.keys)
+	dials := newStreamDeckDialState(sd.model)
+	report := make([]byte, streamDeckInputReportLength)
+	for {
+		n, err := sd.dev.Read(report)
+		if err != nil {
+			streamDeckLogger.Debug("stopped reading stream deck input", "err", err)
+			return
+		}
+
+		for _, event := range parseStreamDeckKeys(report[:n], pressed) {
+			select {
+			case sd.events <- event:
+			case <-sd.closed:
+				return
+			}
+		}
+		for _, event := range dials.parse(report[:n], time.Now()) {
+			select {
+			case sd.dialEvents <- event:
+			case <-sd.closed:
+				return
+			}
+		}
+	}
+}
+
+// parseStreamDeckKeys returns an event for each key whose state in the input report differs from pressed,
+// which is updated to match.
+func parseStreamDeckKeys(report []byte, pressed []bool) []sdeck.Event {
+	if len(report) < 4 || report[0] != streamDeckInputReportID || report[1] != streamDeckInputKeys {
+		return nil
+	}
+
+	var events []sdeck.Event
+	for keyID, state := range report[4:min(len(report), 4+len(pressed))] {
+		if down := state != 0; down != pressed[keyID] {
+			pressed[keyID] = down
+
+			eventType := sdeck.EventTypeUp
+			if down {
+				eventType = sdeck.EventTypeDown
+			}
+			events = append(events, sdeck.Event{Key: keyID, Type: eventType})
+		}
+	}
+	return events
+}
+
+// streamDeckDialState tracks when each dial was pressed, so a press is reported on release with its duration.
+type streamDeckDialState struct {
+	model     streamDeckModel
+	pressedAt []time.Time
+}
+
+func newStreamDeckDialState(model streamDeckModel) *streamDeckDialState {
+	return &streamDeckDialState{model: model, pressedAt: make([]time.Time, model.dials)}
+}
+
+// parse returns the dial events in the input report, which are rotations, completed presses and taps on the strip.
+func (s *streamDeckDialState) parse(report []byte, now time.Time) []DialEvent {
+	if s.model.dials == 0 || len(report) < 5+s.model.dials || report[0] != streamDeckInputReportID {
+		return nil
+	}
+
+	var events []DialEvent
+	switch report[1] {
+	case streamDeckInputDials:
+		for dial, value := range report[5 : 5+s.model.dials] {
+			switch report[4] {
+			case streamDeckDialsTurn:
+				if delta := int(int8(value)); delta != 0 {
+					events = append(events, DialEvent{Dial: dial, Type: DialRotate, Delta: delta})
+				}
+			case streamDeckDialsPress:
+				if value != 0 && s.pressedAt[dial].IsZero() {
+					s.pressedAt[dial] = now
+				} else if value == 0 && !s.pressedAt[dial].IsZero() {
+					events = append(events, DialEvent{Dial: dial, Type: DialPress, PressType: KeyPressTypeForDuration(now.Sub(s.pressedAt[dial]))})
+					s.pressedAt[dial] = time.Time{}
+				}
+			}
+		}
+
+	case streamDeckInputTouch:
+		// Swipes aren't handled; taps are sent to the dial below the segment which was touched.
+		pressType := KeyPressShort
+		switch report[4] {
+		case streamDeckTouchShort:
+		case streamDeckTouchLong:
+			pressType = KeyPressLong
+		default:
+			return nil
+		}
+
+		x := int(report[6]) | int(report[7])<<8
+		if dial := x / s.model.stripSegmentWidth(); dial >= 0 && dial < s.model.dials {
+			events = append(events, DialEvent{Dial: dial, Type: DialTouch, PressType: pressType})
+		}
+	}
+	return events
+}
+
+// EncodeStreamDeckKeyImage encodes an image in the JPEG format used by the key and touch strip displays of the
+// supported models.
+func EncodeStreamDeckKeyImage(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: streamDeckJPEGQuality})
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package deskpad
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The ioctl direction bits from asm-generic/ioctl.h.
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+// hidrawDevice is a HID device opened through its hidraw node.
+type hidrawDevice struct {
+	*os.File
+}
+
+func openHIDDevice(path string) (hidDevice, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return hidrawDevice{f}, nil
+}
+
+// GetFeatureReport reads the feature report whose ID is the first byte of report into it.
+func (d hidrawDevice) GetFeatureReport(report []byte) error {
+	// HIDIOCGFEATURE(len) from linux/hidraw.h.
+	req := uintptr(iocRead|iocWrite)<<30 | uintptr(len(report))<<16 | 'H'<<8 | 0x07
+
+	conn, err := d.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno unix.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(&report[0])))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package deskpad
+
+func openHIDDevice(path string) (hidDevice, error) {
+	return nil, ErrHIDUnavailable
+}
@@ -0,0 +1,394 @@
+package deskpad
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	sdeck "github.com/Luzifer/streamdeck"
+)
+
+// fakeHIDDevice records the output reports written to it and returns the queued input reports.
+type fakeHIDDevice struct {
+	serial string
+	input  chan []byte
+
+	lock      sync.Mutex
+	writes    [][]byte
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newFakeHIDDevice(serial string) *fakeHIDDevice {
+	return &fakeHIDDevice{
+		serial: serial,
+		input:  make(chan []byte),
+		closed: make(chan struct{}),
+	}
+}
+
+func (d *fakeHIDDevice) Read(p []byte) (int, error) {
+	select {
+	case report := <-d.input:
+		return copy(p, report), nil
+	case <-d.closed:
+		return 0, os.ErrClosed
+	}
+}
+
+func (d *fakeHIDDevice) Write(p []byte) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.writes = append(d.writes, bytes.Clone(p))
+	return len(p), nil
+}
+
+func (d *fakeHIDDevice) GetFeatureReport(report []byte) error {
+	if report[0] != streamDeckSerialReportID {
+		return errors.New("unknown feature report")
+	}
+	copy(report[2:], d.serial)
+	return nil
+}
+
+func (d *fakeHIDDevice) Close() error {
+	d.closeOnce.Do(func() { close(d.closed) })
+	return nil
+}
+
+// keyImages reassembles the key images written to the device, in the order they were completed.
+func (d *fakeHIDDevice) keyImages(t *testing.T) map[int][][]byte {
+	t.Helper()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	images := map[int][][]byte{}
+	pending := map[int][]byte{}
+	for _, report := range d.writes {
+		if len(report) != streamDeckImageReportLength || report[0] != streamDeckImageReportID {
+			t.Fatalf("unexpected report header % x", report[:8])
+		}
+		if report[1] != streamDeckKeyImageCommand {
+			continue
+		}
+		keyID, last := int(report[2]), report[3] == 1
+		length := int(report[4]) | int(report[5])<<8
+		pending[keyID] = append(pending[keyID], report[streamDeckKeyImageHeaderLength:streamDeckKeyImageHeaderLength+length]...)
+		if last {
+			images[keyID] = append(images[keyID], pending[keyID])
+			delete(pending, keyID)
+		}
+	}
+	return images
+}
+
+// stripImages reassembles the touch strip images written to the device by their x offset, in the order they
+// were completed.
+func (d *fakeHIDDevice) stripImages(t *testing.T) map[int][][]byte {
+	t.Helper()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	images := map[int][][]byte{}
+	pending := map[int][]byte{}
+	for _, report := range d.writes {
+		if report[1] != streamDeckStripImageCommand {
+			continue
+		}
+		x, last := int(report[2])|int(report[3])<<8, report[10] == 1
+		length := int(report[13]) | int(report[14])<<8
+		pending[x] = append(pending[x], report[streamDeckStripImageHeaderLength:streamDeckStripImageHeaderLength+length]...)
+		if last {
+			images[x] = append(images[x], pending[x])
+			delete(pending, x)
+		}
+	}
+	return images
+}
+
+func newTestHIDStreamDeck(t *testing.T, dev *fakeHIDDevice) *HIDStreamDeck {
+	t.Helper()
+	return newTestHIDStreamDeckModel(t, dev, streamDeckModels[0])
+}
+
+func newTestHIDStreamDeckModel(t *testing.T, dev *fakeHIDDevice, model streamDeckModel) *HIDStreamDeck {
+	t.Helper()
+
+	sd, err := newHIDStreamDeck(dev, model)
+	if err != nil {
+		t.Fatalf("newHIDStreamDeck returned error: %s", err)
+	}
+	t.Cleanup(func() { sd.Close() })
+	return sd
+}
+
+func TestFindHIDStreamDeck(t *testing.T) {
+	root := t.TempDir()
+	for name, id := range map[string]string{
+		"hidraw0": "0003:0000046D:0000C52B",
+		"hidraw1": "0003:00000FD9:0000006D",
+		"hidraw2": "0003:00000FD9:00000084",
+	} {
+		dir := filepath.Join(root, name, "device")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("MkdirAll returned error: %s", err)
+		}
+		uevent := "DRIVER=hid-generic\nHID_ID=" + id + "\nHID_NAME=Test device\n"
+		if err := os.WriteFile(filepath.Join(dir, "uevent"), []byte(uevent), 0o644); err != nil {
+			t.Fatalf("WriteFile returned error: %s", err)
+		}
+	}
+
+	path, model, err := findHIDStreamDeck(root, "/dev")
+	if err != nil || path != "/dev/hidraw1" || model.name != "Stream Deck Original V2" {
+		t.Fatalf("found %q %q (%v), want the original v2 at /dev/hidraw1", path, model.name, err)
+	}
+
+	os.RemoveAll(filepath.Join(root, "hidraw1"))
+	path, model, err = findHIDStreamDeck(root, "/dev")
+	if err != nil || path != "/dev/hidraw2" || model.name != "Stream Deck +" || model.dials != 4 {
+		t.Fatalf("found %q %q (%v), want the stream deck + at /dev/hidraw2", path, model.name, err)
+	}
+
+	os.RemoveAll(filepath.Join(root, "hidraw2"))
+	if _, _, err := findHIDStreamDeck(root, "/dev"); !errors.Is(err, ErrNoStreamDeck) {
+		t.Fatalf("err = %v without a deck, want %v", err, ErrNoStreamDeck)
+	}
+	if _, _, err := findHIDStreamDeck(filepath.Join(root, "missing"), "/dev"); !errors.Is(err, ErrHIDUnavailable) {
+		t.Fatalf("err = %v without hidraw, want %v", err, ErrHIDUnavailable)
+	}
+}
+
+func TestHIDStreamDeckWritesKeyImagesInPages(t *testing.T) {
+	dev := newFakeHIDDevice("AL12H1A00001")
+	sd := newTestHIDStreamDeck(t, dev)
+
+	if serial, err := sd.Serial(); err != nil || serial != "AL12H1A00001" {
+		t.Fatalf("serial = %q (%v), want AL12H1A00001", serial, err)
+	}
+
+	data := bytes.Repeat([]byte{0xab}, 2000)
+	if err := sd.WriteKeyImage(4, data); err != nil {
+		t.Fatalf("WriteKeyImage returned error: %s", err)
+	}
+	if len(dev.writes) != 2 {
+		t.Fatalf("image sent in %d reports, want 2", len(dev.writes))
+	}
+	if want := []byte{0x02, 0x07, 4, 0, 0xf8, 0x03, 0, 0}; !bytes.Equal(dev.writes[0][:8], want) {
+		t.Fatalf("first page header = % x, want % x", dev.writes[0][:8], want)
+	}
+	if want := []byte{0x02, 0x07, 4, 1, 0xd8, 0x03, 1, 0}; !bytes.Equal(dev.writes[1][:8], want) {
+		t.Fatalf("last page header = % x, want % x", dev.writes[1][:8], want)
+	}
+	if images := dev.keyImages(t); !bytes.Equal(images[4][0], data) {
+		t.Fatalf("reassembled image does not match")
+	}
+
+	if err := sd.WriteKeyImage(15, data); err == nil {
+		t.Fatalf("WriteKeyImage accepted an invalid key")
+	}
+}
+
+func TestHIDStreamDeckReadsKeyEvents(t *testing.T) {
+	dev := newFakeHIDDevice("deck")
+	sd := newTestHIDStreamDeck(t, dev)
+	events := sd.Subscribe()
+
+	report := make([]byte, streamDeckInputReportLength)
+	report[0], report[2] = streamDeckInputReportID, 15
+	report[4+7] = 1
+	dev.input <- bytes.Clone(report)
+	report[4+7] = 0
+	dev.input <- bytes.Clone(report)
+
+	for _, want := range []sdeck.Event{{Key: 7, Type: sdeck.EventTypeDown}, {Key: 7, Type: sdeck.EventTypeUp}} {
+		select {
+		case event := <-events:
+			if event != want {
+				t.Fatalf("event = %+v, want %+v", event, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+
+	sd.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("unexpected event after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("events not closed after the device was closed")
+	}
+}
+
+func TestStreamDeckSurfaceEncodesRepeatedKeysOnce(t *testing.T) {
+	dev := newFakeHIDDevice("deck")
+	surface := NewStreamDeckSurface(newTestHIDStreamDeck(t, dev))
+	if spec := surface.RenderSpec(); spec.KeyWidth != 72 || spec.Rotation != 180 {
+		t.Fatalf("render spec = %+v, want 72px keys rotated 180 degrees", spec)
+	}
+
+	home := testImage(color.RGBA{R: 255, A: 255})
+	snapshot := Snapshot{ScreenName: "home", Keys: make([]image.Image, 15)}
+	snapshot.Keys[0] = home
+	snapshot.Keys[2] = testImage(color.RGBA{R: 255, A: 255})
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+
+	// The home icon moves to another key on the next screen, and is sent without being encoded again.
+	next := Snapshot{ScreenName: "next", Keys: make([]image.Image, 15)}
+	next.Keys[4] = home
+	if err := surface.Refresh(next); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+
+	if surface.encoded.Len() != 1 {
+		t.Fatalf("encoded %d images, want the home icon once", surface.encoded.Len())
+	}
+	images := dev.keyImages(t)
+	if len(images[4]) != 2 || !bytes.Equal(images[4][1], images[0][1]) {
+		t.Fatalf("key 4 was not sent the cached home icon")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(images[4][1])); err != nil {
+		t.Fatalf("key image is not a jpeg: %s", err)
+	}
+}
+
+func TestHIDStreamDeckReadsDialEvents(t *testing.T) {
+	dev := newFakeHIDDevice("plus")
+	sd := newTestHIDStreamDeckModel(t, dev, streamDeckModels[2])
+	events := sd.SubscribeDials()
+
+	dials := func(kind byte, values ...byte) []byte {
+		report := make([]byte, streamDeckInputReportLength)
+		report[0], report[1], report[4] = streamDeckInputReportID, streamDeckInputDials, kind
+		copy(report[5:], values)
+		return report
+	}
+	touch := func(kind byte, x int) []byte {
+		report := make([]byte, streamDeckInputReportLength)
+		report[0], report[1], report[4] = streamDeckInputReportID, streamDeckInputTouch, kind
+		report[6], report[7] = byte(x), byte(x>>8)
+		return report
+	}
+
+	dev.input <- dials(streamDeckDialsTurn, 0, 3, 0, 0xfe)
+	dev.input <- dials(streamDeckDialsPress, 0, 0, 1, 0)
+	dev.input <- dials(streamDeckDialsPress, 0, 0, 0, 0)
+	dev.input <- touch(0x03, 100)
+	dev.input <- touch(streamDeckTouchLong, 650)
+
+	for _, want := range []DialEvent{
+		{Dial: 1, Type: DialRotate, Delta: 3},
+		{Dial: 3, Type: DialRotate, Delta: -2},
+		{Dial: 2, Type: DialPress, PressType: KeyPressShort},
+		{Dial: 3, Type: DialTouch, PressType: KeyPressLong},
+	} {
+		select {
+		case event := <-events:
+			if event != want {
+				t.Fatalf("event = %+v, want %+v", event, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+
+	sd.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("unexpected event after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("dial events not closed after the device was closed")
+	}
+}
+
+// notifyingDialScreen sends the dial events it receives on a channel.
+type notifyingDialScreen struct {
+	fakeScreen
+	events chan DialEvent
+}
+
+func (s *notifyingDialScreen) ShowStrip(dialCount int) []image.Image {
+	return nil
+}
+
+func (s *notifyingDialScreen) DialChanged(ctx context.Context, event DialEvent) (KeyPressAction, error) {
+	s.events <- event
+	return KeyPressAction{Action: KeyPressActionNoop}, nil
+}
+
+func TestStreamDeckSurfaceDrivesStripAndDials(t *testing.T) {
+	dev := newFakeHIDDevice("plus")
+	surface := NewStreamDeckSurface(newTestHIDStreamDeckModel(t, dev, streamDeckModels[2]))
+	if spec := surface.RenderSpec(); spec.KeyWidth != 120 || spec.StripWidth != 200 || spec.StripHeight != 100 {
+		t.Fatalf("render spec = %+v, want 120px keys and 200x100 strip segments", spec)
+	}
+	if surface.DialCount() != 4 {
+		t.Fatalf("dial count = %d, want 4", surface.DialCount())
+	}
+
+	volume := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	snapshot := Snapshot{ScreenName: "media", Keys: make([]image.Image, 8), Strip: make([]image.Image, 4)}
+	snapshot.Strip[1] = volume
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+
+	// Each segment is blanked when the deck is attached, then the volume segment is sent once.
+	images := dev.stripImages(t)
+	if len(images[0]) != 1 || len(images[200]) != 2 || len(images[400]) != 1 || len(images[600]) != 1 {
+		t.Fatalf("strip segments written %d, %d, %d, %d times, want the volume segment twice and the others once",
+			len(images[0]), len(images[200]), len(images[400]), len(images[600]))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(images[200][1])); err != nil {
+		t.Fatalf("strip image is not a jpeg: %s", err)
+	}
+	for _, report := range dev.writes {
+		if report[1] == streamDeckStripImageCommand && report[2] == 200 {
+			if want := []byte{0x02, 0x0c, 200, 0, 0, 0, 200, 0, 100, 0}; !bytes.Equal(report[:10], want) {
+				t.Fatalf("strip header = % x, want % x", report[:10], want)
+			}
+		}
+	}
+
+	screen := &notifyingDialScreen{fakeScreen: fakeScreen{name: "media"}, events: make(chan DialEvent, 1)}
+	deck := NewDeck(screen)
+	deck.RegisterSurface(surface)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go surface.Run(ctx, deck)
+
+	report := make([]byte, streamDeckInputReportLength)
+	report[0], report[1], report[4], report[5] = streamDeckInputReportID, streamDeckInputDials, streamDeckDialsTurn, 1
+	dev.input <- report
+
+	select {
+	case event := <-screen.events:
+		if want := (DialEvent{Dial: 0, Type: DialRotate, Delta: 1}); event != want {
+			t.Fatalf("screen received %+v, want %+v", event, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("dial event was not forwarded to the deck")
+	}
+}
@@ -0,0 +1,123 @@
+package deskpad
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const defaultStreamDeckPollInterval = 2 * time.Second
+
+// StreamDeckOpener opens the attached Stream Deck, returning an error if none is present.
+type StreamDeckOpener func() (StreamDeckDevice, error)
+
+// StreamDeckManager watches for a Stream Deck being plugged in or removed. When a deck is attached a surface
+// is created for it and registered with the Deck, which immediately draws the current state; when it is
+// removed the surface is unregistered and the device closed. Devices are detected by polling the opener.
+type StreamDeckManager struct {
+	d            *Deck
+	open         StreamDeckOpener
+	pollInterval time.Duration
+	attached     atomic.Bool
+
+	// onChange is called whenever a device is attached or removed; used in testing.
+	onChange func(attached bool)
+}
+
+// NewStreamDeckManager creates a manager which attaches Stream Decks returned by open to the deck.
+func NewStreamDeckManager(d *Deck, open StreamDeckOpener) *StreamDeckManager {
+	return &StreamDeckManager{
+		d:            d,
+		open:         open,
+		pollInterval: defaultStreamDeckPollInterval,
+	}
+}
+
+// Run watches for devices until the context is cancelled.
+func (m *StreamDeckManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	missingLogged := false
+	for {
+		device, err := m.open()
+		if err != nil {
+			if !missingLogged {
+				streamDeckLogger.Info("no stream deck available, waiting for one to be attached", "err", err)
+				missingLogged = true
+			}
+		} else {
+			missingLogged = false
+			m.attach(ctx, device, ticker.C)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// attach drives the device until it is removed or the context is cancelled.
+func (m *StreamDeckManager) attach(ctx context.Context, device StreamDeckDevice, poll <-chan time.Time) {
+	serial, err := device.Serial()
+	if err != nil {
+		streamDeckLogger.Warn("unable to get stream deck serial number", "err", err)
+		device.Close()
+		return
+	}
+	streamDeckLogger.Info("using stream deck", "surface", serial)
+
+	surface := NewStreamDeckSurface(device)
+	m.d.RegisterSurface(surface)
+	m.notify(true)
+
+	surfaceCtx, surfaceCancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		surface.Run(surfaceCtx, m.d)
+	}()
+
+	// The device is considered removed once it stops delivering events or stops answering requests.
+	func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-poll:
+				if _, err := device.Serial(); err != nil {
+					streamDeckLogger.Warn("stream deck stopped responding", "surface", serial, "err", err)
+					return
+				}
+			}
+		}
+	}()
+
+	m.d.UnregisterSurface(surface)
+	surfaceCancel()
+	<-done
+
+	if err := device.Close(); err != nil {
+		streamDeckLogger.Error("unable to close stream deck", "surface", serial, "err", err)
+	}
+	if ctx.Err() == nil {
+		streamDeckLogger.Info("stream deck removed", "surface", serial)
+	}
+	m.notify(false)
+}
+
+// Attached returns whether a Stream Deck is currently attached.
+func (m *StreamDeckManager) Attached() bool {
+	return m.attached.Load()
+}
+
+func (m *StreamDeckManager) notify(attached bool) {
+	m.attached.Store(attached)
+	if m.onChange != nil {
+		m.onChange(attached)
+	}
+}
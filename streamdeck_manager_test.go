@@ -0,0 +1,229 @@
+package deskpad
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	sdeck "github.com/Luzifer/streamdeck"
+)
+
+type fakeStreamDeck struct {
+	serial string
+	events chan sdeck.Event
+
+	lock      sync.Mutex
+	removed   bool
+	closed    bool
+	filled    map[int]image.Image
+	writes    int
+	clearAlls int
+	serials   int
+}
+
+func newFakeStreamDeck(serial string) *fakeStreamDeck {
+	return &fakeStreamDeck{
+		serial: serial,
+		events: make(chan sdeck.Event),
+		filled: map[int]image.Image{},
+	}
+}
+
+func (sd *fakeStreamDeck) err() error {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	if sd.removed {
+		return errors.New("device removed")
+	}
+	return nil
+}
+
+func (sd *fakeStreamDeck) Serial() (string, error) {
+	sd.lock.Lock()
+	sd.serials++
+	sd.lock.Unlock()
+	return sd.serial, sd.err()
+}
+
+func (sd *fakeStreamDeck) NumKeys() int {
+	return 15
+}
+
+func (sd *fakeStreamDeck) ClearAllKeys() error {
+	sd.lock.Lock()
+	sd.filled = map[int]image.Image{}
+	sd.clearAlls++
+	sd.lock.Unlock()
+	return sd.err()
+}
+
+func (sd *fakeStreamDeck) ClearKey(keyID int) error {
+	sd.lock.Lock()
+	delete(sd.filled, keyID)
+	sd.writes++
+	sd.lock.Unlock()
+	return sd.err()
+}
+
+func (sd *fakeStreamDeck) FillImage(keyID int, img image.Image) error {
+	sd.lock.Lock()
+	sd.filled[keyID] = img
+	sd.writes++
+	sd.lock.Unlock()
+	return sd.err()
+}
+
+func (sd *fakeStreamDeck) Subscribe() <-chan sdeck.Event {
+	return sd.events
+}
+
+func (sd *fakeStreamDeck) Close() error {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	sd.closed = true
+	return nil
+}
+
+func (sd *fakeStreamDeck) remove() {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	sd.removed = true
+}
+
+func (sd *fakeStreamDeck) isClosed() bool {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	return sd.closed
+}
+
+func (sd *fakeStreamDeck) filledKey(keyID int) image.Image {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+
+	return sd.filled[keyID]
+}
+
+// fakeStreamDeckEnumerator hands out the queued devices, reporting no device present when the queue is empty.
+type fakeStreamDeckEnumerator struct {
+	lock    sync.Mutex
+	devices []*fakeStreamDeck
+}
+
+func (e *fakeStreamDeckEnumerator) plug(sd *fakeStreamDeck) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.devices = append(e.devices, sd)
+}
+
+func (e *fakeStreamDeckEnumerator) open() (StreamDeckDevice, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if len(e.devices) == 0 {
+		return nil, errors.New("no stream deck found")
+	}
+
+	sd := e.devices[0]
+	e.devices = e.devices[1:]
+	return sd, nil
+}
+
+func TestStreamDeckManagerReconnects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	icon := testImage(color.RGBA{G: 255, A: 255})
+	deck := NewDeck(&fakeScreen{name: "home", showKeys: []image.Image{icon}})
+	deck.RefreshScreen()
+
+	enumerator := &fakeStreamDeckEnumerator{}
+	manager := NewStreamDeckManager(deck, enumerator.open)
+	manager.pollInterval = 5 * time.Millisecond
+
+	changes := make(chan bool, 4)
+	manager.onChange = func(attached bool) { changes <- attached }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.Run(ctx)
+	}()
+
+	expectChange := func(want bool) {
+		t.Helper()
+
+		select {
+		case attached := <-changes:
+			if attached != want {
+				t.Fatalf("device attached = %t, want %t", attached, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for device attached = %t", want)
+		}
+	}
+
+	first := newFakeStreamDeck("first")
+	enumerator.plug(first)
+	expectChange(true)
+	if !manager.Attached() {
+		t.Fatalf("Attached = false after a deck was plugged in")
+	}
+	if !isGreenKey(first.filledKey(0)) {
+		t.Fatalf("attached deck was not drawn with the current snapshot")
+	}
+	if deck.ID() != "first" {
+		t.Fatalf("deck surface = %q, want first", deck.ID())
+	}
+
+	first.remove()
+	expectChange(false)
+	if manager.Attached() {
+		t.Fatalf("Attached = true after the deck was removed")
+	}
+	if !first.isClosed() {
+		t.Fatalf("removed deck was not closed")
+	}
+	if deck.ID() != "" {
+		t.Fatalf("removed deck still registered as %q", deck.ID())
+	}
+
+	// Unplugging can also show up as the event stream ending.
+	second := newFakeStreamDeck("second")
+	enumerator.plug(second)
+	expectChange(true)
+	if !isGreenKey(second.filledKey(0)) {
+		t.Fatalf("reattached deck was not drawn with the current snapshot")
+	}
+
+	close(second.events)
+	expectChange(false)
+	if !second.isClosed() {
+		t.Fatalf("removed deck was not closed")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("manager did not stop after the context was cancelled")
+	}
+}
+
+// isGreenKey reports if the key was filled with the green test icon, rendered to the Stream Deck key size.
+func isGreenKey(img image.Image) bool {
+	if img == nil || img.Bounds().Dx() != streamDeckKeySize {
+		return false
+	}
+
+	r, g, b, _ := img.At(streamDeckKeySize/2, streamDeckKeySize/2).RGBA()
+	return r == 0 && g == 0xffff && b == 0
+}
@@ -17,11 +17,16 @@ type Snapshot struct {
 	Rows       int
 	Columns    int
 	Keys       []image.Image
+	// Strip holds the touch strip segment above each dial, for surfaces which have dials.
+	Strip []image.Image
 }
 
 func cloneSnapshot(snapshot Snapshot) Snapshot {
 	keys := make([]image.Image, len(snapshot.Keys))
 	copy(keys, snapshot.Keys)
 	snapshot.Keys = keys
+	if snapshot.Strip != nil {
+		snapshot.Strip = append([]image.Image(nil), snapshot.Strip...)
+	}
 	return snapshot
 }
@@ -0,0 +1,187 @@
+package deskpad
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	fileSurfaceKeySize = 72
+	fileSurfaceGap     = 8
+)
+
+var fileSurfaceBackground = color.RGBA{R: 0x19, G: 0x1d, B: 0x20, A: 0xff}
+
+// FileSurface renders state to PNG files on disk. This allows screen layouts to be inspected
+// without a physical deck attached, and rendered output to be compared against golden images.
+// Each render writes grid.png, containing all keys composited into the deck layout, and a
+// key-NN.png for each populated key. If history is enabled, each render is also kept in a
+// numbered subdirectory.
+type FileSurface struct {
+	dir         string
+	keepHistory bool
+
+	lock     sync.Mutex
+	snapshot Snapshot
+	renders  int
+	// keyHashes holds the hash of each key file in dir, so unchanged keys are not rewritten.
+	keyHashes []string
+}
+
+// NewFileSurface creates a surface which writes rendered state into the specified directory.
+func NewFileSurface(dir string, keepHistory bool) *FileSurface {
+	return &FileSurface{
+		dir:         dir,
+		keepHistory: keepHistory,
+	}
+}
+
+func (s *FileSurface) ID() string {
+	return "file:" + s.dir
+}
+
+func (s *FileSurface) KeyCount() int {
+	return 0
+}
+
+func (s *FileSurface) RenderSpec() RenderSpec {
+	return RenderSpec{KeyWidth: fileSurfaceKeySize, KeyHeight: fileSurfaceKeySize}
+}
+
+func (s *FileSurface) Refresh(snapshot Snapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.snapshot = cloneSnapshot(snapshot)
+	return s.writeLocked()
+}
+
+func (s *FileSurface) UpdateKey(snapshot Snapshot, keyID int) error {
+	return s.Refresh(snapshot)
+}
+
+func (s *FileSurface) Clear() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.snapshot.Keys = make([]image.Image, len(s.snapshot.Keys))
+	return s.writeLocked()
+}
+
+// Dir returns the directory the current render is written to.
+func (s *FileSurface) Dir() string {
+	return s.dir
+}
+
+func (s *FileSurface) writeLocked() error {
+	keyHashes, err := writeSnapshotPNGs(s.dir, s.snapshot, s.keyHashes)
+	s.keyHashes = keyHashes
+	if err != nil {
+		return err
+	}
+
+	s.renders++
+	if !s.keepHistory {
+		return nil
+	}
+
+	historyDir := filepath.Join(s.dir, "history", fmt.Sprintf("%06d", s.renders))
+	_, err = writeSnapshotPNGs(historyDir, s.snapshot, nil)
+	return err
+}
+
+// writeSnapshotPNGs writes the grid and key images into dir. Keys whose hash matches the entry in written are
+// assumed to already be on disk and are skipped; a nil written slice writes every key. The hashes of the key
+// files now in dir are returned, or nil if they are unknown because of an error.
+func writeSnapshotPNGs(dir string, snapshot Snapshot, written []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := writePNG(filepath.Join(dir, "grid.png"), RenderGrid(snapshot)); err != nil {
+		return nil, err
+	}
+
+	keyHashes := make([]string, len(snapshot.Keys))
+	for keyID, keyImg := range snapshot.Keys {
+		keyHashes[keyID] = ImageHash(keyImg)
+		if written != nil && keyID < len(written) && written[keyID] == keyHashes[keyID] {
+			continue
+		}
+
+		keyPath := filepath.Join(dir, fmt.Sprintf("key-%02d.png", keyID))
+		if keyImg == nil {
+			if err := os.Remove(keyPath); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := writePNG(keyPath, keyImg); err != nil {
+			return nil, err
+		}
+	}
+
+	return keyHashes, nil
+}
+
+// RenderGrid composites the keys of a snapshot into a single image laid out like the physical deck.
+// Keys are scaled to 72x72 pixels; empty keys are left black.
+func RenderGrid(snapshot Snapshot) image.Image {
+	rows, columns := snapshot.Rows, snapshot.Columns
+	if rows <= 0 || columns <= 0 {
+		rows, columns = deckGeometry(len(snapshot.Keys))
+	}
+
+	cell := fileSurfaceKeySize + fileSurfaceGap
+	grid := image.NewRGBA(image.Rect(0, 0, columns*cell+fileSurfaceGap, rows*cell+fileSurfaceGap))
+	draw.Draw(grid, grid.Bounds(), image.NewUniform(fileSurfaceBackground), image.Point{}, draw.Src)
+
+	for keyID := 0; keyID < rows*columns; keyID++ {
+		x := fileSurfaceGap + (keyID%columns)*cell
+		y := fileSurfaceGap + (keyID/columns)*cell
+		keyRect := image.Rect(x, y, x+fileSurfaceKeySize, y+fileSurfaceKeySize)
+		draw.Draw(grid, keyRect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+		if keyID >= len(snapshot.Keys) || snapshot.Keys[keyID] == nil {
+			continue
+		}
+
+		keyImg := snapshot.Keys[keyID]
+		if keyImg.Bounds().Dx() == fileSurfaceKeySize && keyImg.Bounds().Dy() == fileSurfaceKeySize {
+			draw.Draw(grid, keyRect, keyImg, keyImg.Bounds().Min, draw.Over)
+		} else {
+			xdraw.CatmullRom.Scale(grid, keyRect, keyImg, keyImg.Bounds(), draw.Over, nil)
+		}
+	}
+
+	return grid
+}
+
+func writePNG(path string, img image.Image) error {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
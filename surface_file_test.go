@@ -0,0 +1,89 @@
+package deskpad
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSurfaceWritesGridAndKeyImages(t *testing.T) {
+	dir := t.TempDir()
+	surface := NewFileSurface(dir, false)
+
+	icon := image.NewRGBA(image.Rect(0, 0, 72, 72))
+	for x := 0; x < 72; x++ {
+		for y := 0; y < 72; y++ {
+			icon.Set(x, y, color.RGBA{G: 255, A: 255})
+		}
+	}
+
+	snapshot := Snapshot{ScreenName: "home", Rows: 3, Columns: 5, Keys: make([]image.Image, 15)}
+	snapshot.Keys[6] = icon
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+
+	grid := readTestPNG(t, filepath.Join(dir, "grid.png"))
+	wantWidth := 5*(fileSurfaceKeySize+fileSurfaceGap) + fileSurfaceGap
+	wantHeight := 3*(fileSurfaceKeySize+fileSurfaceGap) + fileSurfaceGap
+	if grid.Bounds().Dx() != wantWidth || grid.Bounds().Dy() != wantHeight {
+		t.Fatalf("grid size = %dx%d, want %dx%d", grid.Bounds().Dx(), grid.Bounds().Dy(), wantWidth, wantHeight)
+	}
+
+	// Key 6 is in the second row, second column.
+	cell := fileSurfaceKeySize + fileSurfaceGap
+	r, g, b, _ := grid.At(fileSurfaceGap+cell+10, fileSurfaceGap+cell+10).RGBA()
+	if r != 0 || g != 0xffff || b != 0 {
+		t.Fatalf("grid key 6 pixel = (%d, %d, %d), want green", r, g, b)
+	}
+
+	readTestPNG(t, filepath.Join(dir, "key-06.png"))
+	if _, err := os.Stat(filepath.Join(dir, "key-00.png")); !os.IsNotExist(err) {
+		t.Fatalf("empty key 0 was written: %v", err)
+	}
+
+	snapshot.Keys[6] = nil
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "key-06.png")); !os.IsNotExist(err) {
+		t.Fatalf("stale key 6 was not removed: %v", err)
+	}
+}
+
+func TestFileSurfaceKeepsHistory(t *testing.T) {
+	dir := t.TempDir()
+	surface := NewFileSurface(dir, true)
+	screen := &fakeScreen{name: "home", showKeys: []image.Image{testImage(color.RGBA{R: 255, A: 255})}}
+	deck := NewDeck(screen)
+	deck.RegisterSurface(surface)
+	deck.RefreshScreen()
+
+	// The first render happens on registration, before the screen has been shown.
+	readTestPNG(t, filepath.Join(dir, "history", "000001", "grid.png"))
+	if _, err := os.Stat(filepath.Join(dir, "history", "000001", "key-00.png")); !os.IsNotExist(err) {
+		t.Fatalf("registration render contained key 0: %v", err)
+	}
+	readTestPNG(t, filepath.Join(dir, "history", "000002", "grid.png"))
+	readTestPNG(t, filepath.Join(dir, "history", "000002", "key-00.png"))
+}
+
+func readTestPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s: %s", path, err)
+	}
+
+	return img
+}
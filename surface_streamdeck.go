@@ -3,43 +3,135 @@ package deskpad
 import (
 	"context"
 	"image"
-	"log"
+	"sync"
 	"time"
 
 	sdeck "github.com/Luzifer/streamdeck"
+	"github.com/rmrobinson/deskpad/logging"
 )
 
+var streamDeckLogger = logging.Logger("streamdeck")
+
+const streamDeckKeySize = 72
+
+// StreamDeckDevice is the set of operations used to drive a physical Stream Deck. It is satisfied by *sdeck.Client.
+type StreamDeckDevice interface {
+	Serial() (string, error)
+	NumKeys() int
+	ClearAllKeys() error
+	ClearKey(keyID int) error
+	FillImage(keyID int, img image.Image) error
+	Subscribe() <-chan sdeck.Event
+	Close() error
+}
+
+// StreamDeckKeyWriter is implemented by devices which accept key images already encoded by
+// EncodeStreamDeckKeyImage, at the size and orientation of their RenderSpec. The surface caches the encoded
+// images by content hash, so icons which are shown repeatedly are only encoded once.
+type StreamDeckKeyWriter interface {
+	RenderSpec() RenderSpec
+	WriteKeyImage(keyID int, data []byte) error
+}
+
+// StreamDeckDialDevice is implemented by devices with dials and a touch strip, such as the Stream Deck +. Strip
+// images are encoded like key images, at the strip size of the RenderSpec of the device.
+type StreamDeckDialDevice interface {
+	DialCount() int
+	SubscribeDials() <-chan DialEvent
+	WriteStripImage(dial int, data []byte) error
+	ClearStripImage(dial int) error
+}
+
 // StreamDeckSurface renders state to a physical Stream Deck and forwards key events.
+// The content hash of the image on each key is tracked so only keys which change are sent to the device,
+// avoiding flicker and USB traffic when screens share icons or are refreshed in place.
 type StreamDeckSurface struct {
-	sd          *sdeck.Client
+	sd          StreamDeckDevice
+	id          string
 	lastKeyDown time.Time
-}
 
-func NewStreamDeckSurface(sd *sdeck.Client) *StreamDeckSurface {
-	return &StreamDeckSurface{sd: sd}
+	// writer and encoded are set if the device accepts encoded key images, and dials if it also has dials.
+	writer  StreamDeckKeyWriter
+	encoded *EncodedImageCache
+	dials   StreamDeckDialDevice
+
+	lock sync.Mutex
+	// keyHashes holds the hash of the image shown on each key, or is nil if the device state is unknown.
+	// stripHashes does the same for the touch strip segment above each dial.
+	keyHashes   []string
+	stripHashes []string
 }
 
-func (s *StreamDeckSurface) ID() string {
-	id, err := s.sd.Serial()
+// NewStreamDeckSurface returns a surface for the device. The serial number is read once here, as each read is a
+// round trip to the device and the ID is used to label every refresh.
+func NewStreamDeckSurface(sd StreamDeckDevice) *StreamDeckSurface {
+	id, err := sd.Serial()
 	if err != nil {
-		log.Printf("error getting streamdeck id: %s\n", err.Error())
-		return ""
+		streamDeckLogger.Error("unable to get stream deck id", "err", err)
 	}
 
-	return id
+	s := &StreamDeckSurface{sd: sd, id: id}
+	if writer, ok := sd.(StreamDeckKeyWriter); ok {
+		s.writer = writer
+		s.encoded = NewEncodedImageCache(EncodeStreamDeckKeyImage, 0)
+		if dials, ok := sd.(StreamDeckDialDevice); ok && dials.DialCount() > 0 {
+			s.dials = dials
+		}
+	}
+	return s
+}
+
+func (s *StreamDeckSurface) ID() string {
+	return s.id
 }
 
 func (s *StreamDeckSurface) KeyCount() int {
 	return s.sd.NumKeys()
 }
 
+// DialCount returns the number of dials on the device, which is 0 for models without them.
+func (s *StreamDeckSurface) DialCount() int {
+	if s.dials == nil {
+		return 0
+	}
+	return s.dials.DialCount()
+}
+
+// RenderSpec returns the key resolution of the device. Devices driven through the Stream Deck library are
+// taken to be the Original V2, and the library orients the images itself.
+func (s *StreamDeckSurface) RenderSpec() RenderSpec {
+	if s.writer != nil {
+		return s.writer.RenderSpec()
+	}
+	return RenderSpec{KeyWidth: streamDeckKeySize, KeyHeight: streamDeckKeySize}
+}
+
 func (s *StreamDeckSurface) Refresh(snapshot Snapshot) error {
-	if err := s.sd.ClearAllKeys(); err != nil {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.resetLocked(); err != nil {
 		return err
 	}
 
-	for keyID, keyImg := range snapshot.Keys {
-		if err := s.fillKey(keyID, keyImg); err != nil {
+	for keyID := range s.keyHashes {
+		var keyImg image.Image
+		if keyID < len(snapshot.Keys) {
+			keyImg = snapshot.Keys[keyID]
+		}
+
+		if err := s.syncKeyLocked(keyID, keyImg); err != nil {
+			return err
+		}
+	}
+
+	for dial := range s.stripHashes {
+		var segment image.Image
+		if dial < len(snapshot.Strip) {
+			segment = snapshot.Strip[dial]
+		}
+
+		if err := s.syncStripLocked(dial, segment); err != nil {
 			return err
 		}
 	}
@@ -52,48 +144,138 @@ func (s *StreamDeckSurface) UpdateKey(snapshot Snapshot, keyID int) error {
 		return nil
 	}
 
-	return s.fillKey(keyID, snapshot.Keys[keyID])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.resetLocked(); err != nil {
+		return err
+	}
+	if keyID >= len(s.keyHashes) {
+		return nil
+	}
+
+	return s.syncKeyLocked(keyID, snapshot.Keys[keyID])
 }
 
 func (s *StreamDeckSurface) Clear() error {
-	return s.sd.ClearAllKeys()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keyHashes = nil
+	s.stripHashes = nil
+	return s.resetLocked()
 }
 
 // Run starts the loop of listening for inputs from the physical Stream Deck.
+// It returns when the context is cancelled or the device stops delivering events.
 func (s *StreamDeckSurface) Run(ctx context.Context, d *Deck) {
 	events := s.sd.Subscribe()
+	var dialEvents <-chan DialEvent
+	if s.dials != nil {
+		dialEvents = s.dials.SubscribeDials()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			if err := s.Clear(); err != nil {
-				log.Printf("error clearing surface %s: %s\n", s.ID(), err.Error())
+				streamDeckLogger.Error("unable to clear surface", "surface", s.ID(), "err", err)
 			}
 			return
 
-		case event := <-events:
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
 			if event.Type == sdeck.EventTypeDown {
 				s.lastKeyDown = time.Now()
 				continue
 			} else if event.Type == sdeck.EventTypeUp {
-				t := KeyPressShort
-				if s.lastKeyDown.Add(longKeypressDuration).Before(time.Now()) {
-					t = KeyPressLong
-				}
-
-				_ = d.PressKey(ctx, event.Key, t)
+				_ = d.PressKey(ctx, event.Key, KeyPressTypeForDuration(time.Since(s.lastKeyDown)))
 				continue
 			}
 
-			log.Printf("received unhandled event type %d for key %d\n", event.Type, event.Key)
+			streamDeckLogger.Debug("unhandled stream deck event", "event", event.Type, "key", event.Key)
+
+		case event, ok := <-dialEvents:
+			if !ok {
+				return
+			}
+
+			_ = d.DialChanged(ctx, event)
+		}
+	}
+}
+
+// resetLocked clears the device if its state is unknown, such as when it was just attached or a previous write failed.
+func (s *StreamDeckSurface) resetLocked() error {
+	if s.keyHashes != nil {
+		return nil
+	}
+
+	if err := s.sd.ClearAllKeys(); err != nil {
+		return err
+	}
+	for dial := 0; dial < s.DialCount(); dial++ {
+		if err := s.dials.ClearStripImage(dial); err != nil {
+			return err
 		}
 	}
+	s.keyHashes = make([]string, s.sd.NumKeys())
+	s.stripHashes = make([]string, s.DialCount())
+	return nil
 }
 
-func (s *StreamDeckSurface) fillKey(keyID int, keyImg image.Image) error {
+// syncKeyLocked sends the key image to the device if it differs from what is already shown.
+func (s *StreamDeckSurface) syncKeyLocked(keyID int, keyImg image.Image) error {
+	hash := ImageHash(keyImg)
+	if s.keyHashes[keyID] == hash {
+		return nil
+	}
+
+	var err error
 	if keyImg == nil {
-		return s.sd.ClearKey(keyID)
+		err = s.sd.ClearKey(keyID)
+	} else if s.writer != nil {
+		var data []byte
+		if _, data, err = s.encoded.Encode(keyImg); err == nil {
+			err = s.writer.WriteKeyImage(keyID, data)
+		}
+	} else {
+		err = s.sd.FillImage(keyID, keyImg)
+	}
+	if err != nil {
+		s.keyHashes = nil
+		return err
 	}
 
-	return s.sd.FillImage(keyID, keyImg)
+	s.keyHashes[keyID] = hash
+	return nil
+}
+
+// syncStripLocked sends the touch strip segment above the dial to the device if it differs from what is already shown.
+func (s *StreamDeckSurface) syncStripLocked(dial int, segment image.Image) error {
+	hash := ImageHash(segment)
+	if s.stripHashes[dial] == hash {
+		return nil
+	}
+
+	var err error
+	if segment == nil {
+		err = s.dials.ClearStripImage(dial)
+	} else {
+		var data []byte
+		if _, data, err = s.encoded.Encode(segment); err == nil {
+			err = s.dials.WriteStripImage(dial, data)
+		}
+	}
+	if err != nil {
+		s.keyHashes = nil
+		s.stripHashes = nil
+		return err
+	}
+
+	s.stripHashes[dial] = hash
+	return nil
 }
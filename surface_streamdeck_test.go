@@ -0,0 +1,77 @@
+package deskpad
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStreamDeckSurfaceOnlySendsChangedKeys(t *testing.T) {
+	sd := newFakeStreamDeck("deck")
+	surface := NewStreamDeckSurface(sd)
+
+	home := testImage(color.RGBA{R: 255, A: 255})
+	snapshot := Snapshot{ScreenName: "home", Keys: make([]image.Image, 15)}
+	snapshot.Keys[0] = home
+	snapshot.Keys[1] = testImage(color.RGBA{G: 255, A: 255})
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if sd.clearAlls != 1 || sd.writes != 2 {
+		t.Fatalf("first refresh made %d clears and %d writes, want 1 and 2", sd.clearAlls, sd.writes)
+	}
+
+	// A new screen sharing the home icon, using an equal but distinct image, only changes key 1.
+	next := Snapshot{ScreenName: "next", Keys: make([]image.Image, 15)}
+	next.Keys[0] = testImage(color.RGBA{R: 255, A: 255})
+	if err := surface.Refresh(next); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if sd.clearAlls != 1 || sd.writes != 3 {
+		t.Fatalf("second refresh made %d clears and %d writes, want 1 and 3", sd.clearAlls, sd.writes)
+	}
+	if sd.filledKey(1) != nil {
+		t.Fatalf("key 1 was not cleared")
+	}
+
+	if err := surface.Refresh(next); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if err := surface.UpdateKey(next, 0); err != nil {
+		t.Fatalf("UpdateKey returned error: %s", err)
+	}
+	if sd.writes != 3 {
+		t.Fatalf("unchanged refresh wrote to the device: %d writes", sd.writes)
+	}
+
+	// After a failed write the device state is unknown, so the next refresh starts from a clear device.
+	sd.remove()
+	next.Keys[2] = home
+	if err := surface.Refresh(next); err == nil {
+		t.Fatalf("Refresh of removed device did not return an error")
+	}
+	sd.removed = false
+	if err := surface.Refresh(next); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if sd.clearAlls != 2 || sd.filledKey(0) == nil || sd.filledKey(2) == nil {
+		t.Fatalf("refresh after error made %d clears, keys 0 and 2 = %v, %v", sd.clearAlls, sd.filledKey(0), sd.filledKey(2))
+	}
+}
+
+func TestStreamDeckSurfaceReadsSerialOnce(t *testing.T) {
+	sd := newFakeStreamDeck("deck")
+	deck := NewDeck(&fakeScreen{name: "home"})
+	surface := NewStreamDeckSurface(sd)
+	deck.RegisterSurface(surface)
+	deck.RefreshScreen()
+
+	sd.remove()
+	deck.RefreshScreen()
+	if surface.ID() != "deck" {
+		t.Fatalf("id after removal = %q, want deck", surface.ID())
+	}
+	if sd.serials != 1 {
+		t.Fatalf("serial read %d times, want once", sd.serials)
+	}
+}
@@ -0,0 +1,351 @@
+package deskpad
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rmrobinson/deskpad/logging"
+	xdraw "golang.org/x/image/draw"
+)
+
+var terminalLogger = logging.Logger("terminal")
+
+// TerminalMode indicates how key images are drawn to the terminal.
+type TerminalMode int
+
+const (
+	// TerminalModeBlocks draws keys using truecolor half-block characters, which works in most terminals.
+	TerminalModeBlocks TerminalMode = iota
+	// TerminalModeKitty draws keys using the Kitty graphics protocol.
+	TerminalModeKitty
+	// TerminalModeSixel draws keys using Sixel graphics.
+	TerminalModeSixel
+)
+
+const (
+	terminalKeyColumns = 12
+	terminalKeyRows    = 6
+	terminalKeyPixels  = 72
+)
+
+// terminalKeyLabels contains the keyboard key used to press each deck key, in key order.
+// The shifted variant of each label triggers a long press.
+const (
+	terminalKeyLabels        = "123456789abcdefghijklmnopqrstuvwxyz"
+	terminalShiftedKeyLabels = "!@#$%^&*(ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// KeyPresser handles key presses from a control surface. The Deck implements this, as can clients of remote decks.
+type KeyPresser interface {
+	PressKey(ctx context.Context, keyID int, t KeyPressType) error
+}
+
+// TerminalSurface renders state to a terminal and forwards keyboard input as key presses.
+type TerminalSurface struct {
+	out  io.Writer
+	in   io.Reader
+	mode TerminalMode
+
+	lock     sync.Mutex
+	snapshot Snapshot
+	// drawn holds the hash of each key currently on the terminal, or is nil if the terminal needs a full redraw.
+	drawn       []string
+	drawnScreen string
+}
+
+// NewTerminalSurface creates a surface which draws to out and reads key presses from in.
+func NewTerminalSurface(out io.Writer, in io.Reader, mode TerminalMode) *TerminalSurface {
+	return &TerminalSurface{
+		out:  out,
+		in:   in,
+		mode: mode,
+	}
+}
+
+// DetectTerminalMode picks the best supported graphics mode based on the terminal environment.
+func DetectTerminalMode() TerminalMode {
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || os.Getenv("TERM_PROGRAM") == "ghostty":
+		return TerminalModeKitty
+	case strings.Contains(term, "sixel") || os.Getenv("TERM_PROGRAM") == "WezTerm" || term == "foot" || term == "mlterm":
+		return TerminalModeSixel
+	default:
+		return TerminalModeBlocks
+	}
+}
+
+func (s *TerminalSurface) ID() string {
+	return "terminal"
+}
+
+func (s *TerminalSurface) KeyCount() int {
+	return 0
+}
+
+func (s *TerminalSurface) RenderSpec() RenderSpec {
+	return RenderSpec{KeyWidth: terminalKeyPixels, KeyHeight: terminalKeyPixels}
+}
+
+func (s *TerminalSurface) Refresh(snapshot Snapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.snapshot = cloneSnapshot(snapshot)
+	return s.drawLocked()
+}
+
+func (s *TerminalSurface) UpdateKey(snapshot Snapshot, keyID int) error {
+	return s.Refresh(snapshot)
+}
+
+func (s *TerminalSurface) Clear() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.drawn = nil
+	_, err := io.WriteString(s.out, "\x1b[0m\x1b[H\x1b[2J")
+	return err
+}
+
+// Run reads keyboard input and forwards key presses until the context is cancelled or the input is closed.
+// Keys 1-9 then a-z press the deck keys in order; holding shift makes it a long press. Ctrl-C or Ctrl-D exits.
+// If the input is a terminal, it is placed into raw mode for the duration of the call.
+func (s *TerminalSurface) Run(ctx context.Context, kp KeyPresser) {
+	if f, ok := s.in.(*os.File); ok {
+		restore, err := makeTerminalRaw(int(f.Fd()))
+		if err != nil {
+			terminalLogger.Warn("unable to put terminal into raw mode", "err", err)
+		} else {
+			defer restore()
+		}
+	}
+
+	input := make(chan byte)
+	go func() {
+		defer close(input)
+
+		r := bufio.NewReader(s.in)
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+
+			select {
+			case input <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case b, ok := <-input:
+			if !ok || b == 0x03 || b == 0x04 {
+				return
+			}
+
+			keyID, t, ok := terminalKeyPress(b)
+			if !ok {
+				continue
+			}
+
+			if err := kp.PressKey(ctx, keyID, t); err != nil {
+				terminalLogger.Error("unable to press key", "surface", s.ID(), "key", keyID, "err", err)
+			}
+		}
+	}
+}
+
+func terminalKeyPress(b byte) (int, KeyPressType, bool) {
+	if idx := strings.IndexByte(terminalKeyLabels, b); idx >= 0 {
+		return idx, KeyPressShort, true
+	}
+	if idx := strings.IndexByte(terminalShiftedKeyLabels, b); idx >= 0 {
+		return idx, KeyPressLong, true
+	}
+
+	return 0, KeyPressShort, false
+}
+
+// drawLocked draws the snapshot. If the screen and layout are unchanged only keys whose image differs are redrawn,
+// avoiding flicker; otherwise the terminal is cleared and everything drawn again.
+func (s *TerminalSurface) drawLocked() error {
+	rows, columns := s.snapshot.Rows, s.snapshot.Columns
+	if rows <= 0 || columns <= 0 {
+		rows, columns = deckGeometry(len(s.snapshot.Keys))
+	}
+
+	hashes := make([]string, rows*columns)
+	for keyID := range hashes {
+		if keyID < len(s.snapshot.Keys) {
+			hashes[keyID] = ImageHash(s.snapshot.Keys[keyID])
+		}
+	}
+	full := len(s.drawn) != len(hashes) || s.drawnScreen != s.snapshot.ScreenName
+
+	var buf bytes.Buffer
+	if full {
+		buf.WriteString("\x1b[0m\x1b[H\x1b[2J")
+		fmt.Fprintf(&buf, "\x1b[1m%s\x1b[0m\r\n", s.snapshot.ScreenName)
+	}
+
+	// Each deck row takes the key height plus a label line.
+	for row := 0; row < rows; row++ {
+		top := 2 + row*(terminalKeyRows+1)
+
+		for column := 0; column < columns; column++ {
+			keyID := row*columns + column
+			left := 1 + column*(terminalKeyColumns+1)
+
+			if !full && s.drawn[keyID] == hashes[keyID] {
+				continue
+			}
+
+			var keyImg image.Image
+			if keyID < len(s.snapshot.Keys) {
+				keyImg = s.snapshot.Keys[keyID]
+			}
+
+			switch s.mode {
+			case TerminalModeKitty:
+				writeKittyKey(&buf, top, left, keyImg)
+			case TerminalModeSixel:
+				writeSixelKey(&buf, top, left, keyImg)
+			default:
+				writeBlockKey(&buf, top, left, keyImg)
+			}
+
+			if full && keyID < len(terminalKeyLabels) {
+				fmt.Fprintf(&buf, "\x1b[%d;%dH\x1b[2m%c\x1b[0m", top+terminalKeyRows, left, terminalKeyLabels[keyID])
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "\x1b[%d;1H", 2+rows*(terminalKeyRows+1))
+
+	s.drawn = nil
+	if _, err := s.out.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.drawn = hashes
+	s.drawnScreen = s.snapshot.ScreenName
+	return nil
+}
+
+// scaleKey scales the key image to the specified size, drawing a black key if no image is set.
+func scaleKey(keyImg image.Image, width, height int) *image.RGBA {
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(scaled, scaled.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	if keyImg != nil {
+		xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), keyImg, keyImg.Bounds(), draw.Over, nil)
+	}
+	return scaled
+}
+
+// writeBlockKey draws a key using the upper half block character, with the foreground colour used for
+// the top pixel and the background colour used for the bottom pixel of each character cell.
+func writeBlockKey(buf *bytes.Buffer, top, left int, keyImg image.Image) {
+	scaled := scaleKey(keyImg, terminalKeyColumns, terminalKeyRows*2)
+
+	for row := 0; row < terminalKeyRows; row++ {
+		fmt.Fprintf(buf, "\x1b[%d;%dH", top+row, left)
+		for column := 0; column < terminalKeyColumns; column++ {
+			upper := scaled.RGBAAt(column, row*2)
+			lower := scaled.RGBAAt(column, row*2+1)
+			fmt.Fprintf(buf, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", upper.R, upper.G, upper.B, lower.R, lower.G, lower.B)
+		}
+		buf.WriteString("\x1b[0m")
+	}
+}
+
+// writeKittyKey draws a key as a PNG using the Kitty graphics protocol, scaled by the terminal to the key cell size.
+func writeKittyKey(buf *bytes.Buffer, top, left int, keyImg image.Image) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, scaleKey(keyImg, terminalKeyPixels, terminalKeyPixels)); err != nil {
+		terminalLogger.Error("unable to encode key", "err", err)
+		return
+	}
+	payload := base64.StdEncoding.EncodeToString(encoded.Bytes())
+
+	fmt.Fprintf(buf, "\x1b[%d;%dH", top, left)
+
+	// The protocol limits each escape sequence to 4096 bytes of payload.
+	const chunkSize = 4096
+	for offset := 0; offset < len(payload); offset += chunkSize {
+		end := offset + chunkSize
+		more := 1
+		if end >= len(payload) {
+			end = len(payload)
+			more = 0
+		}
+
+		if offset == 0 {
+			fmt.Fprintf(buf, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", terminalKeyColumns, terminalKeyRows, more, payload[offset:end])
+		} else {
+			fmt.Fprintf(buf, "\x1b_Gm=%d;%s\x1b\\", more, payload[offset:end])
+		}
+	}
+}
+
+// writeSixelKey draws a key using Sixel graphics. Colours are quantized to a 6x6x6 colour cube.
+func writeSixelKey(buf *bytes.Buffer, top, left int, keyImg image.Image) {
+	scaled := scaleKey(keyImg, terminalKeyPixels, terminalKeyPixels)
+	bounds := scaled.Bounds()
+
+	fmt.Fprintf(buf, "\x1b[%d;%dH", top, left)
+	buf.WriteString("\x1bPq")
+	fmt.Fprintf(buf, "\"1;1;%d;%d", bounds.Dx(), bounds.Dy())
+	for idx := 0; idx < 216; idx++ {
+		r, g, b := idx/36, (idx/6)%6, idx%6
+		fmt.Fprintf(buf, "#%d;2;%d;%d;%d", idx, r*20, g*20, b*20)
+	}
+
+	sixelColor := func(c color.RGBA) int {
+		return int(c.R)*5/255*36 + int(c.G)*5/255*6 + int(c.B)*5/255
+	}
+
+	for bandTop := 0; bandTop < bounds.Dy(); bandTop += 6 {
+		// Gather which rows of the band each colour occupies, so each colour is drawn in a single pass.
+		bands := map[int][]byte{}
+		var order []int
+		for x := 0; x < bounds.Dx(); x++ {
+			for bit := 0; bit < 6 && bandTop+bit < bounds.Dy(); bit++ {
+				c := sixelColor(scaled.RGBAAt(x, bandTop+bit))
+				if _, ok := bands[c]; !ok {
+					bands[c] = make([]byte, bounds.Dx())
+					order = append(order, c)
+				}
+				bands[c][x] |= 1 << bit
+			}
+		}
+
+		for i, c := range order {
+			if i > 0 {
+				buf.WriteByte('$')
+			}
+			fmt.Fprintf(buf, "#%d", c)
+			for _, bits := range bands[c] {
+				buf.WriteByte(63 + bits)
+			}
+		}
+		buf.WriteByte('-')
+	}
+
+	buf.WriteString("\x1b\\")
+}
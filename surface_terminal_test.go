@@ -0,0 +1,104 @@
+package deskpad
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+type fakeKeyPresser struct {
+	keys  []int
+	types []KeyPressType
+}
+
+func (kp *fakeKeyPresser) PressKey(ctx context.Context, keyID int, t KeyPressType) error {
+	kp.keys = append(kp.keys, keyID)
+	kp.types = append(kp.types, t)
+	return nil
+}
+
+func TestTerminalSurfaceRunMapsKeyboardToKeyPresses(t *testing.T) {
+	kp := &fakeKeyPresser{}
+	surface := NewTerminalSurface(&bytes.Buffer{}, strings.NewReader("1aF!x\x1b\x04b"), TerminalModeBlocks)
+
+	surface.Run(context.Background(), kp)
+
+	wantKeys := []int{0, 9, 14, 0, 32}
+	wantTypes := []KeyPressType{KeyPressShort, KeyPressShort, KeyPressLong, KeyPressLong, KeyPressShort}
+	if len(kp.keys) != len(wantKeys) {
+		t.Fatalf("pressed keys = %v, want %v", kp.keys, wantKeys)
+	}
+	for i := range wantKeys {
+		if kp.keys[i] != wantKeys[i] || kp.types[i] != wantTypes[i] {
+			t.Fatalf("press %d = key %d type %d, want key %d type %d", i, kp.keys[i], kp.types[i], wantKeys[i], wantTypes[i])
+		}
+	}
+}
+
+func TestTerminalSurfaceRefreshDrawsKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mode TerminalMode
+		want string
+	}{
+		{name: "blocks", mode: TerminalModeBlocks, want: "\x1b[38;2;255;0;0m"},
+		{name: "kitty", mode: TerminalModeKitty, want: "\x1b_Ga=T,f=100"},
+		{name: "sixel", mode: TerminalModeSixel, want: "\x1bPq"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			surface := NewTerminalSurface(&out, strings.NewReader(""), tc.mode)
+
+			snapshot := Snapshot{ScreenName: "home", Rows: 3, Columns: 5, Keys: make([]image.Image, 15)}
+			snapshot.Keys[0] = testImage(color.RGBA{R: 255, A: 255})
+			if err := surface.Refresh(snapshot); err != nil {
+				t.Fatalf("Refresh returned error: %s", err)
+			}
+
+			if !strings.Contains(out.String(), "home") {
+				t.Fatalf("output did not include screen name")
+			}
+			if !strings.Contains(out.String(), tc.want) {
+				t.Fatalf("output did not include %q", tc.want)
+			}
+		})
+	}
+}
+
+func TestTerminalSurfaceRedrawsOnlyChangedKeys(t *testing.T) {
+	var out bytes.Buffer
+	surface := NewTerminalSurface(&out, strings.NewReader(""), TerminalModeKitty)
+
+	snapshot := Snapshot{ScreenName: "home", Rows: 3, Columns: 5, Keys: make([]image.Image, 15)}
+	snapshot.Keys[0] = testImage(color.RGBA{R: 255, A: 255})
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if got := strings.Count(out.String(), "\x1b_Ga=T"); got != 15 {
+		t.Fatalf("full draw drew %d keys, want 15", got)
+	}
+
+	out.Reset()
+	snapshot.Keys[1] = testImage(color.RGBA{G: 255, A: 255})
+	if err := surface.UpdateKey(snapshot, 1); err != nil {
+		t.Fatalf("UpdateKey returned error: %s", err)
+	}
+	if strings.Contains(out.String(), "\x1b[2J") {
+		t.Fatalf("key update cleared the terminal")
+	}
+	if got := strings.Count(out.String(), "\x1b_Ga=T"); got != 1 {
+		t.Fatalf("key update drew %d keys, want 1", got)
+	}
+
+	out.Reset()
+	snapshot.ScreenName = "next"
+	if err := surface.Refresh(snapshot); err != nil {
+		t.Fatalf("Refresh returned error: %s", err)
+	}
+	if !strings.Contains(out.String(), "\x1b[2J") || !strings.Contains(out.String(), "next") {
+		t.Fatalf("screen change did not redraw the terminal")
+	}
+}
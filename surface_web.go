@@ -4,6 +4,8 @@ import "sync"
 
 // WebSurface stores rendered state for HTTP clients and broadcasts updates.
 type WebSurface struct {
+	dials int
+
 	lock        sync.RWMutex
 	snapshot    Snapshot
 	subscribers map[chan Snapshot]struct{}
@@ -15,6 +17,17 @@ func NewWebSurface() *WebSurface {
 	}
 }
 
+// SetVirtualDials configures the number of dials shown by web clients, allowing screens which use dials
+// to be driven without hardware. It must be called before the surface is registered with a deck.
+func (s *WebSurface) SetVirtualDials(count int) {
+	s.dials = count
+}
+
+// DialCount returns the number of virtual dials.
+func (s *WebSurface) DialCount() int {
+	return s.dials
+}
+
 func (s *WebSurface) ID() string {
 	return "web"
 }
@@ -41,6 +54,7 @@ func (s *WebSurface) Clear() error {
 	defer s.lock.Unlock()
 
 	s.snapshot.Keys = nil
+	s.snapshot.Strip = nil
 	s.broadcastLocked()
 	return nil
 }
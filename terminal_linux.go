@@ -0,0 +1,27 @@
+//go:build linux
+
+package deskpad
+
+import "golang.org/x/sys/unix"
+
+// makeTerminalRaw disables line buffering and echo on the terminal so single key presses can be read.
+// The returned function restores the previous terminal settings.
+func makeTerminalRaw(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *termios
+	termios.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Iflag &^= unix.IXON | unix.ICRNL
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		unix.IoctlSetTermios(fd, unix.TCSETS, &previous)
+	}, nil
+}
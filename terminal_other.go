@@ -0,0 +1,9 @@
+//go:build !linux
+
+package deskpad
+
+import "errors"
+
+func makeTerminalRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is only supported on linux")
+}
@@ -3,15 +3,19 @@ package controllers
 import (
 	"context"
 	"errors"
-	"log"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/muka/go-bluetooth/bluez/profile/adapter"
 	"github.com/muka/go-bluetooth/bluez/profile/agent"
 	"github.com/muka/go-bluetooth/bluez/profile/device"
+	"github.com/rmrobinson/deskpad/logging"
 )
 
+var bluetoothLogger = logging.Logger("bluetooth")
+
 const (
 	bluetoothRefreshTimeout = time.Second * 5
 )
@@ -37,6 +41,7 @@ type BluetoothSetting struct {
 	adapter   *adapter.Adapter1
 	adapterID string
 
+	lock    sync.RWMutex
 	devices []BluetoothDevice
 }
 
@@ -51,7 +56,44 @@ func NewBluetoothSetting(adapter *adapter.Adapter1, adapterID string) *Bluetooth
 
 // GetDevices returns the list of available Bluetooth devices. This list is cached.
 func (bs *BluetoothSetting) GetDevices() []BluetoothDevice {
-	return bs.devices
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+
+	return slices.Clone(bs.devices)
+}
+
+// ConnectedDevices returns the devices known to the adapter which are currently connected. Unlike GetDevices the
+// connection state is read from BlueZ on each call, so it also reflects devices connected or disconnected elsewhere.
+func (bs *BluetoothSetting) ConnectedDevices() ([]BluetoothDevice, error) {
+	devices, err := bs.adapter.GetDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	var connected []BluetoothDevice
+	for _, d := range devices {
+		if d == nil || d.Properties == nil || !d.Properties.Connected {
+			continue
+		}
+
+		connected = append(connected, BluetoothDevice{
+			Address:  d.Properties.Address,
+			Name:     d.Properties.Name,
+			btDevice: d,
+		})
+	}
+	return connected, nil
+}
+
+func (bs *BluetoothSetting) addDevice(d *device.Device1) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	bs.devices = append(bs.devices, BluetoothDevice{
+		Address:  d.Properties.Address,
+		Name:     d.Properties.Name,
+		btDevice: d,
+	})
 }
 
 // ConnectDevice requests that the specified Bluetooth device be connected.
@@ -69,7 +111,7 @@ func (bs *BluetoothSetting) ConnectDevice(dev BluetoothDevice) error {
 	if !dev.btDevice.Properties.Paired || !dev.btDevice.Properties.Trusted {
 		err := dev.btDevice.Pair()
 		if err != nil {
-			log.Printf("error pairing %s: %s\n", dev.Address, err.Error())
+			bluetoothLogger.Error("error pairing", "device", dev.Address, "err", err)
 			return err
 		}
 
@@ -78,7 +120,7 @@ func (bs *BluetoothSetting) ConnectDevice(dev BluetoothDevice) error {
 
 	err := dev.btDevice.Connect()
 	if err != nil {
-		log.Printf("error connecting to %s: %s\n", dev.Address, err.Error())
+		bluetoothLogger.Error("error connecting", "device", dev.Address, "err", err)
 		return err
 	}
 
@@ -88,11 +130,13 @@ func (bs *BluetoothSetting) ConnectDevice(dev BluetoothDevice) error {
 // RefreshDevices refreshes the list of Bluetooth devices. This will run at most 5 seconds
 func (bs *BluetoothSetting) RefreshDevices(ctx context.Context) error {
 	// Reset the device list
+	bs.lock.Lock()
 	bs.devices = nil
+	bs.lock.Unlock()
 
 	devices, err := bs.adapter.GetDevices()
 	if err != nil {
-		log.Printf("error getting list of devices from adapter: %s\n", err.Error())
+		bluetoothLogger.Error("error getting list of devices from adapter", "err", err)
 		return err
 	}
 
@@ -101,21 +145,17 @@ func (bs *BluetoothSetting) RefreshDevices(ctx context.Context) error {
 			continue
 		}
 
-		bs.devices = append(bs.devices, BluetoothDevice{
-			Address:  d.Properties.Address,
-			Name:     d.Properties.Name,
-			btDevice: d,
-		})
+		bs.addDevice(d)
 	}
 
 	discovery, discoverCancel, err := bs.startDiscovery()
 	if err != nil {
 		if isBluetoothDiscoveryInProgress(err) {
-			log.Printf("bluetooth discovery already in progress; using known devices only\n")
+			bluetoothLogger.Info("bluetooth discovery already in progress; using known devices only")
 			return nil
 		}
 
-		log.Printf("error starting to discover bluetooth devices: %s\n", err.Error())
+		bluetoothLogger.Error("error starting to discover bluetooth devices", "err", err)
 		return err
 	}
 	defer discoverCancel()
@@ -128,7 +168,7 @@ func (bs *BluetoothSetting) RefreshDevices(ctx context.Context) error {
 		case discoveredDevice := <-discovery:
 			d, err := device.NewDevice1(discoveredDevice.Path)
 			if err != nil {
-				log.Printf("error creating device from discovered path %s\n", err.Error())
+				bluetoothLogger.Error("error creating device from discovered path", "err", err)
 				return err
 			}
 
@@ -136,11 +176,7 @@ func (bs *BluetoothSetting) RefreshDevices(ctx context.Context) error {
 				continue
 			}
 
-			bs.devices = append(bs.devices, BluetoothDevice{
-				Address:  d.Properties.Address,
-				Name:     d.Properties.Name,
-				btDevice: d,
-			})
+			bs.addDevice(d)
 
 		case <-refreshCtx.Done():
 			return nil
@@ -173,7 +209,7 @@ func (bs *BluetoothSetting) startDiscovery() (<-chan *adapter.DeviceDiscovered,
 
 	cancel := func() {
 		if err := bs.adapter.StopDiscovery(); err != nil && !isBluetoothDiscoveryInProgress(err) {
-			log.Printf("error stopping bluetooth discovery: %s\n", err.Error())
+			bluetoothLogger.Error("error stopping bluetooth discovery", "err", err)
 		}
 		discoveryCancel()
 	}
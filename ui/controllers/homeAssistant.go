@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrobinson/deskpad/logging"
+	"golang.org/x/net/websocket"
+)
+
+var homeAssistantLogger = logging.Logger("homeassistant")
+
+const homeAssistantRequestTimeout = 10 * time.Second
+
+// HomeAssistantEntityConfig is an entity shown on the deck. If Name is empty the entity's friendly name is used.
+type HomeAssistantEntityConfig struct {
+	ID   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+}
+
+// HomeAssistantEntity is the latest known state of a configured entity.
+type HomeAssistantEntity struct {
+	ID    string
+	Name  string
+	State string
+	// Brightness is between 0 and 255; it is only set for lights which are on.
+	Brightness int
+	// RGB is the current colour of a light, or nil if it isn't known.
+	RGB []int
+
+	SupportsBrightness bool
+	SupportsColor      bool
+}
+
+// Domain returns the entity's domain, such as light or scene.
+func (e HomeAssistantEntity) Domain() string {
+	domain, _, _ := strings.Cut(e.ID, ".")
+	return domain
+}
+
+// On returns whether the entity is switched on.
+func (e HomeAssistantEntity) On() bool {
+	return e.State == "on"
+}
+
+// Available returns whether Home Assistant can currently reach the entity.
+func (e HomeAssistantEntity) Available() bool {
+	return e.State != "unavailable" && e.State != "unknown" && len(e.State) > 0
+}
+
+// BrightnessPct returns the brightness of a light as a percentage.
+func (e HomeAssistantEntity) BrightnessPct() int {
+	return (e.Brightness*100 + 127) / 255
+}
+
+// homeAssistantState is a state object as returned by the Home Assistant APIs.
+type homeAssistantState struct {
+	EntityID   string `json:"entity_id"`
+	State      string `json:"state"`
+	Attributes struct {
+		FriendlyName        string   `json:"friendly_name"`
+		Brightness          *int     `json:"brightness"`
+		RGBColor            []int    `json:"rgb_color"`
+		SupportedColorModes []string `json:"supported_color_modes"`
+	} `json:"attributes"`
+}
+
+// homeAssistantMessage is a message sent over the Home Assistant WebSocket API.
+type homeAssistantMessage struct {
+	ID          int    `json:"id,omitempty"`
+	Type        string `json:"type"`
+	AccessToken string `json:"access_token,omitempty"`
+	EventType   string `json:"event_type,omitempty"`
+	Success     *bool  `json:"success,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Event       *struct {
+		EventType string `json:"event_type"`
+		Data      struct {
+			EntityID string              `json:"entity_id"`
+			NewState *homeAssistantState `json:"new_state"`
+		} `json:"data"`
+	} `json:"event,omitempty"`
+}
+
+// HomeAssistant controls entities through the Home Assistant REST API and follows their state over the
+// WebSocket API.
+type HomeAssistant struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	configs []HomeAssistantEntityConfig
+
+	mu          sync.RWMutex
+	entities    map[string]HomeAssistantEntity
+	subscribers map[chan HomeAssistantEntity]struct{}
+	connected   bool
+	streamErr   error
+}
+
+// NewHomeAssistant creates a controller for the supplied entities on the Home Assistant instance at baseURL,
+// authenticating with a long-lived access token.
+func NewHomeAssistant(baseURL string, token string, entities []HomeAssistantEntityConfig) *HomeAssistant {
+	ha := &HomeAssistant{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		token:       token,
+		client:      &http.Client{Timeout: homeAssistantRequestTimeout},
+		configs:     entities,
+		entities:    map[string]HomeAssistantEntity{},
+		subscribers: map[chan HomeAssistantEntity]struct{}{},
+	}
+
+	for _, cfg := range entities {
+		name := cfg.Name
+		if len(name) < 1 {
+			name = cfg.ID
+		}
+		ha.entities[cfg.ID] = HomeAssistantEntity{ID: cfg.ID, Name: name}
+	}
+	return ha
+}
+
+// Entities returns the configured entities, in the order they were configured.
+func (ha *HomeAssistant) Entities() []HomeAssistantEntity {
+	ha.mu.RLock()
+	defer ha.mu.RUnlock()
+
+	entities := make([]HomeAssistantEntity, 0, len(ha.configs))
+	for _, cfg := range ha.configs {
+		entities = append(entities, ha.entities[cfg.ID])
+	}
+	return entities
+}
+
+// Entity returns the latest state of a configured entity.
+func (ha *HomeAssistant) Entity(id string) (HomeAssistantEntity, bool) {
+	ha.mu.RLock()
+	defer ha.mu.RUnlock()
+
+	entity, ok := ha.entities[id]
+	return entity, ok
+}
+
+// Subscribe returns a channel which receives each configured entity when its state changes. Slow subscribers
+// miss changes rather than blocking the stream.
+func (ha *HomeAssistant) Subscribe() (<-chan HomeAssistantEntity, func()) {
+	ch := make(chan HomeAssistantEntity, 16)
+
+	ha.mu.Lock()
+	ha.subscribers[ch] = struct{}{}
+	ha.mu.Unlock()
+
+	cancel := func() {
+		ha.mu.Lock()
+		defer ha.mu.Unlock()
+
+		if _, ok := ha.subscribers[ch]; ok {
+			delete(ha.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, cancel
+}
+
+// StreamStatus returns nil while state changes are being received, otherwise the reason they aren't.
+func (ha *HomeAssistant) StreamStatus() error {
+	ha.mu.RLock()
+	defer ha.mu.RUnlock()
+
+	if ha.connected {
+		return nil
+	}
+	if ha.streamErr != nil {
+		return ha.streamErr
+	}
+	return errors.New("not connected")
+}
+
+func (ha *HomeAssistant) setStreamState(connected bool, err error) {
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
+
+	ha.connected = connected
+	ha.streamErr = err
+}
+
+// setState records the new state of an entity, ignoring entities which aren't configured.
+func (ha *HomeAssistant) setState(state homeAssistantState) {
+	ha.mu.Lock()
+	defer ha.mu.Unlock()
+
+	if _, ok := ha.entities[state.EntityID]; !ok {
+		return
+	}
+
+	// Names from the config take priority over the friendly name.
+	name := state.Attributes.FriendlyName
+	for _, cfg := range ha.configs {
+		if cfg.ID == state.EntityID && len(cfg.Name) > 0 {
+			name = cfg.Name
+		}
+	}
+	if len(name) < 1 {
+		name = state.EntityID
+	}
+
+	entity := HomeAssistantEntity{
+		ID:    state.EntityID,
+		Name:  name,
+		State: state.State,
+		RGB:   state.Attributes.RGBColor,
+	}
+	if state.Attributes.Brightness != nil {
+		entity.Brightness = *state.Attributes.Brightness
+	}
+	for _, mode := range state.Attributes.SupportedColorModes {
+		switch mode {
+		case "onoff":
+		case "brightness", "color_temp", "white":
+			entity.SupportsBrightness = true
+		default:
+			entity.SupportsBrightness = true
+			entity.SupportsColor = true
+		}
+	}
+
+	ha.entities[state.EntityID] = entity
+	for ch := range ha.subscribers {
+		select {
+		case ch <- entity:
+		default:
+		}
+	}
+}
+
+// Toggle switches an entity on or off. Scenes and scripts are activated instead, as they have no off state.
+func (ha *HomeAssistant) Toggle(ctx context.Context, id string) error {
+	switch domain, _, _ := strings.Cut(id, "."); domain {
+	case "scene", "script":
+		return ha.callService(ctx, domain, "turn_on", map[string]any{"entity_id": id})
+	default:
+		return ha.callService(ctx, domain, "toggle", map[string]any{"entity_id": id})
+	}
+}
+
+// SetBrightness turns a light on at the supplied brightness percentage.
+func (ha *HomeAssistant) SetBrightness(ctx context.Context, id string, pct int) error {
+	return ha.callService(ctx, "light", "turn_on", map[string]any{"entity_id": id, "brightness_pct": pct})
+}
+
+// StepBrightness changes the brightness of a light by the supplied percentage, which may be negative.
+func (ha *HomeAssistant) StepBrightness(ctx context.Context, id string, pct int) error {
+	return ha.callService(ctx, "light", "turn_on", map[string]any{"entity_id": id, "brightness_step_pct": pct})
+}
+
+// SetColor turns a light on with the supplied red, green and blue values.
+func (ha *HomeAssistant) SetColor(ctx context.Context, id string, rgb [3]int) error {
+	return ha.callService(ctx, "light", "turn_on", map[string]any{"entity_id": id, "rgb_color": rgb})
+}
+
+// callService calls a Home Assistant service. The entities changed by the call are updated straight away
+// rather than waiting for their state change events.
+func (ha *HomeAssistant) callService(ctx context.Context, domain string, service string, data map[string]any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	req, err := ha.newRequest(ctx, http.MethodPost, "/api/services/"+domain+"/"+service, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := ha.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("call %s.%s: %w", domain, service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("call %s.%s: %s", domain, service, resp.Status)
+	}
+
+	var changed []homeAssistantState
+	if err := json.NewDecoder(resp.Body).Decode(&changed); err != nil {
+		homeAssistantLogger.Warn("unable to decode changed states", "service", domain+"."+service, "err", err)
+		return nil
+	}
+	for _, state := range changed {
+		ha.setState(state)
+	}
+	return nil
+}
+
+// RefreshStates retrieves the current state of every entity.
+func (ha *HomeAssistant) RefreshStates(ctx context.Context) error {
+	req, err := ha.newRequest(ctx, http.MethodGet, "/api/states", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ha.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("get states: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get states: %s", resp.Status)
+	}
+
+	var states []homeAssistantState
+	if err := json.NewDecoder(resp.Body).Decode(&states); err != nil {
+		return fmt.Errorf("decode states: %w", err)
+	}
+	for _, state := range states {
+		ha.setState(state)
+	}
+	return nil
+}
+
+func (ha *HomeAssistant) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, ha.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+ha.token)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Run follows the entity states over the WebSocket API until ctx is cancelled, reconnecting with exponential
+// backoff on any error.
+func (ha *HomeAssistant) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		wasConnected, err := ha.runStream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		ha.setStreamState(false, err)
+		if err != nil {
+			homeAssistantLogger.Warn("stream failed; reconnecting", "err", err, "backoff", backoff)
+		}
+		if wasConnected {
+			backoff = time.Second
+		} else if backoff < maxBackoff {
+			backoff *= 2
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// runStream authenticates, subscribes to state changes and processes them until an error or context
+// cancellation. wasConnected is true if the subscription was set up before the failure.
+func (ha *HomeAssistant) runStream(ctx context.Context) (wasConnected bool, _ error) {
+	wsURL := "ws" + strings.TrimPrefix(ha.baseURL, "http") + "/api/websocket"
+	cfg, err := websocket.NewConfig(wsURL, ha.baseURL)
+	if err != nil {
+		return false, err
+	}
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("dial %s: %w", wsURL, err)
+	}
+	defer conn.Close()
+
+	// Receive doesn't take a context, so close the connection to unblock it.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var msg homeAssistantMessage
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		return false, fmt.Errorf("recv: %w", err)
+	}
+	if msg.Type != "auth_required" {
+		return false, fmt.Errorf("unexpected %q message before authentication", msg.Type)
+	}
+	if err := websocket.JSON.Send(conn, homeAssistantMessage{Type: "auth", AccessToken: ha.token}); err != nil {
+		return false, fmt.Errorf("send auth: %w", err)
+	}
+	msg = homeAssistantMessage{}
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		return false, fmt.Errorf("recv: %w", err)
+	}
+	if msg.Type != "auth_ok" {
+		return false, fmt.Errorf("authentication failed: %s", msg.Message)
+	}
+
+	const subscriptionID = 1
+	if err := websocket.JSON.Send(conn, homeAssistantMessage{ID: subscriptionID, Type: "subscribe_events", EventType: "state_changed"}); err != nil {
+		return false, fmt.Errorf("send subscribe: %w", err)
+	}
+
+	for {
+		msg = homeAssistantMessage{}
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			if ctx.Err() != nil {
+				return wasConnected, nil
+			}
+			return wasConnected, fmt.Errorf("recv: %w", err)
+		}
+		if msg.ID != subscriptionID {
+			continue
+		}
+
+		switch msg.Type {
+		case "result":
+			if msg.Success == nil || !*msg.Success {
+				return false, errors.New("subscribe to state changes failed")
+			}
+
+			// Load the current states once subscribed so no change is missed in between.
+			if err := ha.RefreshStates(ctx); err != nil {
+				return false, err
+			}
+			wasConnected = true
+			ha.setStreamState(true, nil)
+			homeAssistantLogger.Info("stream connected", "url", ha.baseURL)
+		case "event":
+			if msg.Event != nil && msg.Event.Data.NewState != nil {
+				ha.setState(*msg.Event.Data.NewState)
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const homeAssistantTestToken = "secret"
+
+// fakeHomeAssistant implements the parts of the Home Assistant REST and WebSocket APIs used by the controller.
+type fakeHomeAssistant struct {
+	*httptest.Server
+
+	lock   sync.Mutex
+	states map[string]map[string]any
+	calls  []string
+	events chan map[string]any
+}
+
+func newFakeHomeAssistant(t *testing.T) *fakeHomeAssistant {
+	t.Helper()
+
+	fake := &fakeHomeAssistant{
+		states: map[string]map[string]any{
+			"light.living_room": {
+				"entity_id": "light.living_room",
+				"state":     "on",
+				"attributes": map[string]any{
+					"friendly_name":         "Living Room",
+					"brightness":            128,
+					"rgb_color":             []int{255, 180, 107},
+					"supported_color_modes": []string{"hs", "color_temp"},
+				},
+			},
+			"switch.fan": {
+				"entity_id":  "switch.fan",
+				"state":      "off",
+				"attributes": map[string]any{"friendly_name": "Desk Fan"},
+			},
+			"sensor.outside": {
+				"entity_id":  "sensor.outside",
+				"state":      "12",
+				"attributes": map[string]any{},
+			},
+		},
+		events: make(chan map[string]any, 4),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/states", fake.handleStates)
+	mux.HandleFunc("/api/services/", fake.handleService)
+	mux.Handle("/api/websocket", websocket.Handler(fake.handleWebSocket))
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+
+	return fake
+}
+
+func (f *fakeHomeAssistant) authorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer "+homeAssistantTestToken
+}
+
+func (f *fakeHomeAssistant) handleStates(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var states []map[string]any
+	for _, state := range f.states {
+		states = append(states, state)
+	}
+	json.NewEncoder(w).Encode(states)
+}
+
+func (f *fakeHomeAssistant) handleService(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var data map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, _ := json.Marshal(data)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	service := strings.TrimPrefix(r.URL.Path, "/api/services/")
+	f.calls = append(f.calls, service+" "+string(body))
+
+	state, ok := f.states[data["entity_id"].(string)]
+	if !ok {
+		json.NewEncoder(w).Encode([]any{})
+		return
+	}
+	if strings.HasSuffix(service, "/toggle") {
+		if state["state"] == "on" {
+			state["state"] = "off"
+		} else {
+			state["state"] = "on"
+		}
+	}
+	json.NewEncoder(w).Encode([]any{state})
+}
+
+func (f *fakeHomeAssistant) handleWebSocket(conn *websocket.Conn) {
+	websocket.JSON.Send(conn, map[string]any{"type": "auth_required"})
+
+	var auth map[string]any
+	if err := websocket.JSON.Receive(conn, &auth); err != nil {
+		return
+	}
+	if auth["type"] != "auth" || auth["access_token"] != homeAssistantTestToken {
+		websocket.JSON.Send(conn, map[string]any{"type": "auth_invalid", "message": "Invalid access token"})
+		return
+	}
+	websocket.JSON.Send(conn, map[string]any{"type": "auth_ok"})
+
+	var subscribe map[string]any
+	if err := websocket.JSON.Receive(conn, &subscribe); err != nil {
+		return
+	}
+	id := subscribe["id"]
+	websocket.JSON.Send(conn, map[string]any{"id": id, "type": "result", "success": true})
+
+	for state := range f.events {
+		websocket.JSON.Send(conn, map[string]any{
+			"id":   id,
+			"type": "event",
+			"event": map[string]any{
+				"event_type": "state_changed",
+				"data":       map[string]any{"entity_id": state["entity_id"], "new_state": state},
+			},
+		})
+	}
+}
+
+func (f *fakeHomeAssistant) takeCalls() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+func waitForHomeAssistantStream(t *testing.T, ha *HomeAssistant) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ha.StreamStatus() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("stream not connected: %s", ha.StreamStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHomeAssistantFollowsStateChanges(t *testing.T) {
+	fake := newFakeHomeAssistant(t)
+	ha := NewHomeAssistant(fake.URL+"/", homeAssistantTestToken, []HomeAssistantEntityConfig{
+		{ID: "light.living_room"},
+		{ID: "switch.fan", Name: "Fan"},
+		{ID: "scene.missing"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ha.Run(ctx)
+	waitForHomeAssistantStream(t, ha)
+
+	entities := ha.Entities()
+	if len(entities) != 3 || entities[0].Name != "Living Room" || entities[1].Name != "Fan" || entities[2].Name != "scene.missing" {
+		t.Fatalf("entities = %+v, want the configured entities in order", entities)
+	}
+	light := entities[0]
+	if !light.On() || light.BrightnessPct() != 50 || !light.SupportsColor || !reflect.DeepEqual(light.RGB, []int{255, 180, 107}) {
+		t.Fatalf("light = %+v, want it on at 50%% with colour", light)
+	}
+	if entities[1].On() || entities[1].SupportsBrightness || entities[2].Available() {
+		t.Fatalf("entities = %+v, want the fan off and the scene unavailable", entities)
+	}
+
+	changes, stop := ha.Subscribe()
+	defer stop()
+
+	fake.events <- map[string]any{"entity_id": "sensor.outside", "state": "13"}
+	fake.events <- map[string]any{"entity_id": "switch.fan", "state": "on", "attributes": map[string]any{"friendly_name": "Desk Fan"}}
+	select {
+	case entity := <-changes:
+		if entity.ID != "switch.fan" || !entity.On() || entity.Name != "Fan" {
+			t.Fatalf("change = %+v, want the fan switched on", entity)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for the state change")
+	}
+	if _, ok := ha.Entity("sensor.outside"); ok {
+		t.Fatalf("unconfigured entity was recorded")
+	}
+}
+
+func TestHomeAssistantCallsServices(t *testing.T) {
+	fake := newFakeHomeAssistant(t)
+	ha := NewHomeAssistant(fake.URL, homeAssistantTestToken, []HomeAssistantEntityConfig{
+		{ID: "light.living_room"},
+		{ID: "scene.movie_night"},
+	})
+	ctx := context.Background()
+
+	if err := ha.Toggle(ctx, "light.living_room"); err != nil {
+		t.Fatalf("Toggle returned error: %s", err)
+	}
+	if light, _ := ha.Entity("light.living_room"); light.On() || light.Name != "Living Room" {
+		t.Fatalf("light = %+v, want it updated from the service response", light)
+	}
+
+	ha.Toggle(ctx, "scene.movie_night")
+	ha.SetBrightness(ctx, "light.living_room", 25)
+	ha.StepBrightness(ctx, "light.living_room", -10)
+	ha.SetColor(ctx, "light.living_room", [3]int{255, 0, 0})
+
+	want := []string{
+		`light/toggle {"entity_id":"light.living_room"}`,
+		`scene/turn_on {"entity_id":"scene.movie_night"}`,
+		`light/turn_on {"brightness_pct":25,"entity_id":"light.living_room"}`,
+		`light/turn_on {"brightness_step_pct":-10,"entity_id":"light.living_room"}`,
+		`light/turn_on {"entity_id":"light.living_room","rgb_color":[255,0,0]}`,
+	}
+	if calls := fake.takeCalls(); !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+
+	bad := NewHomeAssistant(fake.URL, "wrong", nil)
+	if err := bad.Toggle(ctx, "light.living_room"); err == nil {
+		t.Fatalf("Toggle succeeded with an invalid token")
+	}
+}
+
+func TestHomeAssistantStreamRejectsInvalidToken(t *testing.T) {
+	fake := newFakeHomeAssistant(t)
+	ha := NewHomeAssistant(fake.URL, "wrong", nil)
+
+	wasConnected, err := ha.runStream(context.Background())
+	if wasConnected || err == nil || !strings.Contains(err.Error(), "Invalid access token") {
+		t.Fatalf("runStream = %t, %v; want an authentication error", wasConnected, err)
+	}
+}
@@ -0,0 +1,448 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+var hueLogger = logging.Logger("hue")
+
+const (
+	hueRequestTimeout = 10 * time.Second
+	hueDeviceType     = "deskpad#deskpadd"
+	// hueLinkButtonNotPressed is the error type returned by the bridge while waiting for its link button.
+	hueLinkButtonNotPressed = 101
+)
+
+var (
+	// ErrHueNotPaired is returned when the bridge is used before deskpad has been paired with it.
+	ErrHueNotPaired = errors.New("not paired with the hue bridge")
+	// ErrHueLinkButtonNotPressed is returned by Pair if the bridge's link button wasn't pressed first.
+	ErrHueLinkButtonNotPressed = errors.New("hue bridge link button not pressed")
+	// ErrLightNotFound is returned when the requested light isn't in the list of available lights.
+	ErrLightNotFound = errors.New("light not found")
+)
+
+// hueState is the state of a light, or the last action sent to a room.
+type hueState struct {
+	On        bool  `json:"on"`
+	Bri       *int  `json:"bri"`
+	Hue       *int  `json:"hue"`
+	Sat       *int  `json:"sat"`
+	Reachable *bool `json:"reachable"`
+}
+
+type hueLight struct {
+	Name  string   `json:"name"`
+	State hueState `json:"state"`
+}
+
+type hueGroup struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Action hueState `json:"action"`
+	State  struct {
+		AnyOn bool `json:"any_on"`
+	} `json:"state"`
+}
+
+// hueResult is a single entry in the list of results returned by the bridge for a write, or in place of a
+// resource if reading it failed.
+type hueResult struct {
+	Success map[string]any `json:"success"`
+	Error   *struct {
+		Type        int    `json:"type"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// Hue is a controller which talks to a Philips Hue bridge over its local REST API. Lights are identified by their
+// resource path on the bridge: lights/{id} for a single light and groups/{id} for a room.
+type Hue struct {
+	baseURL      string
+	client       *http.Client
+	usernamePath string
+
+	lock         sync.RWMutex
+	username     string
+	cachedLights []ui.Light
+}
+
+// NewHue creates a controller for the bridge at addr. If username is empty, the username saved to usernamePath
+// by an earlier pairing is used, if there is one.
+func NewHue(addr string, username string, usernamePath string) *Hue {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	if len(username) < 1 && len(usernamePath) > 0 {
+		if data, err := os.ReadFile(usernamePath); err == nil {
+			username = strings.TrimSpace(string(data))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			hueLogger.Error("unable to read hue username", "path", usernamePath, "err", err)
+		}
+	}
+
+	return &Hue{
+		baseURL:      strings.TrimSuffix(addr, "/"),
+		client:       &http.Client{Timeout: hueRequestTimeout},
+		usernamePath: usernamePath,
+		username:     username,
+	}
+}
+
+// Paired returns whether deskpad has a username for the bridge.
+func (h *Hue) Paired() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return len(h.username) > 0
+}
+
+// Pair registers deskpad with the bridge. The bridge's link button must be pressed shortly beforehand. The
+// username issued by the bridge is saved so pairing is only needed once.
+func (h *Hue) Pair(ctx context.Context) error {
+	results, err := h.write(ctx, http.MethodPost, "/api", map[string]any{"devicetype": hueDeviceType})
+	if err != nil {
+		return err
+	}
+	if len(results) < 1 {
+		return errors.New("empty response from hue bridge")
+	}
+	if results[0].Error != nil {
+		if results[0].Error.Type == hueLinkButtonNotPressed {
+			return ErrHueLinkButtonNotPressed
+		}
+		return fmt.Errorf("pair with hue bridge: %s", results[0].Error.Description)
+	}
+
+	username, _ := results[0].Success["username"].(string)
+	if len(username) < 1 {
+		return errors.New("no username returned by hue bridge")
+	}
+
+	h.lock.Lock()
+	h.username = username
+	h.lock.Unlock()
+
+	hueLogger.Info("paired with hue bridge", "bridge", h.baseURL)
+	if len(h.usernamePath) > 0 {
+		if err := os.WriteFile(h.usernamePath, []byte(username+"\n"), 0o600); err != nil {
+			hueLogger.Error("unable to save hue username", "path", h.usernamePath, "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLights returns the rooms, followed by the individual lights.
+func (h *Hue) GetLights() []ui.Light {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return append([]ui.Light(nil), h.cachedLights...)
+}
+
+// RefreshLights retrieves an up-to-date list of rooms and lights and their state.
+func (h *Hue) RefreshLights(ctx context.Context) error {
+	username, err := h.pairedUsername()
+	if err != nil {
+		return err
+	}
+
+	var groups map[string]hueGroup
+	if err := h.get(ctx, "/api/"+username+"/groups", &groups); err != nil {
+		hueLogger.Error("unable to get hue groups", "err", err)
+		return err
+	}
+	var lights map[string]hueLight
+	if err := h.get(ctx, "/api/"+username+"/lights", &lights); err != nil {
+		hueLogger.Error("unable to get hue lights", "err", err)
+		return err
+	}
+
+	var rooms []ui.Light
+	for id, group := range groups {
+		if group.Type != "Room" && group.Type != "Zone" {
+			continue
+		}
+
+		room := lightFromHueState("groups/"+id, group.Name, ui.LightTypeRoom, group.Action)
+		room.On = group.State.AnyOn
+		room.Reachable = true
+		rooms = append(rooms, room)
+	}
+
+	var bulbs []ui.Light
+	for id, light := range lights {
+		bulbs = append(bulbs, lightFromHueState("lights/"+id, light.Name, ui.LightTypeLight, light.State))
+	}
+
+	sortLights(rooms)
+	sortLights(bulbs)
+
+	hueLogger.Info("caching lights", "rooms", len(rooms), "lights", len(bulbs))
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.cachedLights = append(rooms, bulbs...)
+	return nil
+}
+
+// ToggleLight switches the light, or every light in the room, on or off.
+func (h *Hue) ToggleLight(ctx context.Context, id string) error {
+	light, ok := h.light(id)
+	if !ok {
+		return ErrLightNotFound
+	}
+
+	return h.setState(ctx, id, map[string]any{"on": !light.On}, func(l *ui.Light) {
+		l.On = !light.On
+	})
+}
+
+// SetLightBrightness turns the light on at the supplied brightness percentage.
+func (h *Hue) SetLightBrightness(ctx context.Context, id string, brightness int) error {
+	if _, ok := h.light(id); !ok {
+		return ErrLightNotFound
+	}
+
+	brightness = min(max(brightness, 1), 100)
+	bri := max(1, int(math.Round(float64(brightness)*254/100)))
+	return h.setState(ctx, id, map[string]any{"on": true, "bri": bri}, func(l *ui.Light) {
+		l.On = true
+		l.Brightness = brightness
+	})
+}
+
+// SetLightColor turns the light on with the supplied colour. The brightness of the colour is ignored, so the
+// light keeps its current brightness.
+func (h *Hue) SetLightColor(ctx context.Context, id string, c color.Color) error {
+	if _, ok := h.light(id); !ok {
+		return ErrLightNotFound
+	}
+
+	hue, sat := colorToHueSat(c)
+	return h.setState(ctx, id, map[string]any{"on": true, "hue": hue, "sat": sat}, func(l *ui.Light) {
+		l.On = true
+		l.Color = hueSatToColor(hue, sat)
+	})
+}
+
+// setState sends the state change to the light or room, applying update to the cached copy if it succeeds.
+func (h *Hue) setState(ctx context.Context, id string, state map[string]any, update func(*ui.Light)) error {
+	username, err := h.pairedUsername()
+	if err != nil {
+		return err
+	}
+
+	path := "/api/" + username + "/" + id + "/state"
+	if strings.HasPrefix(id, "groups/") {
+		path = "/api/" + username + "/" + id + "/action"
+	}
+
+	results, err := h.write(ctx, http.MethodPut, path, state)
+	if err != nil {
+		hueLogger.Error("unable to set light state", "light", id, "err", err)
+		return err
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return fmt.Errorf("set %s state: %s", id, result.Error.Description)
+		}
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i := range h.cachedLights {
+		if h.cachedLights[i].ID == id {
+			update(&h.cachedLights[i])
+		}
+	}
+	return nil
+}
+
+func (h *Hue) light(id string) (ui.Light, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, light := range h.cachedLights {
+		if light.ID == id {
+			return light, true
+		}
+	}
+
+	return ui.Light{}, false
+}
+
+func (h *Hue) pairedUsername() (string, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.username) < 1 {
+		return "", ErrHueNotPaired
+	}
+	return h.username, nil
+}
+
+// get retrieves a resource from the bridge. The bridge reports errors, such as an unknown username, as a list of
+// results in place of the resource.
+func (h *Hue) get(ctx context.Context, path string, resp any) error {
+	data, err := h.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+
+	var results []hueResult
+	if json.Unmarshal(data, &results) == nil {
+		for _, result := range results {
+			if result.Error != nil {
+				return fmt.Errorf("hue bridge: %s", result.Error.Description)
+			}
+		}
+	}
+	return json.Unmarshal(data, resp)
+}
+
+// write sends a change to the bridge, returning the result of each part of it.
+func (h *Hue) write(ctx context.Context, method string, path string, body any) ([]hueResult, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := h.do(ctx, method, path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []hueResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (h *Hue) do(ctx context.Context, method string, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hue bridge returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func lightFromHueState(id string, name string, lightType ui.LightType, state hueState) ui.Light {
+	light := ui.Light{
+		ID:        id,
+		Name:      name,
+		Type:      lightType,
+		On:        state.On,
+		Reachable: state.Reachable == nil || *state.Reachable,
+	}
+	if state.Bri != nil {
+		light.SupportsBrightness = true
+		light.Brightness = int(math.Round(float64(*state.Bri) * 100 / 254))
+	}
+	if state.Hue != nil && state.Sat != nil {
+		light.SupportsColor = true
+		light.Color = hueSatToColor(*state.Hue, *state.Sat)
+	}
+	return light
+}
+
+// sortLights orders the lights by their numeric ID on the bridge.
+func sortLights(lights []ui.Light) {
+	slices.SortFunc(lights, func(a, b ui.Light) int {
+		aID, _ := strconv.Atoi(a.ID[strings.LastIndex(a.ID, "/")+1:])
+		bID, _ := strconv.Atoi(b.ID[strings.LastIndex(b.ID, "/")+1:])
+		return aID - bID
+	})
+}
+
+// hueSatToColor converts a Hue bridge hue (0 to 65535) and saturation (0 to 254) to a colour at full brightness.
+func hueSatToColor(hue int, sat int) color.Color {
+	h := float64(hue) / 65536 * 6
+	s := float64(sat) / 254
+
+	sector := math.Floor(h)
+	f := h - sector
+	p := 1 - s
+	q := 1 - s*f
+	t := 1 - s*(1-f)
+
+	var r, g, b float64
+	switch int(sector) % 6 {
+	case 0:
+		r, g, b = 1, t, p
+	case 1:
+		r, g, b = q, 1, p
+	case 2:
+		r, g, b = p, 1, t
+	case 3:
+		r, g, b = p, q, 1
+	case 4:
+		r, g, b = t, p, 1
+	default:
+		r, g, b = 1, p, q
+	}
+
+	return color.RGBA{R: uint8(math.Round(r * 255)), G: uint8(math.Round(g * 255)), B: uint8(math.Round(b * 255)), A: 255}
+}
+
+// colorToHueSat converts a colour to a Hue bridge hue and saturation.
+func colorToHueSat(c color.Color) (int, int) {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	r, g, b := float64(rgba.R)/255, float64(rgba.G)/255, float64(rgba.B)/255
+
+	high := max(r, g, b)
+	low := min(r, g, b)
+	if high == 0 {
+		return 0, 0
+	}
+	delta := high - low
+
+	var h float64
+	switch {
+	case delta == 0:
+		h = 0
+	case high == r:
+		h = math.Mod((g-b)/delta, 6)
+	case high == g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	if h < 0 {
+		h += 6
+	}
+
+	return int(math.Round(h / 6 * 65535)), int(math.Round(delta / high * 254))
+}
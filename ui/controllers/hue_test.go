@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+const hueTestUsername = "deskpad-user"
+
+// fakeHueBridge implements the parts of the Hue bridge REST API used by the controller.
+type fakeHueBridge struct {
+	*httptest.Server
+
+	lock          sync.Mutex
+	linkPressed   bool
+	pairAttempts  int
+	stateRequests []string
+}
+
+func newFakeHueBridge(t *testing.T) *fakeHueBridge {
+	t.Helper()
+
+	fake := &fakeHueBridge{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeHueBridge) handle(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.URL.Path == "/api" && r.Method == http.MethodPost {
+		f.pairAttempts++
+		if !f.linkPressed {
+			w.Write([]byte(`[{"error":{"type":101,"address":"","description":"link button not pressed"}}]`))
+			return
+		}
+		w.Write([]byte(`[{"success":{"username":"` + hueTestUsername + `"}}]`))
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/api/"+hueTestUsername+"/")
+	if !ok {
+		w.Write([]byte(`[{"error":{"type":1,"address":"/","description":"unauthorized user"}}]`))
+		return
+	}
+
+	switch {
+	case path == "groups" && r.Method == http.MethodGet:
+		w.Write([]byte(`{
+			"1": {"name": "Living Room", "type": "Room", "state": {"any_on": true}, "action": {"on": false, "bri": 127, "hue": 0, "sat": 254}},
+			"2": {"name": "Entertainment", "type": "Entertainment", "state": {"any_on": false}, "action": {"on": false}}
+		}`))
+	case path == "lights" && r.Method == http.MethodGet:
+		w.Write([]byte(`{
+			"10": {"name": "Lamp", "state": {"on": true, "bri": 254, "reachable": true}},
+			"2": {"name": "Strip", "state": {"on": false, "bri": 1, "hue": 21845, "sat": 254, "reachable": true}},
+			"3": {"name": "Plug", "state": {"on": false, "reachable": false}}
+		}`))
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.stateRequests = append(f.stateRequests, path+" "+strings.TrimSpace(string(body)))
+		w.Write([]byte(`[{"success":{}}]`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestHuePairing(t *testing.T) {
+	fake := newFakeHueBridge(t)
+	usernamePath := filepath.Join(t.TempDir(), "hue-username")
+	ctx := context.Background()
+
+	h := NewHue(strings.TrimPrefix(fake.URL, "http://"), "", usernamePath)
+	if h.Paired() {
+		t.Fatalf("paired before pairing")
+	}
+	if err := h.RefreshLights(ctx); !errors.Is(err, ErrHueNotPaired) {
+		t.Fatalf("refresh err = %v, want %v", err, ErrHueNotPaired)
+	}
+
+	if err := h.Pair(ctx); !errors.Is(err, ErrHueLinkButtonNotPressed) {
+		t.Fatalf("pair err = %v, want %v", err, ErrHueLinkButtonNotPressed)
+	}
+
+	fake.lock.Lock()
+	fake.linkPressed = true
+	fake.lock.Unlock()
+
+	if err := h.Pair(ctx); err != nil {
+		t.Fatalf("pair err = %v", err)
+	}
+	if !h.Paired() {
+		t.Fatalf("not paired after pairing")
+	}
+
+	data, err := os.ReadFile(usernamePath)
+	if err != nil || strings.TrimSpace(string(data)) != hueTestUsername {
+		t.Fatalf("saved username = %q (%v), want %q", data, err, hueTestUsername)
+	}
+
+	// A new controller picks up the saved username without pairing again.
+	if h := NewHue(fake.URL, "", usernamePath); !h.Paired() {
+		t.Fatalf("saved username not used")
+	}
+	if fake.pairAttempts != 2 {
+		t.Fatalf("pair attempts = %d, want 2", fake.pairAttempts)
+	}
+}
+
+func TestHueRefreshLights(t *testing.T) {
+	fake := newFakeHueBridge(t)
+
+	h := NewHue(fake.URL, hueTestUsername, "")
+	if err := h.RefreshLights(context.Background()); err != nil {
+		t.Fatalf("refresh err = %v", err)
+	}
+
+	want := []ui.Light{
+		{ID: "groups/1", Name: "Living Room", Type: ui.LightTypeRoom, On: true, Brightness: 50, Color: color.RGBA{R: 255, A: 255}, Reachable: true, SupportsBrightness: true, SupportsColor: true},
+		{ID: "lights/2", Name: "Strip", Type: ui.LightTypeLight, Brightness: 0, Color: color.RGBA{G: 255, A: 255}, Reachable: true, SupportsBrightness: true, SupportsColor: true},
+		{ID: "lights/3", Name: "Plug", Type: ui.LightTypeLight},
+		{ID: "lights/10", Name: "Lamp", Type: ui.LightTypeLight, On: true, Brightness: 100, Reachable: true, SupportsBrightness: true},
+	}
+	if got := h.GetLights(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("lights = %+v, want %+v", got, want)
+	}
+}
+
+func TestHueSetState(t *testing.T) {
+	fake := newFakeHueBridge(t)
+	ctx := context.Background()
+
+	h := NewHue(fake.URL, hueTestUsername, "")
+	if err := h.ToggleLight(ctx, "lights/2"); !errors.Is(err, ErrLightNotFound) {
+		t.Fatalf("toggle err = %v, want %v", err, ErrLightNotFound)
+	}
+	if err := h.RefreshLights(ctx); err != nil {
+		t.Fatalf("refresh err = %v", err)
+	}
+
+	if err := h.ToggleLight(ctx, "groups/1"); err != nil {
+		t.Fatalf("toggle err = %v", err)
+	}
+	if err := h.SetLightBrightness(ctx, "lights/2", 60); err != nil {
+		t.Fatalf("brightness err = %v", err)
+	}
+	if err := h.SetLightColor(ctx, "lights/2", color.RGBA{B: 255, A: 255}); err != nil {
+		t.Fatalf("color err = %v", err)
+	}
+
+	wantRequests := []string{
+		`groups/1/action {"on":false}`,
+		`lights/2/state {"bri":152,"on":true}`,
+		`lights/2/state {"hue":43690,"on":true,"sat":254}`,
+	}
+	if !reflect.DeepEqual(fake.stateRequests, wantRequests) {
+		t.Fatalf("requests = %q, want %q", fake.stateRequests, wantRequests)
+	}
+
+	lights := h.GetLights()
+	if lights[0].On {
+		t.Errorf("room still on after toggle")
+	}
+	if l := lights[1]; !l.On || l.Brightness != 60 || l.Color != (color.RGBA{B: 255, A: 255}) {
+		t.Errorf("strip = %+v, want on at 60%% and blue", l)
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"math"
 	"sync"
+	"time"
 
 	"github.com/godbus/dbus"
-	"github.com/lawl/pulseaudio"
+	"github.com/rmrobinson/deskpad/logging"
 	"github.com/rmrobinson/deskpad/ui"
 	"github.com/rmrobinson/go-mpris"
 )
 
+var mprisLogger = logging.Logger("mpris")
+
 const linuxMediaPlayerVolumeStep = 0.05
 
 // LinuxMediaPlayer uses the DBus MPRIS interface to control a media agent running on the local machine,
@@ -26,13 +29,13 @@ type LinuxMediaPlayer struct {
 	mprisClient       *mpris.Player
 	mprisInstanceName string
 
-	paClient *pulseaudio.Client
+	paClient PulseAudioClient
 
 	// TODO: add Bluetooth client
 }
 
 // NewLinuxMediaPlayer creates a new player using the supplied MRPIS and PulseAudio clients
-func NewLinuxMediaPlayer(mprisConn *dbus.Conn, mprisInstanceName string, paClient *pulseaudio.Client) *LinuxMediaPlayer {
+func NewLinuxMediaPlayer(mprisConn *dbus.Conn, mprisInstanceName string, paClient PulseAudioClient) *LinuxMediaPlayer {
 	return &LinuxMediaPlayer{
 		mprisConn:         mprisConn,
 		mprisClient:       mpris.New(mprisConn, mprisInstanceName),
@@ -56,7 +59,7 @@ func (m *LinuxMediaPlayer) Play() {
 		return
 	}
 
-	log.Printf("mpris %s: Play\n", name)
+	mprisLogger.Debug("play", "player", name)
 	client.Play()
 }
 func (m *LinuxMediaPlayer) Pause() {
@@ -65,7 +68,7 @@ func (m *LinuxMediaPlayer) Pause() {
 		return
 	}
 
-	log.Printf("mpris %s: Pause\n", name)
+	mprisLogger.Debug("pause", "player", name)
 	client.Pause()
 }
 func (m *LinuxMediaPlayer) Next() {
@@ -74,7 +77,7 @@ func (m *LinuxMediaPlayer) Next() {
 		return
 	}
 
-	log.Printf("mpris %s: Next\n", name)
+	mprisLogger.Debug("next", "player", name)
 	client.Next()
 }
 func (m *LinuxMediaPlayer) Previous() {
@@ -83,7 +86,7 @@ func (m *LinuxMediaPlayer) Previous() {
 		return
 	}
 
-	log.Printf("mpris %s: Previous\n", name)
+	mprisLogger.Debug("previous", "player", name)
 	client.Previous()
 }
 func (m *LinuxMediaPlayer) FastForward() {
@@ -95,7 +98,7 @@ func (m *LinuxMediaPlayer) FastForward() {
 	pos := client.GetPosition()
 
 	newPos := pos + 10000
-	log.Printf("mpris %s: SeekTo fast-forward from %d to %d\n", name, pos, newPos)
+	mprisLogger.Debug("fast-forward", "player", name, "from", pos, "to", newPos)
 	client.SeekTo(newPos)
 }
 func (m *LinuxMediaPlayer) Rewind() {
@@ -110,14 +113,14 @@ func (m *LinuxMediaPlayer) Rewind() {
 	if newPos < 0 {
 		newPos = 0
 	}
-	log.Printf("mpris %s: SeekTo rewind from %d to %d\n", name, pos, newPos)
+	mprisLogger.Debug("rewind", "player", name, "from", pos, "to", newPos)
 	client.SeekTo(newPos)
 
 }
 func (m *LinuxMediaPlayer) VolumeUp() {
 	v, err := m.paClient.Volume()
 	if err != nil {
-		log.Printf("error getting volume: %s\n", err.Error())
+		mprisLogger.Error("error getting volume", "err", err)
 		return
 	}
 
@@ -131,7 +134,7 @@ func (m *LinuxMediaPlayer) VolumeUp() {
 func (m *LinuxMediaPlayer) VolumeDown() {
 	v, err := m.paClient.Volume()
 	if err != nil {
-		log.Printf("error getting volume: %s\n", err.Error())
+		mprisLogger.Error("error getting volume", "err", err)
 		return
 	}
 
@@ -156,18 +159,50 @@ func (m *LinuxMediaPlayer) Shuffle(shuffle bool) {
 		return
 	}
 
-	log.Printf("mpris %s: SetShuffle %t\n", name, shuffle)
-	obj := m.mprisConn.Object(name, dbus.ObjectPath("/org/mpris/MediaPlayer2"))
-	call := obj.Call(
-		"org.freedesktop.DBus.Properties.Set",
-		0,
-		"org.mpris.MediaPlayer2.Player",
-		"Shuffle",
-		dbus.MakeVariant(shuffle),
-	)
-	if call.Err != nil {
-		log.Printf("mpris %s: error setting shuffle: %s\n", name, call.Err.Error())
+	mprisLogger.Debug("set shuffle", "player", name, "shuffle", shuffle)
+	if err := m.setPlayerProperty(name, "Shuffle", shuffle); err != nil {
+		mprisLogger.Error("error setting shuffle", "player", name, "err", err)
+	}
+}
+
+// Seek moves playback of the current item to the supplied position.
+func (m *LinuxMediaPlayer) Seek(position time.Duration) error {
+	client, name, ok := m.currentMPRISClient()
+	if !ok {
+		return errors.New("no MPRIS media player available")
 	}
+
+	mprisLogger.Debug("seek", "player", name, "position", position)
+	client.SeekTo(position.Milliseconds())
+	return nil
+}
+
+// SetVolume sets the output volume as a percentage between 0 and 100.
+func (m *LinuxMediaPlayer) SetVolume(volume int) error {
+	return m.paClient.SetVolume(float32(volume) / 100)
+}
+
+// Repeat sets whether the current track or playlist repeats once it finishes.
+func (m *LinuxMediaPlayer) Repeat(mode ui.RepeatMode) error {
+	_, name, ok := m.currentMPRISClient()
+	if !ok {
+		return errors.New("no MPRIS media player available")
+	}
+
+	var loopStatus string
+	switch mode {
+	case ui.RepeatOff:
+		loopStatus = "None"
+	case ui.RepeatTrack:
+		loopStatus = "Track"
+	case ui.RepeatPlaylist:
+		loopStatus = "Playlist"
+	default:
+		return fmt.Errorf("unsupported repeat mode %q", mode)
+	}
+
+	mprisLogger.Debug("set loop status", "player", name, "status", loopStatus)
+	return m.setPlayerProperty(name, "LoopStatus", loopStatus)
 }
 
 func (m *LinuxMediaPlayer) IsPlaying() bool {
@@ -192,7 +227,7 @@ func (m *LinuxMediaPlayer) IsShuffle() bool {
 func (m *LinuxMediaPlayer) IsMuted() bool {
 	muted, err := m.paClient.Mute()
 	if err != nil {
-		log.Printf("error getting muted state: %s\n", err.Error())
+		mprisLogger.Error("error getting muted state", "err", err)
 		return false
 	}
 
@@ -216,18 +251,62 @@ func (m *LinuxMediaPlayer) CurrentlyPlaying() *ui.MediaItem {
 	}
 }
 
-func (m *LinuxMediaPlayer) getMetadata(name string) map[string]dbus.Variant {
+// PlaybackState returns the position in the current item along with the volume and repeat settings.
+func (m *LinuxMediaPlayer) PlaybackState() ui.PlaybackState {
+	state := ui.PlaybackState{Repeat: ui.RepeatOff}
+
+	if v, err := m.paClient.Volume(); err != nil {
+		mprisLogger.Error("error getting volume", "err", err)
+	} else {
+		state.Volume = int(math.Round(float64(v) * 100))
+	}
+
+	client, name, ok := m.currentMPRISClient()
+	if !ok {
+		return state
+	}
+
+	state.Position = time.Duration(client.GetPosition()) * time.Millisecond
+	state.Duration = time.Duration(metadataInt(m.getMetadata(name), "mpris:length")) * time.Microsecond
+
+	variant, err := m.getPlayerProperty(name, "LoopStatus")
+	if err != nil {
+		mprisLogger.Error("error getting loop status", "player", name, "err", err)
+		return state
+	}
+	switch variant.Value() {
+	case "Track":
+		state.Repeat = ui.RepeatTrack
+	case "Playlist":
+		state.Repeat = ui.RepeatPlaylist
+	}
+
+	return state
+}
+
+func (m *LinuxMediaPlayer) getPlayerProperty(name string, property string) (dbus.Variant, error) {
 	obj := m.mprisConn.Object(name, dbus.ObjectPath("/org/mpris/MediaPlayer2"))
 
 	var variant dbus.Variant
-	if err := obj.Call("org.freedesktop.DBus.Properties.Get", 0, "org.mpris.MediaPlayer2.Player", "Metadata").Store(&variant); err != nil {
-		log.Printf("mpris %s: error getting metadata: %s\n", name, err.Error())
+	err := obj.Call("org.freedesktop.DBus.Properties.Get", 0, "org.mpris.MediaPlayer2.Player", property).Store(&variant)
+	return variant, err
+}
+
+func (m *LinuxMediaPlayer) setPlayerProperty(name string, property string, value interface{}) error {
+	obj := m.mprisConn.Object(name, dbus.ObjectPath("/org/mpris/MediaPlayer2"))
+	return obj.Call("org.freedesktop.DBus.Properties.Set", 0, "org.mpris.MediaPlayer2.Player", property, dbus.MakeVariant(value)).Err
+}
+
+func (m *LinuxMediaPlayer) getMetadata(name string) map[string]dbus.Variant {
+	variant, err := m.getPlayerProperty(name, "Metadata")
+	if err != nil {
+		mprisLogger.Error("error getting metadata", "player", name, "err", err)
 		return nil
 	}
 
 	metadata, ok := variant.Value().(map[string]dbus.Variant)
 	if !ok {
-		log.Printf("mpris %s: metadata had unexpected type %T\n", name, variant.Value())
+		mprisLogger.Warn("metadata had unexpected type", "player", name, "type", fmt.Sprintf("%T", variant.Value()))
 		return nil
 	}
 
@@ -248,6 +327,24 @@ func metadataString(metadata map[string]dbus.Variant, key string) string {
 	return value
 }
 
+func metadataInt(metadata map[string]dbus.Variant, key string) int64 {
+	variant, ok := metadata[key]
+	if !ok {
+		return 0
+	}
+
+	switch value := variant.Value().(type) {
+	case int64:
+		return value
+	case uint64:
+		return int64(value)
+	case int32:
+		return int64(value)
+	default:
+		return 0
+	}
+}
+
 func metadataStrings(metadata map[string]dbus.Variant, key string) []string {
 	variant, ok := metadata[key]
 	if !ok {
@@ -278,7 +375,7 @@ func (m *LinuxMediaPlayer) PlayURI(ctx context.Context, uri string) error {
 		return errors.New("no MPRIS media player available")
 	}
 
-	log.Printf("mpris %s: OpenUri %s\n", name, uri)
+	mprisLogger.Debug("open uri", "player", name, "uri", uri)
 	obj := m.mprisConn.Object(name, dbus.ObjectPath("/org/mpris/MediaPlayer2"))
 	if err := callMPRISWithContext(ctx, obj, "org.mpris.MediaPlayer2.Player.OpenUri", uri); err != nil {
 		return fmt.Errorf("open URI: %w", err)
@@ -306,7 +403,7 @@ func (m *LinuxMediaPlayer) currentMPRISClient() (*mpris.Player, string, bool) {
 
 	names, err := mpris.List(m.mprisConn)
 	if err != nil {
-		log.Printf("mpris: unable to list media players: %s\n", err.Error())
+		mprisLogger.Error("mpris: unable to list media players", "err", err)
 		return nil, "", false
 	}
 
@@ -318,7 +415,7 @@ func (m *LinuxMediaPlayer) currentMPRISClient() (*mpris.Player, string, bool) {
 
 	if len(names) == 0 {
 		if m.mprisInstanceName != "" {
-			log.Printf("mpris: media player %q is no longer available and no replacement was found\n", m.mprisInstanceName)
+			mprisLogger.Warn("media player is no longer available and no replacement was found", "player", m.mprisInstanceName)
 		}
 		m.mprisClient = nil
 		m.mprisInstanceName = ""
@@ -328,6 +425,6 @@ func (m *LinuxMediaPlayer) currentMPRISClient() (*mpris.Player, string, bool) {
 	oldName := m.mprisInstanceName
 	m.mprisInstanceName = names[0]
 	m.mprisClient = mpris.New(m.mprisConn, m.mprisInstanceName)
-	log.Printf("mpris: media player %q is no longer available; switched to %q\n", oldName, m.mprisInstanceName)
+	mprisLogger.Info("media player is no longer available; switched players", "from", oldName, "player", m.mprisInstanceName)
 	return m.mprisClient, m.mprisInstanceName, true
 }
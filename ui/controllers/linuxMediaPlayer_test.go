@@ -47,3 +47,24 @@ func TestMetadataStringsHandlesCommonMPRISArtistShapes(t *testing.T) {
 		t.Fatalf("wrong type artists = %v, want nil", got)
 	}
 }
+
+func TestMetadataIntHandlesMPRISLengthShapes(t *testing.T) {
+	metadata := map[string]dbus.Variant{
+		"int64":     dbus.MakeVariant(int64(180000000)),
+		"uint64":    dbus.MakeVariant(uint64(180000000)),
+		"wrongType": dbus.MakeVariant("180000000"),
+	}
+
+	if got := metadataInt(metadata, "int64"); got != 180000000 {
+		t.Fatalf("int64 length = %d, want 180000000", got)
+	}
+	if got := metadataInt(metadata, "uint64"); got != 180000000 {
+		t.Fatalf("uint64 length = %d, want 180000000", got)
+	}
+	if got := metadataInt(metadata, "wrongType"); got != 0 {
+		t.Fatalf("wrong type length = %d, want 0", got)
+	}
+	if got := metadataInt(metadata, "missing"); got != 0 {
+		t.Fatalf("missing length = %d, want 0", got)
+	}
+}
@@ -2,61 +2,95 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"maps"
+	"math"
+	"sync"
 
-	"github.com/lawl/pulseaudio"
+	"github.com/rmrobinson/deskpad/logging"
 	"github.com/rmrobinson/deskpad/ui"
 	"github.com/zmb3/spotify/v2"
 )
 
+var audioLogger = logging.Logger("audio")
+
+// defaultUnmuteVolume is the volume an output muted by setting its volume to 0 is restored to if its previous
+// volume isn't known.
+const defaultUnmuteVolume = 50
+
+// ErrAudioOutputNotFound is returned when the requested audio output isn't in the list of available outputs.
+var ErrAudioOutputNotFound = errors.New("audio output not found")
+
 // MediaPlayerSetting is a controller which facilitates media playback on the available audio output devices.
 type MediaPlayerSetting struct {
 	spotifyClient *spotify.Client
-	paClient      *pulseaudio.Client
+	paClient      PulseAudioClient
 
+	lock               sync.RWMutex
 	cachedAudioOutputs []ui.AudioOutput
+	// mutedVolumes holds the volume of outputs which are muted by setting their volume to 0: Spotify devices, and
+	// PulseAudio sinks other than the default one.
+	mutedVolumes map[string]int
 }
 
 // NewMediaPlayerSetting creates a new media player setting controller. If the pulseAudio client isn't supplied,
 // it will currently default to use spotify.
-func NewMediaPlayerSetting(sc *spotify.Client, pac *pulseaudio.Client) *MediaPlayerSetting {
+func NewMediaPlayerSetting(sc *spotify.Client, pac PulseAudioClient) *MediaPlayerSetting {
 	return &MediaPlayerSetting{
 		spotifyClient: sc,
 		paClient:      pac,
+		mutedVolumes:  map[string]int{},
 	}
 }
 
 // GetAudioOutputs returns the list of available audio outputs.
 func (mps *MediaPlayerSetting) GetAudioOutputs() []ui.AudioOutput {
-	return mps.cachedAudioOutputs
+	mps.lock.RLock()
+	defer mps.lock.RUnlock()
+
+	return append([]ui.AudioOutput(nil), mps.cachedAudioOutputs...)
 }
 
+// RefreshAudioOutputs retrieves an up-to-date list of audio outputs and their settings.
 func (mps *MediaPlayerSetting) RefreshAudioOutputs(ctx context.Context) error {
 	var audioOutputs []ui.AudioOutput
 
 	if mps.paClient != nil {
 		sinks, err := mps.paClient.Sinks()
 		if err != nil {
-			log.Printf("unable to get pulseaudio sinks: %s\n", err.Error())
+			audioLogger.Error("unable to get pulseaudio sinks", "err", err)
 			return err
 		}
 
+		mps.lock.RLock()
+		mutedVolumes := maps.Clone(mps.mutedVolumes)
+		mps.lock.RUnlock()
+
 		for _, sink := range sinks {
+			var volume int
+			if len(sink.Cvolume) > 0 {
+				volume = int(math.Round(float64(sink.Cvolume[0]) / 0xffff * 100))
+			}
+
+			id := fmt.Sprintf("%d", sink.Index)
+			_, volumeMuted := mutedVolumes[id]
+
 			// State 0: active
 			// State 2: suspended
 			audioOutputs = append(audioOutputs, ui.AudioOutput{
-				ID:          fmt.Sprintf("%d", sink.Index),
+				ID:          id,
 				Name:        sink.Description,
 				Description: sink.Name,
-				Muted:       sink.Muted,
+				Volume:      volume,
+				Muted:       sink.Muted || (volumeMuted && volume == 0),
 				Active:      sink.SinkState == 0,
 			})
 		}
 	} else {
 		devices, err := mps.spotifyClient.PlayerDevices(ctx)
 		if err != nil {
-			log.Printf("error getting player devices: %s\n", err.Error())
+			audioLogger.Error("error getting player devices", "err", err)
 			return err
 		}
 
@@ -79,27 +113,102 @@ func (mps *MediaPlayerSetting) RefreshAudioOutputs(ctx context.Context) error {
 			audioOutputs = append(audioOutputs, ui.AudioOutput{
 				ID:     string(device.ID),
 				Name:   device.Name,
+				Volume: int(device.Volume),
+				Muted:  device.Volume == 0,
 				Active: device.Active,
 				Type:   deviceType,
 			})
 		}
 	}
 
-	log.Printf("caching %d audio outputs\n", len(audioOutputs))
+	audioLogger.Info("caching audio outputs", "count", len(audioOutputs))
+	mps.lock.Lock()
+	defer mps.lock.Unlock()
+
 	mps.cachedAudioOutputs = audioOutputs
 	return nil
 }
 
 // SelectAudioOutput directs the active media playback stream to the specified device ID.
-func (mps *MediaPlayerSetting) SelectAudioOutput(ctx context.Context, deviceID string) {
+func (mps *MediaPlayerSetting) SelectAudioOutput(ctx context.Context, deviceID string) error {
 	if mps.paClient != nil {
 		// Set the default sink in PulseAudio
-		mps.paClient.SetDefaultSink(deviceID)
-	} else {
-		// Transfer playback to the supplied device ID
-		err := mps.spotifyClient.TransferPlayback(ctx, spotify.ID(deviceID), true)
+		if err := mps.paClient.SetDefaultSink(deviceID); err != nil {
+			audioLogger.Error("error setting default sink", "output", deviceID, "err", err)
+			return err
+		}
+		return nil
+	}
+
+	// Transfer playback to the supplied device ID
+	if err := mps.spotifyClient.TransferPlayback(ctx, spotify.ID(deviceID), true); err != nil {
+		audioLogger.Error("error transferring playback", "output", deviceID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// SetAudioOutputVolume sets the volume of the specified device as a percentage between 0 and 100.
+func (mps *MediaPlayerSetting) SetAudioOutputVolume(ctx context.Context, deviceID string, volume int) error {
+	if _, ok := mps.audioOutput(deviceID); !ok {
+		return ErrAudioOutputNotFound
+	}
+
+	if mps.paClient != nil {
+		// PulseAudio resolves numeric sink names to the sink with that index.
+		return mps.paClient.SetSinkVolume(deviceID, float32(volume)/100)
+	}
+
+	id := spotify.ID(deviceID)
+	return mps.spotifyClient.VolumeOpt(ctx, volume, &spotify.PlayOptions{DeviceID: &id})
+}
+
+// SetAudioOutputMute mutes or unmutes the specified device.
+func (mps *MediaPlayerSetting) SetAudioOutputMute(ctx context.Context, deviceID string, muted bool) error {
+	output, ok := mps.audioOutput(deviceID)
+	if !ok {
+		return ErrAudioOutputNotFound
+	}
+
+	if mps.paClient != nil {
+		// The PulseAudio client can only mute the default sink; other sinks are muted using their volume.
+		server, err := mps.paClient.ServerInfo()
 		if err != nil {
-			log.Printf("error transfering playback to %s: %s\n", deviceID, err.Error())
+			return err
+		}
+		if output.Description == server.DefaultSink {
+			return mps.paClient.SetMute(muted)
 		}
 	}
+
+	// Outputs without a mute setting have their volume set to 0, which is restored on unmute.
+	mps.lock.Lock()
+	volume := 0
+	if muted {
+		if output.Volume > 0 {
+			mps.mutedVolumes[deviceID] = output.Volume
+		}
+	} else {
+		var ok bool
+		if volume, ok = mps.mutedVolumes[deviceID]; !ok {
+			volume = defaultUnmuteVolume
+		}
+		delete(mps.mutedVolumes, deviceID)
+	}
+	mps.lock.Unlock()
+
+	return mps.SetAudioOutputVolume(ctx, deviceID, volume)
+}
+
+func (mps *MediaPlayerSetting) audioOutput(deviceID string) (ui.AudioOutput, bool) {
+	mps.lock.RLock()
+	defer mps.lock.RUnlock()
+
+	for _, output := range mps.cachedAudioOutputs {
+		if output.ID == deviceID {
+			return output, true
+		}
+	}
+
+	return ui.AudioOutput{}, false
 }
@@ -2,23 +2,39 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"image"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/metrics"
 	"github.com/rmrobinson/deskpad/ui"
 	"github.com/zmb3/spotify/v2"
 	_ "golang.org/x/image/webp"
 )
 
+var playlistLogger = logging.Logger("playlists")
+
 type PlaylistPlaybackController interface {
 	PlayURI(ctx context.Context, uri string) error
 }
 
+// PlaylistStore persists the statically configured playlists so changes survive restarts.
+type PlaylistStore interface {
+	Save(playlists []ui.MediaPlaylist) error
+}
+
 const playlistPlaybackTimeout = 5 * time.Second
 
+var (
+	// ErrPlaylistNotFound is returned when a static playlist with the requested ID doesn't exist.
+	ErrPlaylistNotFound = errors.New("playlist not found")
+	// ErrPlaylistExists is returned when adding a static playlist whose ID is already in use.
+	ErrPlaylistExists = errors.New("playlist already exists")
+)
+
 // MediaPlaylist is a controller which manages media playlist management
 // There are 2 sources of playlist data: a statically configured list of playlists;
 // and a dynamically refreshed list of playlists. These imlementation details are abstracted away
@@ -27,6 +43,7 @@ type MediaPlaylist struct {
 	lock               sync.RWMutex
 	spotifyClient      *spotify.Client
 	playbackController PlaylistPlaybackController
+	store              PlaylistStore
 
 	staticPlaylists []ui.MediaPlaylist
 	cachedPlaylists []ui.MediaPlaylist
@@ -46,6 +63,14 @@ func NewMediaPlaylist(sc *spotify.Client, pc PlaylistPlaybackController, staticP
 	}
 }
 
+// SetPlaylistStore sets the store used to persist changes to the static playlists.
+func (mp *MediaPlaylist) SetPlaylistStore(store PlaylistStore) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	mp.store = store
+}
+
 // GetPlaylists retrieves the list of cached playlists.
 func (mp *MediaPlaylist) GetPlaylists(count int, offset int) []ui.MediaPlaylist {
 	mp.lock.RLock()
@@ -69,18 +94,23 @@ func (mp *MediaPlaylist) GetPlaylists(count int, offset int) []ui.MediaPlaylist
 
 // RefreshPlaylists retrieves an up-to-date list of playlists. This can be run on a schedule to ensure content is up-to-date.
 func (mp *MediaPlaylist) RefreshPlaylists(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		metrics.PlaylistRefreshDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	mediaPlaylists := []ui.MediaPlaylist{}
 
 	playlists, err := mp.spotifyClient.CurrentUsersPlaylists(ctx, spotify.Limit(50))
 	if err != nil {
-		log.Printf("error getting current playlists: %s\n", err.Error())
+		playlistLogger.Error("error getting current playlists", "err", err)
 		return err
 	}
 
 	for _, playlist := range playlists.Playlists {
-		log.Printf("got playlist %s with name %s\n", playlist.URI, playlist.Name)
+		playlistLogger.Debug("got playlist", "uri", playlist.URI, "name", playlist.Name)
 		if len(playlist.URI) < 1 {
-			log.Printf("skipping empty playlist\n")
+			playlistLogger.Debug("skipping playlist without a uri", "name", playlist.Name)
 			continue
 		}
 
@@ -89,39 +119,163 @@ func (mp *MediaPlaylist) RefreshPlaylists(ctx context.Context) error {
 			imgURL := playlist.Images[0].URL
 			req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
 			if err != nil {
-				log.Printf("unable to create request for playlist image %s: %s\n", imgURL, err.Error())
+				playlistLogger.Error("unable to create request for playlist image", "url", imgURL, "err", err)
 				continue
 			}
 
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
-				log.Printf("unable to download %s: %s\n", imgURL, err.Error())
+				playlistLogger.Error("unable to download playlist image", "url", imgURL, "err", err)
 				continue
 			}
 
 			mediaPlaylist.Icon, _, err = image.Decode(resp.Body)
 			resp.Body.Close()
 			if err != nil {
-				log.Printf("unable to decode image for playlist %s at %s: %s\n", playlist.Name, imgURL, err.Error())
+				playlistLogger.Error("unable to decode playlist image", "name", playlist.Name, "url", imgURL, "err", err)
 				continue
 			}
 		} else {
-			log.Printf("playlist %s has no image\n", playlist.Name)
+			playlistLogger.Debug("playlist has no image", "name", playlist.Name)
 		}
 
 		mediaPlaylists = append(mediaPlaylists, mediaPlaylist)
 	}
 
-	log.Printf("caching %d playlists\n", len(mediaPlaylists))
+	playlistLogger.Info("caching playlists", "count", len(mediaPlaylists))
 	mp.lock.Lock()
 	defer mp.lock.Unlock()
 
 	mp.cachedPlaylists = mediaPlaylists
+	mp.rebuildPlaylistsLocked()
+	return nil
+}
+
+// PlaylistCount returns the total number of playlists available.
+func (mp *MediaPlaylist) PlaylistCount() int {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+
+	return len(mp.playlists)
+}
+
+// Playlist returns the playlist with the supplied ID, if it exists.
+func (mp *MediaPlaylist) Playlist(id string) (*ui.MediaPlaylist, bool) {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+
+	playlist := mp.getPlaylistbyIDLocked(id)
+	return playlist, playlist != nil
+}
+
+// StaticPlaylists returns the statically configured playlists, in display order.
+func (mp *MediaPlaylist) StaticPlaylists() []ui.MediaPlaylist {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+
+	return append([]ui.MediaPlaylist(nil), mp.staticPlaylists...)
+}
+
+// IsStaticPlaylist returns true if the playlist with the supplied ID is statically configured.
+func (mp *MediaPlaylist) IsStaticPlaylist(id string) bool {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+
+	return mp.staticPlaylistIdxLocked(id) >= 0
+}
+
+// AddStaticPlaylist appends a playlist to the static playlists.
+func (mp *MediaPlaylist) AddStaticPlaylist(playlist ui.MediaPlaylist) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	if mp.staticPlaylistIdxLocked(playlist.ID) >= 0 {
+		return ErrPlaylistExists
+	}
+
+	staticPlaylists := append(append([]ui.MediaPlaylist(nil), mp.staticPlaylists...), playlist)
+	return mp.setStaticPlaylistsLocked(staticPlaylists)
+}
+
+// RenameStaticPlaylist changes the name of a static playlist.
+func (mp *MediaPlaylist) RenameStaticPlaylist(id string, name string) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	idx := mp.staticPlaylistIdxLocked(id)
+	if idx < 0 {
+		return ErrPlaylistNotFound
+	}
+
+	staticPlaylists := append([]ui.MediaPlaylist(nil), mp.staticPlaylists...)
+	staticPlaylists[idx].Name = name
+	return mp.setStaticPlaylistsLocked(staticPlaylists)
+}
+
+// RemoveStaticPlaylist removes a playlist from the static playlists.
+func (mp *MediaPlaylist) RemoveStaticPlaylist(id string) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	idx := mp.staticPlaylistIdxLocked(id)
+	if idx < 0 {
+		return ErrPlaylistNotFound
+	}
+
+	var staticPlaylists []ui.MediaPlaylist
+	staticPlaylists = append(staticPlaylists, mp.staticPlaylists[:idx]...)
+	staticPlaylists = append(staticPlaylists, mp.staticPlaylists[idx+1:]...)
+	return mp.setStaticPlaylistsLocked(staticPlaylists)
+}
+
+// MoveStaticPlaylist moves a static playlist to the supplied position, shifting the playlists after it.
+// Positions outside of the list are clamped to the start or end.
+func (mp *MediaPlaylist) MoveStaticPlaylist(id string, position int) error {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	idx := mp.staticPlaylistIdxLocked(id)
+	if idx < 0 {
+		return ErrPlaylistNotFound
+	}
+
+	playlist := mp.staticPlaylists[idx]
+	var staticPlaylists []ui.MediaPlaylist
+	staticPlaylists = append(staticPlaylists, mp.staticPlaylists[:idx]...)
+	staticPlaylists = append(staticPlaylists, mp.staticPlaylists[idx+1:]...)
+
+	position = max(0, min(position, len(staticPlaylists)))
+	staticPlaylists = append(staticPlaylists[:position], append([]ui.MediaPlaylist{playlist}, staticPlaylists[position:]...)...)
+	return mp.setStaticPlaylistsLocked(staticPlaylists)
+}
 
+// setStaticPlaylistsLocked persists the new static playlists and, if successful, makes them visible.
+func (mp *MediaPlaylist) setStaticPlaylistsLocked(staticPlaylists []ui.MediaPlaylist) error {
+	if mp.store != nil {
+		if err := mp.store.Save(staticPlaylists); err != nil {
+			return err
+		}
+	}
+
+	mp.staticPlaylists = staticPlaylists
+	mp.rebuildPlaylistsLocked()
+	return nil
+}
+
+func (mp *MediaPlaylist) rebuildPlaylistsLocked() {
 	mp.playlists = nil
 	mp.playlists = append(mp.playlists, mp.cachedPlaylists...)
 	mp.playlists = append(mp.playlists, mp.staticPlaylists...)
-	return nil
+}
+
+func (mp *MediaPlaylist) staticPlaylistIdxLocked(id string) int {
+	for idx, p := range mp.staticPlaylists {
+		if p.ID == id {
+			return idx
+		}
+	}
+
+	return -1
 }
 
 // StartPlaylist begins playing the requested playlist URI.
@@ -129,13 +283,13 @@ func (mp *MediaPlaylist) StartPlaylist(ctx context.Context, id string) {
 	mp.lock.Lock()
 	playbackController := mp.playbackController
 	if mp.playbackController == nil {
-		log.Printf("no playback controller available for URI: %s\n", id)
+		playlistLogger.Warn("no playback controller available", "uri", id)
 		mp.currentPlaylist = nil
 		mp.lock.Unlock()
 		return
 	}
 
-	log.Printf("playing URI: %s\n", id)
+	playlistLogger.Info("playing playlist", "uri", id)
 	mp.currentPlaylist = mp.getPlaylistbyIDLocked(id)
 	mp.lock.Unlock()
 
@@ -144,7 +298,7 @@ func (mp *MediaPlaylist) StartPlaylist(ctx context.Context, id string) {
 		defer cancel()
 
 		if err := playbackController.PlayURI(playbackCtx, id); err != nil {
-			log.Printf("error playing URI %s: %s\n", id, err.Error())
+			playlistLogger.Error("error playing playlist", "uri", id, "err", err)
 			mp.clearCurrentPlaylistIfID(id)
 		}
 	}()
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+func playlistIDs(playlists []ui.MediaPlaylist) []string {
+	var ids []string
+	for _, playlist := range playlists {
+		ids = append(ids, playlist.ID)
+	}
+	return ids
+}
+
+func TestMediaPlaylistStaticPlaylistsArePersisted(t *testing.T) {
+	store := NewPlaylistFile(filepath.Join(t.TempDir(), "deskpad", "playlists.json"))
+	if _, err := store.Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load before saving returned %v, want os.ErrNotExist", err)
+	}
+
+	mp := NewMediaPlaylist(nil, nil, []ui.MediaPlaylist{{ID: "a", Name: "A"}, {ID: "b", Name: "B"}})
+	mp.SetPlaylistStore(store)
+
+	if err := mp.AddStaticPlaylist(ui.MediaPlaylist{ID: "c", Name: "C"}); err != nil {
+		t.Fatalf("AddStaticPlaylist returned error: %s", err)
+	}
+	if err := mp.AddStaticPlaylist(ui.MediaPlaylist{ID: "a", Name: "Again"}); !errors.Is(err, ErrPlaylistExists) {
+		t.Fatalf("adding duplicate playlist returned %v, want ErrPlaylistExists", err)
+	}
+	if err := mp.MoveStaticPlaylist("c", 0); err != nil {
+		t.Fatalf("MoveStaticPlaylist returned error: %s", err)
+	}
+	if err := mp.RenameStaticPlaylist("b", "Bee"); err != nil {
+		t.Fatalf("RenameStaticPlaylist returned error: %s", err)
+	}
+	if err := mp.RemoveStaticPlaylist("a"); err != nil {
+		t.Fatalf("RemoveStaticPlaylist returned error: %s", err)
+	}
+	if err := mp.RemoveStaticPlaylist("a"); !errors.Is(err, ErrPlaylistNotFound) {
+		t.Fatalf("removing missing playlist returned %v, want ErrPlaylistNotFound", err)
+	}
+
+	got := mp.GetPlaylists(10, 0)
+	if ids := playlistIDs(got); len(ids) != 2 || ids[0] != "c" || ids[1] != "b" || got[1].Name != "Bee" {
+		t.Fatalf("playlists = %+v, want c then renamed b", got)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if ids := playlistIDs(loaded); len(ids) != 2 || ids[0] != "c" || ids[1] != "b" || loaded[1].Name != "Bee" {
+		t.Fatalf("stored playlists = %+v, want c then renamed b", loaded)
+	}
+}
+
+func TestMediaPlaylistMoveClampsPosition(t *testing.T) {
+	mp := NewMediaPlaylist(nil, nil, []ui.MediaPlaylist{{ID: "a"}, {ID: "b"}, {ID: "c"}})
+
+	if err := mp.MoveStaticPlaylist("a", 10); err != nil {
+		t.Fatalf("MoveStaticPlaylist returned error: %s", err)
+	}
+	if ids := playlistIDs(mp.StaticPlaylists()); ids[0] != "b" || ids[1] != "c" || ids[2] != "a" {
+		t.Fatalf("playlists = %v, want a moved to the end", ids)
+	}
+
+	if err := mp.MoveStaticPlaylist("c", -1); err != nil {
+		t.Fatalf("MoveStaticPlaylist returned error: %s", err)
+	}
+	if ids := playlistIDs(mp.StaticPlaylists()); ids[0] != "c" || ids[1] != "b" || ids[2] != "a" {
+		t.Fatalf("playlists = %v, want c moved to the start", ids)
+	}
+}
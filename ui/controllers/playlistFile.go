@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+type playlistFileEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PlaylistFile stores the static playlists as JSON in a file on disk.
+type PlaylistFile struct {
+	path string
+}
+
+// NewPlaylistFile creates a playlist store backed by the file at the supplied path.
+func NewPlaylistFile(path string) *PlaylistFile {
+	return &PlaylistFile{
+		path: path,
+	}
+}
+
+// Load reads the stored playlists. If nothing has been saved yet the returned error matches os.ErrNotExist.
+func (f *PlaylistFile) Load() ([]ui.MediaPlaylist, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []playlistFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	playlists := make([]ui.MediaPlaylist, 0, len(entries))
+	for _, entry := range entries {
+		playlists = append(playlists, ui.MediaPlaylist{ID: entry.ID, Name: entry.Name})
+	}
+	return playlists, nil
+}
+
+// Save replaces the stored playlists. The file is replaced atomically so a failed write doesn't lose the previous list.
+func (f *PlaylistFile) Save(playlists []ui.MediaPlaylist) error {
+	entries := make([]playlistFileEntry, 0, len(playlists))
+	for _, playlist := range playlists {
+		entries = append(entries, playlistFileEntry{ID: playlist.ID, Name: playlist.Name})
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
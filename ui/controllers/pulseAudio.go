@@ -0,0 +1,16 @@
+package controllers
+
+import "github.com/lawl/pulseaudio"
+
+// PulseAudioClient describes the PulseAudio functions used to control the local audio outputs.
+// This is satisfied by *pulseaudio.Client.
+type PulseAudioClient interface {
+	Volume() (float32, error)
+	SetVolume(volume float32) error
+	Mute() (bool, error)
+	SetMute(muted bool) error
+	Sinks() ([]pulseaudio.Sink, error)
+	SetDefaultSink(sinkName string) error
+	SetSinkVolume(sinkName string, volume float32) error
+	ServerInfo() (*pulseaudio.Server, error)
+}
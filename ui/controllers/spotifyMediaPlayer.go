@@ -2,12 +2,16 @@ package controllers
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"time"
 
+	"github.com/rmrobinson/deskpad/logging"
 	"github.com/rmrobinson/deskpad/ui"
 	"github.com/zmb3/spotify/v2"
 )
 
+var spotifyLogger = logging.Logger("spotify")
+
 const spotifyMediaPlayerVolumeStep = 5
 
 // SpotifyMediaPlayer uses the Spotify web API to control media playback of a supported device.
@@ -35,7 +39,7 @@ func NewSpotifyMediaPlayer(ctx context.Context, client *spotify.Client) *Spotify
 func (mp *SpotifyMediaPlayer) RefreshPlayerState() error {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting is playing: %s\n", err.Error())
+		spotifyLogger.Error("error getting is playing", "err", err)
 		return err
 	}
 
@@ -48,7 +52,7 @@ func (mp *SpotifyMediaPlayer) RefreshPlayerState() error {
 func (mp *SpotifyMediaPlayer) ID() string {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("unable to get player state: %s\n", err.Error())
+		spotifyLogger.Error("unable to get player state", "err", err)
 		return ""
 	}
 
@@ -57,7 +61,7 @@ func (mp *SpotifyMediaPlayer) ID() string {
 
 func (mp *SpotifyMediaPlayer) Play() {
 	if err := mp.client.Play(mp.ctx); err != nil {
-		log.Printf("error playing: %s\n", err.Error())
+		spotifyLogger.Error("error playing", "err", err)
 	}
 	mp.isPlaying = true
 }
@@ -79,20 +83,20 @@ func (mp *SpotifyMediaPlayer) PlayURI(ctx context.Context, uri string) error {
 
 func (mp *SpotifyMediaPlayer) Pause() {
 	if err := mp.client.Pause(mp.ctx); err != nil {
-		log.Printf("error pausing: %s\n", err.Error())
+		spotifyLogger.Error("error pausing", "err", err)
 	}
 	mp.isPlaying = false
 }
 
 func (mp *SpotifyMediaPlayer) Next() {
 	if err := mp.client.Next(mp.ctx); err != nil {
-		log.Printf("error going next: %s\n", err.Error())
+		spotifyLogger.Error("error going next", "err", err)
 	}
 }
 
 func (mp *SpotifyMediaPlayer) Previous() {
 	if err := mp.client.Previous(mp.ctx); err != nil {
-		log.Printf("error going previous: %s\n", err.Error())
+		spotifyLogger.Error("error going previous", "err", err)
 	}
 }
 
@@ -111,58 +115,58 @@ func (mp *SpotifyMediaPlayer) IsMuted() bool {
 func (mp *SpotifyMediaPlayer) FastForward() {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting current position: %s\n", err.Error())
+		spotifyLogger.Error("error getting current position", "err", err)
 	}
 
 	newTime := int(state.CurrentlyPlaying.Progress) + 10000
 
 	if newTime > int(state.CurrentlyPlaying.Item.Duration) {
-		log.Print("fast forwarding putting us to next song\n")
+		spotifyLogger.Debug("fast forwarding past the end of the song; skipping to the next")
 		mp.client.Next(mp.ctx)
 		return
 	}
 
 	if err := mp.client.Seek(mp.ctx, newTime); err != nil {
-		log.Printf("error fast forwarding 10 seconds: %s\n", err.Error())
+		spotifyLogger.Error("error fast forwarding 10 seconds", "err", err)
 	}
 }
 
 func (mp *SpotifyMediaPlayer) Rewind() {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting current position: %s\n", err.Error())
+		spotifyLogger.Error("error getting current position", "err", err)
 	}
 
 	newTime := int(state.CurrentlyPlaying.Progress) - 10000
 
 	if newTime < 0 {
-		log.Print("fast forwarding putting us to start of song\n")
+		spotifyLogger.Debug("rewinding past the start of the song; seeking to the start")
 		newTime = 0
 	}
 
 	if err := mp.client.Seek(mp.ctx, newTime); err != nil {
-		log.Printf("error rewinding 10 seconds: %s\n", err.Error())
+		spotifyLogger.Error("error rewinding 10 seconds", "err", err)
 	}
 }
 
 func (mp *SpotifyMediaPlayer) Mute() {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting current volume: %s\n", err.Error())
+		spotifyLogger.Error("error getting current volume", "err", err)
 		return
 	}
 
 	mp.prevVolume = int(state.Device.Volume)
 
 	if err := mp.client.Volume(mp.ctx, 0); err != nil {
-		log.Printf("error muting device: %s\n", err.Error())
+		spotifyLogger.Error("error muting device", "err", err)
 	}
 	mp.isMuted = true
 }
 
 func (mp *SpotifyMediaPlayer) Unmute() {
 	if err := mp.client.Volume(mp.ctx, mp.prevVolume); err != nil {
-		log.Printf("error unmuting device: %s\n", err.Error())
+		spotifyLogger.Error("error unmuting device", "err", err)
 	}
 	mp.isMuted = false
 }
@@ -170,7 +174,7 @@ func (mp *SpotifyMediaPlayer) Unmute() {
 func (mp *SpotifyMediaPlayer) VolumeUp() {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting current volume: %s\n", err.Error())
+		spotifyLogger.Error("error getting current volume", "err", err)
 		return
 	}
 
@@ -180,14 +184,14 @@ func (mp *SpotifyMediaPlayer) VolumeUp() {
 	}
 
 	if err := mp.client.Volume(mp.ctx, newVolume); err != nil {
-		log.Printf("error increasing volume: %s\n", err.Error())
+		spotifyLogger.Error("error increasing volume", "err", err)
 	}
 }
 
 func (mp *SpotifyMediaPlayer) VolumeDown() {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting current volume: %s\n", err.Error())
+		spotifyLogger.Error("error getting current volume", "err", err)
 		return
 	}
 
@@ -197,23 +201,79 @@ func (mp *SpotifyMediaPlayer) VolumeDown() {
 	}
 
 	if err := mp.client.Volume(mp.ctx, newVolume); err != nil {
-		log.Printf("error decreasing volume: %s\n", err.Error())
+		spotifyLogger.Error("error decreasing volume", "err", err)
 	}
 }
 
 func (mp *SpotifyMediaPlayer) Shuffle(shuffle bool) {
 	err := mp.client.Shuffle(mp.ctx, shuffle)
 	if err != nil {
-		log.Printf("error setting shuffle to %t: %s\n", shuffle, err.Error())
+		spotifyLogger.Error("error setting shuffle", "shuffle", shuffle, "err", err)
 		return
 	}
 	mp.isShuffle = shuffle
 }
 
+// Seek moves playback of the current item to the supplied position.
+func (mp *SpotifyMediaPlayer) Seek(position time.Duration) error {
+	return mp.client.Seek(mp.ctx, int(position.Milliseconds()))
+}
+
+// SetVolume sets the device volume as a percentage between 0 and 100.
+func (mp *SpotifyMediaPlayer) SetVolume(volume int) error {
+	if err := mp.client.Volume(mp.ctx, volume); err != nil {
+		return err
+	}
+	mp.isMuted = volume == 0
+	return nil
+}
+
+// Repeat sets whether the current track or playlist repeats once it finishes.
+func (mp *SpotifyMediaPlayer) Repeat(mode ui.RepeatMode) error {
+	var state string
+	switch mode {
+	case ui.RepeatOff:
+		state = "off"
+	case ui.RepeatTrack:
+		state = "track"
+	case ui.RepeatPlaylist:
+		state = "context"
+	default:
+		return fmt.Errorf("unsupported repeat mode %q", mode)
+	}
+
+	return mp.client.Repeat(mp.ctx, state)
+}
+
+// PlaybackState returns the position in the current item along with the volume and repeat settings.
+func (mp *SpotifyMediaPlayer) PlaybackState() ui.PlaybackState {
+	playback := ui.PlaybackState{Repeat: ui.RepeatOff}
+
+	state, err := mp.client.PlayerState(mp.ctx)
+	if err != nil {
+		spotifyLogger.Error("error getting playback state", "err", err)
+		return playback
+	}
+
+	playback.Volume = int(state.Device.Volume)
+	playback.Position = time.Duration(state.CurrentlyPlaying.Progress) * time.Millisecond
+	if state.CurrentlyPlaying.Item != nil {
+		playback.Duration = time.Duration(state.CurrentlyPlaying.Item.Duration) * time.Millisecond
+	}
+	switch state.RepeatState {
+	case "track":
+		playback.Repeat = ui.RepeatTrack
+	case "context":
+		playback.Repeat = ui.RepeatPlaylist
+	}
+
+	return playback
+}
+
 func (mp *SpotifyMediaPlayer) CurrentlyPlaying() *ui.MediaItem {
 	state, err := mp.client.PlayerState(mp.ctx)
 	if err != nil {
-		log.Printf("error getting currently playing: %s\n", err.Error())
+		spotifyLogger.Error("error getting currently playing", "err", err)
 		return nil
 	}
 
@@ -4,12 +4,14 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/rmrobinson/deskpad/logging"
+	"github.com/rmrobinson/deskpad/metrics"
 	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -17,6 +19,8 @@ import (
 	"google.golang.org/grpc/keepalive"
 )
 
+var weatherLogger = logging.Logger("weather")
+
 const maxBackoff = 30 * time.Second
 
 // Weather maintains a live stream of readings from the weather-server gRPC service.
@@ -25,15 +29,23 @@ type Weather struct {
 	useTLS bool
 	caCert string
 
-	mu      sync.RWMutex
-	reading *weatherv1.WeatherReading
+	mu          sync.RWMutex
+	reading     *weatherv1.WeatherReading
+	subscribers map[chan *weatherv1.WeatherReading]struct{}
+	connected   bool
+	streamErr   error
 }
 
 // NewWeather creates a Weather controller that will stream from addr.
 // Set useTLS to enable TLS; set caCert to the path of a PEM CA certificate to
 // override the system roots (leave empty to use system roots).
 func NewWeather(addr string, useTLS bool, caCert string) *Weather {
-	return &Weather{addr: addr, useTLS: useTLS, caCert: caCert}
+	return &Weather{
+		addr:        addr,
+		useTLS:      useTLS,
+		caCert:      caCert,
+		subscribers: make(map[chan *weatherv1.WeatherReading]struct{}),
+	}
 }
 
 // LatestReading returns the most recently received WeatherReading, or nil if none yet.
@@ -43,6 +55,68 @@ func (w *Weather) LatestReading() *weatherv1.WeatherReading {
 	return w.reading
 }
 
+// Subscribe returns a channel which receives the latest reading, if there is one, and each new reading.
+// Slow subscribers miss readings rather than blocking the stream.
+func (w *Weather) Subscribe() (<-chan *weatherv1.WeatherReading, func()) {
+	ch := make(chan *weatherv1.WeatherReading, 4)
+
+	w.mu.Lock()
+	w.subscribers[ch] = struct{}{}
+	if w.reading != nil {
+		ch <- w.reading
+	}
+	w.mu.Unlock()
+
+	cancel := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if _, ok := w.subscribers[ch]; ok {
+			delete(w.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, cancel
+}
+
+// StreamStatus returns nil while readings are being streamed, otherwise the reason the stream is down.
+func (w *Weather) StreamStatus() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.connected {
+		return nil
+	}
+	if w.streamErr != nil {
+		return w.streamErr
+	}
+	return errors.New("not connected")
+}
+
+func (w *Weather) setStreamState(connected bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.connected = connected
+	w.streamErr = err
+}
+
+func (w *Weather) setReading(reading *weatherv1.WeatherReading) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.connected = true
+	w.streamErr = nil
+	w.reading = reading
+	for ch := range w.subscribers {
+		select {
+		case ch <- reading:
+		default:
+		}
+	}
+}
+
 // Run opens a StreamReadings call and keeps it alive until ctx is cancelled,
 // reconnecting with exponential backoff on any error.
 func (w *Weather) Run(ctx context.Context) {
@@ -55,8 +129,9 @@ func (w *Weather) Run(ctx context.Context) {
 		if ctx.Err() != nil {
 			return
 		}
+		w.setStreamState(false, err)
 		if err != nil {
-			log.Printf("[weather] stream: %v; reconnect in %s", err, backoff)
+			weatherLogger.Warn("stream failed; reconnecting", "err", err, "backoff", backoff)
 		}
 		if wasConnected {
 			backoff = time.Second
@@ -68,6 +143,7 @@ func (w *Weather) Run(ctx context.Context) {
 			return
 		case <-time.After(backoff):
 		}
+		metrics.WeatherReconnects.Inc()
 	}
 }
 
@@ -95,7 +171,7 @@ func (w *Weather) runStream(ctx context.Context) (wasConnected bool, _ error) {
 		return false, fmt.Errorf("open stream: %w", err)
 	}
 
-	log.Printf("[weather] stream connected to %s\n", w.addr)
+	weatherLogger.Info("stream connected", "addr", w.addr)
 	for {
 		reading, err := stream.Recv()
 		if err != nil {
@@ -106,9 +182,7 @@ func (w *Weather) runStream(ctx context.Context) (wasConnected bool, _ error) {
 		}
 		wasConnected = true
 
-		w.mu.Lock()
-		w.reading = reading
-		w.mu.Unlock()
+		w.setReading(reading)
 	}
 }
 
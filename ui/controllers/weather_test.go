@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	weatherv1 "github.com/rmrobinson/weather-server/proto/weather/v1"
+)
+
+func TestWeatherSubscribersReceiveReadings(t *testing.T) {
+	w := NewWeather("", false, "")
+
+	first := &weatherv1.WeatherReading{TempC: 12}
+	w.setReading(first)
+
+	readings, cancel := w.Subscribe()
+	if got := <-readings; got != first {
+		t.Fatalf("initial reading = %+v, want the latest reading", got)
+	}
+
+	second := &weatherv1.WeatherReading{TempC: 14}
+	w.setReading(second)
+	if got := <-readings; got != second {
+		t.Fatalf("reading = %+v, want the new reading", got)
+	}
+	if w.LatestReading() != second {
+		t.Fatalf("LatestReading did not return the new reading")
+	}
+
+	cancel()
+	if _, ok := <-readings; ok {
+		t.Fatalf("channel still open after cancel")
+	}
+	w.setReading(first)
+}
+
+func TestWeatherStreamStatusTracksConnection(t *testing.T) {
+	w := NewWeather("", false, "")
+	if w.StreamStatus() == nil {
+		t.Fatalf("StreamStatus = nil before connecting")
+	}
+
+	w.setReading(&weatherv1.WeatherReading{TempC: 12})
+	if err := w.StreamStatus(); err != nil {
+		t.Fatalf("StreamStatus = %s while receiving readings", err)
+	}
+
+	streamErr := errors.New("connection reset")
+	w.setStreamState(false, streamErr)
+	if err := w.StreamStatus(); err != streamErr {
+		t.Fatalf("StreamStatus = %v, want %v", err, streamErr)
+	}
+}
@@ -0,0 +1,28 @@
+package ui
+
+import "image/color"
+
+// LightType describes whether a light is a single bulb or a group of them.
+type LightType int
+
+const (
+	LightTypeLight LightType = iota
+	LightTypeRoom
+)
+
+// Light represents a controllable light, or a room of lights which are controlled together.
+type Light struct {
+	ID   string
+	Name string
+	Type LightType
+
+	On bool
+	// Brightness is a percentage between 0 and 100.
+	Brightness int
+	// Color is the colour of the light at full brightness, or nil if it only has white light.
+	Color color.Color
+
+	Reachable          bool
+	SupportsBrightness bool
+	SupportsColor      bool
+}
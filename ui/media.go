@@ -1,6 +1,9 @@
 package ui
 
-import "image"
+import (
+	"image"
+	"time"
+)
 
 // MediaItem contains relevant information about a single media item.
 type MediaItem struct {
@@ -18,3 +21,21 @@ type MediaPlaylist struct {
 	Name string `mapstructure:"name"`
 	Icon image.Image
 }
+
+// RepeatMode describes what the media player does once the current item finishes.
+type RepeatMode string
+
+const (
+	RepeatOff      RepeatMode = "off"
+	RepeatTrack    RepeatMode = "track"
+	RepeatPlaylist RepeatMode = "playlist"
+)
+
+// PlaybackState contains the position, volume and repeat settings of a media player.
+type PlaybackState struct {
+	Position time.Duration
+	Duration time.Duration
+	// Volume is a percentage between 0 and 100.
+	Volume int
+	Repeat RepeatMode
+}
@@ -5,26 +5,28 @@ import (
 	"embed"
 	"image"
 	"io"
-	"log"
 
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
+	"github.com/rmrobinson/deskpad/logging"
 )
 
+var logger = logging.Logger("screens")
+
 //go:embed assets
 var assets embed.FS
 
 func loadAssetImage(filePath string) image.Image {
 	f, err := assets.Open(filePath)
 	if err != nil {
-		log.Printf("unable to open %s: %s\n", filePath, err.Error())
+		logger.Error("unable to open asset", "path", filePath, "err", err)
 		return nil
 	}
 	defer f.Close()
 
 	i, _, err := image.Decode(bufio.NewReader(f))
 	if err != nil {
-		log.Printf("unable to decode image from %s: %s\n", filePath, err.Error())
+		logger.Error("unable to decode image asset", "path", filePath, "err", err)
 		return nil
 	}
 
@@ -34,20 +36,20 @@ func loadAssetImage(filePath string) image.Image {
 func loadAssetFont(filePath string) *truetype.Font {
 	f, err := assets.Open(filePath)
 	if err != nil {
-		log.Printf("unable to open %s: %s\n", filePath, err.Error())
+		logger.Error("unable to open asset", "path", filePath, "err", err)
 		return nil
 	}
 	defer f.Close()
 
 	bytes, err := io.ReadAll(f)
 	if err != nil {
-		log.Printf("unable to read bytes from font asset %s: %s\n", filePath, err.Error())
+		logger.Error("unable to read font asset", "path", filePath, "err", err)
 		return nil
 	}
 
 	font, err := freetype.ParseFont(bytes)
 	if err != nil {
-		log.Printf("unable to parse font from font asset %s: %s\n", filePath, err.Error())
+		logger.Error("unable to parse font asset", "path", filePath, "err", err)
 		return nil
 	}
 
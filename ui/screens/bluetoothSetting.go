@@ -3,7 +3,6 @@ package screens
 import (
 	"context"
 	"image"
-	"log"
 
 	"github.com/rmrobinson/deskpad"
 	"github.com/rmrobinson/deskpad/ui/controllers"
@@ -111,7 +110,7 @@ func (bs *BluetoothSetting) Show() []image.Image {
 // KeyPressed handles the logic of what to do when a given key is pressed.
 func (bs *BluetoothSetting) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
 	if t == deskpad.KeyPressLong {
-		log.Print("got a long key press!\n")
+		logger.Debug("long key press", "screen", bs.Name(), "key", id)
 	}
 
 	if id == bluetoothSettingHomeKeyID {
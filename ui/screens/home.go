@@ -3,7 +3,6 @@ package screens
 import (
 	"context"
 	"image"
-	"log"
 
 	"github.com/rmrobinson/deskpad"
 	"github.com/rmrobinson/deskpad/ui/controllers"
@@ -21,6 +20,7 @@ type Home struct {
 	controller HomeController
 
 	screens []deskpad.Screen
+	nested  []deskpad.Screen
 }
 
 // HomeController is an interface which defines what the home screen might control.
@@ -68,6 +68,34 @@ func (hs *Home) Icon() image.Image {
 	return hs.iconImg
 }
 
+// Screens returns the home screen followed by each of the screens registered with it, then any screens
+// which are only reachable through another screen.
+func (hs *Home) Screens() []deskpad.Screen {
+	registered := []deskpad.Screen{hs}
+	for _, s := range hs.screens {
+		if s != nil && s != deskpad.Screen(hs) {
+			registered = append(registered, s)
+		}
+	}
+	return append(registered, hs.nested...)
+}
+
+// OnHome returns whether the screen is the home screen or is reachable directly from one of its keys.
+func (hs *Home) OnHome(s deskpad.Screen) bool {
+	for _, cs := range hs.screens {
+		if cs == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AddScreen lists a screen which is reached through another screen, rather than from a Home key, so it can be
+// navigated to directly.
+func (hs *Home) AddScreen(s deskpad.Screen) {
+	hs.nested = append(hs.nested, s)
+}
+
 // RegisterScreen adds a screen to the Home view in the next available spot
 func (hs *Home) RegisterScreen(s deskpad.Screen) {
 	for i, cs := range hs.screens {
@@ -82,7 +110,7 @@ func (hs *Home) RegisterScreen(s deskpad.Screen) {
 // KeyPressed handles the logic of what to do when a given key is pressed.
 func (hs *Home) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
 	if t == deskpad.KeyPressLong {
-		log.Print("got a long key press!\n")
+		logger.Debug("long key press", "screen", hs.Name(), "key", id)
 	}
 
 	if id == homeClockID {
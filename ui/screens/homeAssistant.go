@@ -0,0 +1,149 @@
+package screens
+
+import (
+	"context"
+	"image"
+	"image/color"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+const (
+	homeAssistantHomeKeyID = 14
+	// homeAssistantMaxEntities is the number of entities which fit around the home key.
+	homeAssistantMaxEntities = 14
+)
+
+// HomeAssistantController describes the functions which the Home Assistant screens use to show and change entities.
+type HomeAssistantController interface {
+	Entities() []controllers.HomeAssistantEntity
+	Entity(id string) (controllers.HomeAssistantEntity, bool)
+	Toggle(ctx context.Context, id string) error
+	SetBrightness(ctx context.Context, id string, pct int) error
+	StepBrightness(ctx context.Context, id string, pct int) error
+	SetColor(ctx context.Context, id string, rgb [3]int) error
+}
+
+// HomeAssistant shows a key for each configured Home Assistant entity, coloured by its current state. Pressing a
+// key toggles the entity or activates the scene; a long press on a light opens its brightness and colour controls.
+type HomeAssistant struct {
+	iconImg    image.Image
+	keys       []image.Image
+	controller HomeAssistantController
+
+	homeScreen  deskpad.Screen
+	lightScreen *HomeAssistantLight
+
+	entities []controllers.HomeAssistantEntity // keep a copy of the array to ensure a stable set when the button is pushed
+}
+
+// NewHomeAssistant creates the Home Assistant screen and its light screen, and registers them on the home screen.
+func NewHomeAssistant(homeScreen *Home, hac HomeAssistantController) *HomeAssistant {
+	// Currently setup for a StreamDeck with 15 buttons
+	has := &HomeAssistant{
+		iconImg:    NewTextIcon("home assistant"),
+		keys:       make([]image.Image, 15),
+		controller: hac,
+		homeScreen: homeScreen,
+	}
+	has.lightScreen = newHomeAssistantLight(homeScreen, has, hac)
+
+	has.keys[homeAssistantHomeKeyID] = homeScreen.Icon()
+
+	homeScreen.RegisterScreen(has)
+	homeScreen.AddScreen(has.lightScreen)
+
+	return has
+}
+
+// Name is hardcoded to display as "home assistant"
+func (has *HomeAssistant) Name() string {
+	return "home assistant"
+}
+
+// Icon returns the icon to display for this screen
+func (has *HomeAssistant) Icon() image.Image {
+	return has.iconImg
+}
+
+// LightScreen returns the screen used to change the brightness and colour of a light.
+func (has *HomeAssistant) LightScreen() *HomeAssistantLight {
+	return has.lightScreen
+}
+
+// Show returns the image set which will be shown to the user.
+func (has *HomeAssistant) Show() []image.Image {
+	has.entities = has.controller.Entities()
+	if len(has.entities) > homeAssistantMaxEntities {
+		has.entities = has.entities[:homeAssistantMaxEntities]
+	}
+
+	for i := range has.keys {
+		if i != homeAssistantHomeKeyID {
+			has.keys[i] = nil
+		}
+	}
+	for i, entity := range has.entities {
+		has.keys[i] = homeAssistantEntityIcon(entity)
+	}
+
+	return has.keys
+}
+
+// KeyPressed handles the logic of what to do when a given key is pressed.
+func (has *HomeAssistant) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
+	if id == homeAssistantHomeKeyID {
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: has.homeScreen,
+		}, nil
+	}
+	if id < 0 || id >= len(has.entities) {
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	entity := has.entities[id]
+	if t == deskpad.KeyPressLong && entity.Domain() == "light" {
+		has.lightScreen.entityID = entity.ID
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: has.lightScreen,
+		}, nil
+	}
+
+	if err := has.controller.Toggle(ctx, entity.ID); err != nil {
+		logger.Error("unable to toggle entity", "screen", has.Name(), "entity", entity.ID, "err", err)
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	return deskpad.KeyPressAction{
+		Action: deskpad.KeyPressActionRefreshScreen,
+	}, nil
+}
+
+// homeAssistantEntityIcon labels the entity over a background showing its state. Lights which are on use their
+// colour, dimmed to their brightness.
+func homeAssistantEntityIcon(entity controllers.HomeAssistantEntity) image.Image {
+	bg := lightOffColor
+	switch {
+	case !entity.Available():
+		bg = lightUnavailableColor
+	case entity.Domain() == "scene" || entity.Domain() == "script":
+		bg = lightSceneColor
+	case entity.On():
+		bg = lightOnColor
+		if len(entity.RGB) == 3 {
+			bg = color.RGBA{R: uint8(entity.RGB[0]), G: uint8(entity.RGB[1]), B: uint8(entity.RGB[2]), A: 255}
+		}
+		if entity.SupportsBrightness {
+			bg = dimColor(bg, entity.Brightness)
+		}
+	}
+
+	return NewTextIconWithBackground(entity.Name, solidImage(bg))
+}
@@ -0,0 +1,146 @@
+package screens
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+const (
+	homeAssistantLightToggleKeyID     = 0
+	homeAssistantLightBackKeyID       = 4
+	homeAssistantLightDimKeyID        = 5
+	homeAssistantLightBrightnessID    = 6
+	homeAssistantLightBrightenKeyID   = 7
+	homeAssistantLightHomeKeyID       = 9
+	homeAssistantLightFirstColorKeyID = 10
+
+	// homeAssistantLightStepPct is how much the dim and brighten keys change the brightness by.
+	homeAssistantLightStepPct = 10
+)
+
+// homeAssistantLightPresets are the brightness percentages offered on keys 1 to 3.
+var homeAssistantLightPresets = []int{10, 50, 100}
+
+// HomeAssistantLight changes the brightness and colour of a single light. Brightness controls are only shown
+// for lights which can be dimmed, and colours for lights which support them.
+type HomeAssistantLight struct {
+	keys       []image.Image
+	controller HomeAssistantController
+
+	homeScreen     deskpad.Screen
+	entitiesScreen deskpad.Screen
+
+	entityID string
+}
+
+func newHomeAssistantLight(homeScreen *Home, entitiesScreen *HomeAssistant, hac HomeAssistantController) *HomeAssistantLight {
+	// Currently setup for a StreamDeck with 15 buttons
+	return &HomeAssistantLight{
+		keys:           make([]image.Image, 15),
+		controller:     hac,
+		homeScreen:     homeScreen,
+		entitiesScreen: entitiesScreen,
+	}
+}
+
+// Name is hardcoded to display as "home assistant light"
+func (hals *HomeAssistantLight) Name() string {
+	return "home assistant light"
+}
+
+// Icon returns the icon to display for this screen
+func (hals *HomeAssistantLight) Icon() image.Image {
+	return hals.entitiesScreen.Icon()
+}
+
+// Show returns the image set which will be shown to the user.
+func (hals *HomeAssistantLight) Show() []image.Image {
+	for i := range hals.keys {
+		hals.keys[i] = nil
+	}
+	hals.keys[homeAssistantLightBackKeyID] = hals.entitiesScreen.Icon()
+	hals.keys[homeAssistantLightHomeKeyID] = hals.homeScreen.Icon()
+
+	entity, ok := hals.controller.Entity(hals.entityID)
+	if !ok {
+		hals.keys[homeAssistantLightToggleKeyID] = NewTextIcon("no light")
+		return hals.keys
+	}
+	hals.keys[homeAssistantLightToggleKeyID] = homeAssistantEntityIcon(entity)
+
+	if entity.SupportsBrightness {
+		for i, pct := range homeAssistantLightPresets {
+			hals.keys[homeAssistantLightToggleKeyID+1+i] = NewTextIcon(fmt.Sprintf("%d%%", pct))
+		}
+		hals.keys[homeAssistantLightDimKeyID] = loadAssetImage("assets/subtract-line.png")
+		hals.keys[homeAssistantLightBrightenKeyID] = loadAssetImage("assets/add-line.png")
+		if entity.On() {
+			hals.keys[homeAssistantLightBrightnessID] = NewTextIcon(fmt.Sprintf("%d%%", entity.BrightnessPct()))
+		} else {
+			hals.keys[homeAssistantLightBrightnessID] = NewTextIcon("off")
+		}
+	}
+
+	if entity.SupportsColor {
+		for i, c := range lightColorPresets {
+			hals.keys[homeAssistantLightFirstColorKeyID+i] = solidImage(c)
+		}
+	}
+
+	return hals.keys
+}
+
+// KeyPressed handles the logic of what to do when a given key is pressed.
+func (hals *HomeAssistantLight) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
+	if id == homeAssistantLightBackKeyID {
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: hals.entitiesScreen,
+		}, nil
+	} else if id == homeAssistantLightHomeKeyID {
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: hals.homeScreen,
+		}, nil
+	}
+
+	entity, ok := hals.controller.Entity(hals.entityID)
+	if !ok {
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	var err error
+	switch {
+	case id == homeAssistantLightToggleKeyID:
+		err = hals.controller.Toggle(ctx, entity.ID)
+	case id > homeAssistantLightToggleKeyID && id <= len(homeAssistantLightPresets) && entity.SupportsBrightness:
+		err = hals.controller.SetBrightness(ctx, entity.ID, homeAssistantLightPresets[id-1])
+	case id == homeAssistantLightDimKeyID && entity.SupportsBrightness:
+		err = hals.controller.StepBrightness(ctx, entity.ID, -homeAssistantLightStepPct)
+	case id == homeAssistantLightBrightenKeyID && entity.SupportsBrightness:
+		err = hals.controller.StepBrightness(ctx, entity.ID, homeAssistantLightStepPct)
+	case id >= homeAssistantLightFirstColorKeyID && id < homeAssistantLightFirstColorKeyID+len(lightColorPresets) && entity.SupportsColor:
+		c := lightColorPresets[id-homeAssistantLightFirstColorKeyID]
+		err = hals.controller.SetColor(ctx, entity.ID, [3]int{int(c.R), int(c.G), int(c.B)})
+	default:
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	if err != nil {
+		logger.Error("unable to change light", "screen", hals.Name(), "entity", entity.ID, "key", id, "err", err)
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	return deskpad.KeyPressAction{
+		Action: deskpad.KeyPressActionRefreshScreen,
+	}, nil
+}
@@ -0,0 +1,143 @@
+package screens
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+type homeAssistantTestController struct {
+	entities []controllers.HomeAssistantEntity
+	calls    []string
+}
+
+func (c *homeAssistantTestController) Entities() []controllers.HomeAssistantEntity {
+	return c.entities
+}
+
+func (c *homeAssistantTestController) Entity(id string) (controllers.HomeAssistantEntity, bool) {
+	for _, entity := range c.entities {
+		if entity.ID == id {
+			return entity, true
+		}
+	}
+	return controllers.HomeAssistantEntity{}, false
+}
+
+func (c *homeAssistantTestController) Toggle(ctx context.Context, id string) error {
+	c.calls = append(c.calls, "toggle "+id)
+	return nil
+}
+
+func (c *homeAssistantTestController) SetBrightness(ctx context.Context, id string, pct int) error {
+	c.calls = append(c.calls, fmt.Sprintf("brightness %s %d", id, pct))
+	return nil
+}
+
+func (c *homeAssistantTestController) StepBrightness(ctx context.Context, id string, pct int) error {
+	c.calls = append(c.calls, fmt.Sprintf("step %s %d", id, pct))
+	return nil
+}
+
+func (c *homeAssistantTestController) SetColor(ctx context.Context, id string, rgb [3]int) error {
+	c.calls = append(c.calls, fmt.Sprintf("color %s %v", id, rgb))
+	return nil
+}
+
+func newHomeAssistantTestScreen() (*HomeAssistant, *homeAssistantTestController) {
+	hac := &homeAssistantTestController{
+		entities: []controllers.HomeAssistantEntity{
+			{ID: "light.desk", Name: "Desk", State: "on", Brightness: 255, RGB: []int{255, 0, 0}, SupportsBrightness: true, SupportsColor: true},
+			{ID: "light.hall", Name: "Hall", State: "off", SupportsBrightness: true},
+			{ID: "scene.movie", Name: "Movie", State: "2024-01-01T00:00:00+00:00"},
+			{ID: "switch.fan", Name: "Fan", State: "unavailable"},
+		},
+	}
+	return NewHomeAssistant(NewHome(controllers.NewHome(nil)), hac), hac
+}
+
+func TestHomeAssistantShowsEntityState(t *testing.T) {
+	has, _ := newHomeAssistantTestScreen()
+
+	keys := has.Show()
+	if len(keys) != 15 || keys[homeAssistantHomeKeyID] == nil || keys[4] != nil {
+		t.Fatalf("keys = %v, want four entities and the home key", keys)
+	}
+
+	want := []color.RGBA{
+		{R: 255, A: 255},
+		lightOffColor,
+		lightSceneColor,
+		lightUnavailableColor,
+	}
+	for i, c := range want {
+		if got := color.RGBAModel.Convert(keys[i].At(0, 0)); got != c {
+			t.Errorf("key %d background = %v, want %v", i, got, c)
+		}
+	}
+}
+
+func TestHomeAssistantKeyPresses(t *testing.T) {
+	has, hac := newHomeAssistantTestScreen()
+	has.Show()
+	ctx := context.Background()
+
+	action, _ := has.KeyPressed(ctx, 2, deskpad.KeyPressShort)
+	if action.Action != deskpad.KeyPressActionRefreshScreen {
+		t.Fatalf("action = %v, want a refresh", action.Action)
+	}
+	has.KeyPressed(ctx, 3, deskpad.KeyPressLong)
+	has.KeyPressed(ctx, 10, deskpad.KeyPressShort)
+
+	action, _ = has.KeyPressed(ctx, 1, deskpad.KeyPressLong)
+	if action.Action != deskpad.KeyPressActionChangeScreen || action.NewScreen != has.LightScreen() {
+		t.Fatalf("action = %+v, want the light screen", action)
+	}
+
+	if want := []string{"toggle scene.movie", "toggle switch.fan"}; !reflect.DeepEqual(hac.calls, want) {
+		t.Fatalf("calls = %q, want %q", hac.calls, want)
+	}
+}
+
+func TestHomeAssistantLightControls(t *testing.T) {
+	has, hac := newHomeAssistantTestScreen()
+	has.Show()
+	ctx := context.Background()
+
+	light := has.LightScreen()
+	has.KeyPressed(ctx, 1, deskpad.KeyPressLong)
+	keys := light.Show()
+	if keys[homeAssistantLightFirstColorKeyID] != nil || keys[homeAssistantLightDimKeyID] == nil {
+		t.Fatalf("keys = %v, want brightness but no colour controls for the hall light", keys)
+	}
+	light.KeyPressed(ctx, homeAssistantLightFirstColorKeyID, deskpad.KeyPressShort)
+
+	has.KeyPressed(ctx, 0, deskpad.KeyPressLong)
+	if keys := light.Show(); keys[homeAssistantLightFirstColorKeyID] == nil {
+		t.Fatalf("keys = %v, want colour controls for the desk light", keys)
+	}
+	for _, id := range []int{homeAssistantLightToggleKeyID, 2, homeAssistantLightDimKeyID, homeAssistantLightBrightenKeyID, homeAssistantLightFirstColorKeyID + 2} {
+		light.KeyPressed(ctx, id, deskpad.KeyPressShort)
+	}
+
+	want := []string{
+		"toggle light.desk",
+		"brightness light.desk 50",
+		"step light.desk -10",
+		"step light.desk 10",
+		"color light.desk [255 0 0]",
+	}
+	if !reflect.DeepEqual(hac.calls, want) {
+		t.Fatalf("calls = %q, want %q", hac.calls, want)
+	}
+
+	action, _ := light.KeyPressed(ctx, homeAssistantLightBackKeyID, deskpad.KeyPressShort)
+	if action.Action != deskpad.KeyPressActionChangeScreen || action.NewScreen != has {
+		t.Fatalf("action = %+v, want the entities screen", action)
+	}
+}
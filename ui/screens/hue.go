@@ -0,0 +1,202 @@
+package screens
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+const (
+	hueHomeKeyID    = 4
+	hueRefreshKeyID = 9
+	hueNextKeyID    = 14
+	huePairKeyID    = 0
+
+	// huePageSize is the number of lights shown at once, in the keys not used for navigation.
+	huePageSize = 12
+)
+
+// Hue lists the rooms and lights on a Philips Hue bridge and toggles them when pressed. A long press opens the
+// brightness and colour controls for the room or light.
+type Hue struct {
+	iconImg    image.Image
+	keys       []image.Image
+	controller HueController
+
+	homeScreen  deskpad.Screen
+	lightScreen *HueLight
+
+	lights     []ui.Light // keep a copy of the array to ensure a stable set when the button is pushed
+	currOffset int
+}
+
+// HueController describes the functions which the Hue screens use to interact with the Hue bridge.
+type HueController interface {
+	Paired() bool
+	Pair(ctx context.Context) error
+	GetLights() []ui.Light
+	RefreshLights(ctx context.Context) error
+	ToggleLight(ctx context.Context, id string) error
+	SetLightBrightness(ctx context.Context, id string, brightness int) error
+	SetLightColor(ctx context.Context, id string, c color.Color) error
+}
+
+// NewHue creates a new instance of the Hue screen and its light screen, configured with the provided controller.
+func NewHue(homeScreen *Home, hc HueController) *Hue {
+	// Currently setup for a StreamDeck with 15 buttons
+	hs := &Hue{
+		iconImg:    NewTextIcon("hue"),
+		keys:       make([]image.Image, 15),
+		controller: hc,
+		homeScreen: homeScreen,
+		lights:     []ui.Light{},
+	}
+	hs.lightScreen = newHueLight(hs, hc)
+
+	hs.keys[hueHomeKeyID] = homeScreen.Icon()
+	hs.keys[hueRefreshKeyID] = loadAssetImage("assets/refresh-fill.png")
+
+	homeScreen.RegisterScreen(hs)
+	homeScreen.AddScreen(hs.lightScreen)
+
+	return hs
+}
+
+// Name is hardcoded to display as "hue"
+func (hs *Hue) Name() string {
+	return "hue"
+}
+
+// Icon returns the icon to display for this screen
+func (hs *Hue) Icon() image.Image {
+	return hs.iconImg
+}
+
+// Show returns the image set which will be shown to the user.
+func (hs *Hue) Show() []image.Image {
+	hs.lights = hs.controller.GetLights()
+	if hs.currOffset >= len(hs.lights) {
+		hs.currOffset = 0
+	}
+
+	// Reset the icon set to avoid stale info being shown
+	for i := 0; i < len(hs.keys); i++ {
+		if i == hueHomeKeyID || i == hueRefreshKeyID {
+			continue
+		}
+		hs.keys[i] = nil
+	}
+
+	if !hs.controller.Paired() {
+		hs.keys[huePairKeyID] = NewTextIcon("press link button, then here")
+		return hs.keys
+	}
+
+	if len(hs.lights) > huePageSize {
+		hs.keys[hueNextKeyID] = loadAssetImage("assets/skip-right-line.png")
+	}
+
+	for lightPos, light := range hs.lights[hs.currOffset:min(hs.currOffset+huePageSize, len(hs.lights))] {
+		lightImg := hueLightIcon(light)
+
+		if lightPos <= 3 {
+			hs.keys[lightPos] = lightImg
+		} else if lightPos > 3 && lightPos <= 7 {
+			hs.keys[lightPos+1] = lightImg
+		} else if lightPos > 7 && lightPos <= 11 {
+			hs.keys[lightPos+2] = lightImg
+		}
+	}
+
+	return hs.keys
+}
+
+// KeyPressed handles the logic of what to do when a given key is pressed.
+func (hs *Hue) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
+	if id == hueHomeKeyID {
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: hs.homeScreen,
+		}, nil
+	} else if id == hueRefreshKeyID {
+		hs.controller.RefreshLights(ctx)
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionRefreshScreen,
+		}, nil
+	} else if id == hueNextKeyID {
+		hs.currOffset += huePageSize
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionRefreshScreen,
+		}, nil
+	}
+
+	if !hs.controller.Paired() {
+		if id != huePairKeyID {
+			return deskpad.KeyPressAction{
+				Action: deskpad.KeyPressActionNoop,
+			}, nil
+		}
+
+		if err := hs.controller.Pair(ctx); errors.Is(err, controllers.ErrHueLinkButtonNotPressed) {
+			logger.Info("press the hue bridge link button before pairing", "screen", hs.Name())
+		} else if err != nil {
+			logger.Error("unable to pair with hue bridge", "screen", hs.Name(), "err", err)
+		} else if err := hs.controller.RefreshLights(ctx); err != nil {
+			logger.Error("unable to refresh lights", "screen", hs.Name(), "err", err)
+		}
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionRefreshScreen,
+		}, nil
+	}
+
+	lightIdx := hs.currOffset + keyIDToDeviceIdx(id)
+	if lightIdx >= len(hs.lights) {
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+	light := hs.lights[lightIdx]
+
+	if t == deskpad.KeyPressLong {
+		hs.lightScreen.lightID = light.ID
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: hs.lightScreen,
+		}, nil
+	}
+
+	if err := hs.controller.ToggleLight(ctx, light.ID); err != nil {
+		logger.Error("unable to toggle light", "screen", hs.Name(), "light", light.ID, "err", err)
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	return deskpad.KeyPressAction{
+		Action: deskpad.KeyPressActionRefreshScreen,
+	}, nil
+}
+
+// hueLightIcon labels the light over a background showing its state. Lights which are on use their colour,
+// dimmed to their brightness.
+func hueLightIcon(light ui.Light) image.Image {
+	bg := lightOffColor
+	if !light.Reachable {
+		bg = lightUnavailableColor
+	} else if light.On {
+		bg = lightOnColor
+		if light.Color != nil {
+			bg = color.RGBAModel.Convert(light.Color).(color.RGBA)
+		}
+		if light.SupportsBrightness {
+			bg = dimColor(bg, light.Brightness*255/100)
+		}
+	}
+
+	return NewTextIconWithBackground(light.Name, solidImage(bg))
+}
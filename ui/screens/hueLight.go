@@ -0,0 +1,142 @@
+package screens
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+)
+
+const (
+	hueLightToggleKeyID      = 0
+	hueLightBackKeyID        = 4
+	hueLightFirstColorKeyID  = 5
+	hueLightFirstSliderKeyID = 10
+
+	// hueLightSliderSteps is the number of keys in the brightness slider; each key adds 100/steps percent.
+	hueLightSliderSteps = 5
+)
+
+// HueLight controls a single room or light. The bottom row of keys is a brightness slider, and the middle row
+// offers colour presets for lights which support colour.
+type HueLight struct {
+	keys       []image.Image
+	controller HueController
+
+	lightsScreen *Hue
+
+	lightID string
+}
+
+func newHueLight(lightsScreen *Hue, hc HueController) *HueLight {
+	// Currently setup for a StreamDeck with 15 buttons
+	return &HueLight{
+		keys:         make([]image.Image, 15),
+		controller:   hc,
+		lightsScreen: lightsScreen,
+	}
+}
+
+// Name is hardcoded to display as "hue light"
+func (hls *HueLight) Name() string {
+	return "hue light"
+}
+
+// Icon returns the icon to display for this screen
+func (hls *HueLight) Icon() image.Image {
+	return hls.lightsScreen.Icon()
+}
+
+// Show returns the image set which will be shown to the user.
+func (hls *HueLight) Show() []image.Image {
+	for i := range hls.keys {
+		hls.keys[i] = nil
+	}
+	hls.keys[hueLightBackKeyID] = hls.lightsScreen.Icon()
+
+	light, ok := hls.light()
+	if !ok {
+		hls.keys[hueLightToggleKeyID] = NewTextIcon("no light")
+		return hls.keys
+	}
+	hls.keys[hueLightToggleKeyID] = hueLightIcon(light)
+
+	if light.SupportsColor {
+		for i, c := range lightColorPresets {
+			hls.keys[hueLightFirstColorKeyID+i] = solidImage(c)
+		}
+	}
+
+	if light.SupportsBrightness {
+		for step := 0; step < hueLightSliderSteps; step++ {
+			level := hueSliderLevel(step)
+
+			// Light the slider up to the current brightness, rounding to the nearest step.
+			bg := lightOffColor
+			if light.On && light.Brightness >= level-100/hueLightSliderSteps/2 {
+				bg = lightOnColor
+			}
+			hls.keys[hueLightFirstSliderKeyID+step] = NewTextIconWithBackground(fmt.Sprintf("%d%%", level), solidImage(bg))
+		}
+	}
+
+	return hls.keys
+}
+
+// KeyPressed handles the logic of what to do when a given key is pressed.
+func (hls *HueLight) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
+	if id == hueLightBackKeyID {
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: hls.lightsScreen,
+		}, nil
+	}
+
+	light, ok := hls.light()
+	if !ok {
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	var err error
+	switch {
+	case id == hueLightToggleKeyID:
+		err = hls.controller.ToggleLight(ctx, light.ID)
+	case id >= hueLightFirstColorKeyID && id < hueLightFirstColorKeyID+len(lightColorPresets) && light.SupportsColor:
+		err = hls.controller.SetLightColor(ctx, light.ID, lightColorPresets[id-hueLightFirstColorKeyID])
+	case id >= hueLightFirstSliderKeyID && id < hueLightFirstSliderKeyID+hueLightSliderSteps && light.SupportsBrightness:
+		err = hls.controller.SetLightBrightness(ctx, light.ID, hueSliderLevel(id-hueLightFirstSliderKeyID))
+	default:
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	if err != nil {
+		logger.Error("unable to change light", "screen", hls.Name(), "light", light.ID, "key", id, "err", err)
+		return deskpad.KeyPressAction{
+			Action: deskpad.KeyPressActionNoop,
+		}, nil
+	}
+
+	return deskpad.KeyPressAction{
+		Action: deskpad.KeyPressActionRefreshScreen,
+	}, nil
+}
+
+func (hls *HueLight) light() (ui.Light, bool) {
+	for _, light := range hls.controller.GetLights() {
+		if light.ID == hls.lightID {
+			return light, true
+		}
+	}
+	return ui.Light{}, false
+}
+
+// hueSliderLevel returns the brightness percentage set by the slider key at the supplied step.
+func hueSliderLevel(step int) int {
+	return (step + 1) * 100 / hueLightSliderSteps
+}
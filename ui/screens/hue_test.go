@@ -0,0 +1,162 @@
+package screens
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/rmrobinson/deskpad"
+	"github.com/rmrobinson/deskpad/ui"
+	"github.com/rmrobinson/deskpad/ui/controllers"
+)
+
+type hueTestController struct {
+	paired bool
+	lights []ui.Light
+	calls  []string
+}
+
+func (c *hueTestController) Paired() bool {
+	return c.paired
+}
+
+func (c *hueTestController) Pair(ctx context.Context) error {
+	c.calls = append(c.calls, "pair")
+	c.paired = true
+	return nil
+}
+
+func (c *hueTestController) GetLights() []ui.Light {
+	return c.lights
+}
+
+func (c *hueTestController) RefreshLights(ctx context.Context) error {
+	c.calls = append(c.calls, "refresh")
+	return nil
+}
+
+func (c *hueTestController) ToggleLight(ctx context.Context, id string) error {
+	c.calls = append(c.calls, "toggle "+id)
+	return nil
+}
+
+func (c *hueTestController) SetLightBrightness(ctx context.Context, id string, brightness int) error {
+	c.calls = append(c.calls, fmt.Sprintf("brightness %s %d", id, brightness))
+	for i := range c.lights {
+		if c.lights[i].ID == id {
+			c.lights[i].On = true
+			c.lights[i].Brightness = brightness
+		}
+	}
+	return nil
+}
+
+func (c *hueTestController) SetLightColor(ctx context.Context, id string, col color.Color) error {
+	c.calls = append(c.calls, fmt.Sprintf("color %s %v", id, col))
+	return nil
+}
+
+func newHueTestScreen() (*Hue, *hueTestController) {
+	hc := &hueTestController{
+		paired: true,
+		lights: []ui.Light{
+			{ID: "groups/1", Name: "Living Room", Type: ui.LightTypeRoom, On: true, Brightness: 100, Color: color.RGBA{B: 255, A: 255}, Reachable: true, SupportsBrightness: true, SupportsColor: true},
+			{ID: "lights/1", Name: "Lamp", On: false, Brightness: 40, Reachable: true, SupportsBrightness: true},
+			{ID: "lights/2", Name: "Plug"},
+		},
+	}
+	return NewHue(NewHome(controllers.NewHome(nil)), hc), hc
+}
+
+func TestHueShowsLightState(t *testing.T) {
+	hs, _ := newHueTestScreen()
+
+	keys := hs.Show()
+	if keys[hueHomeKeyID] == nil || keys[hueRefreshKeyID] == nil || keys[hueNextKeyID] != nil || keys[3] != nil {
+		t.Fatalf("keys = %v, want three lights and the navigation keys", keys)
+	}
+
+	want := []color.RGBA{
+		{B: 255, A: 255},
+		lightOffColor,
+		lightUnavailableColor,
+	}
+	for i, c := range want {
+		if got := color.RGBAModel.Convert(keys[i].At(0, 0)); got != c {
+			t.Errorf("key %d background = %v, want %v", i, got, c)
+		}
+	}
+}
+
+func TestHuePairing(t *testing.T) {
+	hs, hc := newHueTestScreen()
+	hc.paired = false
+	ctx := context.Background()
+
+	if keys := hs.Show(); keys[huePairKeyID] == nil || keys[1] != nil {
+		t.Fatalf("keys = %v, want only the pair key", keys)
+	}
+	hs.KeyPressed(ctx, 1, deskpad.KeyPressShort)
+	hs.KeyPressed(ctx, huePairKeyID, deskpad.KeyPressShort)
+
+	if want := []string{"pair", "refresh"}; !reflect.DeepEqual(hc.calls, want) {
+		t.Fatalf("calls = %q, want %q", hc.calls, want)
+	}
+	if keys := hs.Show(); keys[1] == nil {
+		t.Fatalf("keys = %v, want the lights once paired", keys)
+	}
+}
+
+func TestHueLightControls(t *testing.T) {
+	hs, hc := newHueTestScreen()
+	hs.Show()
+	ctx := context.Background()
+
+	hs.KeyPressed(ctx, 0, deskpad.KeyPressShort)
+	action, _ := hs.KeyPressed(ctx, 1, deskpad.KeyPressLong)
+	if action.Action != deskpad.KeyPressActionChangeScreen || action.NewScreen != hs.lightScreen {
+		t.Fatalf("action = %+v, want the light screen", action)
+	}
+
+	light := hs.lightScreen
+	keys := light.Show()
+	if keys[hueLightFirstColorKeyID] != nil {
+		t.Fatalf("keys = %v, want no colour presets for the lamp", keys)
+	}
+	light.KeyPressed(ctx, hueLightFirstColorKeyID, deskpad.KeyPressShort)
+	light.KeyPressed(ctx, hueLightFirstSliderKeyID+2, deskpad.KeyPressShort)
+
+	// The slider is lit up to the new brightness.
+	keys = light.Show()
+	for step := 0; step < hueLightSliderSteps; step++ {
+		want := lightOffColor
+		if step <= 2 {
+			want = lightOnColor
+		}
+		if got := color.RGBAModel.Convert(keys[hueLightFirstSliderKeyID+step].At(0, 0)); got != want {
+			t.Errorf("slider step %d background = %v, want %v", step, got, want)
+		}
+	}
+
+	action, _ = light.KeyPressed(ctx, hueLightBackKeyID, deskpad.KeyPressShort)
+	if action.Action != deskpad.KeyPressActionChangeScreen || action.NewScreen != hs {
+		t.Fatalf("action = %+v, want the lights screen", action)
+	}
+
+	hs.KeyPressed(ctx, 0, deskpad.KeyPressLong)
+	if keys := light.Show(); keys[hueLightFirstColorKeyID] == nil {
+		t.Fatalf("keys = %v, want colour presets for the room", keys)
+	}
+	light.KeyPressed(ctx, hueLightToggleKeyID, deskpad.KeyPressShort)
+
+	want := []string{
+		"toggle groups/1",
+		"brightness lights/1 60",
+		"toggle groups/1",
+	}
+	if !reflect.DeepEqual(hc.calls, want) {
+		t.Fatalf("calls = %q, want %q", hc.calls, want)
+	}
+}
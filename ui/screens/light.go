@@ -0,0 +1,41 @@
+package screens
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Key backgrounds used to show the state of lights and other switchable devices.
+var (
+	lightOffColor         = color.RGBA{R: 48, G: 48, B: 48, A: 255}
+	lightOnColor          = color.RGBA{R: 200, G: 140, B: 0, A: 255}
+	lightSceneColor       = color.RGBA{R: 30, G: 70, B: 130, A: 255}
+	lightUnavailableColor = color.RGBA{R: 100, G: 20, B: 20, A: 255}
+)
+
+// lightColorPresets are the colours offered by the light screens, one per key.
+var lightColorPresets = []color.RGBA{
+	{R: 255, G: 180, B: 107, A: 255}, // warm white
+	{R: 255, G: 255, B: 255, A: 255},
+	{R: 255, G: 0, B: 0, A: 255},
+	{R: 0, G: 255, B: 0, A: 255},
+	{R: 0, G: 0, B: 255, A: 255},
+}
+
+// dimColor scales the colour by the brightness, from 0 to 255. Very dim lights are kept visible.
+func dimColor(c color.RGBA, brightness int) color.RGBA {
+	brightness = max(brightness, 64)
+	return color.RGBA{
+		R: uint8(int(c.R) * brightness / 255),
+		G: uint8(int(c.G) * brightness / 255),
+		B: uint8(int(c.B) * brightness / 255),
+		A: 255,
+	}
+}
+
+func solidImage(c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
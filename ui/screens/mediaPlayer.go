@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"image"
-	"log"
 
 	"github.com/rmrobinson/deskpad"
 )
@@ -24,6 +23,11 @@ const (
 	mediaPlayerSettingsKeyID    = 14
 )
 
+const (
+	mediaPlayerVolumeDialID = 0
+	mediaPlayerSeekDialID   = 1
+)
+
 // MediaPlayer displays a control interface to the user which allows control of their media.
 type MediaPlayer struct {
 	iconImg    image.Image
@@ -38,6 +42,10 @@ type MediaPlayer struct {
 	pauseImg   image.Image
 	shuffleImg image.Image
 	loopImg    image.Image
+
+	volumeStripImg image.Image
+	mutedStripImg  image.Image
+	seekStripImg   image.Image
 }
 
 // MediaPlayerController describes the functions which the screen will use to allow the user to interface with the media source.
@@ -71,6 +79,10 @@ func NewMediaPlayer(homeScreen *Home, mpc MediaPlayerController) *MediaPlayer {
 		pauseImg:   loadAssetImage("assets/pause-fill.png"),
 		shuffleImg: loadAssetImage("assets/shuffle-fill.png"),
 		loopImg:    loadAssetImage("assets/repeat-fill.png"),
+
+		volumeStripImg: NewTextIcon("Volume"),
+		mutedStripImg:  NewTextIcon("Muted"),
+		seekStripImg:   NewTextIcon("Seek"),
 	}
 
 	mps.keys[mediaPlayerHomeKeyID] = homeScreen.Icon()
@@ -129,7 +141,7 @@ func (mps *MediaPlayer) Show() []image.Image {
 // KeyPressed handles the logic of what to do when a given key is pressed.
 func (mps *MediaPlayer) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
 	if t == deskpad.KeyPressLong {
-		log.Print("got a long key press!\n")
+		logger.Debug("long key press", "screen", mps.Name(), "key", id)
 	}
 
 	if id == mediaPlayerPrevKeyID {
@@ -165,7 +177,7 @@ func (mps *MediaPlayer) KeyPressed(ctx context.Context, id int, t deskpad.KeyPre
 		}, nil
 	} else if id == mediaPlayerPlayPauseKeyID {
 		if mps.controller.IsPlaying() {
-			log.Print("media player screen: play/pause key pressed; pausing playback\n")
+			logger.Debug("pausing playback", "screen", mps.Name(), "key", id)
 			mps.controller.Pause()
 			mps.keys[mediaPlayerPlayPauseKeyID] = mps.playImg
 
@@ -174,7 +186,7 @@ func (mps *MediaPlayer) KeyPressed(ctx context.Context, id int, t deskpad.KeyPre
 				NewIcon: mps.playImg,
 			}, nil
 		} else {
-			log.Print("media player screen: play/pause key pressed; starting playback\n")
+			logger.Debug("starting playback", "screen", mps.Name(), "key", id)
 			mps.controller.Play()
 			mps.keys[mediaPlayerPlayPauseKeyID] = mps.pauseImg
 			return deskpad.KeyPressAction{
@@ -227,3 +239,88 @@ func (mps *MediaPlayer) KeyPressed(ctx context.Context, id int, t deskpad.KeyPre
 		Action: deskpad.KeyPressActionNoop,
 	}, errors.New("unhandled key")
 }
+
+// ShowStrip returns the touch strip segments; the first dial controls the volume and the second seeks.
+func (mps *MediaPlayer) ShowStrip(dialCount int) []image.Image {
+	strip := make([]image.Image, dialCount)
+	if dialCount > mediaPlayerVolumeDialID {
+		strip[mediaPlayerVolumeDialID] = mps.volumeStrip()
+	}
+	if dialCount > mediaPlayerSeekDialID {
+		strip[mediaPlayerSeekDialID] = mps.seekStripImg
+	}
+	return strip
+}
+
+// DialChanged handles the dials. Turning the volume dial changes the volume and pressing it toggles mute;
+// turning the seek dial skips forward or back and pressing it toggles playback.
+func (mps *MediaPlayer) DialChanged(ctx context.Context, event deskpad.DialEvent) (deskpad.KeyPressAction, error) {
+	switch event.Dial {
+	case mediaPlayerVolumeDialID:
+		switch event.Type {
+		case deskpad.DialRotate:
+			for step := 0; step < abs(event.Delta); step++ {
+				if event.Delta > 0 {
+					mps.controller.VolumeUp()
+				} else {
+					mps.controller.VolumeDown()
+				}
+			}
+			return deskpad.KeyPressAction{
+				Action: deskpad.KeyPressActionNoop,
+			}, nil
+		case deskpad.DialPress, deskpad.DialTouch:
+			if mps.controller.IsMuted() {
+				mps.controller.Unmute()
+			} else {
+				mps.controller.Mute()
+			}
+			return deskpad.KeyPressAction{
+				Action:  deskpad.KeyPressActionUpdateIcon,
+				NewIcon: mps.volumeStrip(),
+			}, nil
+		}
+	case mediaPlayerSeekDialID:
+		switch event.Type {
+		case deskpad.DialRotate:
+			for step := 0; step < abs(event.Delta); step++ {
+				if event.Delta > 0 {
+					mps.controller.FastForward()
+				} else {
+					mps.controller.Rewind()
+				}
+			}
+			return deskpad.KeyPressAction{
+				Action: deskpad.KeyPressActionNoop,
+			}, nil
+		case deskpad.DialPress, deskpad.DialTouch:
+			if mps.controller.IsPlaying() {
+				mps.controller.Pause()
+			} else {
+				mps.controller.Play()
+			}
+			// The play/pause key reflects the new state.
+			return deskpad.KeyPressAction{
+				Action: deskpad.KeyPressActionRefreshScreen,
+			}, nil
+		}
+	}
+
+	return deskpad.KeyPressAction{
+		Action: deskpad.KeyPressActionNoop,
+	}, nil
+}
+
+func (mps *MediaPlayer) volumeStrip() image.Image {
+	if mps.controller.IsMuted() {
+		return mps.mutedStripImg
+	}
+	return mps.volumeStripImg
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
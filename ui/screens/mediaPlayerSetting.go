@@ -3,7 +3,6 @@ package screens
 import (
 	"context"
 	"image"
-	"log"
 
 	"github.com/rmrobinson/deskpad"
 	"github.com/rmrobinson/deskpad/ui"
@@ -31,7 +30,7 @@ type MediaPlayerSetting struct {
 type MediaPlayerSettingController interface {
 	GetAudioOutputs() []ui.AudioOutput
 	RefreshAudioOutputs(context.Context) error
-	SelectAudioOutput(ctx context.Context, deviceID string)
+	SelectAudioOutput(ctx context.Context, deviceID string) error
 }
 
 // MediaPlayerSetting creates a new instance of the media player setting screen, configured with the provided setting controller.
@@ -48,6 +47,8 @@ func NewMediaPlayerSetting(homeScreen *Home, mpsc MediaPlayerSettingController)
 	mpss.keys[mediaPlayerSettingHomeKeyID] = homeScreen.Icon()
 	mpss.keys[mediaPlayerSettingRefreshKeyID] = loadAssetImage("assets/refresh-fill.png")
 
+	homeScreen.AddScreen(mpss)
+
 	return mpss
 }
 
@@ -101,7 +102,7 @@ func (mpss *MediaPlayerSetting) Show() []image.Image {
 // KeyPressed handles the logic of what to do when a given key is pressed.
 func (mpss *MediaPlayerSetting) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
 	if t == deskpad.KeyPressLong {
-		log.Print("got a long key press!\n")
+		logger.Debug("long key press", "screen", mpss.Name(), "key", id)
 	}
 
 	if id == mediaPlayerSettingHomeKeyID {
@@ -122,7 +123,9 @@ func (mpss *MediaPlayerSetting) KeyPressed(ctx context.Context, id int, t deskpa
 	}
 
 	deviceIdx := keyIDToDeviceIdx(id)
-	mpss.controller.SelectAudioOutput(ctx, mpss.audioOutputs[deviceIdx].ID)
+	if err := mpss.controller.SelectAudioOutput(ctx, mpss.audioOutputs[deviceIdx].ID); err != nil {
+		logger.Error("unable to select audio output", "screen", mpss.Name(), "output", mpss.audioOutputs[deviceIdx].ID, "err", err)
+	}
 
 	return deskpad.KeyPressAction{
 		Action: deskpad.KeyPressActionNoop,
@@ -10,9 +10,11 @@ import (
 )
 
 type mediaPlayerTestController struct {
-	playing bool
-	shuffle bool
-	muted   bool
+	playing  bool
+	shuffle  bool
+	muted    bool
+	volume   int
+	position int
 }
 
 func (c *mediaPlayerTestController) Play() {
@@ -27,13 +29,21 @@ func (c *mediaPlayerTestController) Next() {}
 
 func (c *mediaPlayerTestController) Previous() {}
 
-func (c *mediaPlayerTestController) FastForward() {}
+func (c *mediaPlayerTestController) FastForward() {
+	c.position++
+}
 
-func (c *mediaPlayerTestController) Rewind() {}
+func (c *mediaPlayerTestController) Rewind() {
+	c.position--
+}
 
-func (c *mediaPlayerTestController) VolumeUp() {}
+func (c *mediaPlayerTestController) VolumeUp() {
+	c.volume++
+}
 
-func (c *mediaPlayerTestController) VolumeDown() {}
+func (c *mediaPlayerTestController) VolumeDown() {
+	c.volume--
+}
 
 func (c *mediaPlayerTestController) Mute() {
 	c.muted = true
@@ -96,6 +106,53 @@ func TestMediaPlayerPlayPauseUpdatesCachedKeyIcon(t *testing.T) {
 	}
 }
 
+func TestMediaPlayerDialsControlVolumeAndSeek(t *testing.T) {
+	volumeImg := mediaPlayerTestImage(color.RGBA{R: 255, A: 255})
+	mutedImg := mediaPlayerTestImage(color.RGBA{B: 255, A: 255})
+	seekImg := mediaPlayerTestImage(color.RGBA{G: 255, A: 255})
+	controller := &mediaPlayerTestController{}
+	screen := &MediaPlayer{
+		keys:           make([]image.Image, 15),
+		controller:     controller,
+		volumeStripImg: volumeImg,
+		mutedStripImg:  mutedImg,
+		seekStripImg:   seekImg,
+	}
+
+	strip := screen.ShowStrip(4)
+	if len(strip) != 4 || strip[mediaPlayerVolumeDialID] != volumeImg || strip[mediaPlayerSeekDialID] != seekImg || strip[2] != nil {
+		t.Fatalf("strip = %v, want volume and seek segments", strip)
+	}
+
+	ctx := context.Background()
+	screen.DialChanged(ctx, deskpad.DialEvent{Dial: mediaPlayerVolumeDialID, Type: deskpad.DialRotate, Delta: 3})
+	screen.DialChanged(ctx, deskpad.DialEvent{Dial: mediaPlayerVolumeDialID, Type: deskpad.DialRotate, Delta: -1})
+	if controller.volume != 2 {
+		t.Fatalf("volume steps = %d, want 2", controller.volume)
+	}
+
+	action, err := screen.DialChanged(ctx, deskpad.DialEvent{Dial: mediaPlayerVolumeDialID, Type: deskpad.DialPress})
+	if err != nil {
+		t.Fatalf("DialChanged returned error: %s", err)
+	}
+	if !controller.muted || action.Action != deskpad.KeyPressActionUpdateIcon || action.NewIcon != mutedImg {
+		t.Fatalf("pressing the volume dial did not mute and show the muted segment")
+	}
+
+	screen.DialChanged(ctx, deskpad.DialEvent{Dial: mediaPlayerSeekDialID, Type: deskpad.DialRotate, Delta: -2})
+	if controller.position != -2 {
+		t.Fatalf("seek steps = %d, want -2", controller.position)
+	}
+
+	action, err = screen.DialChanged(ctx, deskpad.DialEvent{Dial: mediaPlayerSeekDialID, Type: deskpad.DialPress})
+	if err != nil {
+		t.Fatalf("DialChanged returned error: %s", err)
+	}
+	if !controller.playing || action.Action != deskpad.KeyPressActionRefreshScreen {
+		t.Fatalf("pressing the seek dial did not start playback and refresh the screen")
+	}
+}
+
 func mediaPlayerTestImage(c color.Color) image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
 	img.Set(0, 0, c)
@@ -3,7 +3,6 @@ package screens
 import (
 	"context"
 	"image"
-	"log"
 
 	"github.com/disintegration/gift"
 	"github.com/rmrobinson/deskpad"
@@ -94,7 +93,7 @@ func (mps *MediaPlaylist) Show() []image.Image {
 // KeyPressed handles the logic of what to do when a given key is pressed.
 func (mps *MediaPlaylist) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
 	if t == deskpad.KeyPressLong {
-		log.Print("got a long key press!\n")
+		logger.Debug("long key press", "screen", mps.Name(), "key", id)
 	}
 
 	if id == mediaPlaylistHomeKeyID {
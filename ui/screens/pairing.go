@@ -0,0 +1,89 @@
+package screens
+
+import (
+	"context"
+	"image"
+
+	"github.com/rmrobinson/deskpad"
+)
+
+const (
+	pairingTitleKeyID  = 2
+	pairingCodeKeyID   = 6 // the code is shown two digits per key, starting here
+	pairingCancelKeyID = 14
+)
+
+// PairingController is the interface the pairing screen uses to retrieve the code being offered.
+type PairingController interface {
+	// PairingCode returns the active pairing code, or an empty string if pairing isn't in progress.
+	PairingCode() string
+	CancelPairing()
+}
+
+// Pairing displays the code a device must enter to be paired with deskpad.
+type Pairing struct {
+	keys       []image.Image
+	controller PairingController
+
+	homeScreen deskpad.Screen
+}
+
+// NewPairing creates a pairing screen. It isn't registered on the home screen as it is only shown while a device
+// is being paired.
+func NewPairing(homeScreen *Home, pc PairingController) *Pairing {
+	// Currently setup for a StreamDeck with 15 buttons
+	ps := &Pairing{
+		keys:       make([]image.Image, 15),
+		controller: pc,
+		homeScreen: homeScreen,
+	}
+
+	ps.keys[pairingTitleKeyID] = NewTextIcon("pair code")
+	ps.keys[pairingCancelKeyID] = NewTextIcon("cancel")
+
+	return ps
+}
+
+// Name is hardcoded to display as "pairing"
+func (ps *Pairing) Name() string {
+	return "pairing"
+}
+
+// Icon returns the icon to display for this screen
+func (ps *Pairing) Icon() image.Image {
+	return ps.keys[pairingTitleKeyID]
+}
+
+// Show returns the image set which will be shown to the user.
+func (ps *Pairing) Show() []image.Image {
+	for i := pairingCodeKeyID; i < pairingCodeKeyID+3; i++ {
+		ps.keys[i] = nil
+	}
+
+	code := ps.controller.PairingCode()
+	if code == "" {
+		ps.keys[pairingCodeKeyID+1] = NewTextIcon("expired")
+		return ps.keys
+	}
+
+	for i := 0; i*2 < len(code) && i < 3; i++ {
+		ps.keys[pairingCodeKeyID+i] = NewTextIcon(code[i*2 : min(i*2+2, len(code))])
+	}
+	return ps.keys
+}
+
+// KeyPressed cancels pairing and returns home when the cancel key is pressed.
+func (ps *Pairing) KeyPressed(ctx context.Context, id int, t deskpad.KeyPressType) (deskpad.KeyPressAction, error) {
+	if id == pairingCancelKeyID {
+		ps.controller.CancelPairing()
+
+		return deskpad.KeyPressAction{
+			Action:    deskpad.KeyPressActionChangeScreen,
+			NewScreen: ps.homeScreen,
+		}, nil
+	}
+
+	return deskpad.KeyPressAction{
+		Action: deskpad.KeyPressActionNoop,
+	}, nil
+}
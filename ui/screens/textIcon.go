@@ -3,7 +3,6 @@ package screens
 import (
 	"image"
 	"image/draw"
-	"log"
 
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
@@ -44,7 +43,7 @@ func NewTextIconWithBackground(input string, bg image.Image) image.Image {
 		row := freetype.Pt(getStartPoint(input), 15+charHeight)
 
 		if _, err := freetypeCtx.DrawString(input, row); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 	} else if len(input) <= rowCharCount*2 {
 		inputRow1 := input[:rowCharCount]
@@ -54,10 +53,10 @@ func NewTextIconWithBackground(input string, bg image.Image) image.Image {
 		row2 := freetype.Pt(getStartPoint(inputRow2), 33+charHeight)
 
 		if _, err := freetypeCtx.DrawString(inputRow1, row1); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 		if _, err := freetypeCtx.DrawString(inputRow2, row2); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 	} else if len(input) <= rowCharCount*3 {
 		inputRow1 := input[:rowCharCount]
@@ -68,13 +67,13 @@ func NewTextIconWithBackground(input string, bg image.Image) image.Image {
 		row3 := freetype.Pt(getStartPoint(inputRow3), 37+charHeight)
 
 		if _, err := freetypeCtx.DrawString(inputRow1, row1); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 		if _, err := freetypeCtx.DrawString(inputRow2, row2); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 		if _, err := freetypeCtx.DrawString(inputRow3, row3); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 	} else {
 		inputRow1 := input[:rowCharCount]
@@ -88,16 +87,16 @@ func NewTextIconWithBackground(input string, bg image.Image) image.Image {
 		row4 := freetype.Pt(getStartPoint(inputRow4), 47+charHeight)
 
 		if _, err := freetypeCtx.DrawString(inputRow1, row1); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 		if _, err := freetypeCtx.DrawString(inputRow2, row2); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 		if _, err := freetypeCtx.DrawString(inputRow3, row3); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 		if _, err := freetypeCtx.DrawString(inputRow4, row4); err != nil {
-			log.Printf("unable to put string into img")
+			logger.Warn("unable to draw text onto icon", "text", input, "err", err)
 		}
 	}
 